@@ -0,0 +1,134 @@
+package cachelayer
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type AdminCacheInfo struct {
+	Name     string
+	Table    string
+	Stats    Stats
+	HitRatio float64
+}
+
+//AdminHandler http handler exposing stats, hot keys and maintenance operations of caches in registry
+//
+//	GET  /caches                  stats of all caches
+//	GET  /caches/{name}           stats of a cache
+//	GET  /caches/{name}/hotkeys   top hot keys, ?n=10
+//	POST /caches/{name}/refresh   refresh a cache
+//	POST /caches/{name}/clear     clear all keys of a cache
+//	GET  /caches/{name}/verify    diff cache keys against database, see Verifier
+//	GET  /caches/{name}/memory    estimated redis memory of the cache, ?samples=1000, see MemoryReporter
+//	GET  /health                  HealthCheck of the registry, status 503 if unhealthy
+//
+// mount it with http.StripPrefix, eg. mux.Handle("/debug/cache/", http.StripPrefix("/debug/cache", h))
+type AdminHandler struct {
+	registry *Registry
+}
+
+func NewAdminHandler(registry *Registry) *AdminHandler {
+	return &AdminHandler{registry: registry}
+}
+
+func (s *AdminHandler) info(name string, c ManagedCache) AdminCacheInfo {
+	stats := c.Stats()
+	return AdminCacheInfo{Name: name, Table: c.GetTableName(), Stats: stats, HitRatio: stats.HitRatio()}
+}
+
+func (s *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 1 && parts[0] == "health" && r.Method == http.MethodGet {
+		report := s.registry.HealthCheck(r.Context())
+		status := http.StatusOK
+		if !report.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		writeJson(w, status, report)
+		return
+	}
+	if len(parts) == 0 || parts[0] != "caches" {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var infos []AdminCacheInfo
+		for _, name := range s.registry.Names() {
+			if c, ok := s.registry.Get(name); ok {
+				infos = append(infos, s.info(name, c))
+			}
+		}
+		writeJson(w, http.StatusOK, infos)
+		return
+	}
+	c, ok := s.registry.Get(parts[1])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	action := ""
+	if len(parts) > 2 {
+		action = parts[2]
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJson(w, http.StatusOK, s.info(parts[1], c))
+	case action == "hotkeys" && r.Method == http.MethodGet:
+		n, err := strconv.Atoi(r.URL.Query().Get("n"))
+		if err != nil || n <= 0 {
+			n = 10
+		}
+		writeJson(w, http.StatusOK, c.HotKeys(n))
+	case action == "refresh" && r.Method == http.MethodPost:
+		writeResult(w, c.Refresh())
+	case action == "clear" && r.Method == http.MethodPost:
+		writeResult(w, c.ClearAll())
+	case action == "verify" && r.Method == http.MethodGet:
+		v, ok := c.(Verifier)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		report, err := v.Verify()
+		if err != nil {
+			writeResult(w, err)
+			return
+		}
+		writeJson(w, http.StatusOK, report)
+	case action == "memory" && r.Method == http.MethodGet:
+		m, ok := c.(MemoryReporter)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		samples, _ := strconv.Atoi(r.URL.Query().Get("samples"))
+		report, err := m.MemoryUsage(samples)
+		if err != nil {
+			writeResult(w, err)
+			return
+		}
+		writeJson(w, http.StatusOK, report)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]string{"result": "ok"})
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,189 @@
+package cachelayer_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAdminCache struct {
+	refreshed bool
+	cleared   bool
+	onClose   func()
+}
+
+func (s *fakeAdminCache) GetTableName() string {
+	return "commodity"
+}
+func (s *fakeAdminCache) Stats() cachelayer.Stats {
+	return cachelayer.Stats{Hits: 3, Misses: 1}
+}
+func (s *fakeAdminCache) HotKeys(n int) []cachelayer.HotKey {
+	return []cachelayer.HotKey{{Key: "app/commodity/id/1", Count: 10}}
+}
+func (s *fakeAdminCache) Refresh() error {
+	s.refreshed = true
+	return nil
+}
+func (s *fakeAdminCache) ClearAll() error {
+	s.cleared = true
+	return nil
+}
+func (s *fakeAdminCache) Close() error {
+	if s.onClose != nil {
+		s.onClose()
+	}
+	return nil
+}
+
+type closerFunc func() error
+
+func (s closerFunc) Close() error {
+	return s()
+}
+
+func TestAdminHandler(t *testing.T) {
+	c := &fakeAdminCache{}
+	registry := cachelayer.NewRegistry()
+	assert.Nil(t, registry.Register("commodity", c))
+	h := cachelayer.NewAdminHandler(registry)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/caches/commodity", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"hitRatio":0.75`)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/caches/commodity/hotkeys?n=1", nil))
+	assert.Contains(t, w.Body.String(), "app/commodity/id/1")
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/caches/commodity/refresh", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, c.refreshed)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/caches/commodity/clear", nil))
+	assert.True(t, c.cleared)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/caches/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRegistry(t *testing.T) {
+	registry := cachelayer.NewRegistry()
+	assert.Nil(t, registry.Register("commodity", &fakeAdminCache{}))
+	assert.Nil(t, registry.Register("commodity_full", &fakeAdminCache{}))
+	assert.ErrorIs(t, registry.Register("commodity", &fakeAdminCache{}), cachelayer.ErrConflict)
+	assert.Equal(t, []string{"commodity", "commodity_full"}, registry.Names())
+	assert.Equal(t, 2, len(registry.GetByTable("Commodity")))
+	assert.Equal(t, int64(6), registry.TotalStats().Hits)
+	assert.Nil(t, registry.Close())
+}
+
+func TestRegistryShutdown(t *testing.T) {
+	registry := cachelayer.NewRegistry()
+	var steps []string
+	registry.Register("commodity", &fakeAdminCache{onClose: func() { steps = append(steps, "cache") }})
+	registry.AddConnection(closerFunc(func() error {
+		steps = append(steps, "redis")
+		return nil
+	}))
+	registry.OnShutdown(func(ctx context.Context) error {
+		steps = append(steps, "refresher")
+		return nil
+	})
+	w := cachelayer.NewAsyncWriter(1, 10)
+	written := false
+	w.Submit(func() error {
+		written = true
+		return nil
+	})
+	registry.OnShutdown(func(ctx context.Context) error {
+		steps = append(steps, "writer")
+		return w.Shutdown(ctx)
+	})
+	assert.Nil(t, registry.Shutdown(context.Background()))
+	assert.True(t, written)
+	assert.Equal(t, []string{"writer", "refresher", "cache", "redis"}, steps)
+}
+
+func TestPublishExpvar(t *testing.T) {
+	r := cachelayer.NewRegistry()
+	assert.Nil(t, r.Register("commodity", &fakeAdminCache{}))
+	r.PublishExpvar("cachelayer_test")
+	v := expvar.Get("cachelayer_test")
+	assert.NotNil(t, v)
+	assert.Contains(t, v.String(), `"Hits":3`)
+}
+
+type fakeLoaderCache struct {
+	fakeAdminCache
+	loads *int32
+}
+
+func (s *fakeLoaderCache) Load() error {
+	atomic.AddInt32(s.loads, 1)
+	return nil
+}
+
+func TestRegistryWarmAll(t *testing.T) {
+	registry := cachelayer.NewRegistry()
+	var loads int32
+	for _, v := range []string{"a", "b", "c", "d"} {
+		assert.Nil(t, registry.Register(v, &fakeLoaderCache{loads: &loads}))
+	}
+	assert.Nil(t, registry.Register("per_id", &fakeAdminCache{}))
+	var progress []cachelayer.WarmProgress
+	registry.OnWarmProgress(func(p cachelayer.WarmProgress) {
+		progress = append(progress, p)
+	})
+	assert.Nil(t, registry.WarmAll(context.Background(), 2))
+	assert.Equal(t, int32(4), loads)
+	assert.Equal(t, 4, len(progress))
+	assert.Equal(t, 4, progress[3].Done)
+	assert.Equal(t, 4, progress[3].Total)
+}
+
+type fakePingCache struct {
+	fakeAdminCache
+	err error
+}
+
+func (s *fakePingCache) Ping(ctx context.Context) error {
+	return s.err
+}
+
+func TestRegistryHealthCheck(t *testing.T) {
+	registry := cachelayer.NewRegistry()
+	assert.Nil(t, registry.Register("a", &fakePingCache{}))
+	assert.Nil(t, registry.Register("no_ping", &fakeAdminCache{}))
+	h := cachelayer.NewAdminHandler(registry)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"healthy":true`)
+
+	assert.Nil(t, registry.Register("b", &fakePingCache{err: errors.New("connection refused")}))
+	report := registry.HealthCheck(context.Background())
+	assert.False(t, report.Healthy)
+	assert.Equal(t, 2, len(report.Caches))
+	assert.Equal(t, "b", report.Caches[1].Name)
+	assert.Equal(t, "connection refused", report.Caches[1].Error)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	c := cachelayer.NewRedisCache[keyUser, uint]("app", "user", "ID", nil, redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1}), time.Minute)
+	assert.True(t, errors.Is(c.Ping(context.Background()), cachelayer.ErrCacheUnavailable))
+}
@@ -0,0 +1,222 @@
+package cachelayer
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//DriftReport result of comparing cached records with database rows
+type DriftReport struct {
+	Sampled int
+	Drifted int
+	//DriftedKeys id keys whose cached record differed from database, they are deleted
+	DriftedKeys []string
+	//Corrupt sampled entries which can not be deserialized, deleted if the corrupt policy is CorruptAsMiss
+	Corrupt int
+}
+
+//DriftRatio drifted / sampled, 0 if nothing is sampled
+func (s DriftReport) DriftRatio() float64 {
+	if s.Sampled == 0 {
+		return 0
+	}
+	return float64(s.Drifted) / float64(s.Sampled)
+}
+
+//AntiEntropy background job comparing cached records with database rows and clearing drifted ones.
+// Every round continues the key scan of the previous one, so all cached records are checked over time.
+// Drift counters are added to cache Stats, see Stats.DriftRatio
+type AntiEntropy[T Table[I], I IDType] struct {
+	cache        *RedisCache[T, I]
+	interval     time.Duration
+	sampleSize   int
+	mu           sync.Mutex
+	cursors      map[string]uint64
+	last         DriftReport
+	errorHandler func(err error)
+	clock        Clock
+}
+
+func NewAntiEntropy[T Table[I], I IDType](cache *RedisCache[T, I], interval time.Duration, sampleSize int) *AntiEntropy[T, I] {
+	return &AntiEntropy[T, I]{
+		cache:        cache,
+		interval:     interval,
+		sampleSize:   sampleSize,
+		cursors:      make(map[string]uint64),
+		errorHandler: func(err error) { ReportError("anti_entropy", cache.GetTableName(), err) },
+		clock:        RealClock{},
+	}
+}
+
+//SetErrorHandler handle errors of rounds run by Run, default the global error handler
+func (s *AntiEntropy[T, I]) SetErrorHandler(handler func(err error)) {
+	s.errorHandler = handler
+}
+
+//SetClock clock scheduling rounds, default RealClock
+func (s *AntiEntropy[T, I]) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+//LastReport report of the last round
+func (s *AntiEntropy[T, I]) LastReport() DriftReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+//Run a round every interval until ctx is done
+func (s *AntiEntropy[T, I]) Run(ctx context.Context) error {
+	labeled(ctx, "anti_entropy", func(ctx context.Context) { s.run(ctx) }, "table", s.cache.GetTableName())
+	return nil
+}
+
+func (s *AntiEntropy[T, I]) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.interval):
+			// a panicking round is reported and the next round still runs
+			if err := safely("anti_entropy", s.cache.GetTableName(), func() error { _, err := s.RunOnce(); return err }); err != nil {
+				s.errorHandler(err)
+			}
+		}
+	}
+}
+
+//RunOnce compare up to sampleSize cached records with database, and clear cache of drifted records.
+// A record written between reading cache and database may be reported as drifted, clearing it is harmless
+func (s *AntiEntropy[T, I]) RunOnce() (DriftReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.cache
+	keys, err := s.sample()
+	if err != nil {
+		return DriftReport{}, c.wrapErr("anti_entropy", "", err)
+	}
+	report, err := c.reconcile(keys)
+	c.stats.sample(report.Sampled, report.Drifted)
+	s.last = report
+	return report, c.wrapErr("anti_entropy", "", err)
+}
+
+//sample scan next id keys from every node
+func (s *AntiEntropy[T, I]) sample() ([]string, error) {
+	c := s.cache
+	pattern := c.tablePattern(c.GetIdField() + "/*")
+	var keys []string
+	var mu sync.Mutex
+	err := forEachNode(c.ctx, c.red.UniversalClient, func(ctx context.Context, node redis.UniversalClient) error {
+		name := ""
+		if n, ok := node.(*redis.Client); ok {
+			name = n.Options().Addr
+		}
+		mu.Lock()
+		cursor := s.cursors[name]
+		mu.Unlock()
+		var found []string
+		for len(found) < s.sampleSize {
+			batch, next, err := node.Scan(ctx, cursor, pattern, int64(s.sampleSize)).Result()
+			if err != nil {
+				return cacheError(err)
+			}
+			found = append(found, batch...)
+			cursor = next
+			if cursor == 0 {
+				break
+			}
+		}
+		mu.Lock()
+		s.cursors[name] = cursor
+		keys = append(keys, found...)
+		mu.Unlock()
+		return nil
+	})
+	return keys, err
+}
+
+//reconcile compare cached records of id keys with database, clear cache of drifted records.
+// Keys are lower case, so ids are taken from cached records and only from keys of cached "not found"
+func (s *RedisCache[T, I]) reconcile(keys []string) (DriftReport, error) {
+	var report DriftReport
+	idKeyPrefix := s.tablePattern(s.GetIdField() + "/")
+	var idKeys []string
+	for _, v := range keys {
+		suffix := strings.TrimPrefix(v, idKeyPrefix)
+		if suffix == v || strings.Contains(suffix, "/") || isAuxKey(v) {
+			continue
+		}
+		idKeys = append(idKeys, v)
+	}
+	if len(idKeys) == 0 {
+		return report, nil
+	}
+	values, err := mget(s.ctx, s.red.UniversalClient, idKeys...)
+	if err != nil {
+		return report, cacheError(err)
+	}
+	var drifted []T
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		isNull := s.red.isNull(v.(string))
+		var cached T
+		var id I
+		if isNull {
+			if id, err = ParseID[I](strings.TrimPrefix(idKeys[i], idKeyPrefix)); err != nil {
+				continue
+			}
+		} else {
+			if err = unmarshal(s.red.serializer, v.(string), &cached); err != nil {
+				report.Corrupt++
+				s.heal(idKeys[i], err)
+				continue
+			}
+			id = cached.GetID()
+		}
+		report.Sampled++
+		obj, exists, err := s.db.Get(id)
+		s.stats.dbLoad(err)
+		if err != nil {
+			return report, err
+		}
+		same, err := s.sameRecord(cached, isNull, obj, exists)
+		if err != nil {
+			return report, err
+		}
+		if same {
+			continue
+		}
+		report.Drifted++
+		report.DriftedKeys = append(report.DriftedKeys, idKeys[i])
+		if !isNull {
+			drifted = append(drifted, cached)
+		}
+		if exists {
+			drifted = append(drifted, obj)
+		}
+	}
+	if len(drifted) > 0 {
+		return report, s.ClearCache(drifted...)
+	}
+	return report, nil
+}
+
+//sameRecord whether cached record serializes the same as the database record
+func (s *RedisCache[T, I]) sameRecord(cached T, isNull bool, obj T, exists bool) (bool, error) {
+	if isNull || !exists {
+		return isNull && !exists, nil
+	}
+	y1, err := marshal(s.red.serializer, cached)
+	if err != nil {
+		return false, err
+	}
+	y2, err := marshal(s.red.serializer, obj)
+	return y1 == y2, err
+}
@@ -0,0 +1,105 @@
+package cachelayer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+// sku entity with a case-sensitive string id
+type sku struct {
+	Code string
+	Name string
+}
+
+func (s sku) GetID() string {
+	return s.Code
+}
+
+func (s sku) ListIndexes() cachelayer.Indexes {
+	return nil
+}
+
+// skuDB in-memory database of skus, only reads are supported
+type skuDB map[string]sku
+
+func (s skuDB) Create(obj *sku) error                          { return nil }
+func (s skuDB) Save(obj *sku) error                            { return nil }
+func (s skuDB) Delete(ids ...string) (int64, error)            { return 0, nil }
+func (s skuDB) Update(id string, v interface{}) (int64, error) { return 0, nil }
+func (s skuDB) Close() error                                   { return nil }
+func (s skuDB) Get(id string) (sku, bool, error) {
+	r, ok := s[id]
+	return r, ok, nil
+}
+func (s skuDB) List(ids ...string) ([]sku, error) {
+	var r []sku
+	for _, v := range ids {
+		if x, ok := s[v]; ok {
+			r = append(r, x)
+		}
+	}
+	return r, nil
+}
+func (s skuDB) GetBy(index cachelayer.Index) (sku, bool, error) {
+	return sku{}, false, nil
+}
+func (s skuDB) ListBy(index cachelayer.Index, orderBys cachelayer.OrderBys) ([]sku, error) {
+	return nil, nil
+}
+
+func TestAntiEntropy(t *testing.T) {
+	cache, db, mr := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com"}, member{ID: 2, Name: "ann", Email: "ann@x.com"})
+	_, err := cache.List(1, 2, 3)
+	assert.Nil(t, err)
+	// changed behind the cache
+	_, err = db.Update(2, map[string]interface{}{"name": "jerry"})
+	assert.Nil(t, err)
+	job := cachelayer.NewAntiEntropy(cache, time.Minute, 100)
+	report, err := job.RunOnce()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, report.Sampled)
+	assert.Equal(t, 1, report.Drifted)
+	assert.Equal(t, []string{cache.MakeCacheKey(cachelayer.NewIndex("ID", 2))}, report.DriftedKeys)
+	assert.False(t, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex("ID", 2))))
+	assert.Equal(t, report, job.LastReport())
+}
+
+func TestAntiEntropyCorrupt(t *testing.T) {
+	cache, _, mr := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com"}, member{ID: 2, Name: "ann", Email: "ann@x.com"})
+	_, err := cache.List(1, 2)
+	assert.Nil(t, err)
+	idKey := cache.MakeCacheKey(cachelayer.NewIndex("ID", 1))
+	mr.Set(idKey, "not json")
+	report, err := cachelayer.NewAntiEntropy(cache, time.Minute, 100).RunOnce()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Corrupt)
+	assert.Equal(t, 1, report.Sampled)
+	assert.Equal(t, 0, report.Drifted)
+	// healed by the default CorruptAsMiss policy
+	assert.False(t, mr.Exists(idKey))
+}
+
+func TestAntiEntropyStringIDs(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	db := skuDB{"ABC": {Code: "ABC", Name: "apple"}}
+	cache := cachelayer.NewRedisCache[sku, string]("app", "sku", "Code", db, red, time.Minute)
+	cache.SetReverseIndex(true)
+	_, _, err := cache.Get("ABC")
+	assert.Nil(t, err)
+	idKey := cache.MakeCacheKey(cachelayer.NewIndex("Code", "ABC"))
+	assert.Equal(t, strings.ToLower(idKey), idKey)
+	// keys kept beside the entry parse as ids too
+	for _, v := range []string{":deadline", ":fresh", ":lock", ":loaded:0a1b", ":seeding"} {
+		mr.Set(idKey+v, "1")
+	}
+	assert.True(t, mr.Exists(idKey+cachelayer.RefsKeySuffix))
+	report, err := cachelayer.NewAntiEntropy(cache, time.Minute, 100).RunOnce()
+	assert.Nil(t, err)
+	// the id is taken from the cached record, not from the lower case key
+	assert.Equal(t, cachelayer.DriftReport{Sampled: 1}, report)
+	assert.True(t, mr.Exists(idKey))
+}
@@ -0,0 +1,119 @@
+package cachelayer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//AnyOf index alternatives of a query matching records of any of them(OR), eg. AnyOf{{"Email": email}, {"Phone": phone}}
+type AnyOf []Index
+
+//AnyLister database answering AnyOf by one query, eg. WHERE (email = ?) OR (phone = ?). Used by ListByAny
+type AnyLister[T Table[I], I IDType] interface {
+	ListByAny(anyOf AnyOf, orderBys OrderBys) ([]T, error)
+}
+
+//AnyOfKey cache key of anyOf: {prefix}/{table}/any/{alternative1}|{alternative2}..., alternatives are normalized like index keys,
+// sorted and deduplicated, so the key does not depend on the order of alternatives or fields
+func (s *CacheBase[T, I]) AnyOfKey(anyOf AnyOf) string {
+	base := strings.ToLower(s.keyPrefix() + "/" + s.table + "/")
+	alts := make([]string, len(anyOf))
+	for i, v := range anyOf {
+		alts[i] = strings.TrimPrefix(s.MakeCacheKey(v), base)
+	}
+	alts = UniqueStrings(alts)
+	sort.Strings(alts)
+	return base + "any/" + strings.Join(alts, "|")
+}
+
+//anyOfTag tag of OR query keys having the index key as an alternative
+func anyOfTag(key string) string {
+	return "any:" + key
+}
+
+//AnyOfTags tags of OR query keys(see ListByAny) having any of keys as an alternative, pass them to ClearKeys to clear those queries with keys
+func (s *CacheBase[T, I]) AnyOfTags(keys []string) []string {
+	r := make([]string, len(keys))
+	for i, v := range keys {
+		r[i] = anyOfTag(v)
+	}
+	return r
+}
+
+//ListByAny records matching any of the alternatives of anyOf, ids are cached under AnyOfKey and cleared when a record of any alternative
+// changes. Databases not implementing AnyLister are queried by ListBy per alternative, records are then ordered by alternative
+func (s *RedisCache[T, I]) ListByAny(anyOf AnyOf, orderBys OrderBys) ([]T, error) {
+	if len(anyOf) == 0 {
+		return nil, nil
+	}
+	if len(anyOf) == 1 {
+		return s.ListBy(anyOf[0], orderBys)
+	}
+	redisKey := s.AnyOfKey(anyOf)
+	s.hotKeys.Record(redisKey)
+	start := s.clock.Now()
+	cachedIds, exists, err := s.redIds.GetJson(redisKey)
+	s.observe(OpCacheRead, redisKey, start, err)
+	if err != nil && err != redis.Nil {
+		return nil, s.wrapErr("list_by_any", redisKey, err)
+	}
+	if exists {
+		s.stats.hit(1)
+		s.trace(TraceHit, nil, redisKey)
+		s.report("refresh", s.red.Refresh(redisKey))
+		return s.List(cachedIds...)
+	}
+	s.stats.miss(1)
+	s.trace(TraceMiss, nil, redisKey)
+	if !s.allowDB(redisKey) {
+		r, _, err := rateLimited[[]T](s.dbLimiter, redisKey)
+		return r, s.wrapErr("list_by_any", redisKey, err)
+	}
+	gen := s.generation.current()
+	start = s.clock.Now()
+	r, err := s.listByAny(anyOf, orderBys)
+	s.dbLoaded(start, len(r), err, redisKey)
+	if err != nil {
+		return nil, s.wrapErr("list_by_any", redisKey, err)
+	}
+	s.dbLimiter.remember(redisKey, r, true)
+	ids := listIDs[T, I](r...)
+	tags := make([]string, len(anyOf))
+	for i, v := range anyOf {
+		tags[i] = anyOfTag(s.MakeCacheKey(v))
+	}
+	err = s.populate(gen, func() error {
+		if err := s.redIds.SetJson(redisKey, ids); err != nil {
+			return err
+		}
+		if err := s.AddTags(s.red.UniversalClient, s.redIds.storeTTL(), redisKey, tags...); err != nil {
+			return err
+		}
+		return s.addRefs(s.red.UniversalClient, s.redIds.storeTTL(), map[string][]I{redisKey: ids})
+	})
+	return r, s.wrapErr("list_by_any", redisKey, err)
+}
+
+//listByAny records of anyOf from db, by one query if db is an AnyLister
+func (s *RedisCache[T, I]) listByAny(anyOf AnyOf, orderBys OrderBys) ([]T, error) {
+	if l, ok := s.db.(AnyLister[T, I]); ok {
+		return l.ListByAny(anyOf, orderBys)
+	}
+	var r []T
+	seen := make(map[I]bool)
+	for _, index := range anyOf {
+		objs, err := s.db.ListBy(index, orderBys)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range objs {
+			if !seen[v.GetID()] {
+				seen[v.GetID()] = true
+				r = append(r, v)
+			}
+		}
+	}
+	return r, nil
+}
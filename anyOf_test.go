@@ -0,0 +1,18 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnyOfKey(t *testing.T) {
+	cache := cachelayer.NewRedisCache[keyUser, uint]("app", "user", "ID", nil, nil, time.Minute)
+	a := cache.AnyOfKey(cachelayer.AnyOf{{"Email": "a@b.c"}, {"Name": "tom", "Age": 3}})
+	b := cache.AnyOfKey(cachelayer.AnyOf{{"age": 3, "name": "tom"}, {"email": "a@b.c"}, {"Email": "a@b.c"}})
+	assert.Equal(t, "app/user/any/age/3/name/tom|email/a@b.c", a)
+	assert.Equal(t, a, b)
+	assert.Equal(t, []string{"any:app/user/email/a@b.c"}, cache.AnyOfTags([]string{"app/user/email/a@b.c"}))
+}
@@ -0,0 +1,153 @@
+package cachelayer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+//AsyncWriterStats counters of an AsyncWriter
+type AsyncWriterStats struct {
+	Queued  int64
+	Written int64
+	Failed  int64
+	//Dropped writes rejected because the queue was full
+	Dropped int64
+	//Stale writes skipped because the cache was invalidated after their records were read
+	Stale int64
+}
+
+//AsyncWriter bounded worker pool writing cache entries in background, so a database miss returns without waiting for redis.
+// Writes are dropped when the queue is full, the record is just loaded again on the next miss
+type AsyncWriter struct {
+	queue   chan func() error
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+	queued  int64
+	written int64
+	failed  int64
+	dropped int64
+	stale   int64
+}
+
+//errStaleWrite queued write skipped by an invalidation, see CacheBase.SetAsyncWriter
+var errStaleWrite = errors.New("stale cache write")
+
+func NewAsyncWriter(workers, queueSize int) *AsyncWriter {
+	if workers <= 0 {
+		workers = 1
+	}
+	s := &AsyncWriter{queue: make(chan func() error, queueSize)}
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go labeled(context.Background(), "async_writer", func(context.Context) { s.work() })
+	}
+	return s
+}
+
+func (s *AsyncWriter) work() {
+	defer s.wg.Done()
+	for fn := range s.queue {
+		atomic.AddInt64(&s.queued, -1)
+		err := safely("async_writer", "", fn)
+		if errors.Is(err, errStaleWrite) {
+			atomic.AddInt64(&s.stale, 1)
+		} else if err != nil {
+			atomic.AddInt64(&s.failed, 1)
+			// panics are already reported by safely
+			if !errors.Is(err, ErrPanic) {
+				ReportError("async_writer", "", err)
+			}
+		} else {
+			atomic.AddInt64(&s.written, 1)
+		}
+	}
+}
+
+//Submit enqueue fn without blocking, return false if the queue is full or the writer is closed
+func (s *AsyncWriter) Submit(fn func() error) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		atomic.AddInt64(&s.dropped, 1)
+		return false
+	}
+	select {
+	case s.queue <- fn:
+		atomic.AddInt64(&s.queued, 1)
+		return true
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+		return false
+	}
+}
+
+func (s *AsyncWriter) Stats() AsyncWriterStats {
+	return AsyncWriterStats{
+		Queued:  atomic.LoadInt64(&s.queued),
+		Written: atomic.LoadInt64(&s.written),
+		Failed:  atomic.LoadInt64(&s.failed),
+		Dropped: atomic.LoadInt64(&s.dropped),
+		Stale:   atomic.LoadInt64(&s.stale),
+	}
+}
+
+//Close stop accepting writes and wait for queued writes
+func (s *AsyncWriter) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+//SetAsyncWriter write cache entries loaded on misses with w in background, nil means writing synchronously.
+// The writer can be shared by caches. A queued write is dropped if the cache invalidated any key since the record was read from database,
+// so a record loaded before an update is not written back after the update cleared it
+func (s *CacheBase[T, I]) SetAsyncWriter(w *AsyncWriter) {
+	s.asyncWriter = w
+}
+
+//populate write records loaded at generation n back to redis, in background if async writer is set.
+// n must be taken before the database read, so a write racing the read is detected by its invalidation
+func (s *CacheBase[T, I]) populate(n int64, fn func() error) error {
+	if s.asyncWriter != nil {
+		s.asyncWriter.Submit(func() error { return s.generation.since(n, fn) })
+		return nil
+	}
+	return fn()
+}
+
+//generation counter of invalidations of a cache, shared by its copies
+type generation struct {
+	mu sync.RWMutex
+	n  int64
+}
+
+func (s *generation) current() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.n
+}
+
+//advance called before deleting keys, waits for writes checked against the previous generation so the delete removes them
+func (s *generation) advance() {
+	s.mu.Lock()
+	s.n++
+	s.mu.Unlock()
+}
+
+//since run fn if the generation is still n, otherwise fn may write a record loaded before an invalidation and is skipped
+func (s *generation) since(n int64, fn func() error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.n != n {
+		return errStaleWrite
+	}
+	return fn()
+}
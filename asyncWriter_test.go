@@ -0,0 +1,114 @@
+package cachelayer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	w := cachelayer.NewAsyncWriter(1, 1)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	assert.True(t, w.Submit(func() error {
+		close(started)
+		<-block
+		return nil
+	}))
+	<-started
+	assert.True(t, w.Submit(func() error { return errors.New("redis down") }))
+	assert.False(t, w.Submit(func() error { return nil }))
+	close(block)
+	w.Close()
+	assert.False(t, w.Submit(func() error { return nil }))
+	assert.Equal(t, cachelayer.AsyncWriterStats{Written: 1, Failed: 1, Dropped: 2}, w.Stats())
+}
+
+func TestAsyncWriterRecoverPanic(t *testing.T) {
+	events := make(chan cachelayer.ErrorEvent, 2)
+	cachelayer.SetErrorHandler(func(event cachelayer.ErrorEvent) { events <- event })
+	defer cachelayer.SetErrorHandler(nil)
+	w := cachelayer.NewAsyncWriter(1, 2)
+	assert.True(t, w.Submit(func() error { panic("boom") }))
+	assert.True(t, w.Submit(func() error { return nil }))
+	w.Close()
+	event := <-events
+	assert.Equal(t, "async_writer", event.Component)
+	assert.Equal(t, "boom", event.Panic)
+	assert.NotEmpty(t, event.Stack)
+	assert.True(t, errors.Is(event.Err, cachelayer.ErrPanic))
+	assert.Equal(t, cachelayer.AsyncWriterStats{Written: 1, Failed: 1}, w.Stats())
+}
+
+func TestAsyncWriterSkipStaleWrites(t *testing.T) {
+	cache, _, mr := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1})
+	w := cachelayer.NewAsyncWriter(1, 4)
+	cache.SetAsyncWriter(w)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	assert.True(t, w.Submit(func() error {
+		close(started)
+		<-block
+		return nil
+	}))
+	<-started
+	// the write of the loaded record waits behind the blocked one
+	r, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "tom", r.Name)
+	_, err = cache.Update(1, map[string]interface{}{"Name": "jerry"})
+	assert.Nil(t, err)
+	close(block)
+	w.Close()
+	assert.False(t, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex("ID", uint(1)))))
+	stats := w.Stats()
+	assert.Equal(t, int64(1), stats.Written)
+	assert.Equal(t, int64(0), stats.Failed)
+	assert.True(t, stats.Stale > 0)
+	cache.SetAsyncWriter(nil)
+	r, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "jerry", r.Name)
+}
+
+//raceDB memDB whose during runs once after a record is read and before it is returned
+type raceDB struct {
+	*memDB
+	during func()
+}
+
+func (s *raceDB) Get(id uint) (member, bool, error) {
+	r, exists, err := s.memDB.Get(id)
+	if s.during != nil {
+		during := s.during
+		s.during = nil
+		during()
+	}
+	return r, exists, err
+}
+
+func TestAsyncWriterSkipRacingLoad(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	db := &raceDB{memDB: newMemDB(member{ID: 1, Name: "tom", GroupID: 1})}
+	cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	w := cachelayer.NewAsyncWriter(1, 4)
+	cache.SetAsyncWriter(w)
+	// updated after tom is read from database, before its write is queued
+	db.during = func() {
+		_, err := cache.Update(1, map[string]interface{}{"Name": "jerry"})
+		assert.Nil(t, err)
+	}
+	r, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "tom", r.Name)
+	w.Close()
+	assert.False(t, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex("ID", uint(1)))))
+	assert.Equal(t, int64(1), w.Stats().Stale)
+	cache.SetAsyncWriter(nil)
+	r, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "jerry", r.Name)
+}
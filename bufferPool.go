@@ -0,0 +1,69 @@
+package cachelayer
+
+import "sync"
+
+//AppendSerializer serializer appending payloads to a buffer, eg. a pooled one. Batch writes(MSetJson, HSetJson) of serializers
+// implementing it marshal all records into one pooled buffer, so they allocate nothing per record
+type AppendSerializer interface {
+	AppendMarshal(dst []byte, obj interface{}) ([]byte, error)
+}
+
+//maxPooledBuffer buffers grown larger are dropped instead of pooled, so one huge batch doesn't pin its memory
+const maxPooledBuffer = 1 << 20
+
+var bufferPool = sync.Pool{New: func() interface{} {
+	b := make([]byte, 0, 4096)
+	return &b
+}}
+
+//payloads serialized records of a batch write in one pooled buffer, release it after the write is executed
+type payloads struct {
+	buf  *[]byte
+	ends []int
+}
+
+func newPayloads(n int) *payloads {
+	return &payloads{buf: bufferPool.Get().(*[]byte), ends: make([]int, 0, n)}
+}
+
+//add marshal obj after the payloads added before
+func (s *payloads) add(serializer Serializer, obj interface{}) error {
+	b, err := appendMarshal(serializer, *s.buf, beforeCache(obj))
+	if err != nil {
+		return NewError(ErrSerialization, err)
+	}
+	*s.buf = b
+	s.ends = append(s.ends, len(b))
+	return nil
+}
+
+//get i-th payload, valid until release
+func (s *payloads) get(i int) []byte {
+	start := 0
+	if i > 0 {
+		start = s.ends[i-1]
+	}
+	return (*s.buf)[start:s.ends[i]:s.ends[i]]
+}
+
+//release return the buffer to the pool
+func (s *payloads) release() {
+	if cap(*s.buf) > maxPooledBuffer {
+		return
+	}
+	*s.buf = (*s.buf)[:0]
+	bufferPool.Put(s.buf)
+}
+
+//appendMarshal append the payload of obj to dst, through the cheapest method serializer implements
+func appendMarshal(serializer Serializer, dst []byte, obj interface{}) ([]byte, error) {
+	switch v := serializer.(type) {
+	case AppendSerializer:
+		return v.AppendMarshal(dst, obj)
+	case BytesSerializer:
+		b, err := v.MarshalBytes(obj)
+		return append(dst, b...), err
+	}
+	r, err := serializer.Marshal(obj)
+	return append(dst, r...), err
+}
@@ -0,0 +1,23 @@
+package cachelayer_test
+
+import (
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonSerializerAppendMarshal(t *testing.T) {
+	s := &cachelayer.JsonSerializer{}
+	var _ cachelayer.AppendSerializer = s
+	user := keyUser{ID: 42}
+	want, err := s.Marshal(user)
+	assert.Nil(t, err)
+	buf := []byte("prefix")
+	buf, err = s.AppendMarshal(buf, user)
+	assert.Nil(t, err)
+	assert.Equal(t, "prefix"+want, string(buf))
+
+	_, err = s.AppendMarshal(nil, make(chan int))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,63 @@
+package cachelayer
+
+import "unsafe"
+
+//BytesSerializer binary safe serializer, eg. msgpack or protobuf. Serializers implementing it are called by MarshalBytes/UnmarshalBytes
+// instead of Marshal/Unmarshal on every read and write, so payloads are never copied between string and []byte.
+// UnmarshalBytes must neither modify nor retain data
+type BytesSerializer interface {
+	MarshalBytes(obj interface{}) ([]byte, error)
+	UnmarshalBytes(data []byte, objRef interface{}) error
+}
+
+//bytesSerializer Serializer of a BytesSerializer
+type bytesSerializer struct {
+	BytesSerializer
+}
+
+//WrapBytesSerializer Serializer of a serializer implementing only BytesSerializer, for SetSerializer and RegisterSerializer
+func WrapBytesSerializer(serializer BytesSerializer) Serializer {
+	return &bytesSerializer{serializer}
+}
+
+func (s *bytesSerializer) Marshal(obj interface{}) (string, error) {
+	b, err := s.MarshalBytes(obj)
+	return string(b), err
+}
+
+func (s *bytesSerializer) Unmarshal(data string, objRef interface{}) error {
+	return s.UnmarshalBytes([]byte(data), objRef)
+}
+
+//serialize payload of obj by serializer, without copying bytes of a BytesSerializer
+func serialize(serializer Serializer, obj interface{}) (string, error) {
+	if b, ok := serializer.(BytesSerializer); ok {
+		r, err := b.MarshalBytes(obj)
+		return bytesToString(r), err
+	}
+	return serializer.Marshal(obj)
+}
+
+//deserialize data into objRef by serializer, without copying data for a BytesSerializer
+func deserialize(serializer Serializer, data string, objRef interface{}) error {
+	if b, ok := serializer.(BytesSerializer); ok {
+		return b.UnmarshalBytes(stringToBytes(data), objRef)
+	}
+	return serializer.Unmarshal(data, objRef)
+}
+
+//bytesToString string sharing memory with b, b must not be modified afterwards
+func bytesToString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+//stringToBytes read only bytes sharing memory with s
+func stringToBytes(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	return *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		int
+	}{s, len(s)}))
+}
@@ -0,0 +1,40 @@
+package cachelayer_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+//gobSerializer binary serializer implementing only BytesSerializer
+type gobSerializer struct{}
+
+func (s gobSerializer) MarshalBytes(obj interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(obj)
+	return buf.Bytes(), err
+}
+
+func (s gobSerializer) UnmarshalBytes(data []byte, objRef interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(objRef)
+}
+
+func TestBytesSerializer(t *testing.T) {
+	var _ cachelayer.BytesSerializer = &cachelayer.JsonSerializer{}
+	s := cachelayer.WrapBytesSerializer(gobSerializer{})
+	user := keyUser{ID: 42}
+	data, err := s.Marshal(user)
+	assert.Nil(t, err)
+	var r keyUser
+	assert.Nil(t, s.Unmarshal(data, &r))
+	assert.Equal(t, user, r)
+
+	b, err := (&cachelayer.JsonSerializer{}).MarshalBytes(user)
+	assert.Nil(t, err)
+	r = keyUser{}
+	assert.Nil(t, (&cachelayer.JsonSerializer{}).UnmarshalBytes(b, &r))
+	assert.Equal(t, user, r)
+}
@@ -3,7 +3,9 @@ package cachelayer
 import (
 	"context"
 	"database/sql"
+	"encoding"
 	"fmt"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,15 +19,38 @@ type IDType interface {
 	IDInt | ~string
 }
 
+//IsNullID whether id is the zero value of its type, ie. not assigned yet
 func IsNullID[I IDType](id I) bool {
-	var v interface{} = id
-	switch x := v.(type) {
-	case string:
-		return len(x) == 0
-	case int, int16, int32, int64, uint, uint16, uint32, uint64:
-		return x == 0
+	var zero I
+	return id == zero
+}
+
+//ParseID parse id from its string form, eg. the last segment of an id cache key
+func ParseID[I IDType](s string) (I, error) {
+	var id I
+	if u, ok := interface{}(&id).(*UUID); ok {
+		r, err := ParseUUID(s)
+		*u = r
+		return id, err
+	}
+	v := reflect.ValueOf(&id).Elem()
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return id, err
+		}
+		v.SetInt(n)
+	default:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return id, err
+		}
+		v.SetUint(n)
 	}
-	return v == nil
+	return id, nil
 }
 
 // type CacheKeyMaker func(prefix, table string, indexes Indexes) string
@@ -99,6 +124,29 @@ type Table[I IDType] interface {
 	ListIndexes() Indexes
 }
 
+//UniqueIndexed optionally implemented by tables to mark unique indexes by their fields, eg. [][]string{{"email"}, {"tenantId", "code"}}.
+// GetBy caches id-only mappings of unique indexes and fails with ErrNotUnique if several records match one,
+// GetBy on other indexes takes the first record of the list path(ListBy). Tables not implementing it treat every index as unique
+type UniqueIndexed interface {
+	UniqueIndexes() [][]string
+}
+
+//getByUnique get the only record of a unique index from db, ErrNotUnique if several records match
+func getByUnique[T Table[I], I IDType](db DBCRUD[T, I], index Index) (T, bool, error) {
+	var t T
+	if _, ok := interface{}(t).(UniqueIndexed); !ok {
+		return db.GetBy(index)
+	}
+	objs, err := db.ListBy(index, nil)
+	if err != nil || len(objs) == 0 {
+		return t, false, err
+	}
+	if len(objs) > 1 {
+		return objs[0], true, NewError(ErrNotUnique, fmt.Errorf("%d records match %v", len(objs), index))
+	}
+	return objs[0], true, nil
+}
+
 type DBCRUD[T Table[I], I IDType] interface {
 	//Creat create new record into dababase
 	Create(obj *T) error
@@ -120,6 +168,17 @@ type DBCRUD[T Table[I], I IDType] interface {
 	Close() error
 }
 
+//Upserter database supporting atomic insert-or-update on conflict columns, return the replaced row and whether it existed
+type Upserter[T Table[I], I IDType] interface {
+	Upsert(obj *T, conflictColumns ...string) (T, bool, error)
+}
+
+//DeleteReturner database deleting rows and returning them atomically, eg. DELETE ... RETURNING or rows locked in the deleting transaction.
+// DeleteReturning of caches uses it so returned rows are exactly the deleted versions
+type DeleteReturner[T Table[I], I IDType] interface {
+	DeleteReturning(ids ...I) ([]T, error)
+}
+
 //Cache
 // 1. Primary key cache: eg. {table}/id/{id} ->  record
 // 2.1 Index cache: eg1. {table}/uid/{uid}->  [id1,id2]
@@ -142,6 +201,16 @@ type Cache[T Table[I], I IDType] interface {
 	GetIdField() string
 }
 
+//ContextDB database returning a copy of itself whose queries carry ctx, used by WithContext of caches so per-call contexts never mutate shared state
+type ContextDB[T Table[I], I IDType] interface {
+	WithContext(ctx context.Context) DBCRUD[T, I]
+}
+
+//PrimaryDB database returning a copy of itself reading from the primary instead of replicas, used by StrongRead of caches
+type PrimaryDB[T Table[I], I IDType] interface {
+	Primary() DBCRUD[T, I]
+}
+
 type FullCache[T Table[I], I IDType] interface {
 	ClearCache(objs ...T) error
 	//Creat create new record into dababase
@@ -188,14 +257,52 @@ type CacheBase[T Table[I], I IDType] struct {
 	// indexFields [][]string
 	ctx context.Context
 	// ttl         time.Duration
+	hotKeys *HotKeyTracker
+	// return ErrNotFound instead of (T, false, nil)
+	notFoundErr bool
+	// do not cache "not found" results
+	noNegativeCache bool
+	purgeAuditor    func(record PurgeRecord)
+	reverseIndex    bool
+	stats           *statsCounter
+	asyncWriter     *AsyncWriter
+	generation      *generation
+	publisher       InvalidationPublisher
+	publisherSource string
+	webhooks        []*Webhook
+	clock           Clock
+	nullID          func(id I) bool
+	idGenerator     func() I
+	slowThreshold   time.Duration
+	slowLog         func(op SlowOp)
+	debug           bool
+	dbLimiter       *DBRateLimiter
+	coalesceWait    time.Duration
+	writeMode       WriteMode
+	countMode       CountMode
+	//strongRead skip redis on reads, see StrongRead
+	strongRead bool
+	keyScope   KeyScopeFunc
+	//canonicalKeys encode index values by KeyValue, see SetCanonicalKeys
+	canonicalKeys bool
+	//onCorruption hook of corrupt entries, see OnCorruption
+	onCorruption func(key string, err error)
+	//onDBLoad hook of database queries of misses, see OnDBLoad
+	onDBLoad func(ctx context.Context, load DBLoad)
+	//failureMode result of reads when redis fails, see SetFailureMode
+	failureMode FailureMode
+	lastValues  *lastValues
 }
 
 func NewCacheBase[T Table[I], I IDType](prefix, table, idField string, ctx context.Context) *CacheBase[T, I] {
 	return &CacheBase[T, I]{
-		prefix:  prefix,
-		table:   table,
-		idField: idField,
-		ctx:     ctx,
+		prefix:     prefix,
+		table:      table,
+		idField:    idField,
+		ctx:        ctx,
+		stats:      &statsCounter{},
+		clock:      RealClock{},
+		generation: &generation{},
 	}
 }
 
@@ -211,12 +318,58 @@ func (s *CacheBase[T, I]) SetCacheKeyPrefix(prefix string) {
 func (s *CacheBase[T, I]) GetCacheKeyPrefix() string {
 	return s.prefix
 }
+
+//KeyScopeFunc scope of the keys of a call taken from its context, eg. tenant id or locale. "" means unscoped
+type KeyScopeFunc func(ctx context.Context) string
+
+//SetKeyScope partition keys by fn(ctx) of the cache context(see WithContext): keys become {prefix}:{scope}/{table}/...,
+// so one cache instance serves every tenant or locale. Pattern clears, memory reports and re-encryption act on the scope of the context
+func (s *CacheBase[T, I]) SetKeyScope(fn KeyScopeFunc) {
+	s.keyScope = fn
+}
+
+//keyPrefix prefix of keys of the cache context, followed by ":" and the scope if scoped
+func (s *CacheBase[T, I]) keyPrefix() string {
+	if s.keyScope == nil {
+		return s.prefix
+	}
+	if scope := s.keyScope(s.ctx); scope != "" {
+		return s.prefix + ":" + scope
+	}
+	return s.prefix
+}
 func (s *CacheBase[T, I]) MakeCacheKey(index Index) string {
-	r := s.prefix + "/" + s.table
-	keys := index.Fields()
+	return makeCacheKey(s.keyPrefix(), s.table, index, s.canonicalKeys)
+}
+
+//SetCanonicalKeys encode index values of keys by KeyValue and order fields case-insensitively, so 1, int64(1), 1.0, "1" and true share a key.
+// Off by default: keys of bool, float, time and []byte values differ from the default encoding, so every instance sharing the keys must switch at once(see README)
+func (s *CacheBase[T, I]) SetCanonicalKeys(enabled bool) {
+	s.canonicalKeys = enabled
+}
+
+//makeCacheKey {prefix}/{table}/{field1}/{value1}/{field2}/{value2}..., values are encoded by Stringify,
+// or by KeyValue with fields ordered case-insensitively if canonical
+func makeCacheKey(prefix, table string, index Index, canonical bool) string {
+	r := prefix + "/" + table
+	if !canonical {
+		keys := index.Fields()
+		sort.Strings(keys)
+		for _, k := range keys {
+			r += "/" + k + "/" + Stringify(index[k], "null")
+		}
+		return strings.ToLower(r)
+	}
+	fields := make(map[string]string, len(index))
+	keys := make([]string, 0, len(index))
+	for k := range index {
+		lower := strings.ToLower(k)
+		fields[lower] = k
+		keys = append(keys, lower)
+	}
 	sort.Strings(keys)
 	for _, k := range keys {
-		r += "/" + k + "/" + Stringify(index[k], "null")
+		r += "/" + k + "/" + KeyValue(index[fields[k]])
 	}
 	return strings.ToLower(r)
 }
@@ -244,6 +397,8 @@ func (s *CacheBase[T, I]) Close() error {
 // func (s *CacheBase[T, I]) ListIndexFields() [][]string {
 // 	return s.indexFields
 // }
+//SetCtx set the default context of the cache.
+// Deprecated: it mutates a cache shared by goroutines, pass per-call contexts by WithContext instead
 func (s *CacheBase[T, I]) SetCtx(ctx context.Context) {
 	s.ctx = ctx
 }
@@ -251,6 +406,107 @@ func (s *CacheBase[T, I]) GetCtx() context.Context {
 	return s.ctx
 }
 
+//SetNullIDFunc override which ids are null(not assigned yet, not cached), eg. treat 0 as a legitimate id of a legacy table. nil restores IsNullID
+func (s *CacheBase[T, I]) SetNullIDFunc(fn func(id I) bool) {
+	s.nullID = fn
+}
+
+//IsNullID whether id is null by the NullIDFunc of the cache, default the package IsNullID
+func (s *CacheBase[T, I]) IsNullID(id I) bool {
+	if s.nullID != nil {
+		return s.nullID(id)
+	}
+	return IsNullID(id)
+}
+
+//IsUniqueIndex whether fields of index are declared unique by UniqueIndexed, true if T does not implement it
+func (s *CacheBase[T, I]) IsUniqueIndex(index Index) bool {
+	var t T
+	u, ok := interface{}(t).(UniqueIndexed)
+	if !ok {
+		return true
+	}
+	for _, fields := range u.UniqueIndexes() {
+		if sameFields(fields, index.Fields()) {
+			return true
+		}
+	}
+	return false
+}
+
+//sameFields whether a and b are the same field set, case-insensitive
+func sameFields(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+//SetIDGenerator fill null ids with fn on Create, eg. cachelayer.NewUUIDv7 for UUID primary keys
+func (s *CacheBase[T, I]) SetIDGenerator(fn func() I) {
+	s.idGenerator = fn
+}
+
+//FillID set a generated id to obj if its id is null, return whether an id is set
+func (s *CacheBase[T, I]) FillID(obj *T) (bool, error) {
+	if s.idGenerator == nil || obj == nil || !s.IsNullID((*obj).GetID()) {
+		return false, nil
+	}
+	v := reflect.ValueOf(obj).Elem()
+	if v.Kind() != reflect.Struct {
+		return false, fmt.Errorf("cachelayer: cannot set id of %s", v.Type())
+	}
+	f, ok := lookupField(v.Type(), s.idField)
+	if !ok {
+		return false, fmt.Errorf("cachelayer: no id field %s in %s", s.idField, v.Type())
+	}
+	id := reflect.ValueOf(s.idGenerator())
+	fv := v.FieldByName(f.Name)
+	if !id.Type().ConvertibleTo(fv.Type()) {
+		return false, fmt.Errorf("cachelayer: cannot set %s id to field %s", id.Type(), f.Name)
+	}
+	fv.Set(id.Convert(fv.Type()))
+	return true, nil
+}
+
+//SetNotFoundError Get/GetBy return error ErrNotFound instead of (T, false, nil) when record does not exist
+func (s *CacheBase[T, I]) SetNotFoundError(enabled bool) {
+	s.notFoundErr = enabled
+}
+
+//SetNegativeCache enable(default) or disable caching of "not found" results
+func (s *CacheBase[T, I]) SetNegativeCache(enabled bool) {
+	s.noNegativeCache = !enabled
+}
+
+//SetHotKeyTracker enable hot-key detection, nil to disable
+func (s *CacheBase[T, I]) SetHotKeyTracker(tracker *HotKeyTracker) {
+	s.hotKeys = tracker
+}
+func (s *CacheBase[T, I]) GetHotKeyTracker() *HotKeyTracker {
+	return s.hotKeys
+}
+
+//HotKeys return top n accessed cache keys, empty if hot-key tracker is not set
+func (s *CacheBase[T, I]) HotKeys(n int) []HotKey {
+	if s.hotKeys == nil {
+		return nil
+	}
+	return s.hotKeys.HotKeys(n)
+}
+
 func StringifyAtom(value interface{}) string {
 	switch v := value.(type) {
 	case string:
@@ -270,9 +526,14 @@ func StringifyAtom(value interface{}) string {
 	case time.Time:
 		return v.Format(time.RFC3339)
 	}
+	if r, ok := stringifyOther(value); ok {
+		return r
+	}
 	return fmt.Sprintf("%#v", value)
 }
 
+//Stringify string form of value for keys and ids, null for nil and invalid sql.NullXxx values.
+// Besides basic types it supports time.Time(RFC3339), encoding.TextMarshaler and fmt.Stringer(eg. shopspring decimal.Decimal), named basic types and pointers
 func Stringify(value interface{}, null string) string {
 	switch v := value.(type) {
 	case string:
@@ -339,10 +600,52 @@ func Stringify(value interface{}, null string) string {
 		} else {
 			return null
 		}
+	case nil:
+		return null
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return null
+		}
+		if _, ok := value.(encoding.TextMarshaler); !ok {
+			if _, ok := value.(fmt.Stringer); !ok {
+				return Stringify(rv.Elem().Interface(), null)
+			}
+		}
+	}
+	if r, ok := stringifyOther(value); ok {
+		return r
 	}
 	return fmt.Sprintf("%#v", value)
 }
 
+//stringifyOther string of TextMarshaler, Stringer and named basic types
+func stringifyOther(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case encoding.TextMarshaler:
+		if b, err := v.MarshalText(); err == nil {
+			return string(b), true
+		}
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%f", rv.Float()), true
+	}
+	return "", false
+}
+
 func UniqueStrings(strs []string) []string {
 	m := make(map[string]bool)
 	for _, v := range strs {
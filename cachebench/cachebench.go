@@ -0,0 +1,353 @@
+//Package cachebench runs workloads against RedisCache, FullRedisCache and the database alone, reporting hit ratio and latency,
+// so the cache mode of a table is chosen with data. NewLocal runs on an embedded redis and an in-memory sqlite database
+package cachebench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+//Strategy how a workload reads and writes records
+type Strategy string
+
+const (
+	//NoCache database only
+	NoCache Strategy = "no_cache"
+	//PerRecord RedisCache, records are cached by id on their first read
+	PerRecord Strategy = "redis_cache"
+	//Full FullRedisCache, all records are cached in one hash
+	Full Strategy = "full_redis_cache"
+)
+
+//Strategies all strategies, run by Run without strategies
+var Strategies = []Strategy{NoCache, PerRecord, Full}
+
+//Workload operations run against every strategy
+type Workload struct {
+	//Records rows seeded into the table
+	Records int
+	//Ops operations run, reads are Get and writes are Update by id
+	Ops int
+	//Concurrency goroutines running operations, 1 if <= 0
+	Concurrency int
+	//ReadRatio share(0-1) of reads, the rest are writes
+	ReadRatio float64
+	//Skew zipf exponent of picked ids, eg. 1.1 for a few hot records; ids are picked uniformly if Skew <= 1
+	Skew float64
+	//Seed of picked operations and ids, so every strategy runs the same operations
+	Seed int64
+	//TTL of cache entries, a minute if 0
+	TTL time.Duration
+}
+
+//DefaultWorkload read mostly workload with hot records
+var DefaultWorkload = Workload{Records: 1000, Ops: 10000, Concurrency: 8, ReadRatio: 0.9, Skew: 1.1, Seed: 1}
+
+//Result of a workload run by a strategy
+type Result struct {
+	Strategy Strategy
+	Reads    int
+	Writes   int
+	Errors   int
+	//Err first error of operations
+	Err error
+	//HitRatio hit ratio of reads, 0 of NoCache
+	HitRatio float64
+	//DBLoads database queries of reads, every read of NoCache
+	DBLoads int64
+	P50     time.Duration
+	P99     time.Duration
+	ReadP99 time.Duration
+	//WriteP99 p99 of writes, including cache invalidation
+	WriteP99 time.Duration
+	Elapsed  time.Duration
+}
+
+//OpsPerSecond throughput of the run
+func (s Result) OpsPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Reads+s.Writes) / s.Elapsed.Seconds()
+}
+
+func (s Result) String() string {
+	return fmt.Sprintf("%s reads=%d writes=%d errors=%d hit_ratio=%.3f db_loads=%d p50=%s p99=%s read_p99=%s write_p99=%s ops/s=%.0f",
+		s.Strategy, s.Reads, s.Writes, s.Errors, s.HitRatio, s.DBLoads, s.P50, s.P99, s.ReadP99, s.WriteP99, s.OpsPerSecond())
+}
+
+//WriteTable write results as an aligned table, one strategy per row
+func WriteTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "strategy\treads\twrites\terrors\thit ratio\tdb loads\tp50\tp99\tread p99\twrite p99\tops/s")
+	for _, v := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.3f\t%d\t%s\t%s\t%s\t%s\t%.0f\n", v.Strategy, v.Reads, v.Writes, v.Errors, v.HitRatio,
+			v.DBLoads, v.P50, v.P99, v.ReadP99, v.WriteP99, v.OpsPerSecond())
+	}
+	return tw.Flush()
+}
+
+//Record row of the benchmark table
+type Record struct {
+	ID    uint
+	Name  string
+	Score int
+}
+
+func (s Record) GetID() uint {
+	return s.ID
+}
+
+func (s Record) ListIndexes() cachelayer.Indexes {
+	return nil
+}
+
+//store operations of a strategy
+type store interface {
+	Get(id uint) (Record, bool, error)
+	Update(id uint, values interface{}) (int64, error)
+}
+
+//statser caches reporting Stats
+type statser interface {
+	Stats() cachelayer.Stats
+}
+
+//Bench database and redis workloads run against
+type Bench struct {
+	db     *gorm.DB
+	red    redis.UniversalClient
+	table  string
+	prefix string
+	closer func() error
+}
+
+//New bench of db and red. Run recreates and seeds the table "bench_record"(see SetTable) of db and clears its cache keys,
+// so neither must be shared with production data
+func New(db *gorm.DB, red redis.UniversalClient) *Bench {
+	return &Bench{db: db, red: red, table: "bench_record", prefix: "cachebench"}
+}
+
+//NewLocal bench of an in-memory sqlite database and an embedded redis, close it by Close
+func NewLocal() (*Bench, error) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		mr.Close()
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		mr.Close()
+		return nil, err
+	}
+	// every connection of ":memory:" opens another database
+	sqlDB.SetMaxOpenConns(1)
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	r := New(db, red)
+	r.closer = func() error {
+		err := red.Close()
+		mr.Close()
+		if err2 := sqlDB.Close(); err == nil {
+			err = err2
+		}
+		return err
+	}
+	return r, nil
+}
+
+//SetTable name of the table created by Run, "bench_record" by default
+func (s *Bench) SetTable(table string) {
+	s.table = table
+}
+
+//Close close the database and redis of NewLocal, nothing of New
+func (s *Bench) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer()
+}
+
+//Run run workload by strategies(all Strategies if none) one after another, each on a freshly seeded table and empty cache.
+// Operations not started when ctx is done are skipped
+func (s *Bench) Run(ctx context.Context, workload Workload, strategies ...Strategy) ([]Result, error) {
+	if workload.Records <= 0 {
+		return nil, errors.New("cachebench: workload without records")
+	}
+	if workload.Concurrency <= 0 {
+		workload.Concurrency = 1
+	}
+	if workload.TTL == 0 {
+		workload.TTL = time.Minute
+	}
+	if len(strategies) == 0 {
+		strategies = Strategies
+	}
+	r := make([]Result, 0, len(strategies))
+	for _, v := range strategies {
+		if err := s.reset(ctx, workload.Records); err != nil {
+			return r, err
+		}
+		st, err := s.store(v, workload.TTL)
+		if err != nil {
+			return r, err
+		}
+		r = append(r, run(ctx, v, st, workload))
+		if err := ctx.Err(); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+//store store of strategy
+func (s *Bench) store(strategy Strategy, ttl time.Duration) (store, error) {
+	db := gormredis.NewGorm[Record, uint](s.db, s.table, "ID")
+	db.SetTableName(s.table)
+	switch strategy {
+	case NoCache:
+		return db, nil
+	case PerRecord:
+		return cachelayer.NewRedisCache[Record, uint](s.prefix, s.table, "ID", db, s.red, ttl), nil
+	case Full:
+		return cachelayer.NewFullRedisCache[Record, uint](s.prefix, s.table, "ID", db, s.red, ttl), nil
+	}
+	return nil, fmt.Errorf("cachebench: unknown strategy %q", strategy)
+}
+
+//reset recreate the table with records rows and clear its cache keys
+func (s *Bench) reset(ctx context.Context, records int) error {
+	db := s.db.WithContext(ctx).Table(s.table)
+	if err := db.Migrator().DropTable(s.table); err != nil {
+		return err
+	}
+	if err := db.Migrator().CreateTable(&Record{}); err != nil {
+		return err
+	}
+	rows := make([]Record, records)
+	for i := range rows {
+		rows[i] = Record{ID: uint(i + 1), Name: fmt.Sprintf("record %d", i+1)}
+	}
+	if err := db.CreateInBatches(rows, 500).Error; err != nil {
+		return err
+	}
+	_, err := cachelayer.ClearByPattern(ctx, s.red, s.prefix+"/"+s.table+"/*", cachelayer.DefaultScanBatchSize, 0)
+	return err
+}
+
+//run run workload on st by Concurrency workers
+func run(ctx context.Context, strategy Strategy, st store, workload Workload) Result {
+	type timing struct {
+		d    time.Duration
+		read bool
+		err  error
+	}
+	ops := make(chan int)
+	timings := make([][]timing, workload.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < workload.Concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(workload.Seed + int64(i)))
+			pick := picker(rnd, workload.Records, workload.Skew)
+			for n := range ops {
+				id := pick()
+				read := rnd.Float64() < workload.ReadRatio
+				opStart := time.Now()
+				var err error
+				if read {
+					_, _, err = st.Get(id)
+				} else {
+					_, err = st.Update(id, map[string]interface{}{"score": n})
+				}
+				timings[i] = append(timings[i], timing{d: time.Since(opStart), read: read, err: err})
+			}
+		}(i)
+	}
+feed:
+	for n := 0; n < workload.Ops; n++ {
+		select {
+		case ops <- n:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(ops)
+	wg.Wait()
+	r := Result{Strategy: strategy, Elapsed: time.Since(start)}
+	var all, reads, writes []time.Duration
+	for _, v := range timings {
+		for _, t := range v {
+			all = append(all, t.d)
+			if t.read {
+				r.Reads++
+				reads = append(reads, t.d)
+			} else {
+				r.Writes++
+				writes = append(writes, t.d)
+			}
+			if t.err != nil {
+				if r.Errors++; r.Err == nil {
+					r.Err = t.err
+				}
+			}
+		}
+	}
+	r.P50, r.P99 = quantile(all, 0.5), quantile(all, 0.99)
+	r.ReadP99, r.WriteP99 = quantile(reads, 0.99), quantile(writes, 0.99)
+	if c, ok := st.(statser); ok {
+		stats := c.Stats()
+		r.HitRatio = stats.HitRatio()
+		r.DBLoads = stats.DBLoads
+	} else {
+		r.DBLoads = int64(r.Reads)
+	}
+	return r
+}
+
+//picker ids 1..records picked uniformly, or by zipf of exponent skew > 1 with id 1 the hottest
+func picker(rnd *rand.Rand, records int, skew float64) func() uint {
+	if skew <= 1 {
+		return func() uint {
+			return uint(rnd.Intn(records) + 1)
+		}
+	}
+	zipf := rand.NewZipf(rnd, skew, 1, uint64(records-1))
+	return func() uint {
+		return uint(zipf.Uint64() + 1)
+	}
+}
+
+//quantile q(0-1) of durations, sorting them
+func quantile(durations []time.Duration, q float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	i := int(q * float64(len(durations)))
+	if i >= len(durations) {
+		i = len(durations) - 1
+	}
+	return durations[i]
+}
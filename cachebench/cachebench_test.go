@@ -0,0 +1,42 @@
+package cachebench_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/daqiancode/cachelayer/cachebench"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	bench, err := cachebench.NewLocal()
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer bench.Close()
+	workload := cachebench.Workload{Records: 100, Ops: 500, Concurrency: 4, ReadRatio: 0.9, Skew: 1.2, Seed: 1}
+	results, err := bench.Run(context.Background(), workload)
+	assert.Nil(t, err)
+	if !assert.Len(t, results, len(cachebench.Strategies)) {
+		return
+	}
+	for i, v := range results {
+		assert.Equal(t, cachebench.Strategies[i], v.Strategy)
+		assert.Equal(t, workload.Ops, v.Reads+v.Writes)
+		assert.True(t, v.P99 >= v.P50)
+		assert.Zero(t, v.Errors, v.Err)
+	}
+	assert.Zero(t, results[0].HitRatio)
+	assert.Equal(t, int64(results[0].Reads), results[0].DBLoads)
+	assert.True(t, results[1].HitRatio > 0.5)
+	assert.True(t, results[1].DBLoads < int64(results[1].Reads))
+
+	var b strings.Builder
+	assert.Nil(t, cachebench.WriteTable(&b, results))
+	assert.Contains(t, b.String(), "redis_cache")
+	assert.Len(t, strings.Split(strings.TrimSpace(b.String()), "\n"), 4)
+
+	_, err = bench.Run(context.Background(), workload, "unknown")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,144 @@
+package cachelayer
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const DefaultScanBatchSize = 500
+
+//ClearByPattern delete keys matching pattern with SCAN+UNLINK in batches(never KEYS), return count of deleted keys.
+// batchesPerSecond limits the deleting rate, <=0 means unlimited. Every node of a Ring or Cluster is scanned
+func ClearByPattern(ctx context.Context, red redis.UniversalClient, pattern string, batchSize int64, batchesPerSecond int) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultScanBatchSize
+	}
+	var ticker *time.Ticker
+	if batchesPerSecond > 0 {
+		ticker = time.NewTicker(time.Second / time.Duration(batchesPerSecond))
+		defer ticker.Stop()
+	}
+	var deleted int64
+	var mu sync.Mutex
+	err := forEachNode(ctx, red, func(ctx context.Context, node redis.UniversalClient) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(ctx, cursor, pattern, batchSize).Result()
+			if err != nil {
+				return cacheError(err)
+			}
+			if len(keys) > 0 {
+				n, err := unlinkKeys(ctx, node, IsSharded(red), keys)
+				mu.Lock()
+				deleted += n
+				mu.Unlock()
+				if err != nil {
+					return err
+				}
+			}
+			cursor = next
+			if cursor == 0 {
+				return nil
+			}
+			if ticker != nil {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-ticker.C:
+				}
+			}
+		}
+	})
+	return deleted, err
+}
+
+//unlinkKeys one UNLINK per key if keys may be in different cluster slots
+func unlinkKeys(ctx context.Context, node redis.UniversalClient, perKey bool, keys []string) (int64, error) {
+	if !perKey {
+		n, err := node.Unlink(ctx, keys...).Result()
+		return n, cacheError(err)
+	}
+	p := node.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, v := range keys {
+		cmds[i] = p.Unlink(ctx, v)
+	}
+	_, err := p.Exec(ctx)
+	var n int64
+	for _, v := range cmds {
+		n += v.Val()
+	}
+	return n, cacheError(err)
+}
+
+//tablePattern pattern relative to cache keys of the table, eg. "status/*" -> "{prefix}/{table}/status/*"
+func (s *CacheBase[T, I]) tablePattern(pattern string) string {
+	return strings.ToLower(s.keyPrefix() + "/" + s.table + "/" + strings.TrimPrefix(pattern, "/"))
+}
+
+//ClearByPattern delete cache keys of this table matching pattern, eg. "status/*" deletes all index keys starting with field status
+func (s *RedisCache[T, I]) ClearByPattern(pattern string, batchSize int64, batchesPerSecond int) (int64, error) {
+	n, err := s.clearByPattern(pattern, batchSize, batchesPerSecond)
+	if n > 0 {
+		s.notify(CacheEvent{Type: EventMassInvalidation, Pattern: pattern, Keys: n})
+	}
+	return n, err
+}
+
+func (s *RedisCache[T, I]) clearByPattern(pattern string, batchSize int64, batchesPerSecond int) (int64, error) {
+	key := s.tablePattern(pattern)
+	s.generation.advance()
+	n, err := ClearByPattern(s.ctx, s.red.UniversalClient, key, batchSize, batchesPerSecond)
+	return n, s.wrapErr("clear_by_pattern", key, err)
+}
+
+//ClearByPattern delete cache keys of this table matching pattern, eg. "status/*" deletes all index keys starting with field status
+func (s *FullRedisCache[T, I]) ClearByPattern(pattern string, batchSize int64, batchesPerSecond int) (int64, error) {
+	n, err := s.clearByPattern(pattern, batchSize, batchesPerSecond)
+	if n > 0 {
+		s.notify(CacheEvent{Type: EventMassInvalidation, Pattern: pattern, Keys: n})
+	}
+	return n, err
+}
+
+func (s *FullRedisCache[T, I]) clearByPattern(pattern string, batchSize int64, batchesPerSecond int) (int64, error) {
+	key := s.tablePattern(pattern)
+	s.generation.advance()
+	n, err := ClearByPattern(s.ctx, s.red.UniversalClient, key, batchSize, batchesPerSecond)
+	return n, s.wrapErr("clear_by_pattern", key, err)
+}
+
+//ClearAll delete all cache keys of this table
+func (s *RedisCache[T, I]) ClearAll() error {
+	n, err := s.clearByPattern("*", DefaultScanBatchSize, 0)
+	if err == nil {
+		s.notify(CacheEvent{Type: EventTableFlush, Keys: n})
+	}
+	return err
+}
+
+//Refresh partial cache reloads records on demand, so refreshing is clearing all cache keys of this table
+func (s *RedisCache[T, I]) Refresh() error {
+	return s.ClearAll()
+}
+
+//ClearAll delete all cache keys of this table, including the full hash
+func (s *FullRedisCache[T, I]) ClearAll() error {
+	n, err := s.clearByPattern("*", DefaultScanBatchSize, 0)
+	if err == nil {
+		s.notify(CacheEvent{Type: EventTableFlush, Keys: n})
+	}
+	return err
+}
+
+//Refresh clear all cache keys of this table and reload full data from database
+func (s *FullRedisCache[T, I]) Refresh() error {
+	if err := s.ClearAll(); err != nil {
+		return err
+	}
+	return s.Load()
+}
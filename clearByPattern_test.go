@@ -0,0 +1,38 @@
+package cachelayer_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClearByPattern(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	counter := newCmdCounter()
+	red.AddHook(counter)
+	for i := 0; i < 250; i++ {
+		mr.Set(fmt.Sprintf("app/user/status=%d", i), "1")
+	}
+	mr.Set("app/user/id=1", "1")
+	mr.Set("app/group/status=1", "1")
+	deleted, err := cachelayer.ClearByPattern(context.Background(), red, "app/user/status=*", 100, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(250), deleted)
+	assert.ElementsMatch(t, []string{"app/group/status=1", "app/user/id=1"}, mr.Keys())
+	assert.Equal(t, 0, counter.Count("keys"))
+	assert.Greater(t, counter.Count("scan"), 0)
+	assert.Greater(t, counter.Count("unlink"), 0)
+
+	// rate limited deleting stops when ctx is done
+	for i := 0; i < 250; i++ {
+		mr.Set(fmt.Sprintf("app/user/status=%d", i), "1")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	deleted, err = cachelayer.ClearByPattern(ctx, red, "app/user/status=*", 100, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, deleted, int64(250))
+}
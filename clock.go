@@ -0,0 +1,102 @@
+package cachelayer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+//Clock source of time of caches and background jobs, replaced by a FakeClock in tests so they advance time instead of sleeping
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+//RealClock Clock of package time
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+//FakeClock Clock moving only by Advance and Set
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (s *FakeClock) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+//After channel receiving the time once the clock is advanced by d
+func (s *FakeClock) After(d time.Duration) <-chan time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	at := s.now.Add(d)
+	if d <= 0 {
+		ch <- s.now
+		return ch
+	}
+	s.waiters = append(s.waiters, fakeWaiter{at: at, ch: ch})
+	return ch
+}
+
+//Advance move the clock forward by d, firing due After channels in order
+func (s *FakeClock) Advance(d time.Duration) {
+	s.mu.Lock()
+	t := s.now.Add(d)
+	s.mu.Unlock()
+	s.Set(t)
+}
+
+//Set move the clock to t, firing due After channels in order
+func (s *FakeClock) Set(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = t
+	sort.SliceStable(s.waiters, func(i, j int) bool {
+		return s.waiters[i].at.Before(s.waiters[j].at)
+	})
+	var pending []fakeWaiter
+	for _, v := range s.waiters {
+		if v.at.After(t) {
+			pending = append(pending, v)
+			continue
+		}
+		v.ch <- t
+	}
+	s.waiters = pending
+}
+
+//Waiters count of After channels not fired yet, tests wait for a background job to block on the clock with it
+func (s *FakeClock) Waiters() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.waiters)
+}
+
+//SetClock clock of timestamps and time based features of the cache, default RealClock
+func (s *CacheBase[T, I]) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+func (s *CacheBase[T, I]) GetClock() Clock {
+	return s.clock
+}
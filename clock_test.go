@@ -0,0 +1,30 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := cachelayer.NewFakeClock(start)
+	a := c.After(time.Minute)
+	b := c.After(time.Hour)
+	assert.Equal(t, 2, c.Waiters())
+
+	c.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), <-a)
+	select {
+	case <-b:
+		t.Fatal("fired before due")
+	default:
+	}
+	assert.Equal(t, 1, c.Waiters())
+
+	c.Set(start.Add(2 * time.Hour))
+	assert.Equal(t, start.Add(2*time.Hour), <-b)
+	assert.Equal(t, start.Add(2*time.Hour), c.Now())
+}
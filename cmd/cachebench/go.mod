@@ -0,0 +1,32 @@
+module github.com/daqiancode/cachelayer/cmd/cachebench
+
+go 1.18
+
+require github.com/daqiancode/cachelayer/cachebench v0.0.0
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/alicebob/miniredis/v2 v2.23.0 // indirect
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/daqiancode/cachelayer v0.0.0 // indirect
+	github.com/daqiancode/jsoniter v1.1.13 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/go-redis/redis/v8 v8.11.5 // indirect
+	github.com/go-stack/stack v1.8.0 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/mattn/go-sqlite3 v1.14.12 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/yuin/gopher-lua v0.0.0-20210529063254-f4c35e4016d9 // indirect
+	go.mongodb.org/mongo-driver v1.9.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	gorm.io/driver/sqlite v1.3.6 // indirect
+	gorm.io/gorm v1.23.8 // indirect
+	gorm.io/plugin/dbresolver v1.2.2 // indirect
+)
+
+replace (
+	github.com/daqiancode/cachelayer => ../../
+	github.com/daqiancode/cachelayer/cachebench => ../../cachebench
+)
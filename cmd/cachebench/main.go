@@ -0,0 +1,53 @@
+// cachebench compares RedisCache, FullRedisCache and no cache on a workload, on an embedded redis and an in-memory sqlite database
+//
+//	cachebench -records 1000 -ops 10000 -read 0.9 -skew 1.1
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/daqiancode/cachelayer/cachebench"
+)
+
+func main() {
+	w := cachebench.DefaultWorkload
+	flag.IntVar(&w.Records, "records", w.Records, "rows seeded into the table")
+	flag.IntVar(&w.Ops, "ops", w.Ops, "operations run against each strategy")
+	flag.IntVar(&w.Concurrency, "c", w.Concurrency, "concurrent workers")
+	flag.Float64Var(&w.ReadRatio, "read", w.ReadRatio, "share of reads(0-1), the rest are updates")
+	flag.Float64Var(&w.Skew, "skew", w.Skew, "zipf exponent of picked ids, uniform if <= 1")
+	flag.Int64Var(&w.Seed, "seed", w.Seed, "random seed")
+	flag.DurationVar(&w.TTL, "ttl", w.TTL, "ttl of cache entries, a minute if 0")
+	strategies := flag.String("strategies", "", "comma separated strategies, all of no_cache,redis_cache,full_redis_cache if empty")
+	flag.Parse()
+	var ss []cachebench.Strategy
+	if *strategies != "" {
+		for _, v := range strings.Split(*strategies, ",") {
+			ss = append(ss, cachebench.Strategy(strings.TrimSpace(v)))
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	bench, err := cachebench.NewLocal()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer bench.Close()
+	results, err := bench.Run(ctx, w, ss...)
+	cachebench.WriteTable(os.Stdout, results)
+	for _, v := range results {
+		if v.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %d errors, first: %v\n", v.Strategy, v.Errors, v.Err)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,281 @@
+// cachectl inspects and maintains cachelayer keys in redis
+//
+//	cachectl [flags] keys <table>      list cache keys of table with ttl
+//	cachectl [flags] ttl <key>         show ttl of key
+//	cachectl [flags] get <key>         dump decoded entry
+//	cachectl [flags] clear <table>     delete all cache keys of table
+//	cachectl [flags] warm <table>      ask running services to warm up table
+//	cachectl [flags] verify <table>    report inconsistent entries of table
+//	cachectl [flags] memory <table>    estimate redis memory used by table
+//	cachectl explain <key>             describe what key caches
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:6379", "redis address")
+	password := flag.String("password", "", "redis password")
+	db := flag.Int("db", 0, "redis database")
+	prefix := flag.String("prefix", "", "cache key prefix")
+	batch := flag.Int64("batch", cachelayer.DefaultScanBatchSize, "SCAN batch size")
+	rate := flag.Int("rate", 0, "max batches per second when clearing, 0 means unlimited")
+	samples := flag.Int("samples", cachelayer.DefaultMemorySamples, "keys measured by MEMORY USAGE of memory command")
+	admin := flag.String("admin", "", "base url of a service AdminHandler, verify diffs against database through it, eg. http://svc:8080/debug/cache")
+	flag.StringVar(&nullPlaceholder, "null", "", "payload of cached \"not found\" entries set by SetNullPlaceholder of the service, null and !null are always recognized")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		usage()
+		os.Exit(2)
+	}
+	red := redis.NewClient(&redis.Options{Addr: *addr, Password: *password, DB: *db})
+	defer red.Close()
+	ctx := context.Background()
+	cmd, arg := flag.Arg(0), flag.Arg(1)
+	var err error
+	switch cmd {
+	case "keys":
+		err = listKeys(ctx, os.Stdout, red, tablePattern(*prefix, arg), *batch)
+	case "explain":
+		fmt.Println(cachelayer.ExplainKey(arg))
+	case "ttl":
+		err = showTTL(ctx, os.Stdout, red, arg)
+	case "get":
+		err = dump(ctx, os.Stdout, red, arg)
+	case "clear":
+		var n int64
+		n, err = cachelayer.ClearByPattern(ctx, red, tablePattern(*prefix, arg), *batch, *rate)
+		fmt.Printf("%d keys deleted\n", n)
+	case "warm":
+		var n int64
+		n, err = cachelayer.RequestWarmUp(ctx, red, *prefix, arg)
+		fmt.Printf("warm-up requested, %d subscribers\n", n)
+	case "verify":
+		if *admin != "" {
+			err = verifyRemote(ctx, os.Stdout, *admin, arg)
+		} else {
+			err = verifyKeys(ctx, os.Stdout, red, tablePattern(*prefix, arg), *batch)
+		}
+	case "memory":
+		var report cachelayer.MemoryReport
+		report, err = cachelayer.MemoryUsage(ctx, red, *prefix, arg, *samples)
+		fmt.Printf("table %s: %d keys, ~%d bytes(%d keys sampled, %d bytes per key)\n", report.Table, report.Keys, report.EstimatedBytes, report.Sampled, report.AvgKeyBytes())
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+//nullPlaceholder custom placeholder of cached "not found" entries, see -null
+var nullPlaceholder string
+
+//isNull whether raw is a cached "not found"
+func isNull(raw string) bool {
+	return cachelayer.IsNullPlaceholder(raw) || (nullPlaceholder != "" && raw == nullPlaceholder)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: cachectl [flags] <command> <arg>
+
+commands:
+  keys <table>   list cache keys of table with ttl
+  ttl <key>      show ttl of key
+  get <key>      dump decoded entry
+  clear <table>  delete all cache keys of table
+  warm <table>   ask running services to warm up table
+  verify <table> report inconsistent entries of table: with -admin the service diffs
+                 them against database, otherwise entries are checked in redis only
+  memory <table> estimate redis memory used by table from sampled keys
+  explain <key>  describe what key caches: table, index fields and values
+
+flags:
+`)
+	flag.PrintDefaults()
+}
+
+func tablePattern(prefix, table string) string {
+	return strings.ToLower(prefix + "/" + table + "/*")
+}
+
+func formatTTL(ttl time.Duration) string {
+	switch ttl {
+	case -2:
+		return "missing"
+	case -1:
+		return "persistent"
+	}
+	return ttl.String()
+}
+
+func listKeys(ctx context.Context, w io.Writer, red *redis.Client, pattern string, batch int64) error {
+	iter := red.Scan(ctx, 0, pattern, batch).Iterator()
+	for iter.Next(ctx) {
+		ttl, err := red.PTTL(ctx, iter.Val()).Result()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\n", iter.Val(), formatTTL(ttl))
+	}
+	return iter.Err()
+}
+
+func showTTL(ctx context.Context, w io.Writer, red *redis.Client, key string) error {
+	ttl, err := red.PTTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, formatTTL(ttl))
+	return nil
+}
+
+func dump(ctx context.Context, w io.Writer, red *redis.Client, key string) error {
+	typ, err := red.Type(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	switch typ {
+	case "none":
+		return fmt.Errorf("key %s does not exist", key)
+	case "string":
+		raw, err := red.Get(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		v = decode(raw)
+	case "hash":
+		raw, err := red.HGetAll(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		m := make(map[string]interface{}, len(raw))
+		for k, u := range raw {
+			m[k] = decode(u)
+		}
+		v = m
+	case "set":
+		if v, err = red.SMembers(ctx, key).Result(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported type %s of key %s", typ, key)
+	}
+	y, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, string(y))
+	return nil
+}
+
+//decode json payload, keep raw string if it is not json
+func decode(raw string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return raw
+	}
+	return v
+}
+
+//verifyRemote print the report of GET {admin}/caches/{name}/verify, only the service can load records from database
+func verifyRemote(ctx context.Context, w io.Writer, admin, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(admin, "/")+"/caches/"+name+"/verify", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("verify %s: %s %s", name, resp.Status, body)
+	}
+	var report cachelayer.VerifyReport
+	if err = json.Unmarshal(body, &report); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "table %s: %d keys, %d stale, %d orphaned, %d malformed\n", report.Table, report.Keys, len(report.Stale), len(report.Orphaned), len(report.Malformed))
+	printKeys(w, "stale", report.Stale)
+	printKeys(w, "orphaned", report.Orphaned)
+	printKeys(w, "malformed", report.Malformed)
+	return nil
+}
+
+//verifyKeys check entries without database: string and hash entries must be json or cached "not found", and every key must expire
+func verifyKeys(ctx context.Context, w io.Writer, red *redis.Client, pattern string, batch int64) error {
+	var keys, malformed, persistent []string
+	nulls := 0
+	iter := red.Scan(ctx, 0, pattern, batch).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		keys = append(keys, key)
+		ttl, err := red.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if ttl == -1 {
+			persistent = append(persistent, key)
+		}
+		typ, err := red.Type(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case "string":
+			raw, err := red.Get(ctx, key).Result()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			if err == nil && isNull(raw) {
+				nulls++
+			} else if err == nil && !json.Valid([]byte(raw)) {
+				malformed = append(malformed, key)
+			}
+		case "hash":
+			raw, err := red.HGetAll(ctx, key).Result()
+			if err != nil {
+				return err
+			}
+			for field, v := range raw {
+				if !json.Valid([]byte(v)) {
+					malformed = append(malformed, key+"#"+field)
+				}
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d keys, %d null, %d persistent, %d malformed\n", len(keys), nulls, len(persistent), len(malformed))
+	printKeys(w, "persistent", persistent)
+	printKeys(w, "malformed", malformed)
+	return nil
+}
+
+func printKeys(w io.Writer, kind string, keys []string) {
+	for _, v := range keys {
+		fmt.Fprintf(w, "%s\t%s\n", kind, v)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { red.Close() })
+	return mr, red
+}
+
+func TestKeysAndTTL(t *testing.T) {
+	mr, red := newRedis(t)
+	ctx := context.Background()
+	mr.Set("app/user/id=1", `{"ID":1}`)
+	mr.SetTTL("app/user/id=1", time.Minute)
+	mr.Set("app/user/email=a@x.com", "1")
+	mr.Set("app/group/id=1", `{"ID":1}`)
+	assert.Equal(t, "app/user/*", tablePattern("App", "User"))
+	var out bytes.Buffer
+	assert.Nil(t, listKeys(ctx, &out, red, tablePattern("app", "user"), 10))
+	assert.ElementsMatch(t, []string{"app/user/id=1\t1m0s", "app/user/email=a@x.com\tpersistent"}, lines(out.String()))
+
+	out.Reset()
+	assert.Nil(t, showTTL(ctx, &out, red, "app/user/none"))
+	assert.Equal(t, "missing\n", out.String())
+}
+
+func TestDump(t *testing.T) {
+	mr, red := newRedis(t)
+	ctx := context.Background()
+	mr.Set("app/user/id=1", `{"ID":1,"Name":"tom"}`)
+	mr.HSet("app/user/full", "1", `{"ID":1}`)
+	mr.SAdd("app/user/id=1:refs", "app/user/id=1")
+	var out bytes.Buffer
+	assert.Nil(t, dump(ctx, &out, red, "app/user/id=1"))
+	assert.JSONEq(t, `{"ID":1,"Name":"tom"}`, out.String())
+	out.Reset()
+	assert.Nil(t, dump(ctx, &out, red, "app/user/full"))
+	assert.JSONEq(t, `{"1":{"ID":1}}`, out.String())
+	out.Reset()
+	assert.Nil(t, dump(ctx, &out, red, "app/user/id=1:refs"))
+	assert.JSONEq(t, `["app/user/id=1"]`, out.String())
+	assert.NotNil(t, dump(ctx, &out, red, "app/user/id=2"))
+}
+
+func TestVerifyKeys(t *testing.T) {
+	mr, red := newRedis(t)
+	mr.Set("app/user/id=1", `{"ID":1}`)
+	mr.SetTTL("app/user/id=1", time.Minute)
+	mr.Set("app/user/id=2", cachelayer.DefaultNullPlaceholder)
+	mr.SetTTL("app/user/id=2", time.Minute)
+	mr.Set("app/user/id=3", "{broken")
+	var out bytes.Buffer
+	assert.Nil(t, verifyKeys(context.Background(), &out, red, "app/user/*", 10))
+	r := lines(out.String())
+	assert.Equal(t, "3 keys, 1 null, 1 persistent, 1 malformed", r[0])
+	assert.ElementsMatch(t, []string{"persistent\tapp/user/id=3", "malformed\tapp/user/id=3"}, r[1:])
+}
+
+func TestVerifyRemote(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/debug/cache/caches/user/verify" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(cachelayer.VerifyReport{Table: "user", Keys: 2, Stale: []string{"app/user/id=1"}})
+	}))
+	defer srv.Close()
+	var out bytes.Buffer
+	assert.Nil(t, verifyRemote(context.Background(), &out, srv.URL+"/debug/cache/", "user"))
+	assert.Equal(t, []string{"table user: 2 keys, 1 stale, 0 orphaned, 0 malformed", "stale\tapp/user/id=1"}, lines(out.String()))
+	assert.NotNil(t, verifyRemote(context.Background(), &out, srv.URL, "user"))
+}
+
+func lines(s string) []string {
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,110 @@
+package cachelayer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	coalesceLockSuffix   = ":lock"
+	coalesceStreamSuffix = ":loaded"
+)
+
+//SetCoalesce coalesce database loads of a missed key across processes: the process holding a short lock loads the record and
+// publishes it on a redis stream, other processes block on the stream up to wait instead of querying database.
+// Waiters load the record themselves if the holder fails or is slower than wait. 0 disables it
+func (s *RedisCache[T, I]) SetCoalesce(wait time.Duration) {
+	s.coalesceWait = wait
+}
+
+//coalesceStreamKey stream of the round of token, eg. app/user/id/1:loaded:{token}.
+// Every round publishes on its own, so waiters never read the result of an earlier round
+func coalesceStreamKey(key, token string) string {
+	return key + coalesceStreamSuffix + ":" + token
+}
+
+//coalesced run load for key in one process, others wait for its result. The lock holds the token of the round
+func (s *RedisCache[T, I]) coalesced(key string, load func() (T, bool, error)) (T, bool, error) {
+	lockKey := key + coalesceLockSuffix
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		s.report("coalesce", err)
+		return load()
+	}
+	token := hex.EncodeToString(b)
+	p := s.red.Pipeline()
+	locked := p.SetNX(s.ctx, lockKey, token, s.coalesceWait)
+	holder := p.Get(s.ctx, lockKey)
+	if _, err := p.Exec(s.ctx); err != nil && err != redis.Nil {
+		s.report("coalesce", cacheError(err))
+		return load()
+	}
+	if locked.Val() {
+		r, exists, err := load()
+		s.report("coalesce", s.publishLoaded(coalesceStreamKey(key, token), r, exists, err))
+		s.report("coalesce", cacheError(releaseLease.Run(s.ctx, s.red, []string{lockKey}, token).Err()))
+		return r, exists, err
+	}
+	// released right after SETNX failed, the round is over
+	if holder.Err() != nil {
+		return load()
+	}
+	r, exists, ok, err := s.waitLoaded(coalesceStreamKey(key, holder.Val()))
+	if err != nil {
+		s.report("coalesce", err)
+	}
+	if ok {
+		s.trace(TraceCoalesced, nil, key)
+		return r, exists, nil
+	}
+	return load()
+}
+
+//publishLoaded add the load result to stream: field v holds the record, null means not found, err means the load failed
+func (s *RedisCache[T, I]) publishLoaded(streamKey string, obj T, exists bool, loadErr error) error {
+	values := map[string]interface{}{"null": 1}
+	if loadErr != nil {
+		values = map[string]interface{}{"err": loadErr.Error()}
+	} else if exists {
+		y, err := marshal(s.red.serializer, obj)
+		if err != nil {
+			return err
+		}
+		values = map[string]interface{}{"v": y}
+	}
+	p := s.red.Pipeline()
+	p.XAdd(s.ctx, &redis.XAddArgs{Stream: streamKey, MaxLen: 1, Values: values})
+	p.PExpire(s.ctx, streamKey, s.coalesceWait)
+	_, err := p.Exec(s.ctx)
+	return cacheError(err)
+}
+
+//waitLoaded block up to coalesceWait for the result of the lock holder, ok is false if there is no usable result
+func (s *RedisCache[T, I]) waitLoaded(streamKey string) (obj T, exists, ok bool, err error) {
+	streams, err := s.red.XRead(s.ctx, &redis.XReadArgs{Streams: []string{streamKey, "0"}, Count: 1, Block: s.coalesceWait}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			err = nil
+		}
+		return obj, false, false, cacheError(err)
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return obj, false, false, nil
+	}
+	values := streams[0].Messages[0].Values
+	if _, failed := values["err"]; failed {
+		return obj, false, false, nil
+	}
+	y, found := values["v"].(string)
+	if !found {
+		return obj, false, true, nil
+	}
+	if err = unmarshal(s.red.serializer, y, &obj); err != nil {
+		return obj, false, false, err
+	}
+	return obj, true, true, nil
+}
@@ -0,0 +1,82 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+//slowDB memDB whose Get signals entered and blocks until release is closed
+type slowDB struct {
+	*memDB
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s slowDB) Get(id uint) (member, bool, error) {
+	s.entered <- struct{}{}
+	<-s.release
+	return s.memDB.Get(id)
+}
+
+func TestCoalesce(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	holderDB := slowDB{memDB: newMemDB(member{ID: 1, Name: "tom"}), entered: make(chan struct{}, 1), release: make(chan struct{})}
+	holder := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", holderDB, red, time.Minute)
+	holder.SetCoalesce(5 * time.Second)
+	waiterRed := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer waiterRed.Close()
+	counter := newCmdCounter()
+	waiterRed.AddHook(counter)
+	waiterDB := newMemDB(member{ID: 1, Name: "tom"})
+	waiter := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", waiterDB, waiterRed, time.Minute)
+	waiter.SetCoalesce(5 * time.Second)
+
+	loaded := make(chan member, 2)
+	go func() {
+		r, _, err := holder.Get(1)
+		assert.Nil(t, err)
+		loaded <- r
+	}()
+	<-holderDB.entered
+	go func() {
+		r, _, err := waiter.Get(1)
+		assert.Nil(t, err)
+		loaded <- r
+	}()
+	for counter.Count("xread") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(holderDB.release)
+	assert.Equal(t, "tom", (<-loaded).Name)
+	assert.Equal(t, "tom", (<-loaded).Name)
+	// the waiter took the record of the holder
+	assert.Equal(t, 0, waiterDB.Queries())
+	assert.False(t, mr.Exists("app/member/id/1:lock"))
+}
+
+func TestCoalesceRounds(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	db := newMemDB(member{ID: 1, Name: "old"})
+	cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	cache.SetCoalesce(50 * time.Millisecond)
+	r, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "old", r.Name)
+
+	_, err = cache.Update(1, map[string]interface{}{"name": "new"})
+	assert.Nil(t, err)
+	assert.Nil(t, cache.ClearCache(r))
+	// another process holds the lock of a new round, the stream of the finished round must not be read
+	mr.Set("app/member/id/1:lock", "0a1b")
+	r, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "new", r.Name)
+	// nor is the lock of that round released
+	v, err := mr.Get("app/member/id/1:lock")
+	assert.Nil(t, err)
+	assert.Equal(t, "0a1b", v)
+}
@@ -0,0 +1,236 @@
+package cachelayer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	serializersMu sync.RWMutex
+	serializers   = map[string]Serializer{"json": &JsonSerializer{}, "std_json": NewJsonSerializer(StdJson)}
+)
+
+//RegisterSerializer make serializer available to CacheConfig.Serializer by name
+func RegisterSerializer(name string, serializer Serializer) {
+	serializersMu.Lock()
+	defer serializersMu.Unlock()
+	serializers[name] = serializer
+}
+
+func GetSerializer(name string) (Serializer, bool) {
+	serializersMu.RLock()
+	defer serializersMu.RUnlock()
+	s, ok := serializers[name]
+	return s, ok
+}
+
+//CacheConfig declaration of a cache, durations are written like "10m" in yaml
+type CacheConfig struct {
+	Name    string        `yaml:"name"`
+	Table   string        `yaml:"table"`
+	Prefix  string        `yaml:"prefix"`
+	IdField string        `yaml:"idField"`
+	TTL     time.Duration `yaml:"ttl"`
+	//NullTTL ttl of cached "not found" entries, 0 means same as ttl
+	NullTTL time.Duration `yaml:"nullTTL"`
+	//NullPlaceholder payload of cached "not found" entries, eg. !null once every process reads it, default null. See RedisJson.SetNullPlaceholder
+	NullPlaceholder string `yaml:"nullPlaceholder"`
+	//Serializer registered serializer name, default json. std_json serializes by encoding/json
+	Serializer string `yaml:"serializer"`
+	//Json field naming of the json serializer instead of DefaultJsonOptions, unset options are zero
+	Json *JsonOptions `yaml:"json"`
+	//Full full cache(FullRedisCache) or partial cache(RedisCache)
+	Full bool `yaml:"full"`
+	//Expiration sliding(default), absolute or sliding_with_max
+	Expiration string        `yaml:"expiration"`
+	MaxTTL     time.Duration `yaml:"maxTTL"`
+	//Grace serve stale entries up to grace after ttl when database fails, partial cache only
+	Grace         time.Duration `yaml:"grace"`
+	NegativeCache *bool         `yaml:"negativeCache"`
+	ReverseIndex  bool          `yaml:"reverseIndex"`
+	NotFoundError bool          `yaml:"notFoundError"`
+	KeepTTL       bool          `yaml:"keepTTL"`
+	//CanonicalKeys encode index values of keys canonically, see CacheBase.SetCanonicalKeys
+	CanonicalKeys bool `yaml:"canonicalKeys"`
+}
+
+//Config caches of a service
+//
+//	prefix: app
+//	caches:
+//	  - table: user
+//	    idField: Id
+//	    ttl: 10m
+//	    nullTTL: 30s
+//	  - table: category
+//	    idField: Id
+//	    ttl: 1h
+//	    full: true
+type Config struct {
+	//Prefix default prefix of caches
+	Prefix string        `yaml:"prefix"`
+	Caches []CacheConfig `yaml:"caches"`
+}
+
+func LoadConfig(r io.Reader) (*Config, error) {
+	var cfg Config
+	if err := yaml.NewDecoder(r).Decode(&cfg); err != nil {
+		return nil, err
+	}
+	for i := range cfg.Caches {
+		if cfg.Caches[i].Prefix == "" {
+			cfg.Caches[i].Prefix = cfg.Prefix
+		}
+		if cfg.Caches[i].Name == "" {
+			cfg.Caches[i].Name = cfg.Caches[i].Table
+		}
+		if err := cfg.Caches[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
+
+func LoadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadConfig(f)
+}
+
+//Cache config of cache by name
+func (s *Config) Cache(name string) (CacheConfig, bool) {
+	for _, v := range s.Caches {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return CacheConfig{}, false
+}
+
+func (s CacheConfig) Validate() error {
+	if s.Table == "" {
+		return fmt.Errorf("cachelayer config: table is required")
+	}
+	if s.IdField == "" {
+		return fmt.Errorf("cachelayer config %s: idField is required", s.Table)
+	}
+	if s.TTL <= 0 {
+		return fmt.Errorf("cachelayer config %s: ttl must be positive", s.Table)
+	}
+	if _, err := s.expirationPolicy(); err != nil {
+		return err
+	}
+	if s.Serializer != "" {
+		if _, ok := GetSerializer(s.Serializer); !ok {
+			return fmt.Errorf("cachelayer config %s: unknown serializer %s", s.Table, s.Serializer)
+		}
+	}
+	if s.NullPlaceholder != "" && s.NullPlaceholder != LegacyNullPlaceholder && !strings.HasPrefix(s.NullPlaceholder, "!") {
+		return fmt.Errorf("cachelayer config %s: nullPlaceholder must start with '!'", s.Table)
+	}
+	if s.Json != nil && s.Serializer != "" && s.Serializer != "json" {
+		return fmt.Errorf("cachelayer config %s: json options need serializer json", s.Table)
+	}
+	if s.Json != nil && s.Json.Naming != "" {
+		if _, ok := NameMapperOf(s.Json.Naming); !ok {
+			return fmt.Errorf("cachelayer config %s: unknown naming %s", s.Table, s.Json.Naming)
+		}
+	}
+	return nil
+}
+
+//serializer serializer of the config, false for the default
+func (s CacheConfig) serializer() (Serializer, bool) {
+	if s.Json != nil {
+		return NewJsonSerializer(NewJsoniterEngine(*s.Json)), true
+	}
+	if s.Serializer == "" {
+		return nil, false
+	}
+	return GetSerializer(s.Serializer)
+}
+
+func (s CacheConfig) expirationPolicy() (ExpirationPolicy, error) {
+	for _, v := range []ExpirationPolicy{ExpirationSliding, ExpirationAbsolute, ExpirationSlidingWithMax} {
+		if s.Expiration == "" || s.Expiration == v.String() {
+			return v, nil
+		}
+	}
+	return ExpirationSliding, fmt.Errorf("cachelayer config %s: unknown expiration %s", s.Table, s.Expiration)
+}
+
+//TableCache cache built from config, implemented by RedisCache and FullRedisCache
+type TableCache[T Table[I], I IDType] interface {
+	DBCRUD[T, I]
+	ManagedCache
+}
+
+//BuildCache build RedisCache or FullRedisCache(cfg.Full) from config
+func BuildCache[T Table[I], I IDType](cfg CacheConfig, db FullDBCache[T, I], red redis.UniversalClient) (TableCache[T, I], error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Full {
+		return BuildFullRedisCache[T, I](cfg, db, red)
+	}
+	return BuildRedisCache[T, I](cfg, db, red)
+}
+
+//BuildRedisCache build partial cache from config
+func BuildRedisCache[T Table[I], I IDType](cfg CacheConfig, db DBCRUD[T, I], red redis.UniversalClient) (*RedisCache[T, I], error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	c := NewRedisCache[T, I](cfg.Prefix, cfg.Table, cfg.IdField, db, red, cfg.TTL)
+	applyBaseConfig(cfg, c.CacheBase)
+	policy, _ := cfg.expirationPolicy()
+	c.SetExpirationPolicy(policy, cfg.MaxTTL)
+	c.SetNullTTL(cfg.NullTTL)
+	if cfg.NullPlaceholder != "" {
+		c.SetNullPlaceholder(cfg.NullPlaceholder)
+	}
+	c.SetGrace(cfg.Grace)
+	c.SetKeepTTL(cfg.KeepTTL)
+	if serializer, ok := cfg.serializer(); ok {
+		c.SetSerializer(serializer)
+	}
+	return c, nil
+}
+
+//BuildFullRedisCache build full cache from config
+func BuildFullRedisCache[T Table[I], I IDType](cfg CacheConfig, db FullDBCache[T, I], red redis.UniversalClient) (*FullRedisCache[T, I], error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	c := NewFullRedisCache[T, I](cfg.Prefix, cfg.Table, cfg.IdField, db, red, cfg.TTL)
+	applyBaseConfig(cfg, c.CacheBase)
+	policy, _ := cfg.expirationPolicy()
+	c.SetExpirationPolicy(policy, cfg.MaxTTL)
+	c.SetNullTTL(cfg.NullTTL)
+	if cfg.NullPlaceholder != "" {
+		c.SetNullPlaceholder(cfg.NullPlaceholder)
+	}
+	if serializer, ok := cfg.serializer(); ok {
+		c.SetSerializer(serializer)
+	}
+	return c, nil
+}
+
+func applyBaseConfig[T Table[I], I IDType](cfg CacheConfig, base *CacheBase[T, I]) {
+	if cfg.NegativeCache != nil {
+		base.SetNegativeCache(*cfg.NegativeCache)
+	}
+	base.SetReverseIndex(cfg.ReverseIndex)
+	base.SetNotFoundError(cfg.NotFoundError)
+	base.SetCanonicalKeys(cfg.CanonicalKeys)
+}
@@ -0,0 +1,106 @@
+package cachelayer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := cachelayer.LoadConfig(strings.NewReader(`
+prefix: app
+caches:
+  - table: user
+    idField: Id
+    ttl: 10m
+    nullTTL: 30s
+    nullPlaceholder: "!null"
+    negativeCache: false
+  - name: category_full
+    table: category
+    prefix: shop
+    idField: Id
+    ttl: 1h
+    full: true
+    expiration: sliding_with_max
+    maxTTL: 2h
+`))
+	assert.Nil(t, err)
+	user, ok := cfg.Cache("user")
+	assert.True(t, ok)
+	assert.Equal(t, "app", user.Prefix)
+	assert.Equal(t, 10*time.Minute, user.TTL)
+	assert.Equal(t, 30*time.Second, user.NullTTL)
+	assert.Equal(t, cachelayer.DefaultNullPlaceholder, user.NullPlaceholder)
+	assert.False(t, *user.NegativeCache)
+	category, ok := cfg.Cache("category_full")
+	assert.True(t, ok)
+	assert.Equal(t, "shop", category.Prefix)
+	assert.True(t, category.Full)
+	assert.Equal(t, 2*time.Hour, category.MaxTTL)
+
+	_, err = cachelayer.LoadConfig(strings.NewReader(`
+caches:
+  - table: user
+    idField: Id
+    ttl: 10m
+    serializer: unknown
+`))
+	assert.NotNil(t, err)
+
+	_, err = cachelayer.LoadConfig(strings.NewReader(`
+caches:
+  - table: user
+    idField: Id
+    ttl: 10m
+    nullPlaceholder: missing
+`))
+	assert.NotNil(t, err)
+}
+
+func TestJsonOptions(t *testing.T) {
+	type item struct {
+		CategoryId uint
+		Name       string `json:"title"`
+	}
+	obj := item{CategoryId: 3, Name: "pen"}
+	for _, c := range []struct {
+		s    cachelayer.Serializer
+		want string
+	}{
+		{&cachelayer.JsonSerializer{}, `{"categoryId":3,"title":"pen"}`},
+		{cachelayer.NewJsonSerializer(cachelayer.StdJson), `{"CategoryId":3,"title":"pen"}`},
+		{cachelayer.NewJsonSerializer(cachelayer.NewJsoniterEngine(cachelayer.JsonOptions{})), `{"CategoryId":3,"title":"pen"}`},
+	} {
+		data, err := c.s.Marshal(obj)
+		assert.Nil(t, err)
+		assert.Equal(t, c.want, data)
+		var r item
+		assert.Nil(t, c.s.Unmarshal(data, &r))
+		assert.Equal(t, obj, r)
+	}
+
+	cfg, err := cachelayer.LoadConfig(strings.NewReader(`
+caches:
+  - table: item
+    idField: Id
+    ttl: 10m
+    json:
+      caseSensitive: true
+`))
+	assert.Nil(t, err)
+	assert.True(t, cfg.Caches[0].Json.CaseSensitive)
+	_, err = cachelayer.LoadConfig(strings.NewReader(`
+caches:
+  - table: item
+    idField: Id
+    ttl: 10m
+    serializer: std_json
+    json:
+      decapitalize: true
+`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,52 @@
+package cachelayer_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+type ctxDB struct {
+	cachelayer.DBCRUD[keyUser, uint]
+	ctx context.Context
+}
+
+func (s *ctxDB) WithContext(ctx context.Context) cachelayer.DBCRUD[keyUser, uint] {
+	return &ctxDB{ctx: ctx}
+}
+
+func (s *ctxDB) Get(id uint) (keyUser, bool, error) {
+	if s.ctx == nil {
+		return keyUser{}, false, nil
+	}
+	return keyUser{ID: s.ctx.Value(ctxKey{}).(uint)}, true, nil
+}
+
+func TestWithContextConcurrent(t *testing.T) {
+	cache := cachelayer.NewRedisCache[keyUser, uint]("app", "user", "ID", &ctxDB{}, nil, time.Minute)
+	var wg sync.WaitGroup
+	for i := uint(1); i <= 20; i++ {
+		wg.Add(1)
+		go func(id uint) {
+			defer wg.Done()
+			ctx := context.WithValue(context.Background(), ctxKey{}, id)
+			c := cache.WithContext(ctx)
+			assert.Equal(t, ctx, c.GetCtx())
+			r, exists, err := c.GetFromDB(id)
+			assert.Nil(t, err)
+			assert.True(t, exists)
+			assert.Equal(t, id, r.ID)
+		}(i)
+	}
+	wg.Wait()
+	// the shared cache keeps its own context
+	assert.Equal(t, context.Background(), cache.GetCtx())
+	_, exists, _ := cache.GetFromDB(1)
+	assert.False(t, exists)
+}
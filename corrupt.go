@@ -0,0 +1,66 @@
+package cachelayer
+
+import "errors"
+
+//CorruptPolicy how reads treat cached entries which can't be unmarshaled
+type CorruptPolicy int
+
+const (
+	//CorruptFail fail the whole read with ErrSerialization, default of RedisJson
+	CorruptFail CorruptPolicy = iota
+	//CorruptAsMiss delete corrupt entries and read them as misses, so their records are loaded from database and cached again.
+	// One bad write can't take down list endpoints. Default of RedisCache
+	CorruptAsMiss
+)
+
+//SetCorruptPolicy choose CorruptFail(default) or CorruptAsMiss for MGetJson, onCorrupt(may be nil) is called with the keys of
+// corrupt entries and the first unmarshal error
+func (s *RedisJson[T]) SetCorruptPolicy(policy CorruptPolicy, onCorrupt func(keys []string, err error)) {
+	s.corruptPolicy = policy
+	s.onCorrupt = onCorrupt
+}
+
+//dropCorrupt delete corrupt entries, best effort since they are reloaded anyway
+func (s *RedisJson[T]) dropCorrupt(keys []string, err error) {
+	if s.onCorrupt != nil {
+		s.onCorrupt(keys, err)
+	}
+	if _, err := unlinkKeys(s.ctx, s.UniversalClient, IsSharded(s.UniversalClient), keys); err != nil && s.onCorrupt != nil {
+		s.onCorrupt(nil, err)
+	}
+}
+
+//SetCorruptPolicy choose CorruptAsMiss(default) or CorruptFail for reads of this cache. With CorruptAsMiss corrupt entries heal
+// themselves: they are deleted, counted by Stats().Corrupted, reported to the error handler, sent to webhooks as EventCorruption
+// and to the OnCorruption hook, and their records are loaded from database and cached again
+func (s *RedisCache[T, I]) SetCorruptPolicy(policy CorruptPolicy) {
+	s.red.SetCorruptPolicy(policy, s.corrupted)
+}
+
+//OnCorruption call fn with the key and unmarshal error of every corrupt entry found
+func (s *CacheBase[T, I]) OnCorruption(fn func(key string, err error)) {
+	s.onCorruption = fn
+}
+
+//corrupted count, report and notify corrupt entries of keys
+func (s *CacheBase[T, I]) corrupted(keys []string, err error) {
+	s.stats.corrupted(len(keys))
+	s.report("corrupt", err)
+	for _, v := range keys {
+		s.notify(CacheEvent{Type: EventCorruption, Pattern: v, Keys: 1, Error: err.Error()})
+		if s.onCorruption != nil {
+			s.onCorruption(v, err)
+		}
+	}
+}
+
+//heal delete the entry of key if err is an unmarshal error and the policy is CorruptAsMiss, return whether key is read as a miss
+func (s *RedisCache[T, I]) heal(key string, err error) bool {
+	if s.red.corruptPolicy != CorruptAsMiss || !errors.Is(err, ErrSerialization) {
+		return false
+	}
+	s.corrupted([]string{key}, err)
+	_, err = s.delKeys(s.red.UniversalClient, s.red.withAuxKeys([]string{key})...)
+	s.report("corrupt", err)
+	return true
+}
@@ -0,0 +1,180 @@
+package cachelayer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//Counter database counting records of an index. Used by Count
+type Counter interface {
+	Count(index Index) (int64, error)
+}
+
+//CountMode how writes through the cache treat cached counts
+type CountMode int
+
+const (
+	//CountInvalidate every write deletes cached counts of the indexes of written records
+	CountInvalidate CountMode = iota
+	//CountIncrement Create and Delete increment/decrement cached counts instead of deleting them, so hot counters stay warm.
+	// Other writes still delete them since index values may change
+	CountIncrement
+)
+
+//SetCountMode choose CountInvalidate(default) or CountIncrement
+func (s *RedisCache[T, I]) SetCountMode(mode CountMode) {
+	s.countMode = mode
+}
+
+//CountKey integer key of the count of index: {prefix}/{table}/count/{field1}/{value1}..., {prefix}/{table}/count for an empty index
+func (s *CacheBase[T, I]) CountKey(index Index) string {
+	base := strings.ToLower(s.keyPrefix() + "/" + s.table)
+	return base + "/count" + strings.TrimPrefix(s.MakeCacheKey(index), base)
+}
+
+//isCountKey whether key is a count key of the table
+func (s *CacheBase[T, I]) isCountKey(key string) bool {
+	base := s.CountKey(Index{})
+	return key == base || strings.HasPrefix(key, base+"/")
+}
+
+//CountKeys count keys of the whole table and of every index of objs
+func (s *RedisCache[T, I]) CountKeys(objs ...T) []string {
+	if len(objs) == 0 {
+		return nil
+	}
+	keys := []string{s.CountKey(Index{})}
+	for _, v := range objs {
+		for _, index := range v.ListIndexes() {
+			keys = append(keys, s.CountKey(index))
+		}
+	}
+	return UniqueStrings(keys)
+}
+
+//countLoadingPrefix prefix of the marker a Count miss claims the count key with(SET NX) before counting in database.
+// Writes delete the marker, so a count read before them is not cached
+const countLoadingPrefix = "!loading:"
+
+//countLoadingTTL ttl of the marker, bounds how long counts are not cached if the loading process dies
+const countLoadingTTL = 10 * time.Second
+
+//setCountIfClaimed set KEYS[1] to ARGV[2] with ttl ARGV[3] ms only if it still holds the marker ARGV[1]
+var setCountIfClaimed = newScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3]) end
+return 0
+`)
+
+//Count count of records of index(all records if empty), cached as an integer key. The database must implement Counter.
+// A count is cached only if no write of the index was made while counting, see countLoadingPrefix
+func (s *RedisCache[T, I]) Count(index Index) (int64, error) {
+	redisKey := s.CountKey(index)
+	s.hotKeys.Record(redisKey)
+	start := s.clock.Now()
+	v, err := s.red.Get(s.ctx, redisKey).Result()
+	s.observe(OpCacheRead, redisKey, start, err)
+	if err == nil {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			s.stats.hit(1)
+			s.trace(TraceHit, nil, redisKey)
+			return n, nil
+		}
+	} else if err != redis.Nil {
+		return 0, s.wrapErr("count", redisKey, cacheError(err))
+	}
+	s.stats.miss(1)
+	s.trace(TraceMiss, nil, redisKey)
+	c, ok := s.db.(Counter)
+	if !ok {
+		return 0, s.wrapErr("count", redisKey, errors.New("cachelayer: database does not implement Counter"))
+	}
+	if !s.allowDB(redisKey) {
+		n, _, err := rateLimited[int64](s.dbLimiter, redisKey)
+		return n, s.wrapErr("count", redisKey, err)
+	}
+	// counted without caching if another load holds the key
+	marker, claimed := s.claimCount(redisKey)
+	gen := s.generation.current()
+	start = s.clock.Now()
+	n, err := c.Count(index)
+	s.dbLoaded(start, 1, err, redisKey)
+	if err != nil {
+		return 0, s.wrapErr("count", redisKey, err)
+	}
+	s.dbLimiter.remember(redisKey, n, true)
+	if !claimed {
+		return n, nil
+	}
+	err = s.populate(gen, func() error {
+		return cacheError(setCountIfClaimed.Run(s.ctx, s.red, []string{redisKey}, marker, n, s.redIds.storeTTL().Milliseconds()).Err())
+	})
+	return n, s.wrapErr("count", redisKey, err)
+}
+
+//claimCount set a fresh marker to the missing count key, false if it is held or can't be claimed
+func (s *RedisCache[T, I]) claimCount(key string) (string, bool) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		s.report("count", err)
+		return "", false
+	}
+	marker := countLoadingPrefix + hex.EncodeToString(b)
+	ok, err := s.red.SetNX(s.ctx, key, marker, countLoadingTTL).Result()
+	if err != nil {
+		s.report("count", cacheError(err))
+	}
+	return marker, ok
+}
+
+//incrIfExists add ARGV[1] to the count KEYS[1] only if it is cached, keeping its ttl. A loading marker is deleted,
+// the count being loaded may miss the write
+var incrIfExists = newScript(`
+local v = redis.call('GET', KEYS[1])
+if not v then return 0 end
+if tonumber(v) then return redis.call('INCRBY', KEYS[1], ARGV[1]) end
+return redis.call('DEL', KEYS[1])
+`)
+
+//incrCounts add delta per record of objs to their cached counts by one pipeline
+func (s *RedisCache[T, I]) incrCounts(delta int64, objs ...T) error {
+	deltas := make(map[string]int64)
+	for _, v := range objs {
+		for _, key := range s.CountKeys(v) {
+			deltas[key] += delta
+		}
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(deltas))
+	for k := range deltas {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	p := s.red.Pipeline()
+	for _, k := range keys {
+		incrIfExists.Eval(s.ctx, p, []string{k}, deltas[k])
+	}
+	_, err := p.Exec(s.ctx)
+	return cacheError(err)
+}
+
+//clearCounted invalidate objs created(delta 1) or deleted(delta -1). In CountIncrement mode their counts are adjusted instead of deleted,
+// and deleted if the adjustment fails so they can't drift
+func (s *RedisCache[T, I]) clearCounted(delta int64, objs ...T) error {
+	if s.countMode != CountIncrement {
+		return s.ClearCache(objs...)
+	}
+	if err := s.incrCounts(delta, objs...); err != nil {
+		s.report("count", err)
+		return s.ClearCache(objs...)
+	}
+	return s.clearCache(false, nil, objs...)
+}
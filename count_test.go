@@ -0,0 +1,97 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountKey(t *testing.T) {
+	cache := cachelayer.NewRedisCache[keyUser, uint]("App", "user", "ID", nil, nil, time.Minute)
+	assert.Equal(t, "app/user/count", cache.CountKey(cachelayer.Index{}))
+	assert.Equal(t, "app/user/count/age/3/name/tom", cache.CountKey(cachelayer.Index{"Name": "tom", "Age": 3}))
+	assert.Equal(t, []string{"app/user/count"}, cache.CountKeys(keyUser{ID: 1}))
+}
+
+//countDB memDB implementing Counter, during runs after counting and before the count is returned
+type countDB struct {
+	*memDB
+	counts int
+	during func()
+}
+
+func (s *countDB) Count(index cachelayer.Index) (int64, error) {
+	s.counts++
+	r, err := s.ListBy(index, nil)
+	if s.during != nil {
+		during := s.during
+		s.during = nil
+		during()
+	}
+	return int64(len(r)), err
+}
+
+func newCountCache(t *testing.T, rows ...member) (*cachelayer.RedisCache[member, uint], *countDB, *miniredis.Miniredis) {
+	mr, red := newMiniRedis(t)
+	db := &countDB{memDB: newMemDB(rows...)}
+	return cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute), db, mr
+}
+
+func TestCountIncrement(t *testing.T) {
+	cache, db, _ := newCountCache(t, member{ID: 1, GroupID: 1}, member{ID: 2, GroupID: 1}, member{ID: 3, GroupID: 2})
+	cache.SetCountMode(cachelayer.CountIncrement)
+	group := cachelayer.NewIndex("GroupID", uint(1))
+	n, err := cache.Count(group)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Nil(t, cache.Create(&member{GroupID: 1}))
+	n, err = cache.Count(group)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+	_, err = cache.Delete(1, 2)
+	assert.Nil(t, err)
+	n, err = cache.Count(group)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 1, db.counts)
+	// count keys are no ids
+	report, err := cache.Verify()
+	assert.Nil(t, err)
+	assert.Empty(t, report.Malformed)
+}
+
+func TestCountInterleavedWrite(t *testing.T) {
+	for _, mode := range []cachelayer.CountMode{cachelayer.CountInvalidate, cachelayer.CountIncrement} {
+		cache, db, _ := newCountCache(t, member{ID: 1, GroupID: 1})
+		cache.SetCountMode(mode)
+		group := cachelayer.NewIndex("GroupID", uint(1))
+		// created after the count is read from database, before it is cached
+		db.during = func() { assert.Nil(t, cache.Create(&member{GroupID: 1})) }
+		n, err := cache.Count(group)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), n)
+		n, err = cache.Count(group)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), n, "mode %d", mode)
+		n, err = cache.Count(group)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), n)
+		assert.Equal(t, 2, db.counts)
+	}
+}
+
+func TestCountClaimed(t *testing.T) {
+	cache, _, mr := newCountCache(t, member{ID: 1, GroupID: 1})
+	key := cache.CountKey(cachelayer.Index{})
+	// another process is loading the count
+	mr.Set(key, "!loading:0a1b")
+	n, err := cache.Count(cachelayer.Index{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	v, err := mr.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, "!loading:0a1b", v)
+}
@@ -0,0 +1,57 @@
+// Package di provides constructors for dependency injection frameworks(google/wire, uber/fx).
+// Every cache built by the providers is registered into the shared *cachelayer.Registry, so closing the registry closes all of them.
+package di
+
+import (
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/daqiancode/cachelayer/mongoredis"
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+const fullSuffix = ":full"
+
+//cacheName name a cache is registered as: name if given, otherwise the table qualified by its adapter,
+// so caches of a sql table and a mongo collection of the same name do not collide
+func cacheName(adapter, table string, name []string) string {
+	if len(name) > 0 && name[0] != "" {
+		return name[0]
+	}
+	return adapter + ":" + table
+}
+
+//GormRedis return a provider of gorm backed RedisCache, registered as name or "gorm:{table}"
+func GormRedis[T cachelayer.Table[I], I cachelayer.IDType](prefix, table, idField string, ttl time.Duration, name ...string) func(db *gorm.DB, red redis.UniversalClient, registry *cachelayer.Registry) (*cachelayer.RedisCache[T, I], error) {
+	return func(db *gorm.DB, red redis.UniversalClient, registry *cachelayer.Registry) (*cachelayer.RedisCache[T, I], error) {
+		c := gormredis.NewGormRedis[T, I](prefix, table, idField, db, red, ttl)
+		return c, registry.Register(cacheName("gorm", table, name), c)
+	}
+}
+
+//GormRedisFull return a provider of gorm backed FullRedisCache, registered as name or "gorm:{table}:full"
+func GormRedisFull[T cachelayer.Table[I], I cachelayer.IDType](prefix, table, idField string, ttl time.Duration, name ...string) func(db *gorm.DB, red redis.UniversalClient, registry *cachelayer.Registry) (*cachelayer.FullRedisCache[T, I], error) {
+	return func(db *gorm.DB, red redis.UniversalClient, registry *cachelayer.Registry) (*cachelayer.FullRedisCache[T, I], error) {
+		c := cachelayer.NewFullRedisCache[T, I](prefix, table, idField, gormredis.NewGorm[T, I](db, table, idField), red, ttl)
+		return c, registry.Register(cacheName("gorm", table+fullSuffix, name), c)
+	}
+}
+
+//MongoRedis return a provider of mongo backed RedisCache, registered as name or "mongo:{database}.{collection}"
+func MongoRedis[T cachelayer.Table[I], I cachelayer.IDType](prefix, database, collection, idField string, ttl time.Duration, name ...string) func(db *mongo.Client, red redis.UniversalClient, registry *cachelayer.Registry) (*cachelayer.RedisCache[T, I], error) {
+	return func(db *mongo.Client, red redis.UniversalClient, registry *cachelayer.Registry) (*cachelayer.RedisCache[T, I], error) {
+		c := mongoredis.NewMongoRedis[T, I](prefix, database, collection, idField, db, red, ttl)
+		return c, registry.Register(cacheName("mongo", database+"."+collection, name), c)
+	}
+}
+
+//MongoRedisFull return a provider of mongo backed FullRedisCache, registered as name or "mongo:{database}.{collection}:full"
+func MongoRedisFull[T cachelayer.Table[I], I cachelayer.IDType](prefix, database, collection, idField string, ttl time.Duration, name ...string) func(db *mongo.Client, red redis.UniversalClient, registry *cachelayer.Registry) (*cachelayer.FullRedisCache[T, I], error) {
+	return func(db *mongo.Client, red redis.UniversalClient, registry *cachelayer.Registry) (*cachelayer.FullRedisCache[T, I], error) {
+		c := mongoredis.NewMongoRedisFull[T, I](prefix, database, collection, idField, db, red, ttl)
+		return c, registry.Register(cacheName("mongo", database+"."+collection+fullSuffix, name), c)
+	}
+}
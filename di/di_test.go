@@ -0,0 +1,56 @@
+package di_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/di"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type User struct {
+	ID   uint
+	Name string
+}
+
+func (s User) GetID() uint {
+	return s.ID
+}
+
+func (s User) ListIndexes() cachelayer.Indexes {
+	return nil
+}
+
+func TestProviderNames(t *testing.T) {
+	mr := miniredis.RunT(t)
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer red.Close()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.Nil(t, err)
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://127.0.0.1:27017"))
+	assert.Nil(t, err)
+	registry := cachelayer.NewRegistry()
+
+	// a sql table and a mongo collection of the same name
+	_, err = di.GormRedis[User, uint]("app", "user", "ID", time.Minute)(db, red, registry)
+	assert.Nil(t, err)
+	_, err = di.GormRedisFull[User, uint]("app", "user", "ID", time.Minute)(db, red, registry)
+	assert.Nil(t, err)
+	_, err = di.MongoRedis[User, uint]("app", "shop", "user", "ID", time.Minute)(client, red, registry)
+	assert.Nil(t, err)
+	_, err = di.MongoRedisFull[User, uint]("app", "shop", "user", "ID", time.Minute)(client, red, registry)
+	assert.Nil(t, err)
+	// the same table cached under another prefix needs an explicit name
+	_, err = di.GormRedis[User, uint]("admin", "user", "ID", time.Minute)(db, red, registry)
+	assert.NotNil(t, err)
+	_, err = di.GormRedis[User, uint]("admin", "user", "ID", time.Minute, "admin_user")(db, red, registry)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"gorm:user", "gorm:user:full", "mongo:shop.user", "mongo:shop.user:full", "admin_user"}, registry.Names())
+}
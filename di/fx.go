@@ -0,0 +1,27 @@
+package di
+
+import (
+	"context"
+
+	"github.com/daqiancode/cachelayer"
+	"go.uber.org/fx"
+)
+
+//Module fx module providing *cachelayer.Registry, the registry is shut down on stop, see Registry.Shutdown.
+//
+//	fx.New(
+//		di.Module,
+//		fx.Provide(di.GormRedis[User, int]("app", "user", "Id", time.Minute)),
+//	)
+var Module = fx.Module("cachelayer",
+	fx.Provide(cachelayer.NewRegistry),
+	fx.Invoke(closeOnStop),
+)
+
+func closeOnStop(lc fx.Lifecycle, registry *cachelayer.Registry) {
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return registry.Shutdown(ctx)
+		},
+	})
+}
@@ -0,0 +1,24 @@
+package di
+
+import (
+	"context"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/google/wire"
+)
+
+//ProviderSet wire provider set of *cachelayer.Registry.
+// wire does not accept generic providers, declare one provider per entity:
+//
+//	func provideUserCache(db *gorm.DB, red redis.UniversalClient, registry *cachelayer.Registry) (*cachelayer.RedisCache[User, int], error) {
+//		return di.GormRedis[User, int]("app", "user", "Id", time.Minute)(db, red, registry)
+//	}
+var ProviderSet = wire.NewSet(ProvideRegistry)
+
+//ProvideRegistry return registry and a cleanup shutting it down, see Registry.Shutdown
+func ProvideRegistry() (*cachelayer.Registry, func()) {
+	registry := cachelayer.NewRegistry()
+	return registry, func() {
+		registry.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,247 @@
+package cachelayer
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//EncryptingSerializer encrypt payloads of an inner serializer with AES-GCM. Payloads are written as {keyID}:{base64(nonce+ciphertext)}
+// by the primary key and read by the key named in their prefix, so keys can be rotated without flushing the cache:
+// add the new key, make it primary, then ReEncrypt entries written by old keys and remove them
+type EncryptingSerializer struct {
+	inner   Serializer
+	mu      sync.RWMutex
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+//NewEncryptingSerializer encrypt payloads of inner(JsonSerializer if nil) with key named keyID, key is 16, 24 or 32 bytes(AES-128/192/256)
+func NewEncryptingSerializer(inner Serializer, keyID string, key []byte) (*EncryptingSerializer, error) {
+	if inner == nil {
+		inner = &JsonSerializer{}
+	}
+	s := &EncryptingSerializer{inner: inner, keys: make(map[string]cipher.AEAD)}
+	if err := s.AddKey(keyID, key); err != nil {
+		return nil, err
+	}
+	s.primary = keyID
+	return s, nil
+}
+
+//AddKey add a key decrypting payloads written by it, keyID must not contain ':'
+func (s *EncryptingSerializer) AddKey(keyID string, key []byte) error {
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return fmt.Errorf("cachelayer: invalid encryption key id %q", keyID)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[keyID] = aead
+	return nil
+}
+
+//SetPrimary encrypt new payloads with the added key keyID
+func (s *EncryptingSerializer) SetPrimary(keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[keyID]; !ok {
+		return fmt.Errorf("cachelayer: unknown encryption key id %q", keyID)
+	}
+	s.primary = keyID
+	return nil
+}
+
+//RemoveKey drop a retired key, payloads written by it can not be read anymore. The primary key can not be removed
+func (s *EncryptingSerializer) RemoveKey(keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if keyID == s.primary {
+		return fmt.Errorf("cachelayer: can not remove primary encryption key %q", keyID)
+	}
+	delete(s.keys, keyID)
+	return nil
+}
+
+//Primary id of the key encrypting new payloads
+func (s *EncryptingSerializer) Primary() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.primary
+}
+
+func (s *EncryptingSerializer) Marshal(obj interface{}) (string, error) {
+	data, err := s.inner.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return s.encrypt(data)
+}
+
+func (s *EncryptingSerializer) Unmarshal(data string, objRef interface{}) error {
+	// legacy cached "not found" entries
+	if data == "null" {
+		return s.inner.Unmarshal(data, objRef)
+	}
+	plain, _, err := s.decrypt(data)
+	if err != nil {
+		return err
+	}
+	return s.inner.Unmarshal(plain, objRef)
+}
+
+func (s *EncryptingSerializer) encrypt(data string) (string, error) {
+	s.mu.RLock()
+	keyID, aead := s.primary, s.keys[s.primary]
+	s.mu.RUnlock()
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(data), nil)
+	return keyID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+//decrypt return the inner payload and id of the key it was written by
+func (s *EncryptingSerializer) decrypt(data string) (string, string, error) {
+	i := strings.IndexByte(data, ':')
+	if i < 0 {
+		return "", "", errors.New("cachelayer: payload is not encrypted")
+	}
+	keyID := data[:i]
+	s.mu.RLock()
+	aead, ok := s.keys[keyID]
+	s.mu.RUnlock()
+	if !ok {
+		return "", keyID, fmt.Errorf("cachelayer: unknown encryption key id %q", keyID)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(data[i+1:])
+	if err != nil {
+		return "", keyID, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", keyID, errors.New("cachelayer: encrypted payload too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	return string(plain), keyID, err
+}
+
+//reEncrypt encrypt data by the primary key, changed is false if it is already encrypted by it or is not a payload, eg. a grace marker
+func (s *EncryptingSerializer) reEncrypt(data string) (string, bool, error) {
+	if IsNullPlaceholder(data) || !strings.Contains(data, ":") || strings.HasPrefix(data, s.Primary()+":") {
+		return data, false, nil
+	}
+	plain, _, err := s.decrypt(data)
+	if err != nil {
+		return data, false, err
+	}
+	r, err := s.encrypt(plain)
+	return r, err == nil, err
+}
+
+//replaceValue set a string key to new value keeping its ttl, only if it still holds old value
+var replaceValue = newScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then return 0 end
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then redis.call('SET', KEYS[1], ARGV[2], 'PX', ttl) else redis.call('SET', KEYS[1], ARGV[2]) end
+return 1
+`)
+
+//replaceField set a hash field to new value, only if it still holds old value
+var replaceField = newScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) ~= ARGV[2] then return 0 end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+return 1
+`)
+
+//ReEncrypt rewrite cache entries of table encrypted by non-primary keys with the primary key, keeping their ttl.
+// Entries changed meanwhile are skipped, they are already written by the primary key. Return count of rewritten entries
+func ReEncrypt(ctx context.Context, red redis.UniversalClient, prefix, table string, serializer *EncryptingSerializer) (int, error) {
+	keys, err := scanKeys(ctx, red, strings.ToLower(prefix+"/"+table+"/*"))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, key := range keys {
+		typ, err := red.Type(ctx, key).Result()
+		if err != nil {
+			return n, cacheError(err)
+		}
+		switch typ {
+		case "string":
+			old, err := red.Get(ctx, key).Result()
+			if err == redis.Nil {
+				continue
+			} else if err != nil {
+				return n, cacheError(err)
+			}
+			v, changed, err := serializer.reEncrypt(old)
+			if err != nil {
+				return n, NewError(ErrSerialization, fmt.Errorf("%s: %w", key, err))
+			}
+			if !changed {
+				continue
+			}
+			ok, err := replaceValue.Run(ctx, red, []string{key}, old, v).Int()
+			if err != nil {
+				return n, cacheError(err)
+			}
+			n += ok
+		case "hash":
+			fields, err := red.HGetAll(ctx, key).Result()
+			if err != nil {
+				return n, cacheError(err)
+			}
+			for field, old := range fields {
+				v, changed, err := serializer.reEncrypt(old)
+				if err != nil {
+					return n, NewError(ErrSerialization, fmt.Errorf("%s %s: %w", key, field, err))
+				}
+				if !changed {
+					continue
+				}
+				ok, err := replaceField.Run(ctx, red, []string{key}, field, old, v).Int()
+				if err != nil {
+					return n, cacheError(err)
+				}
+				n += ok
+			}
+		}
+	}
+	return n, nil
+}
+
+//ReEncrypt rewrite entries of this cache with the primary key of its EncryptingSerializer, see ReEncrypt
+func (s *RedisCache[T, I]) ReEncrypt() (int, error) {
+	serializer, ok := s.red.serializer.(*EncryptingSerializer)
+	if !ok {
+		return 0, s.wrapErr("re_encrypt", "", errors.New("cachelayer: serializer is not an EncryptingSerializer"))
+	}
+	n, err := ReEncrypt(s.ctx, s.red.UniversalClient, s.keyPrefix(), s.table, serializer)
+	return n, s.wrapErr("re_encrypt", "", err)
+}
+
+//ReEncrypt rewrite entries of this cache with the primary key of its EncryptingSerializer, see ReEncrypt
+func (s *FullRedisCache[T, I]) ReEncrypt() (int, error) {
+	serializer, ok := s.red.serializer.(*EncryptingSerializer)
+	if !ok {
+		return 0, s.wrapErr("re_encrypt", "", errors.New("cachelayer: serializer is not an EncryptingSerializer"))
+	}
+	n, err := ReEncrypt(s.ctx, s.red.UniversalClient, s.keyPrefix(), s.table, serializer)
+	return n, s.wrapErr("re_encrypt", "", err)
+}
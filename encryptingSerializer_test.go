@@ -0,0 +1,51 @@
+package cachelayer_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptingSerializer(t *testing.T) {
+	oldKey := []byte("0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+	s, err := cachelayer.NewEncryptingSerializer(nil, "k1", oldKey)
+	assert.Nil(t, err)
+	user := keyUser{ID: 42}
+	old, err := s.Marshal(user)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(old, "k1:"))
+	assert.NotContains(t, old, "{")
+
+	// rotate
+	assert.Nil(t, s.AddKey("k2", newKey))
+	assert.Nil(t, s.SetPrimary("k2"))
+	fresh, err := s.Marshal(user)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(fresh, "k2:"))
+	for _, v := range []string{old, fresh} {
+		var r keyUser
+		assert.Nil(t, s.Unmarshal(v, &r))
+		assert.Equal(t, user, r)
+	}
+
+	// cached "not found" is not encrypted
+	var r *keyUser
+	assert.Nil(t, s.Unmarshal("null", &r))
+	assert.Nil(t, r)
+
+	assert.NotNil(t, s.RemoveKey("k2"))
+	assert.Nil(t, s.RemoveKey("k1"))
+	var u keyUser
+	assert.NotNil(t, s.Unmarshal(old, &u))
+	assert.NotNil(t, s.Unmarshal(fresh[:len(fresh)-2]+"AA", &u))
+	assert.NotNil(t, s.SetPrimary("k1"))
+	_, err = cachelayer.NewEncryptingSerializer(nil, "k:1", oldKey)
+	assert.NotNil(t, err)
+	_, err = cachelayer.NewEncryptingSerializer(nil, "k1", []byte("short"))
+	assert.False(t, errors.Is(err, cachelayer.ErrSerialization))
+	assert.NotNil(t, err)
+}
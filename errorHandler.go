@@ -0,0 +1,77 @@
+package cachelayer
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//ErrorEvent error of background work or of a best-effort cache write, which would be swallowed otherwise
+type ErrorEvent struct {
+	//Component where the error happened, eg. async_writer, webhook, anti_entropy, warmup_subscriber, refresh, invalidation
+	Component string
+	Table     string
+	Err       error
+	//Panic recovered value, nil for errors
+	Panic interface{}
+	//Stack of the recovered panic
+	Stack []byte
+}
+
+var errorHandler atomic.Value
+
+func init() {
+	SetErrorHandler(nil)
+}
+
+//SetErrorHandler global handler of errors and recovered panics of background work, nil restores the default which logs them by the standard logger
+func SetErrorHandler(fn func(event ErrorEvent)) {
+	if fn == nil {
+		fn = func(event ErrorEvent) {
+			if event.Panic != nil {
+				log.Printf("cachelayer: %s table=%s: %v\n%s", event.Component, event.Table, event.Err, event.Stack)
+				return
+			}
+			log.Printf("cachelayer: %s table=%s: %v", event.Component, event.Table, event.Err)
+		}
+	}
+	errorHandler.Store(fn)
+}
+
+//ReportError pass err to the global error handler, nil err is ignored
+func ReportError(component, table string, err error) {
+	if err == nil {
+		return
+	}
+	errorHandler.Load().(func(event ErrorEvent))(ErrorEvent{Component: component, Table: table, Err: err})
+}
+
+//recovered report a recovered panic r and return it as an ErrPanic error, nil if r is nil. Call it as: defer func() { recovered(component, table, recover()) }()
+func recovered(component, table string, r interface{}) error {
+	if r == nil {
+		return nil
+	}
+	err := NewError(ErrPanic, fmt.Errorf("%v", r))
+	errorHandler.Load().(func(event ErrorEvent))(ErrorEvent{Component: component, Table: table, Err: err, Panic: r, Stack: debug.Stack()})
+	return err
+}
+
+//safely run fn, a panic of fn is reported and returned as an ErrPanic error
+func safely(component, table string, fn func() error) (err error) {
+	defer func() {
+		if e := recovered(component, table, recover()); e != nil {
+			err = e
+		}
+	}()
+	return fn()
+}
+
+//report pass err of a best-effort operation of this cache to the global error handler
+func (s *CacheBase[T, I]) report(component string, err error) {
+	if err != nil && err != redis.Nil {
+		ReportError(component, s.table, err)
+	}
+}
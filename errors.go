@@ -0,0 +1,133 @@
+package cachelayer
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	//ErrNotFound record does not exist, only returned when not-found error is enabled
+	ErrNotFound = errors.New("cachelayer: not found")
+	//ErrCacheUnavailable redis command failed
+	ErrCacheUnavailable = errors.New("cachelayer: cache unavailable")
+	//ErrSerialization cache entry can not be marshaled or unmarshaled
+	ErrSerialization = errors.New("cachelayer: serialization failed")
+	//ErrConflict record conflicts with an existing one, eg. duplicate key
+	ErrConflict = errors.New("cachelayer: conflict")
+	//ErrUpsertNotSupported database does not implement Upserter
+	ErrUpsertNotSupported = errors.New("cachelayer: upsert not supported")
+	//ErrNotUnique several records match a unique index
+	ErrNotUnique = errors.New("cachelayer: unique index matches several records")
+	//ErrPanic panic recovered in background work
+	ErrPanic = errors.New("cachelayer: panic")
+	//ErrRateLimited database load rejected by DBRateLimiter and no value of the key was loaded before
+	ErrRateLimited = errors.New("cachelayer: database load rate limited")
+	//ErrUnavailable redis and the database both failed, see FailureMode
+	ErrUnavailable = errors.New("cachelayer: cache and database unavailable")
+)
+
+//Error error with operation context, errors.Is(err, ErrXxx) matches its Kind, errors.Unwrap returns the lower error
+type Error struct {
+	Op    string
+	Table string
+	Key   string
+	Kind  error
+	Err   error
+}
+
+func (e *Error) Error() string {
+	var parts []string
+	if e.Op != "" {
+		parts = append(parts, "op="+e.Op)
+	}
+	if e.Table != "" {
+		parts = append(parts, "table="+e.Table)
+	}
+	if e.Key != "" {
+		parts = append(parts, "key="+e.Key)
+	}
+	msg := "cachelayer"
+	if e.Kind != nil {
+		msg = e.Kind.Error()
+	}
+	if len(parts) > 0 {
+		msg += " [" + strings.Join(parts, " ") + "]"
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+func NewError(kind error, err error) *Error {
+	return &Error{Kind: kind, Err: err}
+}
+
+//cacheError classify redis errors as ErrCacheUnavailable, keep already classified errors
+func cacheError(err error) error {
+	if err == nil || err == redis.Nil {
+		return err
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return NewError(ErrCacheUnavailable, err)
+}
+
+func marshal(serializer Serializer, obj interface{}) (string, error) {
+	r, err := serialize(serializer, beforeCache(obj))
+	if err != nil {
+		return r, NewError(ErrSerialization, err)
+	}
+	return r, nil
+}
+
+//unmarshal deserialize data into objRef, a cached "not found" is read as the zero value
+func unmarshal(serializer Serializer, data string, objRef interface{}) error {
+	if IsNullPlaceholder(data) {
+		setZero(objRef)
+		return nil
+	}
+	if err := deserialize(serializer, data, objRef); err != nil {
+		return NewError(ErrSerialization, err)
+	}
+	afterLoad(objRef)
+	return nil
+}
+
+//wrapErr attach operation, table and key to err. Errors carrying an operation are returned as is, an *Error without one is copied,
+// since it may be shared, eg. returned by a database for every call
+func (s *CacheBase[T, I]) wrapErr(op, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) && e.Op != "" {
+		return err
+	}
+	if e, ok := err.(*Error); ok {
+		r := *e
+		r.Op, r.Table, r.Key = op, s.table, key
+		return &r
+	}
+	return &Error{Op: op, Table: s.table, Key: key, Err: err}
+}
+
+//notFound wrap err, return ErrNotFound if record does not exist and not-found error is enabled
+func (s *CacheBase[T, I]) notFound(op, key string, exists bool, err error) error {
+	if err == nil && !exists && s.notFoundErr {
+		return s.wrapErr(op, key, ErrNotFound)
+	}
+	return s.wrapErr(op, key, err)
+}
@@ -0,0 +1,72 @@
+package cachelayer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	lower := errors.New("connection refused")
+	err := error(&cachelayer.Error{Op: "get", Table: "user", Key: "app/user/id/1", Kind: cachelayer.ErrCacheUnavailable, Err: lower})
+	assert.True(t, errors.Is(err, cachelayer.ErrCacheUnavailable))
+	assert.True(t, errors.Is(err, lower))
+	assert.False(t, errors.Is(err, cachelayer.ErrSerialization))
+	assert.Equal(t, "cachelayer: cache unavailable [op=get table=user key=app/user/id/1]: connection refused", err.Error())
+
+	err = &cachelayer.Error{Op: "get", Table: "user", Err: cachelayer.ErrNotFound}
+	assert.True(t, errors.Is(err, cachelayer.ErrNotFound))
+}
+
+func TestNotFound(t *testing.T) {
+	cache, db, _ := newMemberCache(t, member{ID: 1, Email: "tom@x.com"})
+	// the second read is a hit of the cached "not found"
+	for i := 0; i < 2; i++ {
+		_, exists, err := cache.Get(2)
+		assert.Nil(t, err)
+		assert.False(t, exists)
+		_, exists, err = cache.GetBy(cachelayer.NewIndex("Email", "ann@x.com"))
+		assert.Nil(t, err)
+		assert.False(t, exists)
+	}
+	assert.Equal(t, 2, db.Queries())
+
+	cache.SetNotFoundError(true)
+	for i := 0; i < 2; i++ {
+		_, exists, err := cache.Get(2)
+		assert.True(t, errors.Is(err, cachelayer.ErrNotFound))
+		assert.False(t, exists)
+		_, exists, err = cache.GetBy(cachelayer.NewIndex("Email", "ann@x.com"))
+		assert.True(t, errors.Is(err, cachelayer.ErrNotFound))
+		assert.False(t, exists)
+	}
+	_, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, db.Queries())
+}
+
+//conflictDB fails creates by one shared error
+type conflictDB struct {
+	*memDB
+	err error
+}
+
+func (s *conflictDB) Create(obj *member) error {
+	return s.err
+}
+
+func TestWrapErrCopiesSharedError(t *testing.T) {
+	shared := cachelayer.NewError(cachelayer.ErrConflict, errors.New("duplicate"))
+	_, red := newMiniRedis(t)
+	users := cachelayer.NewRedisCache[member, uint]("app", "user", "ID", &conflictDB{newMemDB(), shared}, red, time.Minute)
+	members := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", &conflictDB{newMemDB(), shared}, red, time.Minute)
+	err := users.Create(&member{})
+	assert.True(t, errors.Is(err, cachelayer.ErrConflict))
+	assert.Contains(t, err.Error(), "table=user")
+	err = members.Create(&member{})
+	assert.Contains(t, err.Error(), "table=member")
+	assert.Equal(t, "", shared.Op)
+}
@@ -0,0 +1,173 @@
+package cachelayer
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//ExpirationPolicy how ttl of cached entries behaves on reads
+type ExpirationPolicy int
+
+const (
+	//ExpirationSliding every read restarts the ttl (default)
+	ExpirationSliding ExpirationPolicy = iota
+	//ExpirationAbsolute entry expires ttl after it is written, reads never extend it
+	ExpirationAbsolute
+	//ExpirationSlidingWithMax reads restart the ttl, but entry never lives longer than maxTTL after it is written
+	ExpirationSlidingWithMax
+)
+
+func (s ExpirationPolicy) String() string {
+	switch s {
+	case ExpirationAbsolute:
+		return "absolute"
+	case ExpirationSlidingWithMax:
+		return "sliding_with_max"
+	}
+	return "sliding"
+}
+
+const deadlineKeySuffix = ":deadline"
+
+func deadlineKey(key string) string {
+	return key + deadlineKeySuffix
+}
+
+// KEYS[1]: cache key, KEYS[2]: deadline key, ARGV[1]: ttl in milliseconds
+var slidingWithMaxScript = newScript(`
+local left = redis.call('PTTL', KEYS[2])
+if left == -2 then
+	return 0
+end
+local ttl = tonumber(ARGV[1])
+if left > 0 and left < ttl then
+	ttl = left
+end
+return redis.call('PEXPIRE', KEYS[1], ttl)
+`)
+
+//SetExpirationPolicy maxTTL is only used by ExpirationSlidingWithMax
+func (s *RedisJson[T]) SetExpirationPolicy(policy ExpirationPolicy, maxTTL time.Duration) {
+	s.policy = policy
+	s.maxTTL = maxTTL
+}
+
+func (s *RedisJson[T]) GetExpirationPolicy() (ExpirationPolicy, time.Duration) {
+	return s.policy, s.maxTTL
+}
+
+//Refresh extend ttl of keys after a read according to the expiration policy
+func (s *RedisJson[T]) Refresh(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	switch s.policy {
+	case ExpirationAbsolute:
+		return nil
+	case ExpirationSlidingWithMax:
+		_, err := s.expireWithMax(keys...)
+		return err
+	}
+	return s.Expires(keys...)
+}
+
+//expireWithMax restart ttl of keys capped by their deadline keys, return whether each key was extended
+func (s *RedisJson[T]) expireWithMax(keys ...string) ([]bool, error) {
+	ttl := s.storeTTL()
+	r := make([]bool, len(keys))
+	p := s.Pipeline()
+	if !IsSharded(s.UniversalClient) {
+		cmds := make([]*redis.Cmd, len(keys))
+		for i, v := range keys {
+			cmds[i] = slidingWithMaxScript.Eval(s.ctx, p, []string{v, deadlineKey(v)}, ttl.Milliseconds())
+		}
+		s.expireFresh(p, keys...)
+		if _, err := p.Exec(s.ctx); err != nil && err != redis.Nil {
+			return r, cacheError(err)
+		}
+		for i, v := range cmds {
+			n, _ := v.Int64()
+			r[i] = n == 1
+		}
+		return r, nil
+	}
+	// the deadline key may live on another node, so the script runs client side
+	left := make([]*redis.DurationCmd, len(keys))
+	for i, v := range keys {
+		left[i] = p.PTTL(s.ctx, deadlineKey(v))
+	}
+	if _, err := p.Exec(s.ctx); err != nil && err != redis.Nil {
+		return r, cacheError(err)
+	}
+	p = s.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(keys))
+	for i, v := range keys {
+		// -2: deadline key does not exist, the entry reached its max lifetime
+		if left[i].Val() == -2 {
+			continue
+		}
+		keyTTL := ttl
+		if left[i].Val() > 0 && left[i].Val() < ttl {
+			keyTTL = left[i].Val()
+		}
+		cmds[i] = p.PExpire(s.ctx, v, keyTTL)
+	}
+	s.expireFresh(p, keys...)
+	if _, err := p.Exec(s.ctx); err != nil && err != redis.Nil {
+		return r, cacheError(err)
+	}
+	for i, v := range cmds {
+		r[i] = v != nil && v.Val()
+	}
+	return r, nil
+}
+
+func (s *RedisJson[T]) expireFresh(p redis.Pipeliner, keys ...string) {
+	if s.grace <= 0 {
+		return
+	}
+	for _, v := range keys {
+		p.Expire(s.ctx, freshKey(v), s.ttl)
+	}
+}
+
+//withAuxKeys keys and their max lifetime(ExpirationSlidingWithMax) and freshness(grace mode) keys, so they are deleted together
+func (s *RedisJson[T]) withAuxKeys(keys []string) []string {
+	if s.policy != ExpirationSlidingWithMax && s.grace <= 0 {
+		return keys
+	}
+	r := append(make([]string, 0, len(keys)*3), keys...)
+	for _, v := range keys {
+		if s.policy == ExpirationSlidingWithMax {
+			r = append(r, deadlineKey(v))
+		}
+		if s.grace > 0 {
+			r = append(r, freshKey(v))
+		}
+	}
+	return r
+}
+
+//afterWrite start max lifetime (ExpirationSlidingWithMax) and freshness (grace mode) of keys, must be called on every write
+func (s *RedisJson[T]) afterWrite(keys ...string) error {
+	s.replicas.markWritten(keys...)
+	if len(keys) == 0 || (s.policy != ExpirationSlidingWithMax && s.grace <= 0) {
+		return nil
+	}
+	maxTTL := s.maxTTL
+	if maxTTL < s.storeTTL() {
+		maxTTL = s.storeTTL()
+	}
+	p := s.Pipeline()
+	for _, v := range keys {
+		if s.policy == ExpirationSlidingWithMax {
+			p.SetEX(s.ctx, deadlineKey(v), "1", maxTTL)
+		}
+		if s.grace > 0 {
+			p.SetEX(s.ctx, freshKey(v), "1", s.ttl)
+		}
+	}
+	_, err := p.Exec(s.ctx)
+	return cacheError(err)
+}
@@ -0,0 +1,166 @@
+package cachelayer_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+//cmdCounter redis hook counting commands by name
+type cmdCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newCmdCounter() *cmdCounter {
+	return &cmdCounter{counts: make(map[string]int)}
+}
+
+func (s *cmdCounter) count(cmds ...redis.Cmder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range cmds {
+		s.counts[v.Name()]++
+	}
+}
+
+func (s *cmdCounter) Count(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[name]
+}
+
+func (s *cmdCounter) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	s.count(cmd)
+	return ctx, nil
+}
+
+func (s *cmdCounter) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (s *cmdCounter) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	s.count(cmds...)
+	return ctx, nil
+}
+
+func (s *cmdCounter) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestSlidingWithMax(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", newMemDB(member{ID: 1}), red, time.Minute)
+	cache.SetExpirationPolicy(cachelayer.ExpirationSlidingWithMax, 150*time.Second)
+	_, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 150*time.Second, mr.TTL("app/member/id/1:deadline"))
+
+	// reads restart the ttl
+	mr.FastForward(50 * time.Second)
+	_, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, mr.TTL("app/member/id/1"))
+	// but never beyond the deadline
+	mr.FastForward(50 * time.Second)
+	_, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 50*time.Second, mr.TTL("app/member/id/1"))
+}
+
+func TestListRefreshesOnce(t *testing.T) {
+	_, red := newMiniRedis(t)
+	counter := newCmdCounter()
+	red.AddHook(counter)
+	cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", newMemDB(member{ID: 1}, member{ID: 2}), red, time.Minute)
+	_, err := cache.List(1, 2)
+	assert.Nil(t, err)
+	before := counter.Count("expire")
+	_, err = cache.List(1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, counter.Count("expire")-before)
+	// hits of partially missed lists are refreshed too
+	_, err = cache.List(1, 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, counter.Count("expire")-before)
+}
+
+func TestClearCacheAuxKeys(t *testing.T) {
+	cache, _, mr := newMemberCache(t, member{ID: 1, Name: "tom"})
+	cache.SetGrace(time.Minute)
+	cache.SetExpirationPolicy(cachelayer.ExpirationSlidingWithMax, 10*time.Minute)
+	_, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	for _, v := range []string{"app/member/id/1", "app/member/id/1:fresh", "app/member/id/1:deadline"} {
+		assert.True(t, mr.Exists(v), v)
+	}
+	assert.Nil(t, cache.ClearCache(member{ID: 1}))
+	for _, v := range []string{"app/member/id/1", "app/member/id/1:fresh", "app/member/id/1:deadline"} {
+		assert.False(t, mr.Exists(v), v)
+	}
+}
+
+func TestGetTTLAndTouch(t *testing.T) {
+	cache, _, mr := newMemberCache(t, member{ID: 1}, member{ID: 2})
+	cache.SetGrace(30 * time.Second)
+	_, exists, err := cache.GetTTL(1)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	_, err = cache.List(1, 2)
+	assert.Nil(t, err)
+	mr.FastForward(20 * time.Second)
+	// ttl is reported without grace
+	ttl, exists, err := cache.GetTTL(1)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, 40*time.Second, ttl)
+
+	n, err := cache.Touch(1, 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+	ttl, _, err = cache.GetTTL(2)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, ttl)
+	assert.Equal(t, time.Minute, mr.TTL("app/member/id/2:fresh"))
+}
+
+func TestKeepTTL(t *testing.T) {
+	cache, db, mr := newMemberCache(t, member{ID: 1, Name: "tom"}, member{ID: 2, Name: "ann"})
+	cache.SetExpirationPolicy(cachelayer.ExpirationAbsolute, 0)
+	_, err := cache.List(1, 2)
+	assert.Nil(t, err)
+	mr.FastForward(40 * time.Second)
+
+	// without KEEPTTL writes delete the entry
+	_, err = cache.Update(2, map[string]interface{}{"Name": "anna"})
+	assert.Nil(t, err)
+	assert.False(t, mr.Exists("app/member/id/2"))
+
+	cache.SetKeepTTL(true)
+	_, err = cache.Update(1, map[string]interface{}{"Name": "tommy"})
+	assert.Nil(t, err)
+	assert.Equal(t, 20*time.Second, mr.TTL("app/member/id/1"))
+	queries := db.Queries()
+	r, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "tommy", r.Name)
+	assert.Equal(t, queries, db.Queries())
+
+	r.Name = "thomas"
+	assert.Nil(t, cache.Save(&r))
+	assert.Equal(t, 20*time.Second, mr.TTL("app/member/id/1"))
+	r, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "thomas", r.Name)
+	// the old record read by Update is cached, then rewritten
+	_, err = cache.Update(2, map[string]interface{}{"Name": "annie"})
+	assert.Nil(t, err)
+	r, _, err = cache.Get(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "annie", r.Name)
+}
@@ -0,0 +1,105 @@
+package cachelayer
+
+import (
+	"fmt"
+	"sync"
+)
+
+//FailureMode result of Get and List when redis fails, see SetFailureMode
+type FailureMode int
+
+const (
+	//FailCache a redis error fails the read without trying the database, default
+	FailCache FailureMode = iota
+	//FailUnavailable read the database when redis fails, ErrUnavailable if the database fails too
+	FailUnavailable
+	//FailStale read the database when redis fails, the value last read by this process if the database fails too,
+	// ErrUnavailable if there is none
+	FailStale
+	//FailZero read the database when redis fails, zero values(not found) if the database fails too
+	FailZero
+)
+
+const defaultLastValuesCapacity = 10000
+
+//lastValues values last read per key, served by FailStale
+type lastValues struct {
+	mu       sync.Mutex
+	capacity int
+	values   map[string]lastValue
+}
+
+type lastValue struct {
+	value  interface{}
+	exists bool
+}
+
+func newLastValues(capacity int) *lastValues {
+	return &lastValues{capacity: capacity, values: make(map[string]lastValue)}
+}
+
+func (s *lastValues) put(key string, value interface{}, exists bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; !ok && len(s.values) >= s.capacity {
+		for k := range s.values {
+			delete(s.values, k)
+			break
+		}
+	}
+	s.values[key] = lastValue{value: value, exists: exists}
+}
+
+func (s *lastValues) get(key string) (lastValue, bool) {
+	if s == nil {
+		return lastValue{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+//SetFailureMode choose what Get and List return when redis fails: the redis error(FailCache, default), or the database result
+// and, if the database fails too, ErrUnavailable(FailUnavailable), the value last read by this process(FailStale, up to
+// capacity keys, default 10000) or zero values(FailZero). Out of FailCache, failed cache writes after database loads are
+// reported to the error handler instead of failing the read
+func (s *RedisCache[T, I]) SetFailureMode(mode FailureMode, capacity int) {
+	s.failureMode = mode
+	s.lastValues = nil
+	if mode == FailStale {
+		if capacity <= 0 {
+			capacity = defaultLastValuesCapacity
+		}
+		s.lastValues = newLastValues(capacity)
+	}
+}
+
+//cacheFailed err of a write to redis after a database load, reported instead of returned out of FailCache
+func (s *CacheBase[T, I]) cacheFailed(err error) error {
+	if s.failureMode == FailCache {
+		return err
+	}
+	s.report("populate", err)
+	return nil
+}
+
+//failed result of key whose redis read and database load both failed, by the failure mode
+func failed[V any](mode FailureMode, last *lastValues, key string, cacheErr, dbErr error) (V, bool, error) {
+	var r V
+	switch mode {
+	case FailZero:
+		return r, false, nil
+	case FailStale:
+		if v, ok := last.get(key); ok {
+			if !v.exists {
+				return r, false, nil
+			}
+			return v.value.(V), true, nil
+		}
+	}
+	return r, false, NewError(ErrUnavailable, fmt.Errorf("cache: %v, database: %w", cacheErr, dbErr))
+}
@@ -0,0 +1,76 @@
+package cachelayer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+type flakyDB struct {
+	cachelayer.DBCRUD[keyUser, uint]
+	down bool
+}
+
+func (s *flakyDB) Get(id uint) (keyUser, bool, error) {
+	if s.down {
+		return keyUser{}, false, errors.New("db down")
+	}
+	return keyUser{ID: id}, true, nil
+}
+
+func (s *flakyDB) List(ids ...uint) ([]keyUser, error) {
+	if s.down {
+		return nil, errors.New("db down")
+	}
+	r := make([]keyUser, len(ids))
+	for i, v := range ids {
+		r[i] = keyUser{ID: v}
+	}
+	return r, nil
+}
+
+func TestFailureMode(t *testing.T) {
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer red.Close()
+	db := &flakyDB{}
+	cache := cachelayer.NewRedisCache[keyUser, uint]("app", "user", "ID", db, red, time.Minute)
+
+	cache.SetFailureMode(cachelayer.FailUnavailable, 0)
+	r, exists, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, uint(1), r.ID)
+	db.down = true
+	_, _, err = cache.Get(1)
+	assert.True(t, errors.Is(err, cachelayer.ErrUnavailable))
+
+	cache.SetFailureMode(cachelayer.FailStale, 10)
+	db.down = false
+	_, err = cache.List(1)
+	assert.Nil(t, err)
+	db.down = true
+	r, exists, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, uint(1), r.ID)
+	_, _, err = cache.Get(2)
+	assert.True(t, errors.Is(err, cachelayer.ErrUnavailable))
+	_, err = cache.List(1, 2)
+	assert.True(t, errors.Is(err, cachelayer.ErrUnavailable))
+
+	cache.SetFailureMode(cachelayer.FailZero, 0)
+	r, exists, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	rs, err := cache.List(1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []keyUser{{}, {}}, rs)
+
+	cache.SetFailureMode(cachelayer.FailCache, 0)
+	_, _, err = cache.Get(1)
+	assert.True(t, errors.Is(err, cachelayer.ErrCacheUnavailable))
+}
@@ -0,0 +1,119 @@
+package cachelayer
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//ErrInjectedFault error of redis commands failed by FaultInjector, errors.Is(err, ErrInjectedFault) matches it
+var ErrInjectedFault = errors.New("cachelayer: injected fault")
+
+//invalidationCommands commands failed by FaultInjector.SetFailInvalidations
+var invalidationCommands = map[string]bool{"del": true, "unlink": true, "hdel": true}
+
+//FaultInjector go-redis hook injecting faults for resilience testing in staging, add it to the redis client of caches:
+//
+//	fi := cachelayer.NewFaultInjector()
+//	red.AddHook(fi)
+//	fi.SetDropRate(0.1)
+//
+// A pipeline is delayed, dropped or failed as a whole
+type FaultInjector struct {
+	mu                sync.Mutex
+	rand              *rand.Rand
+	dropRate          float64
+	latency           time.Duration
+	failInvalidations bool
+	injected          int64
+}
+
+//NewFaultInjector injector without faults until configured
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+//SetDropRate fail rate(0-1) of commands with ErrInjectedFault
+func (s *FaultInjector) SetDropRate(rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropRate = rate
+}
+
+//SetLatency delay every command by latency
+func (s *FaultInjector) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+//SetFailInvalidations fail all DEL, UNLINK and HDEL commands, so cache invalidation fails
+func (s *FaultInjector) SetFailInvalidations(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failInvalidations = fail
+}
+
+//Reset remove all faults
+func (s *FaultInjector) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropRate = 0
+	s.latency = 0
+	s.failInvalidations = false
+}
+
+//Injected count of commands failed by the injector
+func (s *FaultInjector) Injected() int64 {
+	return atomic.LoadInt64(&s.injected)
+}
+
+//inject delay and decide whether commands fail
+func (s *FaultInjector) inject(ctx context.Context, cmds ...redis.Cmder) error {
+	s.mu.Lock()
+	latency := s.latency
+	drop := s.dropRate > 0 && s.rand.Float64() < s.dropRate
+	failInvalidations := s.failInvalidations
+	s.mu.Unlock()
+	if latency > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(latency):
+		}
+	}
+	if !drop && failInvalidations {
+		for _, v := range cmds {
+			if invalidationCommands[v.Name()] {
+				drop = true
+				break
+			}
+		}
+	}
+	if drop {
+		atomic.AddInt64(&s.injected, 1)
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+func (s *FaultInjector) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, s.inject(ctx, cmd)
+}
+
+func (s *FaultInjector) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (s *FaultInjector) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, s.inject(ctx, cmds...)
+}
+
+func (s *FaultInjector) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
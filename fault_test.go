@@ -0,0 +1,33 @@
+package cachelayer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultInjector(t *testing.T) {
+	//commands fail before dialing, no redis is needed
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer red.Close()
+	fi := cachelayer.NewFaultInjector()
+	red.AddHook(fi)
+	ctx := context.Background()
+
+	fi.SetFailInvalidations(true)
+	assert.True(t, errors.Is(red.Del(ctx, "a").Err(), cachelayer.ErrInjectedFault))
+	p := red.Pipeline()
+	p.Set(ctx, "a", 1, 0)
+	p.Unlink(ctx, "a")
+	_, err := p.Exec(ctx)
+	assert.True(t, errors.Is(err, cachelayer.ErrInjectedFault))
+
+	fi.Reset()
+	fi.SetDropRate(1)
+	assert.True(t, errors.Is(red.Get(ctx, "a").Err(), cachelayer.ErrInjectedFault))
+	assert.Equal(t, int64(3), fi.Injected())
+}
@@ -0,0 +1,154 @@
+package cachelayer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/daqiancode/jsoniter"
+)
+
+//fieldNameExtension names fields by their cache tag(if cacheTag), eg. `cache:"name"`, `cache:"-"` leaves a field out of cache payloads,
+// and fields without tag by mapper(if not nil)
+type fieldNameExtension struct {
+	jsoniter.DummyExtension
+	tagKey   string
+	cacheTag bool
+	mapper   NameMapper
+}
+
+func (s *fieldNameExtension) UpdateStructDescriptor(structDescriptor *jsoniter.StructDescriptor) {
+	for _, v := range structDescriptor.Fields {
+		name := ""
+		if s.cacheTag {
+			name = strings.Split(v.Field.Tag().Get("cache"), ",")[0]
+		}
+		if name == "" && s.mapper != nil && strings.Split(v.Field.Tag().Get(s.tagKey), ",")[0] == "" {
+			name = s.mapper(v.Field.Name())
+		}
+		switch name {
+		case "":
+		case "-":
+			v.FromNames, v.ToNames = []string{}, []string{}
+		default:
+			v.FromNames, v.ToNames = []string{name}, []string{name}
+		}
+	}
+}
+
+//VerifyFieldMapping check every field of T loaded from database survives a round trip through serializer(nil is JsonSerializer{}),
+// eg. at startup or in a test. A field lost in cache, because it is left out by a json tag or its cache name clashes with another
+// field, is zero after cache hits but not after database reads. Fields left out by gorm:"-" or bson:"-" are not checked
+func VerifyFieldMapping[T any](serializer Serializer) error {
+	if serializer == nil {
+		serializer = &JsonSerializer{}
+	}
+	var t T
+	typ := reflect.TypeOf(t)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return fmt.Errorf("cachelayer: verify field mapping: %T is not a struct", t)
+	}
+	in := reflect.New(typ)
+	fields := fillFields(in.Elem(), nil)
+	data, err := serialize(serializer, in.Interface())
+	if err != nil {
+		return fmt.Errorf("cachelayer: verify field mapping of %s: %w", typ.Name(), err)
+	}
+	out := reflect.New(typ)
+	if err = deserialize(serializer, data, out.Interface()); err != nil {
+		return fmt.Errorf("cachelayer: verify field mapping of %s: %w", typ.Name(), err)
+	}
+	var lost []string
+	for _, v := range fields {
+		a, b := in.Elem().FieldByIndex(v.Index), out.Elem().FieldByIndex(v.Index)
+		if sameValue(a, b) {
+			continue
+		}
+		if names := fieldNames(v); len(names) > 1 {
+			lost = append(lost, v.Name+"("+strings.Join(names[1:], ",")+")")
+		} else {
+			lost = append(lost, v.Name)
+		}
+	}
+	if len(lost) > 0 {
+		return fmt.Errorf("cachelayer: fields of %s are lost in cache, they read as zero after cache hits: %s", typ.Name(), strings.Join(lost, " "))
+	}
+	return nil
+}
+
+//fillFields set distinct non zero values to the fields of v stored in database, return the fields set
+func fillFields(v reflect.Value, index []int) []reflect.StructField {
+	var r []reflect.StructField
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		f.Index = append(append([]int{}, index...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			r = append(r, fillFields(v.Field(i), f.Index)...)
+			continue
+		}
+		if !f.IsExported() || f.Tag.Get("gorm") == "-" || strings.Split(f.Tag.Get("bson"), ",")[0] == "-" {
+			continue
+		}
+		if fillValue(v.Field(i), len(r)+1) {
+			r = append(r, f)
+		}
+	}
+	return r
+}
+
+//fillValue set n(or a value derived from it) to v, false if v is of a kind not checked
+func fillValue(v reflect.Value, n int) bool {
+	if v.Type() == reflect.TypeOf(time.Time{}) {
+		v.Set(reflect.ValueOf(time.Date(2000, 1, n, 0, 0, 0, 0, time.UTC)))
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(n) + 0.5)
+	case reflect.String:
+		v.SetString(fmt.Sprintf("v%d", n))
+	case reflect.Ptr:
+		e := reflect.New(v.Type().Elem())
+		if !fillValue(e.Elem(), n) {
+			return false
+		}
+		v.Set(e)
+	default:
+		return false
+	}
+	return true
+}
+
+func sameValue(a, b reflect.Value) bool {
+	if t, ok := a.Interface().(time.Time); ok {
+		return t.Equal(b.Interface().(time.Time))
+	}
+	if a.Kind() == reflect.Ptr {
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return sameValue(a.Elem(), b.Elem())
+	}
+	return a.Interface() == b.Interface()
+}
+
+//VerifyFieldMapping check fields of T survive the serializer of the cache, see VerifyFieldMapping
+func (s *RedisCache[T, I]) VerifyFieldMapping() error {
+	return VerifyFieldMapping[T](s.red.serializer)
+}
+
+//VerifyFieldMapping check fields of T survive the serializer of the cache, see VerifyFieldMapping
+func (s *FullRedisCache[T, I]) VerifyFieldMapping() error {
+	return VerifyFieldMapping[T](s.red.serializer)
+}
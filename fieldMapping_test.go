@@ -0,0 +1,59 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+type mappedBase struct {
+	ID        uint
+	CreatedAt time.Time
+}
+
+type mappedUser struct {
+	mappedBase
+	Email    string  `gorm:"column:email" json:"email" cache:"e"`
+	Nickname *string `bson:"nick"`
+	Password string  `json:"-"`
+	Session  string  `gorm:"-" json:"-"`
+}
+
+func TestVerifyFieldMapping(t *testing.T) {
+	err := cachelayer.VerifyFieldMapping[mappedUser](nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Password")
+	assert.NotContains(t, err.Error(), "Session")
+	assert.NotContains(t, err.Error(), "Email")
+
+	type fixed struct {
+		mappedBase
+		Email    string  `json:"email"`
+		Nickname *string `bson:"nick"`
+	}
+	assert.Nil(t, cachelayer.VerifyFieldMapping[fixed](nil))
+	assert.Nil(t, cachelayer.VerifyFieldMapping[*fixed](cachelayer.NewJsonSerializer(cachelayer.StdJson)))
+
+	// two fields named id in cache
+	type clash struct {
+		ID    uint
+		Other uint `json:"iD"`
+	}
+	assert.NotNil(t, cachelayer.VerifyFieldMapping[clash](nil))
+}
+
+func TestCacheTag(t *testing.T) {
+	s := cachelayer.NewJsonSerializer(cachelayer.NewJsoniterEngine(cachelayer.JsonOptions{CacheTag: true}))
+	nick := "tom"
+	u := mappedUser{Email: "a@b.c", Nickname: &nick, Password: "secret"}
+	data, err := s.Marshal(u)
+	assert.Nil(t, err)
+	assert.Contains(t, data, `"e":"a@b.c"`)
+	assert.Contains(t, data, `"Nickname":"tom"`)
+	var r mappedUser
+	assert.Nil(t, s.Unmarshal(data, &r))
+	assert.Equal(t, "a@b.c", r.Email)
+	assert.Equal(t, "", r.Password)
+}
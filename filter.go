@@ -0,0 +1,195 @@
+package cachelayer
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//FilterTag tag of cached filter results, cleared by every ClearCache since range conditions can't be matched to changed records
+const FilterTag = "filter"
+
+//FilterOp operator of a filter condition
+type FilterOp string
+
+const (
+	//FilterEq field == Values[0]
+	FilterEq FilterOp = "eq"
+	//FilterIn field is one of Values
+	FilterIn FilterOp = "in"
+	//FilterRange Values[0] <= field < Values[1], a nil bound is unbounded
+	FilterRange FilterOp = "range"
+)
+
+//FilterCond a condition of a Filter
+type FilterCond struct {
+	Field  string
+	Op     FilterOp
+	Values []interface{}
+}
+
+//filterEscaper escape separators of filter keys in values
+var filterEscaper = strings.NewReplacer("%", "%25", ",", "%2C", "/", "%2F")
+
+//filterNull null value in filter keys, escaped values never contain it
+const filterNull = "%nil"
+
+//filterValue value of a condition in filter keys: KeyValue with separators escaped, null is filterNull so it differs from "null"
+func filterValue(v interface{}) string {
+	if isNilValue(v) {
+		return filterNull
+	}
+	return filterEscaper.Replace(KeyValue(v))
+}
+
+//isNilValue whether v is nil, a nil pointer or a driver.Valuer of NULL
+func isNilValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	if (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil() {
+		return true
+	}
+	if dv, ok := v.(driver.Valuer); ok {
+		r, err := dv.Value()
+		return err == nil && isNilValue(r)
+	}
+	if rv.Kind() == reflect.Ptr {
+		return isNilValue(rv.Elem().Interface())
+	}
+	return false
+}
+
+//key canonical form of the condition, values of In are sorted
+func (s FilterCond) key() string {
+	values := make([]string, len(s.Values))
+	for i, v := range s.Values {
+		values[i] = filterValue(v)
+	}
+	if s.Op == FilterIn {
+		values = UniqueStrings(values)
+		sort.Strings(values)
+	}
+	return strings.ToLower(s.Field) + "/" + string(s.Op) + "/" + strings.Join(values, ",")
+}
+
+//Filter query builder of conditions ANDed together, ordering and limit, eg.
+// cachelayer.NewFilter().Eq("Status", 1).Range("Age", 18, 30).Order("Age", true).Limit(20)
+type Filter struct {
+	conds    []FilterCond
+	orderBys OrderBys
+	limit    int
+}
+
+func NewFilter() *Filter {
+	return &Filter{}
+}
+
+//Eq field == value
+func (s *Filter) Eq(field string, value interface{}) *Filter {
+	s.conds = append(s.conds, FilterCond{Field: field, Op: FilterEq, Values: []interface{}{value}})
+	return s
+}
+
+//In field is one of values
+func (s *Filter) In(field string, values ...interface{}) *Filter {
+	s.conds = append(s.conds, FilterCond{Field: field, Op: FilterIn, Values: values})
+	return s
+}
+
+//Range from <= field < to, nil from or to is unbounded
+func (s *Filter) Range(field string, from, to interface{}) *Filter {
+	s.conds = append(s.conds, FilterCond{Field: field, Op: FilterRange, Values: []interface{}{from, to}})
+	return s
+}
+
+//Order append an ordering field
+func (s *Filter) Order(field string, asc bool) *Filter {
+	s.orderBys = s.orderBys.Add(field, asc)
+	return s
+}
+
+//Limit max count of records, <= 0 means no limit
+func (s *Filter) Limit(limit int) *Filter {
+	s.limit = limit
+	return s
+}
+
+//Conds conditions in the order they were added
+func (s *Filter) Conds() []FilterCond {
+	return s.conds
+}
+
+//OrderBys ordering fields
+func (s *Filter) OrderBys() OrderBys {
+	return s.orderBys
+}
+
+//GetLimit max count of records, <= 0 means no limit
+func (s *Filter) GetLimit() int {
+	return s.limit
+}
+
+//Index equality index of the filter, ok is false if it has other conditions, a field twice or a limit
+func (s *Filter) Index() (Index, bool) {
+	if s.limit > 0 {
+		return nil, false
+	}
+	r := make(Index, len(s.conds))
+	fields := make(map[string]bool, len(s.conds))
+	for _, v := range s.conds {
+		field := strings.ToLower(v.Field)
+		if v.Op != FilterEq || fields[field] {
+			return nil, false
+		}
+		fields[field] = true
+		r[v.Field] = v.Values[0]
+	}
+	return r, true
+}
+
+//Key canonical serialization of the filter: conditions are sorted by field case-insensitively and In values are sorted,
+// so filters built in any order at any call site share one key, eg. where/age/range/18,30/status/eq/1/order/age asc/limit/20.
+// Separators in values are escaped and null is %nil, so distinct filters never share a key
+func (s *Filter) Key() string {
+	conds := make([]string, len(s.conds))
+	for i, v := range s.conds {
+		conds[i] = v.key()
+	}
+	sort.Strings(conds)
+	r := "where"
+	if len(conds) > 0 {
+		r += "/" + strings.Join(conds, "/")
+	}
+	if len(s.orderBys) > 0 {
+		r += "/order/" + strings.ToLower(s.orderBys.String())
+	}
+	if s.limit > 0 {
+		r += "/limit/" + strconv.Itoa(s.limit)
+	}
+	return r
+}
+
+//FilterLister database answering a Filter. Used by ListWhere
+type FilterLister[T Table[I], I IDType] interface {
+	ListWhere(filter *Filter) ([]T, error)
+}
+
+//ListWhere list records matching filter. Pure equality filters without limit are cached like ListBy, others are cached by CachedQuery
+// under filter.Key() tagged with FilterTag, so they are cleared on every write. The database must implement FilterLister for them
+func (s *RedisCache[T, I]) ListWhere(filter *Filter) ([]T, error) {
+	if index, ok := filter.Index(); ok && len(index) > 0 {
+		return s.ListBy(index, filter.OrderBys())
+	}
+	l, ok := s.db.(FilterLister[T, I])
+	if !ok {
+		return nil, s.wrapErr("list_where", "", errors.New("cachelayer: database does not implement FilterLister"))
+	}
+	return s.CachedQuery(filter.Key(), 0, func() ([]T, error) {
+		return l.ListWhere(filter)
+	}, FilterTag)
+}
@@ -0,0 +1,50 @@
+package cachelayer_test
+
+import (
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterKey(t *testing.T) {
+	a := cachelayer.NewFilter().Eq("Status", 1).Range("Age", 18, nil).In("Type", 3, 1, 2).Order("Age", true).Limit(20)
+	b := cachelayer.NewFilter().In("type", 2, 1, 3, 1).Range("age", 18, nil).Eq("status", 1).Order("Age", true).Limit(20)
+	assert.Equal(t, "where/age/range/18,%nil/status/eq/1/type/in/1,2,3/order/age asc/limit/20", a.Key())
+	assert.Equal(t, a.Key(), b.Key())
+	assert.NotEqual(t, a.Key(), b.Limit(10).Key())
+
+	index, ok := cachelayer.NewFilter().Eq("Status", 1).Eq("Type", 2).Index()
+	assert.True(t, ok)
+	assert.Equal(t, cachelayer.Index{"Status": 1, "Type": 2}, index)
+	_, ok = a.Index()
+	assert.False(t, ok)
+	_, ok = cachelayer.NewFilter().Eq("Status", 1).Limit(1).Index()
+	assert.False(t, ok)
+	// unsatisfiable, left to the database
+	_, ok = cachelayer.NewFilter().Eq("Status", 1).Eq("status", 2).Index()
+	assert.False(t, ok)
+}
+
+func TestFilterKeyCollision(t *testing.T) {
+	var none *int
+	distinct := []*cachelayer.Filter{
+		cachelayer.NewFilter().In("Name", "a,b"),
+		cachelayer.NewFilter().In("Name", "a", "b"),
+		cachelayer.NewFilter().Range("Name", "a", nil),
+		cachelayer.NewFilter().Range("Name", "a", "null"),
+		cachelayer.NewFilter().Eq("Name", "%nil"),
+		cachelayer.NewFilter().Eq("Name", "a/type/eq/1"),
+		cachelayer.NewFilter().Eq("Name", "a").Eq("Type", 1),
+		cachelayer.NewFilter().Eq("Name", "%2C"),
+		cachelayer.NewFilter().Eq("Name", ","),
+	}
+	keys := map[string]int{}
+	for i, v := range distinct {
+		if j, ok := keys[v.Key()]; ok {
+			t.Errorf("filters %d and %d share key %s", j, i, v.Key())
+		}
+		keys[v.Key()] = i
+	}
+	assert.Equal(t, cachelayer.NewFilter().Range("Name", "a", nil).Key(), cachelayer.NewFilter().Range("Name", "a", none).Key())
+}
@@ -0,0 +1,198 @@
+package cachelayer
+
+import (
+	"strings"
+)
+
+//FilteredFullCache keep a redis hash of only rows matching a fixed predicate(eg. status=active) besides the per-id cache of RedisCache,
+// for tables too big for FullRedisCache but with a small hot subset. Writes through it update the hash incrementally,
+// ClearCache(eg. by gorm plugin or invalidation events) drops the hash and it is reloaded on next ListAll.
+// Get/List/GetBy/ListBy read any row through the embedded RedisCache
+type FilteredFullCache[T Table[I], I IDType] struct {
+	*RedisCache[T, I]
+	name  string
+	match func(obj T) bool
+	load  func() ([]T, error)
+	hash  *RedisHashJson[T, I]
+	//loads collapse concurrent loads of a missing hash into one
+	loads *reloadDebouncer
+}
+
+//NewFilteredFullCache subset name of cache, match tells whether a row belongs to the subset, load loads all rows of the subset from database
+func NewFilteredFullCache[T Table[I], I IDType](cache *RedisCache[T, I], name string, match func(obj T) bool, load func() ([]T, error)) *FilteredFullCache[T, I] {
+	hash := NewRedisHashJson[T, I](cache.red.UniversalClient, cache.red.ttl)
+	hash.SetSerializer(cache.red.serializer)
+	return &FilteredFullCache[T, I]{RedisCache: cache, name: name, match: match, load: load, hash: hash, loads: &reloadDebouncer{}}
+}
+
+//CacheKey hash of the subset, eg. app/user/full/active
+func (s *FilteredFullCache[T, I]) CacheKey() string {
+	return strings.ToLower(s.keyPrefix() + "/" + s.table + "/full/" + s.name)
+}
+
+//Load load rows of the subset from database into the hash. Concurrent loads join a running one or wait for one load after it,
+// so a burst of misses does not query the database once per caller
+func (s *FilteredFullCache[T, I]) Load() error {
+	return s.loads.do(s.clock, s.table, s.reload)
+}
+
+func (s *FilteredFullCache[T, I]) reload() error {
+	start := s.clock.Now()
+	r, err := s.load()
+	s.dbLoaded(start, len(r), err, s.CacheKey())
+	if err != nil {
+		return s.wrapErr("load", s.CacheKey(), err)
+	}
+	// fill a loading key then rename it, so rows which left the subset do not survive a reload
+	key := s.CacheKey()
+	if len(r) == 0 {
+		return s.wrapErr("load", key, cacheError(s.hash.Del(s.ctx, key).Err()))
+	}
+	loadingKey, err := newLoadingKey(key)
+	if err != nil {
+		return s.wrapErr("load", key, err)
+	}
+	if err = s.hash.HSetJson(loadingKey, r...); err != nil {
+		return s.wrapErr("load", key, err)
+	}
+	// a load dying before the rename leaves an expiring key
+	if err = s.hash.Expire(s.ctx, loadingKey, s.hash.ttl).Err(); err != nil {
+		return s.wrapErr("load", loadingKey, cacheError(err))
+	}
+	if err = renameKey(s.ctx, s.hash.UniversalClient, loadingKey, key); err != nil {
+		return s.wrapErr("load", key, cacheError(err))
+	}
+	if err = s.hash.Expire(s.ctx, key, s.hash.ttl).Err(); err != nil {
+		return s.wrapErr("load", key, cacheError(err))
+	}
+	return s.wrapErr("load", key, s.hash.afterWrite(key))
+}
+
+//ListAll all rows of the subset, read by one HGETALL. An empty hash is a miss, Load does not keep the hash of an empty subset
+func (s *FilteredFullCache[T, I]) ListAll() ([]T, error) {
+	key := s.CacheKey()
+	s.hotKeys.Record(key)
+	r, err := s.hash.HGetAllJson(key)
+	if err != nil {
+		return nil, s.wrapErr("list_all", key, err)
+	}
+	if len(r) > 0 {
+		s.stats.hit(1)
+		s.trace(TraceHit, nil, key)
+		s.report("refresh", s.hash.Refresh(key))
+		return r, nil
+	}
+	s.stats.miss(1)
+	s.trace(TraceMiss, nil, key)
+	if err = s.Load(); err != nil {
+		return nil, err
+	}
+	r, err = s.hash.HGetAllJson(key)
+	return r, s.wrapErr("list_all", key, err)
+}
+
+//syncHash set and delete fields of the hash KEYS[1] only if it exists, so a hash dropped meanwhile is not recreated partially.
+// ARGV[1] is the count n of fields to set, followed by n field/value pairs and the fields to delete
+var syncHash = newScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return 0 end
+local n = tonumber(ARGV[1])
+for i = 2, 2 * n, 2 do redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1]) end
+for i = 2 * n + 2, #ARGV do redis.call('HDEL', KEYS[1], ARGV[i]) end
+return 1
+`)
+
+//sync put objs matching the predicate into the hash and remove the others, skipped if the hash is not loaded
+func (s *FilteredFullCache[T, I]) sync(objs ...T) error {
+	args := []interface{}{0}
+	var removed []interface{}
+	for _, v := range objs {
+		if s.IsNullID(v.GetID()) {
+			continue
+		}
+		id := Stringify(v.GetID(), "")
+		if !s.match(v) {
+			removed = append(removed, id)
+			continue
+		}
+		payload, err := marshal(s.hash.serializer, v)
+		if err != nil {
+			return err
+		}
+		args = append(args, id, payload)
+	}
+	if len(args) == 1 && len(removed) == 0 {
+		return nil
+	}
+	args[0] = (len(args) - 1) / 2
+	key := s.CacheKey()
+	s.hash.replicas.markWritten(key)
+	return cacheError(syncHash.Run(s.ctx, s.hash.UniversalClient, []string{key}, append(args, removed...)...).Err())
+}
+
+//syncIDs reload rows of ids and sync them into the hash
+func (s *FilteredFullCache[T, I]) syncIDs(op string, ids ...I) error {
+	objs, err := s.RedisCache.List(ids...)
+	if err != nil {
+		return s.wrapErr(op, s.CacheKey(), err)
+	}
+	return s.wrapErr(op, s.CacheKey(), s.sync(objs...))
+}
+
+func (s *FilteredFullCache[T, I]) Create(obj *T) error {
+	if err := s.RedisCache.Create(obj); err != nil {
+		return err
+	}
+	return s.wrapErr("create", s.CacheKey(), s.sync(*obj))
+}
+
+func (s *FilteredFullCache[T, I]) Save(obj *T) error {
+	if err := s.RedisCache.Save(obj); err != nil {
+		return err
+	}
+	return s.syncIDs("save", (*obj).GetID())
+}
+
+func (s *FilteredFullCache[T, I]) Upsert(obj *T, conflictColumns ...string) error {
+	if err := s.RedisCache.Upsert(obj, conflictColumns...); err != nil {
+		return err
+	}
+	return s.syncIDs("upsert", (*obj).GetID())
+}
+
+//Update update the row and move it into or out of the subset by its new values
+func (s *FilteredFullCache[T, I]) Update(id I, values interface{}) (int64, error) {
+	n, err := s.RedisCache.Update(id, values)
+	if err != nil {
+		return n, err
+	}
+	return n, s.syncIDs("update", id)
+}
+
+func (s *FilteredFullCache[T, I]) Delete(ids ...I) (int64, error) {
+	n, err := s.RedisCache.Delete(ids...)
+	if err != nil {
+		return n, err
+	}
+	return n, s.wrapErr("delete", s.CacheKey(), s.hash.HDelJson(s.CacheKey(), ids...))
+}
+
+//DeleteReturning delete records and return the deleted ones, see RedisCache.DeleteReturning
+func (s *FilteredFullCache[T, I]) DeleteReturning(ids ...I) ([]T, error) {
+	objs, err := s.RedisCache.DeleteReturning(ids...)
+	if err != nil {
+		return objs, err
+	}
+	return objs, s.wrapErr("delete_returning", s.CacheKey(), s.hash.HDelJson(s.CacheKey(), ids...))
+}
+
+//ClearCache clear cache of objs and drop the subset hash, it is reloaded on next ListAll
+func (s *FilteredFullCache[T, I]) ClearCache(objs ...T) error {
+	if err := s.RedisCache.ClearCache(objs...); err != nil {
+		return err
+	}
+	key := s.CacheKey()
+	s.hash.replicas.markWritten(key)
+	s.generation.advance()
+	_, err := DelKeys(s.ctx, s.hash.UniversalClient, key)
+	return s.wrapErr("clear_cache", key, err)
+}
@@ -0,0 +1,104 @@
+package cachelayer_test
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+//newGroupCache subset of members of group 1, counting its loads
+func newGroupCache(t *testing.T, rows ...member) (*cachelayer.FilteredFullCache[member, uint], *int32) {
+	cache, db, _ := newMemberCache(t, rows...)
+	var loads int32
+	group := cachelayer.NewFilteredFullCache(cache, "group1", func(obj member) bool { return obj.GroupID == 1 }, func() ([]member, error) {
+		atomic.AddInt32(&loads, 1)
+		// slow enough for concurrent misses to overlap
+		time.Sleep(20 * time.Millisecond)
+		return db.ListBy(cachelayer.NewIndex("GroupID", 1), nil)
+	})
+	return group, &loads
+}
+
+//names sorted names of objs
+func names(objs []member) []string {
+	r := make([]string, len(objs))
+	for i, v := range objs {
+		r[i] = v.Name
+	}
+	sort.Strings(r)
+	return r
+}
+
+func TestFilteredFullCacheSync(t *testing.T) {
+	group, loads := newGroupCache(t, member{ID: 1, Name: "tom", GroupID: 1}, member{ID: 2, Name: "ann", GroupID: 2})
+	all, err := group.ListAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"tom"}, names(all))
+
+	assert.Nil(t, group.Create(&member{Name: "bob", GroupID: 1}))
+	assert.Nil(t, group.Create(&member{Name: "joe", GroupID: 2}))
+	all, err = group.ListAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bob", "tom"}, names(all))
+
+	// moved into and out of the subset
+	_, err = group.Update(2, map[string]interface{}{"groupid": uint(1)})
+	assert.Nil(t, err)
+	_, err = group.Update(1, map[string]interface{}{"groupid": uint(2)})
+	assert.Nil(t, err)
+	all, err = group.ListAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ann", "bob"}, names(all))
+
+	_, err = group.Delete(2)
+	assert.Nil(t, err)
+	all, err = group.ListAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bob"}, names(all))
+	// every read above was served by the hash kept in sync
+	assert.Equal(t, int32(1), atomic.LoadInt32(loads))
+}
+
+func TestFilteredFullCacheDropped(t *testing.T) {
+	group, loads := newGroupCache(t, member{ID: 1, Name: "tom", GroupID: 1})
+	_, err := group.ListAll()
+	assert.Nil(t, err)
+	r, _, err := group.Get(1)
+	assert.Nil(t, err)
+	assert.Nil(t, group.ClearCache(r))
+	// writes while the hash is dropped do not recreate a partial one
+	assert.Nil(t, group.Create(&member{Name: "bob", GroupID: 1}))
+	all, err := group.ListAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bob", "tom"}, names(all))
+	assert.Equal(t, int32(2), atomic.LoadInt32(loads))
+}
+
+func TestFilteredFullCacheStampede(t *testing.T) {
+	group, loads := newGroupCache(t, member{ID: 1, Name: "tom", GroupID: 1})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			all, err := group.ListAll()
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"tom"}, names(all))
+		}()
+	}
+	wg.Wait()
+	// misses join the running load or one load after it
+	assert.LessOrEqual(t, atomic.LoadInt32(loads), int32(2))
+
+	// an empty subset is loaded on every miss but not cached
+	empty, loads := newGroupCache(t)
+	all, err := empty.ListAll()
+	assert.Nil(t, err)
+	assert.Empty(t, all)
+	assert.Equal(t, int32(1), atomic.LoadInt32(loads))
+}
@@ -2,7 +2,10 @@ package cachelayer
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -20,6 +23,25 @@ type FullDBCache[T Table[I], I IDType] interface {
 	// Close() error
 }
 
+const DefaultLoadBatchSize = 1000
+const loadingKeySuffix = ":loading"
+
+//newLoadingKey key a load of key fills before renaming it to key, eg. app/user/full:loading:{random hex}.
+// Every load has its own, so concurrent loads neither delete nor rename the hash of each other
+func newLoadingKey(key string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return key + loadingKeySuffix + ":" + hex.EncodeToString(b), nil
+}
+
+//BatchLister database which can stream all records in batches, FullRedisCache.Load uses it instead of ListAll to bound memory.
+// batch may be reused after fn returns
+type BatchLister[T Table[I], I IDType] interface {
+	ListAllInBatches(batchSize int, fn func(batch []T) error) error
+}
+
 type FullRedisCache[T Table[I], I IDType] struct {
 	*CacheBase[T, I]
 	db     FullDBCache[T, I]
@@ -27,185 +49,709 @@ type FullRedisCache[T Table[I], I IDType] struct {
 	ctx    context.Context
 	redId  *RedisJson[I]
 	redIds *RedisJson[[]I]
+	//loadBatchSize batch size of Load with BatchLister
+	loadBatchSize int
+	//hashIndexes maintain index sets of the full hash, see SetHashIndexes
+	hashIndexes bool
+	//reloads debouncer of Load, see SetReloadDebounce
+	reloads *reloadDebouncer
+	//writes ids written to the full hash while batched loads run, shared by copies
+	writes *reloadWrites[I]
 }
 
-func NewFullRedisCache[T Table[I], I IDType](prefix, table, idField string, db FullDBCache[T, I], red *redis.Client, ttl time.Duration) *FullRedisCache[T, I] {
+func NewFullRedisCache[T Table[I], I IDType](prefix, table, idField string, db FullDBCache[T, I], red redis.UniversalClient, ttl time.Duration) *FullRedisCache[T, I] {
 	return &FullRedisCache[T, I]{
-		CacheBase: &CacheBase[T, I]{prefix: prefix, table: table, idField: idField, ctx: context.Background()},
+		CacheBase: NewCacheBase[T, I](prefix, table, idField, context.Background()),
 		db:        db,
 		red:       NewRedisHashJson[T, I](red, ttl),
 		ctx:       context.Background(),
 		redId:     NewRedisJson[I](red, ttl),
 		redIds:    NewRedisJson[[]I](red, ttl),
+
+		loadBatchSize: DefaultLoadBatchSize,
+		writes:        &reloadWrites[I]{},
+	}
+}
+
+func (s *FullRedisCache[T, I]) GetDB() FullDBCache[T, I] {
+	return s.db
+}
+
+//SetCtx set context of cache operations, and of database queries if db supports it(eg. gormredis.Gorm).
+// Deprecated: it mutates a cache shared by goroutines, use WithContext per call
+func (s *FullRedisCache[T, I]) SetCtx(ctx context.Context) {
+	s.CacheBase.SetCtx(ctx)
+	s.ctx = ctx
+	if c, ok := s.db.(interface{ SetCtx(context.Context) }); ok {
+		c.SetCtx(ctx)
+	}
+}
+
+//SetNullIDFunc override which ids are null, and of the database if it decides null ids itself(eg. gormredis.Gorm inserting or upserting on Save)
+func (s *FullRedisCache[T, I]) SetNullIDFunc(fn func(id I) bool) {
+	s.CacheBase.SetNullIDFunc(fn)
+	if d, ok := s.db.(interface{ SetNullIDFunc(fn func(id I) bool) }); ok {
+		d.SetNullIDFunc(fn)
 	}
 }
 
+//SetExpirationPolicy set ttl semantics of all cache keys of this cache, maxTTL is only used by ExpirationSlidingWithMax
+func (s *FullRedisCache[T, I]) SetExpirationPolicy(policy ExpirationPolicy, maxTTL time.Duration) {
+	s.red.SetExpirationPolicy(policy, maxTTL)
+	s.redId.SetExpirationPolicy(policy, maxTTL)
+	s.redIds.SetExpirationPolicy(policy, maxTTL)
+}
+
+func (s *FullRedisCache[T, I]) SetSerializer(serializer Serializer) {
+	s.red.SetSerializer(serializer)
+	s.redId.SetSerializer(serializer)
+	s.redIds.SetSerializer(serializer)
+}
+
+//SetNullTTL ttl of cached "not found" entries, 0 means same as ttl
+func (s *FullRedisCache[T, I]) SetNullTTL(nullTTL time.Duration) {
+	s.red.SetNullTTL(nullTTL)
+	s.redId.SetNullTTL(nullTTL)
+	s.redIds.SetNullTTL(nullTTL)
+}
+
+//WithoutNegativeCache return a copy of the cache which does not cache "not found" results, eg. cache.WithoutNegativeCache().GetBy(index)
+func (s *FullRedisCache[T, I]) WithoutNegativeCache() *FullRedisCache[T, I] {
+	r := *s
+	base := *s.CacheBase
+	base.noNegativeCache = true
+	r.CacheBase = &base
+	return &r
+}
+
+//WithContext return a copy of the cache whose calls carry ctx, see RedisCache.WithContext
+func (s *FullRedisCache[T, I]) WithContext(ctx context.Context) *FullRedisCache[T, I] {
+	r := *s
+	base := *s.CacheBase
+	base.ctx = ctx
+	r.CacheBase = &base
+	r.ctx = ctx
+	r.red = s.red.withContext(ctx)
+	r.redId = s.redId.withContext(ctx)
+	r.redIds = s.redIds.withContext(ctx)
+	if c, ok := s.db.(ContextDB[T, I]); ok {
+		if db, ok := c.WithContext(ctx).(FullDBCache[T, I]); ok {
+			r.db = db
+		}
+	}
+	return &r
+}
+
 func (s *FullRedisCache[T, I]) CacheKey() string {
-	r := s.prefix + "/" + s.table + "/full"
+	r := s.keyPrefix() + "/" + s.table + "/full"
 	return strings.ToLower(r)
 }
 
+//Load load all records from database into the full hash, fire EventReloadFailure on error. Loads are collapsed if SetReloadDebounce is set
 func (s *FullRedisCache[T, I]) Load() error {
+	load := func() error {
+		err := s.load()
+		if err != nil {
+			s.notify(CacheEvent{Type: EventReloadFailure, Error: err.Error()})
+		}
+		return err
+	}
+	if s.reloads != nil {
+		return s.reloads.do(s.clock, s.table, load)
+	}
+	return load()
+}
+
+func (s *FullRedisCache[T, I]) load() error {
+	if bl, ok := s.db.(BatchLister[T, I]); ok {
+		return s.loadInBatches(bl)
+	}
+	start := s.clock.Now()
 	r, err := s.db.ListAll()
+	s.dbLoaded(start, len(r), err, s.CacheKey())
 	if err != nil {
-		return err
+		return s.wrapErr("load", "", err)
 	}
 
 	key := s.CacheKey()
 	err = s.red.HSetJson(key, r...)
 	if err != nil {
-		return err
+		return s.wrapErr("load", "", err)
+	}
+	if err = s.red.Expire(s.ctx, key, s.red.ttl).Err(); err != nil {
+		return s.wrapErr("load", "", cacheError(err))
 	}
-	return s.red.Expire(s.ctx, key, s.red.ttl).Err()
+	if err = s.addRefs(s.red.UniversalClient, s.red.storeTTL(), map[string][]I{key: listIDs[T, I](r...)}); err != nil {
+		return s.wrapErr("load", "", err)
+	}
+	if s.hashIndexes {
+		sets := make(map[string][]interface{})
+		s.addIndexSets(sets, r...)
+		if err = s.rebuildIndexes(sets); err != nil {
+			return s.wrapErr("load", "", err)
+		}
+	}
+	return s.wrapErr("load", "", s.red.afterWrite(key))
+}
+
+//loadInBatches stream records into a loading key batch by batch, then rename it to the full cache key, so readers never see a partial hash.
+// Records written through the cache meanwhile are merged into it, and it is dropped if the cache was cleared meanwhile
+func (s *FullRedisCache[T, I]) loadInBatches(bl BatchLister[T, I]) error {
+	key := s.CacheKey()
+	loadingKey, err := newLoadingKey(key)
+	if err != nil {
+		return s.wrapErr("load", key, err)
+	}
+	count := 0
+	gen := s.generation.current()
+	s.writes.begin()
+	start := s.clock.Now()
+	var cacheErr error
+	sets := make(map[string][]interface{})
+	err = bl.ListAllInBatches(s.loadBatchSize, func(batch []T) error {
+		count += len(batch)
+		if s.hashIndexes {
+			s.addIndexSets(sets, batch...)
+		}
+		if cacheErr = s.red.HSetJson(loadingKey, batch...); cacheErr != nil {
+			return cacheErr
+		}
+		if cacheErr = cacheError(s.red.Expire(s.ctx, loadingKey, s.red.ttl).Err()); cacheErr != nil {
+			return cacheErr
+		}
+		cacheErr = s.addRefs(s.red.UniversalClient, s.red.storeTTL(), map[string][]I{key: listIDs[T, I](batch...)})
+		return cacheErr
+	})
+	if cacheErr == nil {
+		s.dbLoaded(start, count, err, key)
+	}
+	if err != nil {
+		s.writes.finish(func([]I) error { return nil })
+		s.report("load", s.red.Del(s.ctx, loadingKey).Err())
+		return s.wrapErr("load", key, err)
+	}
+	err = s.writes.finish(func(written []I) error {
+		// dropped if the hash was cleared meanwhile, records loaded before may be stale
+		return s.generation.since(gen, func() error {
+			present := count > 0
+			if len(written) > 0 {
+				if present, err = s.mergeWrites(key, loadingKey, written, sets); err != nil {
+					return err
+				}
+			}
+			if !present {
+				return cacheError(s.red.Del(s.ctx, key).Err())
+			}
+			if err := renameKey(s.ctx, s.red.UniversalClient, loadingKey, key); err != nil {
+				return err
+			}
+			if err := s.red.Expire(s.ctx, key, s.red.ttl).Err(); err != nil {
+				return cacheError(err)
+			}
+			if s.hashIndexes {
+				return s.rebuildIndexes(sets)
+			}
+			return nil
+		})
+	})
+	if err == errStaleWrite {
+		s.report("load", s.red.Del(s.ctx, loadingKey).Err())
+		return nil
+	}
+	if err != nil {
+		return s.wrapErr("load", key, err)
+	}
+	return s.wrapErr("load", key, s.red.afterWrite(key))
+}
+
+//mergeWrites copy records of ids written to the live hash during a batched load into the loading hash, ids missing there are deleted.
+// sets of the load are updated by the copied records if hash indexes are on. Return whether the loading hash has records
+func (s *FullRedisCache[T, I]) mergeWrites(key, loadingKey string, ids []I, sets map[string][]interface{}) (bool, error) {
+	fields := make([]string, len(ids))
+	written := make(map[interface{}]bool, len(ids))
+	for i, v := range ids {
+		fields[i] = Stringify(v, "")
+		written[fields[i]] = true
+	}
+	values, err := s.red.HMGet(s.ctx, key, fields...).Result()
+	if err != nil {
+		return false, cacheError(err)
+	}
+	if s.hashIndexes {
+		objs, err := s.red.HMGetJson(key, ids...)
+		if err != nil {
+			return false, err
+		}
+		for k, v := range sets {
+			kept := v[:0]
+			for _, id := range v {
+				if !written[id] {
+					kept = append(kept, id)
+				}
+			}
+			sets[k] = kept
+		}
+		s.addIndexSets(sets, s.existingRecords(objs)...)
+	}
+	var set []interface{}
+	var del []string
+	for i, v := range values {
+		if v == nil {
+			del = append(del, fields[i])
+		} else {
+			set = append(set, fields[i], v)
+		}
+	}
+	p := s.red.Pipeline()
+	if len(set) > 0 {
+		p.HSet(s.ctx, loadingKey, set...)
+	}
+	if len(del) > 0 {
+		p.HDel(s.ctx, loadingKey, del...)
+	}
+	n := p.Exists(s.ctx, loadingKey)
+	if _, err = p.Exec(s.ctx); err != nil {
+		return false, cacheError(err)
+	}
+	return n.Val() > 0, nil
+}
+
+//reloadWrites ids written to the full hash while batched loads build a new hash, merged into it before it replaces the live one.
+// Writes hold the read lock across their hash write, so none lands on the live hash between the merge and the rename
+type reloadWrites[I IDType] struct {
+	mu    sync.RWMutex
+	idsMu sync.Mutex
+	loads int
+	ids   map[I]bool
+}
+
+//begin start tracking writes for a load
+func (s *reloadWrites[I]) begin() {
+	s.idsMu.Lock()
+	defer s.idsMu.Unlock()
+	s.loads++
+	if s.ids == nil {
+		s.ids = make(map[I]bool)
+	}
+}
+
+//write run fn writing records of ids to the live hash
+func (s *reloadWrites[I]) write(ids []I, fn func() error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.idsMu.Lock()
+	if s.loads > 0 {
+		for _, v := range ids {
+			s.ids[v] = true
+		}
+	}
+	s.idsMu.Unlock()
+	return fn()
+}
+
+//finish end tracking writes for a load, fn merges ids written since any running load began, writes wait for it
+func (s *reloadWrites[I]) finish(fn func(written []I) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idsMu.Lock()
+	written := make([]I, 0, len(s.ids))
+	for v := range s.ids {
+		written = append(written, v)
+	}
+	s.loads--
+	if s.loads == 0 {
+		s.ids = nil
+	}
+	s.idsMu.Unlock()
+	return fn(written)
+}
+
+//SetLoadBatchSize batch size of Load if db implements BatchLister, default DefaultLoadBatchSize
+func (s *FullRedisCache[T, I]) SetLoadBatchSize(batchSize int) {
+	s.loadBatchSize = batchSize
 }
 
 func (s *FullRedisCache[T, I]) Get(id I) (T, bool, error) {
+	r, exists, err := s.get(id)
+	return r, exists, s.notFound("get", s.CacheKey(), exists, err)
+}
+
+func (s *FullRedisCache[T, I]) get(id I) (T, bool, error) {
 	key := s.CacheKey()
+	s.hotKeys.Record(key)
+	start := s.clock.Now()
 	r, exists, err := s.red.HGetJson(key, id)
+	s.observe(OpCacheRead, key, start, err)
 	if err != nil {
 		return r, false, err
 	}
 	if exists {
+		s.stats.hit(1)
+		s.trace(TraceHit, nil, key)
 		return r, true, nil
 	}
+	s.stats.miss(1)
+	s.trace(TraceMiss, nil, key)
 	if err := s.Load(); err != nil {
 		return r, false, err
 	}
-	s.red.Expires(key)
+	s.report("refresh", s.red.Refresh(key))
 	return s.red.HGetJson(key, id)
 }
 
+//List records of ids from the full hash, the i-th record is the record of ids[i] and a zero record if it is not found
 func (s *FullRedisCache[T, I]) List(id ...I) ([]T, error) {
 	key := s.CacheKey()
+	s.hotKeys.Record(key)
 	count, err := s.red.Exists(s.ctx, key).Result()
 	if err != nil {
-		return nil, err
+		return nil, s.wrapErr("list", key, cacheError(err))
 	}
 	if count == 0 {
+		s.stats.miss(len(id))
+		s.trace(TraceMiss, nil, key)
 		if err := s.Load(); err != nil {
-			return nil, err
+			return nil, s.wrapErr("list", key, err)
 		}
+	} else {
+		s.stats.hit(len(id))
+		s.trace(TraceHit, nil, key)
 	}
-	s.red.Expires(key)
-	return s.red.HMGetJson(key, id...)
+	s.report("refresh", s.red.Refresh(key))
+	r, err := s.red.HMGetJson(key, id...)
+	return r, s.wrapErr("list", key, err)
 }
 
 func (s *FullRedisCache[T, I]) Create(r *T) error {
+	if _, err := s.FillID(r); err != nil {
+		return s.wrapErr("create", "", err)
+	}
 	if err := s.db.Create(r); err != nil {
-		return err
+		return s.wrapErr("create", "", err)
+	}
+	if err := s.hset(*r); err != nil {
+		return s.wrapErr("create", "", err)
+	}
+	s.report("reindex", s.reindex(nil, []T{*r}))
+	return s.wrapErr("create", "", s.clearRefs(*r))
+}
+//Upsert insert r or update the row conflicting on conflictColumns(id if empty) atomically, db must implement Upserter.
+// The full hash is updated in place, index keys of both the replaced row and r are cleared
+func (s *FullRedisCache[T, I]) Upsert(r *T, conflictColumns ...string) error {
+	u, ok := s.db.(Upserter[T, I])
+	if !ok {
+		return s.wrapErr("upsert", "", ErrUpsertNotSupported)
+	}
+	old, existed, err := u.Upsert(r, conflictColumns...)
+	if err != nil {
+		return s.wrapErr("upsert", "", err)
+	}
+	objs := []T{*r}
+	if existed {
+		objs = append(objs, old)
+		if old.GetID() != (*r).GetID() {
+			if err = s.writes.write([]I{old.GetID()}, func() error { return s.red.HDelJson(s.CacheKey(), old.GetID()) }); err != nil {
+				return s.wrapErr("upsert", "", err)
+			}
+		}
+	}
+	if err = s.hset(*r); err != nil {
+		return s.wrapErr("upsert", "", err)
 	}
-	return s.red.HSetJson(s.CacheKey(), *r)
+	s.report("reindex", s.reindex(objs[1:], objs[:1]))
+	var keys []string
+	for _, v := range objs {
+		for _, index := range v.ListIndexes() {
+			keys = append(keys, s.MakeCacheKey(index))
+		}
+	}
+	s.red.replicas.markWritten(keys...)
+	if _, err = s.delKeys(s.red.UniversalClient, s.red.withAuxKeys(keys)...); err != nil {
+		return s.wrapErr("upsert", "", err)
+	}
+	return s.wrapErr("upsert", "", s.clearRefs(objs...))
 }
+
+//Save upsert r if db implements Upserter, otherwise create r if it is not found or update it
 func (s *FullRedisCache[T, I]) Save(r *T) error {
-	_, exists, err := s.Get((*r).GetID())
+	if _, ok := s.db.(Upserter[T, I]); ok && !s.IsNullID((*r).GetID()) {
+		return s.Upsert(r)
+	}
+	old, exists, err := s.get((*r).GetID())
 	if err != nil {
-		return err
+		return s.wrapErr("save", "", err)
 	}
-	if IsNullID((*r).GetID()) || !exists {
+	if s.IsNullID((*r).GetID()) || !exists {
 		if err := s.db.Create(r); err != nil {
-			return err
+			return s.wrapErr("save", "", err)
 		}
 	} else {
 		if err := s.db.Save(r); err != nil {
-			return err
+			return s.wrapErr("save", "", err)
 		}
 	}
-	return s.red.HSetJson(s.CacheKey(), *r)
+	if err := s.hset(*r); err != nil {
+		return s.wrapErr("save", "", err)
+	}
+	var olds []T
+	if exists {
+		olds = append(olds, old)
+	}
+	s.report("reindex", s.reindex(olds, []T{*r}))
+	return s.wrapErr("save", "", s.clearRefs(*r))
 }
 func (s *FullRedisCache[T, I]) Update(id I, values interface{}) (int64, error) {
-	if IsNullID(id) {
+	if s.IsNullID(id) {
 		return 0, nil
 	}
-
+	var olds []T
+	if s.hashIndexes || s.hasRelations() {
+		old, exists, err := s.red.HGetJson(s.CacheKey(), id)
+		if err != nil {
+			return 0, s.wrapErr("update", "", err)
+		}
+		if exists {
+			olds = append(olds, old)
+		}
+	}
 	effectedRows, err := s.db.Update(id, values)
 	if err != nil {
-		return 0, err
+		return 0, s.wrapErr("update", "", err)
 	}
 	r, _, err := s.db.Get(id)
 	if err != nil {
-		return 0, err
+		return 0, s.wrapErr("update", "", err)
+	}
+	if err = s.hset(r); err != nil {
+		return effectedRows, s.wrapErr("update", "", err)
+	}
+	s.report("reindex", s.reindex(olds, []T{r}))
+	return effectedRows, s.wrapErr("update", "", s.clearRefs(append(olds, r)...))
+}
+//DeleteReturning delete records and return the deleted ones, eg. for audit logs. Missing ids are skipped.
+// Rows are deleted and returned atomically if db implements DeleteReturner, see RedisCache.DeleteReturning
+func (s *FullRedisCache[T, I]) DeleteReturning(ids ...I) ([]T, error) {
+	d, ok := s.db.(DeleteReturner[T, I])
+	if !ok {
+		objs, err := s.List(ids...)
+		if err != nil {
+			return nil, s.wrapErr("delete_returning", "", err)
+		}
+		objs = s.existingRecords(objs)
+		if _, err = s.Delete(ids...); err != nil {
+			return nil, err
+		}
+		return objs, nil
+	}
+	objs, err := d.DeleteReturning(ids...)
+	if err != nil {
+		return nil, s.wrapErr("delete_returning", "", err)
 	}
-	return effectedRows, s.red.HSetJson(s.CacheKey(), r)
+	return objs, s.wrapErr("delete_returning", "", s.deleted(ids, objs))
 }
+
 func (s *FullRedisCache[T, I]) Delete(ids ...I) (int64, error) {
+	var objs []T
+	if s.hasRelations() || s.hashIndexes {
+		all, err := s.List(ids...)
+		if err != nil {
+			return 0, s.wrapErr("delete", "", err)
+		}
+		objs = s.existingRecords(all)
+	}
 	rowsAffected, err := s.db.Delete(ids...)
 	if err != nil {
-		return 0, err
+		return 0, s.wrapErr("delete", "", err)
 	}
-	s.red.HDelJson(s.CacheKey(), ids...)
-	return rowsAffected, err
+	return rowsAffected, s.wrapErr("delete", "", s.deleted(ids, objs))
+}
+
+//deleted remove records of ids deleted from database out of the full hash, objs are the deleted rows if known
+func (s *FullRedisCache[T, I]) deleted(ids []I, objs []T) error {
+	related := s.relatedKeys(objs...)
+	err := s.writes.write(ids, func() error { return s.red.HDelJson(s.CacheKey(), ids...) })
+	s.trace(TraceInvalidate, err, s.CacheKey())
+	s.report("invalidation", err)
+	s.report("reindex", s.reindex(objs, nil))
+	refs, err := s.listRefs(s.red.UniversalClient, ids...)
+	refs = append(refs, related...)
+	if err == nil && len(refs) > 0 {
+		s.red.replicas.markWritten(refs...)
+		_, err = s.delKeys(s.red.UniversalClient, s.red.withAuxKeys(refs)...)
+	}
+	if err == nil {
+		s.publishInvalidation(append(refs, s.CacheKey()), ids...)
+	}
+	return err
 }
 
 func (s *FullRedisCache[T, I]) ListAll() ([]T, error) {
 	key := s.CacheKey()
+	s.hotKeys.Record(key)
 	count, err := s.red.Exists(s.ctx, key).Result()
 	if err != nil {
-		return nil, err
+		return nil, s.wrapErr("list_all", key, cacheError(err))
 	}
 	if count == 0 {
+		s.stats.miss(1)
+		s.trace(TraceMiss, nil, key)
 		if err := s.Load(); err != nil {
-			return nil, err
+			return nil, s.wrapErr("list_all", key, err)
 		}
+	} else {
+		s.stats.hit(1)
+		s.trace(TraceHit, nil, key)
 	}
-	s.red.Expires(key)
-	return s.red.HGetAllJson(key)
+	s.report("refresh", s.red.Refresh(key))
+	r, err := s.red.HGetAllJson(key)
+	return r, s.wrapErr("list_all", key, err)
 }
 
 func (s *FullRedisCache[T, I]) ClearCache(objs ...T) error {
-	return s.red.Del(s.ctx, s.CacheKey()).Err()
+	refs, err := s.listRefs(s.red.UniversalClient, listIDs[T, I](objs...)...)
+	if err != nil {
+		return s.wrapErr("clear_cache", "", err)
+	}
+	keys := UniqueStrings(append(append(refs, s.relatedKeys(objs...)...), s.CacheKey()))
+	s.stats.invalidate(len(keys))
+	s.red.replicas.markWritten(keys...)
+	// batched loads running are dropped
+	s.generation.advance()
+	if _, err = s.delKeys(s.red.UniversalClient, s.red.withAuxKeys(keys)...); err != nil {
+		return s.wrapErr("clear_cache", "", err)
+	}
+	s.publishInvalidation(keys, listIDs[T, I](objs...)...)
+	return nil
+}
+
+//hset write r to the full hash
+func (s *FullRedisCache[T, I]) hset(r T) error {
+	return s.writes.write([]I{r.GetID()}, func() error { return s.red.HSetJson(s.CacheKey(), r) })
+}
+
+//clearRefs delete index keys referencing objs, the full hash itself is kept
+func (s *FullRedisCache[T, I]) clearRefs(objs ...T) error {
+	refs, err := s.listRefs(s.red.UniversalClient, listIDs[T, I](objs...)...)
+	if err != nil {
+		return err
+	}
+	key := s.CacheKey()
+	keys := s.relatedKeys(objs...)
+	for _, v := range refs {
+		if v != key {
+			keys = append(keys, v)
+		}
+	}
+	s.red.replicas.markWritten(keys...)
+	if _, err = s.delKeys(s.red.UniversalClient, s.red.withAuxKeys(keys)...); err != nil {
+		return err
+	}
+	// the hash is updated in place here, other deployments reload it
+	s.publishInvalidation(append(keys, key), listIDs[T, I](objs...)...)
+	return nil
 }
 
 func (s *FullRedisCache[T, I]) GetBy(index Index) (T, bool, error) {
-	// fetch id from redis
 	redisKey := s.MakeCacheKey(index)
+	if s.isHashIndex(index) {
+		objs, err := s.listIndexed(index, nil)
+		if err != nil || len(objs) == 0 {
+			var r T
+			return r, false, s.notFound("get_by", redisKey, false, err)
+		}
+		return objs[0], true, nil
+	}
+	if !s.IsUniqueIndex(index) {
+		objs, err := s.ListBy(index, nil)
+		if err != nil || len(objs) == 0 {
+			var r T
+			return r, false, s.notFound("get_by", redisKey, false, err)
+		}
+		return objs[0], true, nil
+	}
+	// fetch id from redis
+	s.hotKeys.Record(redisKey)
 	var r T
-	cachedId, exists, err := s.redId.GetJson(redisKey)
+	start := s.clock.Now()
+	cachedId, exists, isNull, err := s.redId.getJson(redisKey)
+	s.observe(OpCacheRead, redisKey, start, err)
 	if err != nil && err != redis.Nil {
-		return r, false, err
+		return r, false, s.wrapErr("get_by", redisKey, err)
 	}
-	if exists && IsNullID(cachedId) {
-		return r, false, nil
+	if exists && (isNull || s.IsNullID(cachedId)) {
+		s.stats.hit(1)
+		s.stats.nullHit()
+		s.trace(TraceNullHit, nil, redisKey)
+		return r, false, s.notFound("get_by", redisKey, false, nil)
 	}
 	if exists {
-		s.red.Expires(redisKey)
-		return s.Get(cachedId)
+		s.stats.hit(1)
+		s.trace(TraceHit, nil, redisKey)
+		s.report("refresh", s.red.Refresh(redisKey))
+		r, exists, err = s.get(cachedId)
+		return r, exists, s.notFound("get_by", redisKey, exists, err)
 	}
 	// search from db
-	r, exists, err = s.db.GetBy(index)
+	s.stats.miss(1)
+	s.trace(TraceMiss, nil, redisKey)
+	start = s.clock.Now()
+	r, exists, err = getByUnique[T, I](s.db, index)
+	s.dbLoaded(start, rowsOf(exists), err, redisKey)
 	if err != nil {
-		return r, false, err
+		return r, false, s.wrapErr("get_by", redisKey, err)
 	}
 	if !exists {
-		err = s.red.SetNull(redisKey)
-		return r, exists, err
+		if !s.noNegativeCache {
+			err = s.red.SetNull(redisKey)
+		}
+		return r, exists, s.notFound("get_by", redisKey, exists, err)
 	}
 	// set id to redis
-	err = s.redId.SetJson(redisKey, r.GetID())
-	return r, true, err
+	if err = s.redId.SetJson(redisKey, r.GetID()); err != nil {
+		return r, true, s.wrapErr("get_by", redisKey, err)
+	}
+	err = s.addRefs(s.red.UniversalClient, s.redId.storeTTL(), map[string][]I{redisKey: {r.GetID()}})
+	return r, true, s.wrapErr("get_by", redisKey, err)
 }
 
 func (s *FullRedisCache[T, I]) ListBy(index Index, orderBys OrderBys) ([]T, error) {
+	if s.isHashIndex(index) {
+		r, err := s.listIndexed(index, orderBys)
+		return r, s.wrapErr("list_by", s.IndexSetKey(index), err)
+	}
 	// fetch ids from redis
 	redisKey := s.MakeCacheKey(index)
+	s.hotKeys.Record(redisKey)
 	var r []T
+	start := s.clock.Now()
 	cachedIds, exists, err := s.redIds.GetJson(redisKey)
+	s.observe(OpCacheRead, redisKey, start, err)
 	if err != nil && err != redis.Nil {
-		return nil, err
+		return nil, s.wrapErr("list_by", redisKey, err)
 	}
 	if exists {
-		s.red.Expires(redisKey)
+		s.stats.hit(1)
+		s.trace(TraceHit, nil, redisKey)
+		s.report("refresh", s.red.Refresh(redisKey))
 		return s.List(cachedIds...)
 	}
 	// search from db
+	s.stats.miss(1)
+	s.trace(TraceMiss, nil, redisKey)
+	start = s.clock.Now()
 	r, err = s.db.ListBy(index, orderBys)
+	s.dbLoaded(start, len(r), err, redisKey)
 	if err != nil {
-		return nil, err
+		return nil, s.wrapErr("list_by", redisKey, err)
 	}
 	ids := make([]I, len(r))
 	for i, v := range r {
 		ids[i] = v.GetID()
 	}
 	// set ids to redis
-	err = s.redIds.SetJson(redisKey, ids)
-	return r, err
+	if err = s.redIds.SetJson(redisKey, ids); err != nil {
+		return r, s.wrapErr("list_by", redisKey, err)
+	}
+	err = s.addRefs(s.red.UniversalClient, s.redIds.storeTTL(), map[string][]I{redisKey: ids})
+	return r, s.wrapErr("list_by", redisKey, err)
 }
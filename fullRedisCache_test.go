@@ -0,0 +1,102 @@
+package cachelayer_test
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+//batchDB memDB streaming all rows in batches, every load waits at barrier and runs during after its first batch
+type batchDB struct {
+	*memDB
+	barrier *sync.WaitGroup
+	during  func()
+}
+
+func (s batchDB) ListAllInBatches(batchSize int, fn func(batch []member) error) error {
+	all, err := s.ListAll()
+	if err != nil {
+		return err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	for i := 0; i < len(all); i += batchSize {
+		end := i + batchSize
+		if end > len(all) {
+			end = len(all)
+		}
+		if err = fn(all[i:end]); err != nil {
+			return err
+		}
+		if i == 0 && s.barrier != nil {
+			s.barrier.Done()
+			s.barrier.Wait()
+		}
+		if i == 0 && s.during != nil {
+			s.during()
+		}
+	}
+	return nil
+}
+
+func TestConcurrentLoads(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	barrier := &sync.WaitGroup{}
+	barrier.Add(2)
+	db := batchDB{memDB: newMemDB(member{ID: 1, Name: "tom"}, member{ID: 2, Name: "ann"}, member{ID: 3, Name: "bob"}), barrier: barrier}
+	cache := cachelayer.NewFullRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	cache.SetLoadBatchSize(2)
+	// both loads fill their first batch before either finishes
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- cache.Load() }()
+	}
+	assert.Nil(t, <-errs)
+	assert.Nil(t, <-errs)
+	assert.Equal(t, []string{cache.CacheKey()}, mr.Keys())
+	objs, err := cache.ListAll()
+	assert.Nil(t, err)
+	assert.Len(t, objs, 3)
+}
+
+func TestLoadMergesWrites(t *testing.T) {
+	_, red := newMiniRedis(t)
+	db := &batchDB{memDB: newMemDB(member{ID: 1, Name: "tom"}, member{ID: 2, Name: "ann"}, member{ID: 3, Name: "bob"})}
+	cache := cachelayer.NewFullRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	cache.SetLoadBatchSize(2)
+	assert.Nil(t, cache.Load())
+	// written through the cache after the rows were read, before the loaded hash replaces the live one
+	db.during = func() {
+		_, err := cache.Update(1, map[string]interface{}{"Name": "jerry"})
+		assert.Nil(t, err)
+		_, err = cache.Delete(3)
+		assert.Nil(t, err)
+		assert.Nil(t, cache.Create(&member{ID: 4, Name: "lily"}))
+	}
+	assert.Nil(t, cache.Load())
+	objs, err := cache.List(1, 2, 3, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"jerry", "ann", "", "lily"}, []string{objs[0].Name, objs[1].Name, objs[2].Name, objs[3].Name})
+}
+
+func TestLoadDroppedByClear(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	db := &batchDB{memDB: newMemDB(member{ID: 1, Name: "tom"}, member{ID: 2, Name: "ann"}, member{ID: 3, Name: "bob"})}
+	cache := cachelayer.NewFullRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	cache.SetLoadBatchSize(2)
+	// changed outside the cache and cleared while the rows read before are loaded
+	db.during = func() {
+		_, err := db.memDB.Update(2, map[string]interface{}{"Name": "jerry"})
+		assert.Nil(t, err)
+		assert.Nil(t, cache.ClearCache(member{ID: 2}))
+	}
+	assert.Nil(t, cache.Load())
+	assert.Empty(t, mr.Keys())
+	db.during = nil
+	r, _, err := cache.Get(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "jerry", r.Name)
+}
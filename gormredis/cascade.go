@@ -0,0 +1,120 @@
+package gormredis
+
+import (
+	"errors"
+
+	"github.com/daqiancode/cachelayer"
+	"gorm.io/gorm"
+)
+
+var errNotGorm = errors.New("gormredis: cache is not backed by gorm")
+
+//Child child table of a cascading delete, rows reference parent ids of type P by a foreign key
+type Child[P cachelayer.IDType] interface {
+	//deleteChildren delete rows referencing parentIDs and their own children in tx, return the invalidation to run after commit
+	deleteChildren(tx *gorm.DB, parentIDs []P) (func() error, error)
+}
+
+type child[T cachelayer.Table[I], I cachelayer.IDType, P cachelayer.IDType] struct {
+	cache      *cachelayer.RedisCache[T, I]
+	foreignKey string
+	children   []Child[I]
+}
+
+//NewChild child table of cache whose column foreignKey references parents, children are grandchildren referencing its ids, eg.
+//
+//	items := gormredis.NewChild[OrderItem, uint, uint](itemCache, "order_id")
+//	gormredis.CascadeDelete(orderCache, []uint{orderID}, items)
+func NewChild[T cachelayer.Table[I], I cachelayer.IDType, P cachelayer.IDType](cache *cachelayer.RedisCache[T, I], foreignKey string, children ...Child[I]) Child[P] {
+	return &child[T, I, P]{cache: cache, foreignKey: foreignKey, children: children}
+}
+
+func (s *child[T, I, P]) deleteChildren(tx *gorm.DB, parentIDs []P) (func() error, error) {
+	g, ok := s.cache.GetDB().(*Gorm[T, I])
+	if !ok {
+		return nil, errNotGorm
+	}
+	g = g.withTx(tx)
+	var objs []T
+	if err := g.writer().Where(map[string]interface{}{s.foreignKey: parentIDs}).Find(&objs).Error; err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return func() error { return nil }, nil
+	}
+	return deleteCascade(g, s.cache, objs, s.children)
+}
+
+//CascadeDelete delete records of ids and their children(recursively) in one transaction, cache of all deleted rows is cleared after commit.
+// Return count of deleted parent rows
+func CascadeDelete[T cachelayer.Table[I], I cachelayer.IDType](cache *cachelayer.RedisCache[T, I], ids []I, children ...Child[I]) (int64, error) {
+	g, ok := cache.GetDB().(*Gorm[T, I])
+	if !ok {
+		return 0, errNotGorm
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var n int64
+	var clear func() error
+	err := g.db.WithContext(g.ctx).Transaction(func(tx *gorm.DB) error {
+		gtx := g.withTx(tx)
+		var objs []T
+		if err := gtx.writer().Find(&objs, ids).Error; err != nil {
+			return err
+		}
+		var err error
+		if clear, err = deleteCascade(gtx, cache, objs, children); err != nil {
+			return err
+		}
+		n = int64(len(objs))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, clear()
+}
+
+//deleteCascade delete children of objs, then objs in the transaction of g
+func deleteCascade[T cachelayer.Table[I], I cachelayer.IDType](g *Gorm[T, I], cache *cachelayer.RedisCache[T, I], objs []T, children []Child[I]) (func() error, error) {
+	ids := make([]I, len(objs))
+	for i, v := range objs {
+		ids[i] = v.GetID()
+	}
+	clears := make([]func() error, 0, len(children)+1)
+	for _, v := range children {
+		clear, err := v.deleteChildren(g.db, ids)
+		if err != nil {
+			return nil, err
+		}
+		clears = append(clears, clear)
+	}
+	if len(ids) > 0 {
+		if err := g.writer().Delete(new(T), ids).Error; err != nil {
+			return nil, err
+		}
+		if g.outbox != nil {
+			if err := g.outbox.Record(g.db, g.outboxKeys(objs...)...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	clears = append(clears, func() error { return cache.ClearCache(objs...) })
+	return func() error {
+		var first error
+		for _, v := range clears {
+			if err := v(); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}, nil
+}
+
+//withTx copy of s running in transaction tx
+func (s *Gorm[T, I]) withTx(tx *gorm.DB) *Gorm[T, I] {
+	g := *s
+	g.db = tx
+	return &g
+}
@@ -0,0 +1,84 @@
+package gormredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/stretchr/testify/assert"
+)
+
+//Line order line of a product
+type Line struct {
+	ID        uint
+	ProductID uint
+}
+
+func (s Line) GetID() uint {
+	return s.ID
+}
+
+func (s Line) ListIndexes() cachelayer.Indexes {
+	return cachelayer.Indexes{}.Add(cachelayer.NewIndex("ProductID", s.ProductID))
+}
+
+//Note note of an order line
+type Note struct {
+	ID     uint
+	LineID uint
+}
+
+func (s Note) GetID() uint {
+	return s.ID
+}
+
+func (s Note) ListIndexes() cachelayer.Indexes {
+	return cachelayer.Indexes{}.Add(cachelayer.NewIndex("LineID", s.LineID))
+}
+
+func TestCascadeDelete(t *testing.T) {
+	db := newSQLite(t, Product{ID: 1, Name: "apple", CategoryID: 1}, Product{ID: 2, Name: "pear", CategoryID: 1})
+	assert.Nil(t, db.AutoMigrate(&Line{}, &Note{}))
+	assert.Nil(t, db.Create(&[]Line{{ID: 1, ProductID: 1}, {ID: 2, ProductID: 1}, {ID: 3, ProductID: 2}}).Error)
+	assert.Nil(t, db.Create(&[]Note{{ID: 1, LineID: 1}, {ID: 2, LineID: 3}}).Error)
+	mr, red := newMiniRedis(t)
+	products := gormredis.NewGormRedis[Product, uint]("app", "product", "ID", db, red, time.Minute)
+	lines := gormredis.NewGormRedis[Line, uint]("app", "line", "ID", db, red, time.Minute)
+	notes := gormredis.NewGormRedis[Note, uint]("app", "note", "ID", db, red, time.Minute)
+	warm := func() {
+		_, err := products.List(1, 2)
+		assert.Nil(t, err)
+		_, err = lines.ListBy(cachelayer.NewIndex("ProductID", 1), nil)
+		assert.Nil(t, err)
+		_, err = notes.ListBy(cachelayer.NewIndex("LineID", 1), nil)
+		assert.Nil(t, err)
+	}
+	warm()
+	keys := mr.Keys()
+
+	// a missing table fails the grandchildren, nothing is deleted
+	broken := gormredis.NewGormRedis[Note, uint]("app", "note", "ID", db, red, time.Minute)
+	broken.GetDB().(*gormredis.Gorm[Note, uint]).SetTableName("missing")
+	_, err := gormredis.CascadeDelete(products, []uint{1}, gormredis.NewChild[Line, uint, uint](lines, "product_id", gormredis.NewChild[Note, uint, uint](broken, "line_id")))
+	assert.NotNil(t, err)
+	var count int64
+	assert.Nil(t, db.Model(&Line{}).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, keys, mr.Keys())
+
+	n, err := gormredis.CascadeDelete(products, []uint{1}, gormredis.NewChild[Line, uint, uint](lines, "product_id", gormredis.NewChild[Note, uint, uint](notes, "line_id")))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	var left []Line
+	assert.Nil(t, db.Find(&left).Error)
+	assert.Equal(t, []Line{{ID: 3, ProductID: 2}}, left)
+	var leftNotes []Note
+	assert.Nil(t, db.Find(&leftNotes).Error)
+	assert.Equal(t, []Note{{ID: 2, LineID: 3}}, leftNotes)
+	// cache of every deleted row is cleared, including child list keys
+	assert.False(t, mr.Exists(products.MakeCacheKey(cachelayer.NewIndex("ID", 1))))
+	assert.False(t, mr.Exists(lines.MakeCacheKey(cachelayer.NewIndex("ProductID", 1))))
+	assert.False(t, mr.Exists(notes.MakeCacheKey(cachelayer.NewIndex("LineID", 1))))
+	assert.True(t, mr.Exists(products.MakeCacheKey(cachelayer.NewIndex("ID", 2))))
+}
@@ -0,0 +1,37 @@
+package gormredis
+
+import (
+	"strings"
+
+	"github.com/daqiancode/cachelayer"
+)
+
+//duplicateKeyMessages messages of unique constraint violations of mysql, postgres, sqlite and sql server
+var duplicateKeyMessages = []string{
+	"Duplicate entry",
+	"duplicate key value violates unique constraint",
+	"UNIQUE constraint failed",
+	"Cannot insert duplicate key",
+}
+
+//IsDuplicateKey whether err is a unique constraint violation of the database
+func IsDuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, v := range duplicateKeyMessages {
+		if strings.Contains(msg, v) {
+			return true
+		}
+	}
+	return false
+}
+
+//conflict classify unique constraint violations as cachelayer.ErrConflict
+func conflict(err error) error {
+	if IsDuplicateKey(err) {
+		return cachelayer.NewError(cachelayer.ErrConflict, err)
+	}
+	return err
+}
@@ -0,0 +1,17 @@
+package gormredis_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDuplicateKey(t *testing.T) {
+	assert.True(t, gormredis.IsDuplicateKey(errors.New("Error 1062: Duplicate entry 'tom@x.com' for key 'email'")))
+	assert.True(t, gormredis.IsDuplicateKey(errors.New(`ERROR: duplicate key value violates unique constraint "users_email_key" (SQLSTATE 23505)`)))
+	assert.True(t, gormredis.IsDuplicateKey(errors.New("UNIQUE constraint failed: users.email")))
+	assert.False(t, gormredis.IsDuplicateKey(errors.New("connection refused")))
+	assert.False(t, gormredis.IsDuplicateKey(nil))
+}
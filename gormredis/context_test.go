@@ -0,0 +1,51 @@
+package gormredis_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type requestKey struct{}
+
+func TestContext(t *testing.T) {
+	db := newSQLite(t, Product{ID: 1, Name: "apple", CategoryID: 1})
+	_, red := newMiniRedis(t)
+	var mu sync.Mutex
+	var seen []interface{}
+	record := func(db *gorm.DB) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, db.Statement.Context.Value(requestKey{}))
+	}
+	assert.Nil(t, db.Callback().Query().Before("gorm:query").Register("test:query_ctx", record))
+	assert.Nil(t, db.Callback().Update().Before("gorm:update").Register("test:update_ctx", record))
+	assert.Nil(t, db.Callback().Delete().Before("gorm:delete").Register("test:delete_ctx", record))
+	cache := gormredis.NewGormRedis[Product, uint]("app", "products", "ID", db, red, time.Minute)
+
+	c := cache.WithContext(context.WithValue(context.Background(), requestKey{}, "req-1"))
+	_, _, err := c.Get(1)
+	assert.Nil(t, err)
+	_, err = c.Update(1, map[string]interface{}{"name": "pear"})
+	assert.Nil(t, err)
+	_, err = c.Delete(1)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, seen)
+	for _, v := range seen {
+		assert.Equal(t, "req-1", v)
+	}
+
+	// a canceled request cancels its queries, the shared cache keeps its own context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = cache.WithContext(ctx).Get(2)
+	assert.True(t, errors.Is(err, context.Canceled), err)
+	_, _, err = cache.Get(2)
+	assert.Nil(t, err)
+}
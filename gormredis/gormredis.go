@@ -1,26 +1,177 @@
 package gormredis
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/daqiancode/cachelayer"
 	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"gorm.io/plugin/dbresolver"
 )
 
-func NewGormRedis[T cachelayer.Table[I], I cachelayer.IDType](prefix, table, idField string, db *gorm.DB, red *redis.Client, ttl time.Duration) *cachelayer.RedisCache[T, I] {
-	rc := cachelayer.NewRedisCache[T, I](prefix, table, idField, &Gorm[T, I]{db: db, table: table, idField: idField}, red, ttl)
+func NewGormRedis[T cachelayer.Table[I], I cachelayer.IDType](prefix, table, idField string, db *gorm.DB, red redis.UniversalClient, ttl time.Duration) *cachelayer.RedisCache[T, I] {
+	rc := cachelayer.NewRedisCache[T, I](prefix, table, idField, NewGorm[T, I](db, table, idField), red, ttl)
 	return rc
 }
-func NewGormRedisFull[T cachelayer.Table[I], I cachelayer.IDType](prefix, table, idField string, db *gorm.DB, red *redis.Client, ttl time.Duration) cachelayer.FullCache[T, I] {
-	rc := cachelayer.NewFullRedisCache[T, I](prefix, table, idField, &Gorm[T, I]{db: db, table: table, idField: idField}, red, ttl)
+func NewGormRedisFull[T cachelayer.Table[I], I cachelayer.IDType](prefix, table, idField string, db *gorm.DB, red redis.UniversalClient, ttl time.Duration) cachelayer.FullCache[T, I] {
+	rc := cachelayer.NewFullRedisCache[T, I](prefix, table, idField, NewGorm[T, I](db, table, idField), red, ttl)
 	return rc
 }
 
+//NewGormRedisFiltered cache rows matching query(eg. "status = ?", "active") in a hash besides the per-id cache, see cachelayer.FilteredFullCache.
+// match must agree with query, it decides whether written rows enter or leave the subset
+func NewGormRedisFiltered[T cachelayer.Table[I], I cachelayer.IDType](prefix, table, idField string, db *gorm.DB, red redis.UniversalClient, ttl time.Duration, name string, match func(obj T) bool, query interface{}, args ...interface{}) *cachelayer.FilteredFullCache[T, I] {
+	g := NewGorm[T, I](db, table, idField)
+	rc := cachelayer.NewRedisCache[T, I](prefix, table, idField, g, red, ttl)
+	return cachelayer.NewFilteredFullCache(rc, name, match, func() ([]T, error) {
+		var r []T
+		err := g.reader().Where(query, args...).Find(&r).Error
+		return r, err
+	})
+}
+
+func NewGorm[T cachelayer.Table[I], I cachelayer.IDType](db *gorm.DB, table, idField string) *Gorm[T, I] {
+	return &Gorm[T, I]{db: db, table: table, idField: idField, ctx: context.Background(), shapes: newShapes()}
+}
+
 type Gorm[T cachelayer.Table[I], I cachelayer.IDType] struct {
 	db      *gorm.DB
 	table   string
 	idField string
+	ctx     context.Context
+	//tableName explicit table name of queries, eg. "schema.table"
+	tableName string
+	resolver  bool
+	//resolverName name of dbresolver config, empty means default
+	resolverName string
+	//primary read from primary even if resolver is enabled, see Primary
+	primary bool
+	scopes       []Scope
+	outbox       *Outbox
+	outboxKeys   func(objs ...T) []string
+	//returning fill database generated columns into records on write
+	returning bool
+	//idGenerator ids of rows created without id, nil means auto increment
+	idGenerator func() I
+	//nullID which ids are null on Create and Save, nil means cachelayer.IsNullID
+	nullID func(id I) bool
+	//nameMapper columns of index fields, nil means the schema of T
+	nameMapper cachelayer.NameMapper
+	//shapes *queryShape by index shape
+	shapes      *sync.Map
+	prepareStmt bool
+}
+
+//SetNameMapper translate fields of indexes, filters and orders into columns by mapper instead of the schema of T,
+// eg. cachelayer.AsIs for tables whose columns are named as go fields
+func (s *Gorm[T, I]) SetNameMapper(mapper cachelayer.NameMapper) {
+	s.nameMapper = mapper
+	s.shapes = newShapes()
+}
+
+//column column of an index field: its column in the schema of T(go name or column, eg. UserId with `gorm:"column:uid"` is uid),
+// otherwise by the naming strategy of db
+func (s *Gorm[T, I]) column(field string) string {
+	if s.nameMapper != nil {
+		return s.nameMapper(field)
+	}
+	if f := s.schemaField(field); f != nil && f.DBName != "" {
+		return f.DBName
+	}
+	return s.db.NamingStrategy.ColumnName(s.table, field)
+}
+
+//schemaField field of T by go name or column, nil if not found. Schemas are parsed once and cached by gorm
+func (s *Gorm[T, I]) schemaField(name string) *schema.Field {
+	stmt := &gorm.Statement{DB: s.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil
+	}
+	return stmt.Schema.LookUpField(name)
+}
+
+//SetTableName query explicit table(may be schema qualified, eg. "archive.users") instead of the table name of model T
+func (s *Gorm[T, I]) SetTableName(name string) {
+	s.tableName = name
+}
+
+//SetResolver add gorm dbresolver hints: Get/List read from replicas, writes go to primary. name selects a named resolver, empty means default
+func (s *Gorm[T, I]) SetResolver(enabled bool, name string) {
+	s.resolver = enabled
+	s.resolverName = name
+}
+
+//SetCtx context of every query, so statement timeouts, cancellation and tracing plugins work.
+// Deprecated: it mutates a client shared by goroutines, use WithContext per call
+func (s *Gorm[T, I]) SetCtx(ctx context.Context) {
+	s.ctx = ctx
+}
+//WithContext copy of s whose queries carry ctx, see cachelayer.ContextDB
+func (s *Gorm[T, I]) WithContext(ctx context.Context) cachelayer.DBCRUD[T, I] {
+	r := *s
+	r.ctx = ctx
+	return &r
+}
+//Primary copy of s reading from primary, see cachelayer.PrimaryDB
+func (s *Gorm[T, I]) Primary() cachelayer.DBCRUD[T, I] {
+	r := *s
+	r.primary = true
+	return &r
+}
+func (s *Gorm[T, I]) GetCtx() context.Context {
+	return s.ctx
+}
+
+//Ping ping the database, see cachelayer.Pinger. database/sql reconnects by itself
+func (s *Gorm[T, I]) Ping(ctx context.Context) error {
+	db, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
+}
+
+//conn db session with the context and table
+func (s *Gorm[T, I]) conn() *gorm.DB {
+	db := s.db.WithContext(s.ctx)
+	if s.prepareStmt {
+		db = db.Session(&gorm.Session{PrepareStmt: true})
+	}
+	if s.tableName != "" {
+		db = db.Table(s.tableName)
+	}
+	if s.resolver && s.resolverName != "" {
+		db = db.Clauses(dbresolver.Use(s.resolverName))
+	}
+	return db
+}
+
+//reader session of queries with scopes, read from replicas if resolver is enabled
+func (s *Gorm[T, I]) reader() *gorm.DB {
+	db := s.applyScopes(s.conn())
+	if s.resolver && s.primary {
+		return db.Clauses(dbresolver.Write)
+	}
+	if s.resolver {
+		return db.Clauses(dbresolver.Read)
+	}
+	return db
+}
+
+//writer session of writes with scopes, so updates and deletes never touch rows outside them. Always primary if resolver is enabled.
+// Writes are skipped by Plugin since the cache clears them itself
+func (s *Gorm[T, I]) writer() *gorm.DB {
+	db := s.applyScopes(s.conn().Set(skipInvalidationKey, true))
+	if s.resolver {
+		return db.Clauses(dbresolver.Write)
+	}
+	return db
 }
 
 func (s *Gorm[T, I]) Close() error {
@@ -29,23 +180,73 @@ func (s *Gorm[T, I]) Close() error {
 func (s *Gorm[T, I]) DB() *gorm.DB {
 	return s.db
 }
+//SetIDGenerator generate ids of rows created without id instead of relying on auto increment, eg. cachelayer.SnowflakeIDs[int64](sf)
+func (s *Gorm[T, I]) SetIDGenerator(fn func() I) {
+	s.idGenerator = fn
+}
+
+//SetNullIDFunc which ids are null(not assigned yet) on Create and Save, set by SetNullIDFunc of the cache
+func (s *Gorm[T, I]) SetNullIDFunc(fn func(id I) bool) {
+	s.nullID = fn
+}
+
+//isNullID whether id is null by the NullIDFunc, default cachelayer.IsNullID
+func (s *Gorm[T, I]) isNullID(id I) bool {
+	if s.nullID != nil {
+		return s.nullID(id)
+	}
+	return cachelayer.IsNullID(id)
+}
+
 func (s *Gorm[T, I]) Create(r *T) error {
-	if err := s.db.Create(r).Error; err != nil {
-		return err
+	if s.idGenerator != nil && s.isNullID((*r).GetID()) {
+		// idField may be a go field or a column
+		f := s.schemaField(s.idField)
+		if f == nil {
+			return fmt.Errorf("gormredis: no id field %s in %T", s.idField, *r)
+		}
+		if err := f.Set(s.ctx, reflect.ValueOf(r).Elem(), s.idGenerator()); err != nil {
+			return err
+		}
 	}
-	return nil
+	if s.outbox != nil {
+		return s.withOutbox(func(g *Gorm[T, I]) ([]T, error) {
+			// keys are made of the id assigned by the insert
+			if err := g.Create(r); err != nil {
+				return nil, err
+			}
+			return []T{*r}, nil
+		})
+	}
+	if err := s.returningWriter().Create(r).Error; err != nil {
+		return conflict(err)
+	}
+	return s.reload(r)
 }
+//Save create r if its id is null, otherwise upsert it on the id column
 func (s *Gorm[T, I]) Save(r *T) error {
-	_, exists, err := s.Get((*r).GetID())
-	if err != nil {
-		return err
-	}
-	if !exists {
+	if s.isNullID((*r).GetID()) {
 		return s.Create(r)
 	}
-	return s.db.Save(r).Error
+	_, _, err := s.Upsert(r)
+	return err
 }
 func (s *Gorm[T, I]) Update(id I, values interface{}) (int64, error) {
+	if s.outbox != nil {
+		var n int64
+		err := s.withOutbox(func(g *Gorm[T, I]) ([]T, error) {
+			old, _, err := g.Get(id)
+			if err != nil {
+				return nil, err
+			}
+			if n, err = g.Update(id, values); err != nil {
+				return nil, err
+			}
+			obj, _, err := g.Get(id)
+			return []T{old, obj}, err
+		})
+		return n, err
+	}
 	old, exists, err := s.Get(id)
 	if err != nil {
 		return 0, err
@@ -53,14 +254,26 @@ func (s *Gorm[T, I]) Update(id I, values interface{}) (int64, error) {
 	if !exists {
 		return 0, nil
 	}
-	rs := s.db.Model(&old).Updates(values)
+	rs := s.writer().Model(&old).Updates(values)
 	if rs.Error != nil {
-		return 0, rs.Error
+		return 0, conflict(rs.Error)
 	}
 	return rs.RowsAffected, nil
 }
 func (s *Gorm[T, I]) Delete(ids ...I) (int64, error) {
-	rs := s.db.Delete(new(T), ids)
+	if s.outbox != nil {
+		var n int64
+		err := s.withOutbox(func(g *Gorm[T, I]) ([]T, error) {
+			objs, err := g.List(ids...)
+			if err != nil {
+				return nil, err
+			}
+			n, err = g.Delete(ids...)
+			return objs, err
+		})
+		return n, err
+	}
+	rs := s.writer().Delete(new(T), ids)
 	if rs.Error != nil {
 		return 0, rs.Error
 	}
@@ -68,7 +281,7 @@ func (s *Gorm[T, I]) Delete(ids ...I) (int64, error) {
 }
 func (s *Gorm[T, I]) Get(id I) (T, bool, error) {
 	var r T
-	if err := s.db.Where(map[string]interface{}{s.idField: id}).First(&r).Error; err != nil {
+	if err := s.reader().Where(map[string]interface{}{s.idField: id}).First(&r).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return r, false, nil
 		}
@@ -78,11 +291,7 @@ func (s *Gorm[T, I]) Get(id I) (T, bool, error) {
 }
 func (s *Gorm[T, I]) GetBy(index cachelayer.Index) (T, bool, error) {
 	var r T
-	index1 := make(cachelayer.Index, len(index))
-	for k, v := range index {
-		index1[s.db.NamingStrategy.ColumnName(s.table, k)] = v
-	}
-	if err := s.db.Where(map[string]interface{}(index1)).First(&r).Error; err != nil {
+	if err := s.where(s.reader(), index, nil).First(&r).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return r, false, nil
 		}
@@ -92,29 +301,115 @@ func (s *Gorm[T, I]) GetBy(index cachelayer.Index) (T, bool, error) {
 }
 func (s *Gorm[T, I]) List(ids ...I) ([]T, error) {
 	var r []T
-	err := s.db.Find(&r, ids).Error
+	err := s.reader().Find(&r, ids).Error
 	return r, err
 }
 func (s *Gorm[T, I]) ListBy(index cachelayer.Index, orderBys cachelayer.OrderBys) ([]T, error) {
 	var r []T
-	index1 := make(cachelayer.Index, len(index))
-	for k, v := range index {
-		index1[s.db.NamingStrategy.ColumnName(s.table, k)] = v
+	if err := s.where(s.reader(), index, orderBys).Find(&r).Error; err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//ListByIn rows whose field is one of values, see cachelayer.InLister
+func (s *Gorm[T, I]) ListByIn(field string, values []interface{}) ([]T, error) {
+	var r []T
+	column := s.column(field)
+	if err := s.reader().Where(map[string]interface{}{column: values}).Find(&r).Error; err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//ListByAny rows matching any of the alternatives of anyOf, grouped as (a AND b) OR (c), see cachelayer.AnyLister
+func (s *Gorm[T, I]) ListByAny(anyOf cachelayer.AnyOf, orderBys cachelayer.OrderBys) ([]T, error) {
+	var r []T
+	var cond *gorm.DB
+	for _, index := range anyOf {
+		index1 := make(map[string]interface{}, len(index))
+		for k, v := range index {
+			index1[s.column(k)] = v
+		}
+		if cond == nil {
+			cond = s.db.Where(index1)
+		} else {
+			cond = cond.Or(index1)
+		}
+	}
+	// a copy, orderBys of the caller are kept
+	columns := make(cachelayer.OrderBys, 0, len(orderBys)+1)
+	idColumn := s.column(s.idField)
+	byID := false
+	for _, v := range orderBys {
+		columns = append(columns, cachelayer.OrderBy{Field: s.column(v.Field), Asc: v.Asc})
+		byID = byID || columns[len(columns)-1].Field == idColumn
 	}
-	for i, v := range orderBys {
-		orderBys[i].Field = s.db.NamingStrategy.ColumnName(s.table, v.Field)
+	// rows of several alternatives come in a stable order
+	if !byID {
+		columns = append(columns, cachelayer.OrderBy{Field: idColumn, Asc: true})
 	}
+	if err := s.reader().Where(cond).Order(columns.String()).Find(&r).Error; err != nil {
+		return nil, err
+	}
+	return r, nil
+}
 
-	if err := s.db.Where(map[string]interface{}(index1)).Order(orderBys.String()).Find(&r).Error; err != nil {
+//ListWhere rows matching filter, see cachelayer.FilterLister
+func (s *Gorm[T, I]) ListWhere(filter *cachelayer.Filter) ([]T, error) {
+	var r []T
+	db := s.reader()
+	for _, v := range filter.Conds() {
+		column := s.column(v.Field)
+		switch v.Op {
+		case cachelayer.FilterEq:
+			db = db.Where(map[string]interface{}{column: v.Values[0]})
+		case cachelayer.FilterIn:
+			db = db.Where(map[string]interface{}{column: v.Values})
+		case cachelayer.FilterRange:
+			if v.Values[0] != nil {
+				db = db.Where(clause.Gte{Column: column, Value: v.Values[0]})
+			}
+			if v.Values[1] != nil {
+				db = db.Where(clause.Lt{Column: column, Value: v.Values[1]})
+			}
+		}
+	}
+	orderBys := make(cachelayer.OrderBys, len(filter.OrderBys()))
+	for i, v := range filter.OrderBys() {
+		orderBys[i] = cachelayer.OrderBy{Field: s.column(v.Field), Asc: v.Asc}
+	}
+	if len(orderBys) > 0 {
+		db = db.Order(orderBys.String())
+	}
+	if filter.GetLimit() > 0 {
+		db = db.Limit(filter.GetLimit())
+	}
+	if err := db.Find(&r).Error; err != nil {
 		return nil, err
 	}
 	return r, nil
 }
 
+//Count rows of index, see cachelayer.Counter
+func (s *Gorm[T, I]) Count(index cachelayer.Index) (int64, error) {
+	var n int64
+	err := s.where(s.reader().Model(new(T)), index, nil).Count(&n).Error
+	return n, err
+}
+
 func (s *Gorm[T, I]) ListAll() ([]T, error) {
 	var r []T
-	if err := s.db.Find(&r).Error; err != nil {
+	if err := s.reader().Find(&r).Error; err != nil {
 		return nil, err
 	}
 	return r, nil
 }
+
+//ListAllInBatches stream all records with FindInBatches, batch is reused between calls of fn
+func (s *Gorm[T, I]) ListAllInBatches(batchSize int, fn func(batch []T) error) error {
+	var batch []T
+	return s.reader().FindInBatches(&batch, batchSize, func(tx *gorm.DB, n int) error {
+		return fn(batch)
+	}).Error
+}
@@ -0,0 +1,52 @@
+package gormredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/stretchr/testify/assert"
+)
+
+//Legacy row of a table where 0 is a legitimate id
+type Legacy struct {
+	ID   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name string
+}
+
+func (s Legacy) GetID() uint {
+	return s.ID
+}
+
+func (s Legacy) ListIndexes() cachelayer.Indexes {
+	return cachelayer.Indexes{}
+}
+
+func TestNullIDFunc(t *testing.T) {
+	db := newSQLite(t)
+	assert.Nil(t, db.AutoMigrate(&Legacy{}))
+	assert.Nil(t, db.Create(&Legacy{ID: 0, Name: "root"}).Error)
+	_, red := newMiniRedis(t)
+	g := gormredis.NewGorm[Legacy, uint](db, "legacies", "id")
+	g.SetIDGenerator(func() uint { return 100 })
+	cache := cachelayer.NewRedisCache[Legacy, uint]("app", "legacy", "ID", g, red, time.Minute)
+	cache.SetNullIDFunc(func(id uint) bool { return false })
+
+	// id 0 is upserted, not inserted with a generated id
+	assert.Nil(t, cache.Save(&Legacy{ID: 0, Name: "admin"}))
+	assert.Nil(t, g.Save(&Legacy{ID: 0, Name: "admin"}))
+	r, exists, err := cache.Get(0)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "admin", r.Name)
+	var count int64
+	assert.Nil(t, db.Model(&Legacy{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// ids are generated by the default of the adapter again, the id field given as column
+	cache.SetNullIDFunc(nil)
+	obj := Legacy{Name: "tom"}
+	assert.Nil(t, cache.Create(&obj))
+	assert.Equal(t, uint(100), obj.ID)
+}
@@ -0,0 +1,144 @@
+package gormredis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+const DefaultOutboxTable = "cachelayer_outbox"
+
+//OutboxEvent invalidation intent, Keys are cache keys joined by "\n"
+type OutboxEvent struct {
+	ID        uint64 `gorm:"primaryKey"`
+	Keys      string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+//Outbox guaranteed invalidation: writes record cache keys to invalidate in the outbox table inside their transaction,
+// Run deletes the keys from redis and then the events, so invalidation survives a crash between the DB commit and the redis DEL
+type Outbox struct {
+	db       *gorm.DB
+	red      redis.UniversalClient
+	table    string
+	interval time.Duration
+	batch    int
+}
+
+func NewOutbox(db *gorm.DB, red redis.UniversalClient) *Outbox {
+	return &Outbox{db: db, red: red, table: DefaultOutboxTable, interval: time.Second, batch: 100}
+}
+
+//SetTableName outbox table, default DefaultOutboxTable
+func (s *Outbox) SetTableName(table string) {
+	s.table = table
+}
+
+//SetRelay polling interval and max events per round of Run
+func (s *Outbox) SetRelay(interval time.Duration, batch int) {
+	s.interval = interval
+	s.batch = batch
+}
+
+//Migrate create the outbox table
+func (s *Outbox) Migrate() error {
+	return s.db.Table(s.table).AutoMigrate(&OutboxEvent{})
+}
+
+//Record add an invalidation intent with tx, so it's committed or rolled back together with the write
+func (s *Outbox) Record(tx *gorm.DB, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return tx.Table(s.table).Create(&OutboxEvent{Keys: strings.Join(keys, "\n")}).Error
+}
+
+//ProcessOnce delete cache keys of pending events(including keys found by reverse index) and then the events, return count of processed events
+func (s *Outbox) ProcessOnce(ctx context.Context) (int, error) {
+	var events []OutboxEvent
+	if err := s.db.WithContext(ctx).Table(s.table).Order("id").Limit(s.batch).Find(&events).Error; err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	var keys []string
+	ids := make([]uint64, len(events))
+	for i, v := range events {
+		ids[i] = v.ID
+		keys = append(keys, strings.Split(v.Keys, "\n")...)
+	}
+	keys = cachelayer.UniqueStrings(keys)
+	p := s.red.Pipeline()
+	refs := make([]*redis.StringSliceCmd, len(keys))
+	for i, v := range keys {
+		refs[i] = p.SMembers(ctx, v+cachelayer.RefsKeySuffix)
+	}
+	if _, err := p.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, err
+	}
+	for i, v := range refs {
+		keys = append(keys, keys[i]+cachelayer.RefsKeySuffix)
+		keys = append(keys, v.Val()...)
+	}
+	if _, err := cachelayer.DelKeys(ctx, s.red, cachelayer.UniqueStrings(keys)...); err != nil {
+		return 0, err
+	}
+	if err := s.db.WithContext(ctx).Table(s.table).Delete(&OutboxEvent{}, ids).Error; err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+//Run relay events until ctx is done. Errors of a round are passed to cachelayer.ReportError and the round is retried after the interval
+func (s *Outbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		// drain the backlog before waiting
+		for {
+			n, err := s.ProcessOnce(ctx)
+			if err != nil && ctx.Err() == nil {
+				cachelayer.ReportError("outbox", s.table, err)
+			}
+			if err != nil || n < s.batch {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+//EnableOutbox record invalidation intents of writes through cache in outbox, in the transaction of each write
+func EnableOutbox[T cachelayer.Table[I], I cachelayer.IDType](cache *cachelayer.RedisCache[T, I], outbox *Outbox) error {
+	g, ok := cache.GetDB().(*Gorm[T, I])
+	if !ok {
+		return errors.New("gormredis.EnableOutbox: cache is not backed by gorm")
+	}
+	g.outbox = outbox
+	g.outboxKeys = cache.CacheKeys
+	return nil
+}
+
+//withOutbox run write fn in a transaction and record cache keys of the returned records in outbox
+func (s *Gorm[T, I]) withOutbox(fn func(g *Gorm[T, I]) ([]T, error)) error {
+	return s.db.WithContext(s.ctx).Transaction(func(tx *gorm.DB) error {
+		g := *s
+		g.db = tx
+		g.outbox = nil
+		objs, err := fn(&g)
+		if err != nil {
+			return err
+		}
+		return s.outbox.Record(tx, s.outboxKeys(objs...)...)
+	})
+}
@@ -0,0 +1,73 @@
+package gormredis_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxCreate(t *testing.T) {
+	db := newSQLite(t)
+	_, red := newMiniRedis(t)
+	outbox := gormredis.NewOutbox(db, red)
+	assert.Nil(t, outbox.Migrate())
+	cache := gormredis.NewGormRedis[Product, uint]("app", "product", "ID", db, red, time.Minute)
+	assert.Nil(t, gormredis.EnableOutbox(cache, outbox))
+	p := Product{Name: "apple", CategoryID: 1}
+	assert.Nil(t, cache.Create(&p))
+	assert.Equal(t, uint(1), p.ID)
+	var events []gormredis.OutboxEvent
+	assert.Nil(t, db.Table(gormredis.DefaultOutboxTable).Find(&events).Error)
+	assert.Len(t, events, 1)
+	// keys of the id assigned by the insert, not of the zero id
+	assert.Contains(t, strings.Split(events[0].Keys, "\n"), cache.MakeCacheKey(cachelayer.NewIndex("ID", 1)))
+}
+
+func TestOutboxProcessOnce(t *testing.T) {
+	db := newSQLite(t)
+	mr, red := newMiniRedis(t)
+	outbox := gormredis.NewOutbox(db, red)
+	assert.Nil(t, outbox.Migrate())
+	mr.Set("app/product/id/1", "{}")
+	mr.Set("app/product/categoryid/1", "[1]")
+	mr.SAdd("app/product/id/1"+cachelayer.RefsKeySuffix, "app/product/categoryid/1")
+	mr.Set("app/product/id/2", "{}")
+	assert.Nil(t, outbox.Record(db, "app/product/id/1"))
+	n, err := outbox.ProcessOnce(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"app/product/id/2"}, mr.Keys())
+	var count int64
+	assert.Nil(t, db.Table(gormredis.DefaultOutboxTable).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestOutboxRunReportsErrors(t *testing.T) {
+	db := newSQLite(t)
+	_, red := newMiniRedis(t)
+	// the outbox table is never migrated
+	outbox := gormredis.NewOutbox(db, red)
+	outbox.SetRelay(time.Millisecond, 10)
+	events := make(chan cachelayer.ErrorEvent, 10)
+	cachelayer.SetErrorHandler(func(event cachelayer.ErrorEvent) {
+		select {
+		case events <- event:
+		default:
+		}
+	})
+	defer cachelayer.SetErrorHandler(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- outbox.Run(ctx) }()
+	event := <-events
+	cancel()
+	assert.Nil(t, <-done)
+	assert.Equal(t, "outbox", event.Component)
+	assert.Equal(t, gormredis.DefaultOutboxTable, event.Table)
+	assert.NotNil(t, event.Err)
+}
@@ -0,0 +1,297 @@
+package gormredis
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/daqiancode/cachelayer"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+//skipInvalidationKey gorm setting of queries issued by the adapter, the cache invalidates those writes itself
+const skipInvalidationKey = "cachelayer:skip_invalidation"
+
+//oldRowsKey gorm instance setting holding rows loaded before an update or delete, by handler
+const oldRowsKey = "cachelayer:old_rows"
+
+//Plugin gorm plugin clearing caches of registered tables after create/update/delete, so writes done with raw gorm still invalidate the cache.
+// eg. p := gormredis.NewPlugin(); gormredis.Register(p, userCache); db.Use(p)
+type Plugin struct {
+	mu       sync.RWMutex
+	handlers []*handler
+	onError  func(table string, err error)
+}
+
+//handler invalidation of a registered cache
+type handler struct {
+	cache string
+	//model pointer to a zero record, its schema gives the sql table and id column
+	model interface{}
+	//table explicit sql table of the cache, see Gorm.SetTableName. Empty means the table of model
+	table   string
+	idField string
+	//load rows matching a write statement, nil if the statement is not bounded by a where clause or ids
+	load func(db *gorm.DB) (interface{}, error)
+	//clear clear cache of rows written by db, old are rows loaded before the write
+	clear func(db *gorm.DB, old interface{}, reload bool) error
+}
+
+func NewPlugin() *Plugin {
+	return &Plugin{}
+}
+
+//Register clear cache on writes of the sql table of cache: the table of the Gorm adapter(see Gorm.SetTableName), otherwise the table of T.
+// Rows matched by updates and deletes are loaded before the write, so index keys of both old and new values are cleared even if the statement
+// has a partial model, eg. db.Model(&User{ID: 1}).Update("email", email). Unbounded writes(eg. db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&User{}))
+// clear all cache keys of the table
+func Register[T cachelayer.Table[I], I cachelayer.IDType](p *Plugin, cache cachelayer.Cache[T, I]) {
+	isNullID := cachelayer.IsNullID[I]
+	if c, ok := cache.(interface{ IsNullID(id I) bool }); ok {
+		isNullID = c.IsNullID
+	}
+	h := &handler{cache: cache.GetTableName(), model: new(T), idField: cache.GetIdField()}
+	if g := gormOf[T, I](cache); g != nil {
+		h.table = g.tableName
+	}
+	//records of T among statement values, all false if any has no id
+	records := func(values []interface{}) ([]T, bool) {
+		var objs []T
+		for _, v := range values {
+			var obj T
+			switch x := v.(type) {
+			case T:
+				obj = x
+			case *T:
+				if x == nil {
+					continue
+				}
+				obj = *x
+			default:
+				continue
+			}
+			if isNullID(obj.GetID()) {
+				return nil, false
+			}
+			objs = append(objs, obj)
+		}
+		return objs, len(objs) > 0
+	}
+	h.load = func(db *gorm.DB) (interface{}, error) {
+		objs, _ := records(statementValues(db.Statement.ReflectValue))
+		ids := make([]interface{}, len(objs))
+		for i, v := range objs {
+			ids[i] = v.GetID()
+		}
+		q := h.rowsQuery(db, ids, true)
+		if q == nil {
+			return nil, nil
+		}
+		var old []T
+		return old, q.Find(&old).Error
+	}
+	h.clear = func(db *gorm.DB, old interface{}, reload bool) error {
+		objs, ok := records(statementValues(db.Statement.ReflectValue))
+		rows, loaded := old.([]T)
+		if !ok && !loaded {
+			return clearAll(cache)
+		}
+		objs = append(objs, rows...)
+		if reload && len(objs) > 0 {
+			ids := make([]interface{}, len(objs))
+			for i, v := range objs {
+				ids[i] = v.GetID()
+			}
+			var cur []T
+			if err := h.rowsQuery(db, ids, false).Find(&cur).Error; err != nil {
+				return err
+			}
+			objs = append(objs, cur...)
+		}
+		if len(objs) == 0 {
+			return nil
+		}
+		return cache.ClearCache(objs...)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers = append(p.handlers, h)
+}
+
+type dbGetter[T cachelayer.Table[I], I cachelayer.IDType] interface {
+	GetDB() cachelayer.DBCRUD[T, I]
+}
+
+type fullDBGetter[T cachelayer.Table[I], I cachelayer.IDType] interface {
+	GetDB() cachelayer.FullDBCache[T, I]
+}
+
+//gormOf Gorm adapter of cache, nil if cache is not backed by gorm
+func gormOf[T cachelayer.Table[I], I cachelayer.IDType](cache interface{}) *Gorm[T, I] {
+	if c, ok := cache.(dbGetter[T, I]); ok {
+		g, _ := c.GetDB().(*Gorm[T, I])
+		return g
+	}
+	if c, ok := cache.(fullDBGetter[T, I]); ok {
+		g, _ := c.GetDB().(*Gorm[T, I])
+		return g
+	}
+	return nil
+}
+
+//schema schema of the model of s by the naming strategy of db, cached by gorm
+func (s *handler) schema(db *gorm.DB) (*gorm.Statement, error) {
+	stmt := &gorm.Statement{DB: db}
+	return stmt, stmt.Parse(s.model)
+}
+
+//matches whether statement writes the sql table of s
+func (s *handler) matches(db *gorm.DB) bool {
+	table := s.table
+	if table == "" {
+		stmt, err := s.schema(db)
+		if err != nil {
+			return false
+		}
+		table = stmt.Schema.Table
+	}
+	return strings.EqualFold(table, db.Statement.Table)
+}
+
+//rowsQuery query of rows with ids, and matching the where clause of the statement of db if where. Nil if neither bounds the rows
+func (s *handler) rowsQuery(db *gorm.DB, ids []interface{}, where bool) *gorm.DB {
+	var conds []clause.Expression
+	if c, ok := db.Statement.Clauses["WHERE"]; ok && where {
+		if w, ok := c.Expression.(clause.Where); ok && len(w.Exprs) > 0 {
+			conds = append(conds, w)
+		}
+	}
+	if len(ids) > 0 {
+		stmt, err := s.schema(db)
+		if err != nil {
+			return nil
+		}
+		column := s.idField
+		if f := stmt.Schema.LookUpField(s.idField); f != nil {
+			column = f.DBName
+		}
+		conds = append(conds, clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Values: ids})
+	}
+	if len(conds) == 0 {
+		return nil
+	}
+	tx := db.Session(&gorm.Session{NewDB: true}).Table(db.Statement.Table)
+	tx.Statement.AddClause(clause.Where{Exprs: conds})
+	return tx
+}
+
+func clearAll(cache interface{}) error {
+	if c, ok := cache.(interface{ ClearAll() error }); ok {
+		return c.ClearAll()
+	}
+	return nil
+}
+
+//SetErrorHandler receive invalidation errors, the write itself is not failed
+func (s *Plugin) SetErrorHandler(fn func(table string, err error)) {
+	s.onError = fn
+}
+
+func (s *Plugin) Name() string {
+	return "cachelayer"
+}
+
+func (s *Plugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().After("gorm:create").Register("cachelayer:after_create", s.invalidate(false)); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("cachelayer:before_update", s.loadOld); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("cachelayer:after_update", s.invalidate(true)); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("cachelayer:before_delete", s.loadOld); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("cachelayer:after_delete", s.invalidate(false))
+}
+
+//matching handlers of the table written by db, nil if the write is done by the adapter
+func (s *Plugin) matching(db *gorm.DB) []*handler {
+	if db.Error != nil || db.Statement == nil {
+		return nil
+	}
+	if skip, ok := db.Get(skipInvalidationKey); ok && skip == true {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var r []*handler
+	for _, h := range s.handlers {
+		if h.matches(db) {
+			r = append(r, h)
+		}
+	}
+	return r
+}
+
+func (s *Plugin) reportError(h *handler, err error) {
+	if err != nil && s.onError != nil {
+		s.onError(h.cache, err)
+	}
+}
+
+//loadOld load rows an update or delete is about to change
+func (s *Plugin) loadOld(db *gorm.DB) {
+	handlers := s.matching(db)
+	if len(handlers) == 0 {
+		return
+	}
+	old := make(map[*handler]interface{}, len(handlers))
+	for _, h := range handlers {
+		rows, err := h.load(db)
+		s.reportError(h, err)
+		if err == nil && rows != nil {
+			old[h] = rows
+		}
+	}
+	db.InstanceSet(oldRowsKey, old)
+}
+
+//invalidate callback clearing cache of written rows, reloading them after the write if reload
+func (s *Plugin) invalidate(reload bool) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		handlers := s.matching(db)
+		if len(handlers) == 0 {
+			return
+		}
+		var old map[*handler]interface{}
+		if v, ok := db.InstanceGet(oldRowsKey); ok {
+			old, _ = v.(map[*handler]interface{})
+		}
+		for _, h := range handlers {
+			s.reportError(h, h.clear(db, old[h], reload))
+		}
+	}
+}
+
+//statementValues records of the statement, as values and pointers
+func statementValues(rv reflect.Value) []interface{} {
+	rv = reflect.Indirect(rv)
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		r := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			r = append(r, reflect.Indirect(rv.Index(i)).Interface())
+		}
+		return r
+	case reflect.Struct:
+		return []interface{}{rv.Interface()}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package gormredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+//newPluginCache cache named "product" of table products, invalidated by the plugin on raw writes of db
+func newPluginCache(t *testing.T) (*cachelayer.RedisCache[Product, uint], *gorm.DB, *miniredis.Miniredis) {
+	db := newSQLite(t, Product{ID: 1, Name: "apple", CategoryID: 1}, Product{ID: 2, Name: "pear", CategoryID: 1})
+	mr, red := newMiniRedis(t)
+	cache := gormredis.NewGormRedis[Product, uint]("app", "product", "ID", db, red, time.Minute)
+	p := gormredis.NewPlugin()
+	p.SetErrorHandler(func(table string, err error) { t.Error(table, err) })
+	gormredis.Register[Product, uint](p, cache)
+	assert.Nil(t, db.Use(p))
+	return cache, db, mr
+}
+
+//warm cache id 1 and list of category 1
+func warm(t *testing.T, cache *cachelayer.RedisCache[Product, uint]) {
+	_, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	_, err = cache.ListBy(cachelayer.NewIndex("CategoryID", 1), nil)
+	assert.Nil(t, err)
+}
+
+func TestPluginPartialModel(t *testing.T) {
+	cache, db, mr := newPluginCache(t)
+	idKey := cache.MakeCacheKey(cachelayer.NewIndex("ID", 1))
+	category1 := cache.MakeCacheKey(cachelayer.NewIndex("CategoryID", 1))
+	category2 := cache.MakeCacheKey(cachelayer.NewIndex("CategoryID", 2))
+	warm(t, cache)
+	_, err := cache.ListBy(cachelayer.NewIndex("CategoryID", 2), nil)
+	assert.Nil(t, err)
+	// the model has no category, the old one is loaded before the update
+	assert.Nil(t, db.Model(&Product{ID: 1}).Update("category_id", 2).Error)
+	assert.False(t, mr.Exists(idKey))
+	assert.False(t, mr.Exists(category1))
+	assert.False(t, mr.Exists(category2))
+	objs, err := cache.ListBy(cachelayer.NewIndex("CategoryID", 1), nil)
+	assert.Nil(t, err)
+	assert.Len(t, objs, 1)
+
+	warm(t, cache)
+	assert.Nil(t, db.Delete(&Product{ID: 2}).Error)
+	assert.False(t, mr.Exists(category1))
+	objs, err = cache.ListBy(cachelayer.NewIndex("CategoryID", 1), nil)
+	assert.Nil(t, err)
+	assert.Len(t, objs, 0)
+}
+
+func TestPluginWhere(t *testing.T) {
+	cache, db, mr := newPluginCache(t)
+	category1 := cache.MakeCacheKey(cachelayer.NewIndex("CategoryID", 1))
+	warm(t, cache)
+	assert.Nil(t, db.Model(&Product{}).Where("name = ?", "pear").Update("category_id", 3).Error)
+	assert.False(t, mr.Exists(category1))
+	assert.True(t, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex("ID", 1))))
+
+	// writes without model match by table
+	warm(t, cache)
+	assert.Nil(t, db.Table("products").Where("id = ?", 1).Updates(map[string]interface{}{"name": "green apple"}).Error)
+	r, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "green apple", r.Name)
+
+	warm(t, cache)
+	assert.Nil(t, db.Create(&Product{ID: 3, Name: "plum", CategoryID: 1}).Error)
+	assert.False(t, mr.Exists(category1))
+
+	warm(t, cache)
+	assert.Nil(t, db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Product{}).Error)
+	assert.Empty(t, mr.Keys())
+
+	// writes of other tables are ignored
+	assert.Nil(t, db.Table("other_products").AutoMigrate(&Product{}))
+	_, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Table("other_products").Create(&Product{ID: 1, Name: "other"}).Error)
+	assert.True(t, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex("ID", 1))))
+}
@@ -0,0 +1,32 @@
+package gormredis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"gorm.io/gorm"
+)
+
+//CachedQuery run custom gorm query fn with the cache's db and cache the result under key, see RedisCache.CachedQuery
+func CachedQuery[T cachelayer.Table[I], I cachelayer.IDType](cache *cachelayer.RedisCache[T, I], key string, ttl time.Duration, fn func(db *gorm.DB) ([]T, error), tags ...string) ([]T, error) {
+	g, ok := cache.GetDB().(*Gorm[T, I])
+	if !ok {
+		return nil, errors.New("gormredis.CachedQuery: cache is not backed by gorm")
+	}
+	return cache.CachedQuery(key, ttl, func() ([]T, error) {
+		return fn(g.reader())
+	}, tags...)
+}
+
+//CachedRecent run gorm query fn of rows within [from, to) and cache the result per time bucket, see RedisCache.CachedRecent.
+// eg. CachedRecent(cache, "recent-orders", time.Hour, 5*time.Minute, func(db *gorm.DB, from, to time.Time) ([]Order, error) {...})
+func CachedRecent[T cachelayer.Table[I], I cachelayer.IDType](cache *cachelayer.RedisCache[T, I], key string, window, bucket time.Duration, fn func(db *gorm.DB, from, to time.Time) ([]T, error), tags ...string) ([]T, error) {
+	g, ok := cache.GetDB().(*Gorm[T, I])
+	if !ok {
+		return nil, errors.New("gormredis.CachedRecent: cache is not backed by gorm")
+	}
+	return cache.CachedRecent(key, window, bucket, func(from, to time.Time) ([]T, error) {
+		return fn(g.reader(), from, to)
+	}, tags...)
+}
@@ -0,0 +1,103 @@
+package gormredis
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/daqiancode/cachelayer"
+	"gorm.io/gorm"
+)
+
+//queryShape where and order clauses of the queries of an index shape(its fields and orders), built once per shape, so misses
+// under high QPS don't translate columns again and send identical SQL, reusing prepared statements(see SetPrepareStmt)
+type queryShape struct {
+	//fields index fields in the order of the placeholders of where
+	fields []string
+	where  string
+	order  string
+}
+
+//args values of index in the order of the placeholders
+func (s *queryShape) args(index cachelayer.Index) []interface{} {
+	r := make([]interface{}, len(s.fields))
+	for i, v := range s.fields {
+		r[i] = index[v]
+	}
+	return r
+}
+
+//SetPrepareStmt run queries as prepared statements cached by gorm, queries of an index shape have identical SQL
+func (s *Gorm[T, I]) SetPrepareStmt(enabled bool) {
+	s.prepareStmt = enabled
+}
+
+//shape query shape of index and orderBys, false if a value can't be bound to "= ?", eg. nil or a slice
+func (s *Gorm[T, I]) shape(index cachelayer.Index, orderBys cachelayer.OrderBys) (*queryShape, bool) {
+	for _, v := range index {
+		if !bindable(v) {
+			return nil, false
+		}
+	}
+	fields := index.Fields()
+	sort.Strings(fields)
+	key := strings.Join(fields, ",") + "|" + orderBys.String()
+	if v, ok := s.shapes.Load(key); ok {
+		return v.(*queryShape), true
+	}
+	conds := make([]string, len(fields))
+	for i, v := range fields {
+		conds[i] = s.db.Statement.Quote(s.column(v)) + " = ?"
+	}
+	orders := make(cachelayer.OrderBys, len(orderBys))
+	for i, v := range orderBys {
+		orders[i] = cachelayer.OrderBy{Field: s.column(v.Field), Asc: v.Asc}
+	}
+	r := &queryShape{fields: fields, where: strings.Join(conds, " AND "), order: orders.String()}
+	s.shapes.Store(key, r)
+	return r, true
+}
+
+//where db with the conditions of index and orders of orderBys, by the query shape of index if its values are bindable
+func (s *Gorm[T, I]) where(db *gorm.DB, index cachelayer.Index, orderBys cachelayer.OrderBys) *gorm.DB {
+	shape, ok := s.shape(index, orderBys)
+	if !ok {
+		index1 := make(map[string]interface{}, len(index))
+		for k, v := range index {
+			index1[s.column(k)] = v
+		}
+		orders := make(cachelayer.OrderBys, len(orderBys))
+		for i, v := range orderBys {
+			orders[i] = cachelayer.OrderBy{Field: s.column(v.Field), Asc: v.Asc}
+		}
+		shape = &queryShape{order: orders.String()}
+		db = db.Where(index1)
+	} else if shape.where != "" {
+		db = db.Where(shape.where, shape.args(index)...)
+	}
+	if shape.order != "" {
+		db = db.Order(shape.order)
+	}
+	return db
+}
+
+//bindable whether v binds to a single placeholder
+func bindable(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	if _, ok := v.([]byte); ok {
+		return true
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Ptr:
+		return false
+	}
+	return true
+}
+
+//newShapes shapes of a new Gorm, shared by its copies
+func newShapes() *sync.Map {
+	return &sync.Map{}
+}
@@ -0,0 +1,89 @@
+package gormredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+)
+
+func TestTableName(t *testing.T) {
+	db := newSQLite(t, Product{ID: 1, Name: "apple"})
+	assert.Nil(t, db.Table("archived_products").AutoMigrate(&Product{}))
+	assert.Nil(t, db.Table("archived_products").Create(&Product{ID: 1, Name: "old apple"}).Error)
+	_, red := newMiniRedis(t)
+	g := gormredis.NewGorm[Product, uint](db, "archived_products", "ID")
+	// schema qualified
+	g.SetTableName("main.archived_products")
+	r, exists, err := g.Get(1)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "old apple", r.Name)
+	_, err = g.Update(1, map[string]interface{}{"name": "older apple"})
+	assert.Nil(t, err)
+	r, _, err = gormredis.NewGorm[Product, uint](db, "products", "ID").Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "apple", r.Name)
+
+	cache := gormredis.NewGormRedis[Product, uint]("app", "archived_products", "ID", db, red, time.Minute)
+	cache.GetDB().(*gormredis.Gorm[Product, uint]).SetTableName("archived_products")
+	r, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "older apple", r.Name)
+}
+
+//openShared in-memory database shared by connections of the same name
+func openShared(t *testing.T, name string) gorm.Dialector {
+	return sqlite.Open("file:" + t.Name() + name + "?mode=memory&cache=shared")
+}
+
+func TestResolver(t *testing.T) {
+	db, err := gorm.Open(openShared(t, "primary"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	assert.Nil(t, err)
+	assert.Nil(t, db.Use(dbresolver.Register(dbresolver.Config{Replicas: []gorm.Dialector{openShared(t, "replica")}}).
+		Register(dbresolver.Config{Replicas: []gorm.Dialector{openShared(t, "reports")}}, "reports")))
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	defer sqlDB.Close()
+	// the replica lags without the row, every database names its copy
+	for _, name := range []string{"primary", "replica", "reports"} {
+		conn, err := gorm.Open(openShared(t, name), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		assert.Nil(t, err)
+		assert.Nil(t, conn.AutoMigrate(&Product{}))
+		if name != "replica" {
+			assert.Nil(t, conn.Create(&Product{ID: 1, Name: name}).Error)
+		}
+		c, err := conn.DB()
+		assert.Nil(t, err)
+		defer c.Close()
+	}
+
+	g := gormredis.NewGorm[Product, uint](db, "products", "ID")
+	g.SetResolver(true, "")
+	_, exists, err := g.Get(1)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	r, exists, err := g.Primary().Get(1)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "primary", r.Name)
+	// writes go to primary
+	assert.Nil(t, g.Create(&Product{ID: 2, Name: "pear"}))
+	_, exists, err = g.Primary().Get(2)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	_, exists, err = g.Get(2)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	g.SetResolver(true, "reports")
+	r, exists, err = g.Get(1)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "reports", r.Name)
+}
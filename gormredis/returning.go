@@ -0,0 +1,111 @@
+package gormredis
+
+import (
+	"errors"
+
+	"github.com/daqiancode/cachelayer"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+//SetReturning fill columns generated by database(defaults, triggers, timestamps) into *T on Create and Save, so the persisted version is returned and cached.
+// Dialects supporting RETURNING(eg. postgres, sqlite) use clause.Returning, others read the row back from primary
+func (s *Gorm[T, I]) SetReturning(enabled bool) {
+	s.returning = enabled
+}
+
+//EnableReturning SetReturning(true) of the gorm database of cache, cache is *cachelayer.RedisCache or *cachelayer.FullRedisCache.
+// FullRedisCache writes the returned record into the full hash
+func EnableReturning[T cachelayer.Table[I], I cachelayer.IDType](cache interface{}) error {
+	var db interface{}
+	switch c := cache.(type) {
+	case *cachelayer.RedisCache[T, I]:
+		db = c.GetDB()
+	case *cachelayer.FullRedisCache[T, I]:
+		db = c.GetDB()
+	}
+	g, ok := db.(*Gorm[T, I])
+	if !ok {
+		return errors.New("gormredis.EnableReturning: cache is not backed by gorm")
+	}
+	g.SetReturning(true)
+	return nil
+}
+
+//supportsReturning whether the dialect registered a RETURNING clause for creates
+func (s *Gorm[T, I]) supportsReturning() bool {
+	for _, v := range s.db.Callback().Create().Clauses {
+		if v == "RETURNING" {
+			return true
+		}
+	}
+	return false
+}
+
+//deleteSupportsReturning whether the dialect registered a RETURNING clause for deletes
+func (s *Gorm[T, I]) deleteSupportsReturning() bool {
+	for _, v := range s.db.Callback().Delete().Clauses {
+		if v == "RETURNING" {
+			return true
+		}
+	}
+	return false
+}
+
+//returningWriter writer returning all columns if returning is enabled and supported
+func (s *Gorm[T, I]) returningWriter() *gorm.DB {
+	db := s.writer()
+	if s.returning && s.supportsReturning() {
+		return db.Clauses(clause.Returning{})
+	}
+	return db
+}
+
+//reload read r back from primary if returning is enabled but the dialect does not support RETURNING
+func (s *Gorm[T, I]) reload(r *T) error {
+	if !s.returning || s.supportsReturning() {
+		return nil
+	}
+	var obj T
+	err := s.writer().Where(map[string]interface{}{s.idField: (*r).GetID()}).First(&obj).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	*r = obj
+	return nil
+}
+
+//DeleteReturning delete rows of ids and return them, see cachelayer.DeleteReturner. Dialects supporting RETURNING(eg. postgres, sqlite) delete
+// and return in one statement, others lock the rows by primary key(SELECT ... FOR UPDATE) and delete them in the same transaction
+func (s *Gorm[T, I]) DeleteReturning(ids ...I) ([]T, error) {
+	var objs []T
+	if len(ids) == 0 {
+		return objs, nil
+	}
+	if s.outbox != nil {
+		err := s.withOutbox(func(g *Gorm[T, I]) ([]T, error) {
+			var err error
+			objs, err = g.DeleteReturning(ids...)
+			return objs, err
+		})
+		return objs, err
+	}
+	if s.deleteSupportsReturning() {
+		return objs, s.writer().Clauses(clause.Returning{}).Delete(&objs, ids).Error
+	}
+	err := s.db.WithContext(s.ctx).Transaction(func(tx *gorm.DB) error {
+		g := *s
+		g.db = tx
+		if err := g.writer().Clauses(clause.Locking{Strength: "UPDATE"}).Find(&objs, ids).Error; err != nil {
+			return err
+		}
+		if len(objs) == 0 {
+			return nil
+		}
+		return g.writer().Delete(new(T), ids).Error
+	})
+	return objs, err
+}
@@ -0,0 +1,109 @@
+package gormredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/callbacks"
+)
+
+//Ticket product with a code generated by database
+type Ticket struct {
+	ID   uint
+	Name string
+	Code string `gorm:"->"`
+}
+
+func (s Ticket) GetID() uint {
+	return s.ID
+}
+
+func (s Ticket) ListIndexes() cachelayer.Indexes {
+	return nil
+}
+
+func TestReturning(t *testing.T) {
+	for _, returning := range []bool{true, false} {
+		name := "returning"
+		if !returning {
+			name = "reload"
+		}
+		t.Run(name, func(t *testing.T) {
+			db := newSQLite(t)
+			assert.Nil(t, db.Exec("CREATE TABLE tickets (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, code TEXT DEFAULT 'T-0')").Error)
+			if !returning {
+				// as mysql, which has no INSERT ... RETURNING
+				config := &callbacks.Config{LastInsertIDReversed: true}
+				assert.Nil(t, db.Callback().Create().Replace("gorm:create", callbacks.Create(config)))
+				db.Callback().Create().Clauses = []string{"INSERT", "VALUES", "ON CONFLICT"}
+			}
+			_, red := newMiniRedis(t)
+			cache := gormredis.NewGormRedisFull[Ticket, uint]("app", "ticket", "ID", db, red, time.Minute)
+			r := Ticket{Name: "a"}
+			assert.Nil(t, cache.Create(&r))
+			assert.Equal(t, Ticket{ID: 1, Name: "a"}, r)
+			assert.Nil(t, gormredis.EnableReturning[Ticket, uint](cache))
+			r = Ticket{Name: "b"}
+			assert.Nil(t, cache.Create(&r))
+			assert.Equal(t, Ticket{ID: 2, Name: "b", Code: "T-0"}, r)
+			// the persisted version is cached
+			cached, _, err := cache.Get(2)
+			assert.Nil(t, err)
+			assert.Equal(t, r, cached)
+		})
+	}
+}
+
+func TestDeleteReturning(t *testing.T) {
+	for _, returning := range []bool{true, false} {
+		name := "returning"
+		if !returning {
+			name = "locking"
+		}
+		t.Run(name, func(t *testing.T) {
+			db := newSQLite(t, Product{ID: 1, Name: "apple", CategoryID: 1}, Product{ID: 2, Name: "pear", CategoryID: 1}, Product{ID: 3, Name: "plum", CategoryID: 2})
+			if !returning {
+				// as mysql, which has no DELETE ... RETURNING
+				assert.Nil(t, db.Callback().Delete().Replace("gorm:delete", callbacks.Delete(&callbacks.Config{})))
+				db.Callback().Delete().Clauses = []string{"DELETE", "FROM", "WHERE"}
+			}
+			mr, red := newMiniRedis(t)
+			cache := gormredis.NewGormRedis[Product, uint]("app", "product", "ID", db, red, time.Minute)
+			_, err := cache.List(1, 2)
+			assert.Nil(t, err)
+			_, err = cache.ListBy(cachelayer.NewIndex("CategoryID", 1), nil)
+			assert.Nil(t, err)
+			// changed behind the cache, the deleted version is returned rather than the cached one
+			assert.Nil(t, db.Model(&Product{ID: 1}).Update("name", "green apple").Error)
+
+			objs, err := cache.DeleteReturning(1, 2, 4)
+			assert.Nil(t, err)
+			assert.ElementsMatch(t, []Product{{ID: 1, Name: "green apple", CategoryID: 1}, {ID: 2, Name: "pear", CategoryID: 1}}, objs)
+			var left []Product
+			assert.Nil(t, db.Find(&left).Error)
+			assert.Equal(t, []Product{{ID: 3, Name: "plum", CategoryID: 2}}, left)
+			assert.Empty(t, mr.Keys())
+			objs, err = cache.DeleteReturning(1)
+			assert.Nil(t, err)
+			assert.Empty(t, objs)
+		})
+	}
+}
+
+func TestDeleteReturningOutbox(t *testing.T) {
+	db := newSQLite(t, Product{ID: 1, Name: "apple", CategoryID: 1})
+	_, red := newMiniRedis(t)
+	outbox := gormredis.NewOutbox(db, red)
+	assert.Nil(t, outbox.Migrate())
+	cache := gormredis.NewGormRedis[Product, uint]("app", "product", "ID", db, red, time.Minute)
+	assert.Nil(t, gormredis.EnableOutbox(cache, outbox))
+	objs, err := cache.DeleteReturning(1)
+	assert.Nil(t, err)
+	assert.Equal(t, []Product{{ID: 1, Name: "apple", CategoryID: 1}}, objs)
+	var events []gormredis.OutboxEvent
+	assert.Nil(t, db.Table(gormredis.DefaultOutboxTable).Find(&events).Error)
+	assert.Len(t, events, 1)
+}
@@ -0,0 +1,63 @@
+package gormredis
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/daqiancode/cachelayer"
+	"gorm.io/gorm"
+)
+
+//Scope named gorm scope, eg. tenant filter, soft-delete or sharding hint. Name identifies the scope in cache keys, so it must be unique per behavior, eg. "tenant:42"
+type Scope struct {
+	Name  string
+	Apply func(db *gorm.DB) *gorm.DB
+}
+
+//TenantScope filter records by column = tenantID
+func TenantScope(column string, tenantID interface{}) Scope {
+	return Scope{
+		Name: column + ":" + cachelayer.Stringify(tenantID, "null"),
+		Apply: func(db *gorm.DB) *gorm.DB {
+			return db.Where(map[string]interface{}{column: tenantID})
+		},
+	}
+}
+
+//WithScopes return a copy applying scopes to every query, reads and writes
+func (s *Gorm[T, I]) WithScopes(scopes ...Scope) *Gorm[T, I] {
+	r := *s
+	r.scopes = append(append([]Scope{}, s.scopes...), scopes...)
+	return &r
+}
+
+//ScopeKey identity of the scopes in cache keys, empty if there is no scope
+func (s *Gorm[T, I]) ScopeKey() string {
+	names := make([]string, len(s.scopes))
+	for i, v := range s.scopes {
+		names[i] = v.Name
+	}
+	return strings.Join(names, "+")
+}
+
+func (s *Gorm[T, I]) applyScopes(db *gorm.DB) *gorm.DB {
+	if len(s.scopes) == 0 {
+		return db
+	}
+	fns := make([]func(*gorm.DB) *gorm.DB, len(s.scopes))
+	for i, v := range s.scopes {
+		fns[i] = v.Apply
+	}
+	return db.Scopes(fns...)
+}
+
+//WithScopes return a copy of cache whose queries apply scopes, scope names are folded into cache keys: {prefix}/{scope key}/{table}/...
+// Writes of the scoped records should go through the scoped cache, so they clear the scoped keys
+func WithScopes[T cachelayer.Table[I], I cachelayer.IDType](cache *cachelayer.RedisCache[T, I], scopes ...Scope) (*cachelayer.RedisCache[T, I], error) {
+	g, ok := cache.GetDB().(*Gorm[T, I])
+	if !ok {
+		return nil, errors.New("gormredis.WithScopes: cache is not backed by gorm")
+	}
+	g = g.WithScopes(scopes...)
+	return cache.WithDB(g).WithCacheKeyPrefix(cache.GetCacheKeyPrefix() + "/" + g.ScopeKey()), nil
+}
@@ -0,0 +1,53 @@
+package gormredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/stretchr/testify/assert"
+)
+
+type Account struct {
+	ID       uint
+	TenantID int
+	Name     string
+}
+
+func (s Account) GetID() uint {
+	return s.ID
+}
+
+func (s Account) ListIndexes() cachelayer.Indexes {
+	return nil
+}
+
+func TestScopedWrites(t *testing.T) {
+	db := newSQLite(t)
+	assert.Nil(t, db.AutoMigrate(&Account{}))
+	assert.Nil(t, db.Create(&[]Account{{ID: 1, TenantID: 1, Name: "a"}, {ID: 2, TenantID: 2, Name: "b"}, {ID: 3, TenantID: 1, Name: "c"}}).Error)
+	_, red := newMiniRedis(t)
+	cache := gormredis.NewGormRedis[Account, uint]("app", "accounts", "ID", db, red, time.Minute)
+	tenant1, err := gormredis.WithScopes(cache, gormredis.TenantScope("tenant_id", 1))
+	assert.Nil(t, err)
+
+	_, exists, err := tenant1.Get(2)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	// rows of other tenants are neither updated nor deleted
+	n, err := tenant1.Update(2, map[string]interface{}{"name": "x"})
+	assert.Nil(t, err)
+	assert.Zero(t, n)
+	n, err = tenant1.Delete(1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	g := cache.GetDB().(*gormredis.Gorm[Account, uint]).WithScopes(gormredis.TenantScope("tenant_id", 1))
+	n, err = g.Delete(2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+
+	var rest []Account
+	assert.Nil(t, db.Order("id").Find(&rest).Error)
+	assert.Equal(t, []Account{{ID: 2, TenantID: 2, Name: "b"}}, rest)
+}
@@ -0,0 +1,187 @@
+package gormredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type Product struct {
+	ID         uint
+	Name       string
+	CategoryID int
+}
+
+func (s Product) GetID() uint {
+	return s.ID
+}
+
+func (s Product) ListIndexes() cachelayer.Indexes {
+	return cachelayer.Indexes{}.Add(cachelayer.NewIndex("CategoryID", s.CategoryID))
+}
+
+//newSQLite in-memory database with table products of rows, closed by the end of t
+func newSQLite(t *testing.T, rows ...Product) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	assert.Nil(t, err)
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	// every connection of ":memory:" opens another database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	assert.Nil(t, db.AutoMigrate(&Product{}))
+	if len(rows) > 0 {
+		assert.Nil(t, db.Create(&rows).Error)
+	}
+	return db
+}
+
+//newMiniRedis embedded redis and a client of it, both closed by the end of t
+func newMiniRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { red.Close() })
+	return mr, red
+}
+
+//newProductCache cache named "product" of table products with rows
+func newProductCache(t *testing.T, rows ...Product) (*cachelayer.RedisCache[Product, uint], *gorm.DB, *miniredis.Miniredis) {
+	db := newSQLite(t, rows...)
+	mr, red := newMiniRedis(t)
+	return gormredis.NewGormRedis[Product, uint]("app", "product", "ID", db, red, time.Minute), db, mr
+}
+
+func TestListByIn(t *testing.T) {
+	cache, db, _ := newProductCache(t, Product{ID: 2, Name: "jerry", CategoryID: 2})
+	for i := 0; i < 2; i++ {
+		r, err := cache.ListByIn("Name", []interface{}{"jerry", "nobody"})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(r))
+		assert.Equal(t, uint(2), r[0].ID)
+		assert.Equal(t, uint(0), r[1].ID)
+	}
+	// the second round is served by the cache
+	var queries int
+	assert.Nil(t, db.Callback().Query().Before("gorm:query").Register("test:queries", func(*gorm.DB) { queries++ }))
+	_, err := cache.ListByIn("Name", []interface{}{"jerry", "nobody"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, queries)
+}
+
+func TestListByAny(t *testing.T) {
+	cache, db, _ := newProductCache(t, Product{ID: 2, Name: "jerry", CategoryID: 2}, Product{ID: 3, Name: "tom", CategoryID: 3}, Product{ID: 1, Name: "ann", CategoryID: 2})
+	for i := 0; i < 2; i++ {
+		r, err := cache.ListByAny(cachelayer.AnyOf{{"Name": "tom"}, {"CategoryID": 2}}, nil)
+		assert.Nil(t, err)
+		// ordered by id
+		assert.Equal(t, []Product{{ID: 1, Name: "ann", CategoryID: 2}, {ID: 2, Name: "jerry", CategoryID: 2}, {ID: 3, Name: "tom", CategoryID: 3}}, r)
+	}
+	_, err := cache.Delete(2)
+	assert.Nil(t, err)
+	r, err := cache.ListByAny(cachelayer.AnyOf{{"CategoryID": 2}, {"Name": "tom"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []Product{{ID: 1, Name: "ann", CategoryID: 2}, {ID: 3, Name: "tom", CategoryID: 3}}, r)
+
+	// orders of the caller are neither changed nor appended to
+	orderBys := append(make(cachelayer.OrderBys, 0, 2), cachelayer.OrderBy{Field: "Name", Asc: false})
+	r, err = gormredis.NewGorm[Product, uint](db, "products", "ID").ListByAny(cachelayer.AnyOf{{"CategoryID": 2}, {"Name": "tom"}}, orderBys)
+	assert.Nil(t, err)
+	assert.Equal(t, "tom", r[0].Name)
+	assert.Equal(t, cachelayer.OrderBys{{Field: "Name", Asc: false}, {}}, orderBys[:2])
+}
+
+func TestListWithMissing(t *testing.T) {
+	cache, _, _ := newProductCache(t, Product{ID: 2, Name: "jerry", CategoryID: 2})
+	for i := 0; i < 2; i++ {
+		r, missing, err := cache.ListWithMissing(404, 2, 404)
+		assert.Nil(t, err)
+		assert.Equal(t, []Product{{ID: 2, Name: "jerry", CategoryID: 2}}, r)
+		assert.Equal(t, []uint{404}, missing)
+	}
+}
+
+func TestListOrder(t *testing.T) {
+	cache, _, mr := newProductCache(t, Product{ID: 2, Name: "jerry", CategoryID: 2}, Product{ID: 3, Name: "tom", CategoryID: 2})
+	_, _, err := cache.Get(2)
+	assert.Nil(t, err)
+	// 2 is cached, 3 and 404 are loaded from database
+	r, err := cache.List(3, 404, 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{3, 0, 2, 3}, []uint{r[0].ID, r[1].ID, r[2].ID, r[3].ID})
+	assert.True(t, mr.Exists("app/product/id/3"))
+	// and all of them from cache
+	r, err = cache.List(3, 404, 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{3, 0, 2, 3}, []uint{r[0].ID, r[1].ID, r[2].ID, r[3].ID})
+}
+
+func TestClearCacheFor(t *testing.T) {
+	cache, db, _ := newProductCache(t, Product{ID: 2, Name: "jerry", CategoryID: 2})
+	_, _, err := cache.Get(2)
+	assert.Nil(t, err)
+	// changed outside the cache, eg. by another service
+	assert.Nil(t, db.Model(&Product{ID: 2}).Update("Name", "tom").Error)
+	r, _, err := cache.Get(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "jerry", r.Name)
+	assert.Nil(t, cache.ClearCacheFor(Product{ID: 2, Name: "tom", CategoryID: 2}))
+	r, _, err = cache.Get(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "tom", r.Name)
+}
+
+func TestCorruptAsMiss(t *testing.T) {
+	cache, _, mr := newProductCache(t, Product{ID: 2, Name: "jerry", CategoryID: 2})
+	cache.SetCorruptPolicy(cachelayer.CorruptAsMiss)
+	mr.Set("app/product/id/2", "{broken")
+	r, err := cache.List(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "jerry", r[0].Name)
+	assert.Equal(t, int64(1), cache.Stats().Corrupted)
+}
+
+func TestSelfHealing(t *testing.T) {
+	cache, _, mr := newProductCache(t, Product{ID: 2, Name: "jerry", CategoryID: 2})
+	var corrupted []string
+	cache.OnCorruption(func(key string, err error) {
+		corrupted = append(corrupted, key)
+	})
+	// truncated payload
+	mr.Set("app/product/id/2", `{"ID":2,"Na`)
+	r, exists, err := cache.Get(2)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "jerry", r.Name)
+	assert.Equal(t, []string{"app/product/id/2"}, corrupted)
+	// the entry is cached again
+	r, _, err = cache.Get(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "jerry", r.Name)
+	assert.Equal(t, 1, len(corrupted))
+}
+
+func TestPrepareStmt(t *testing.T) {
+	db := newSQLite(t, Product{ID: 2, Name: "jerry", CategoryID: 2}, Product{ID: 3, Name: "tom", CategoryID: 3})
+	_, red := newMiniRedis(t)
+	g := gormredis.NewGorm[Product, uint](db, "products", "ID")
+	g.SetPrepareStmt(true)
+	cache := cachelayer.NewRedisCache[Product, uint]("app", "product", "ID", g, red, time.Minute)
+	// same index shape, same statement
+	for _, category := range []int{2, 3, 2} {
+		r, err := cache.ListBy(cachelayer.NewIndex("CategoryID", category), cachelayer.OrderBys{}.Add("Name", true))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(r))
+		assert.Nil(t, cache.ClearCacheFor(r...))
+	}
+	// prepared once
+	stmts := db.Session(&gorm.Session{PrepareStmt: true}).Statement.ConnPool.(*gorm.PreparedStmtDB)
+	assert.Equal(t, 1, len(stmts.Stmts))
+}
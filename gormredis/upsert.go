@@ -0,0 +1,128 @@
+package gormredis
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/daqiancode/cachelayer"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+//upsertAttempts attempts of Upsert when a concurrent write inserts, deletes or changes the conflicting row in between
+const upsertAttempts = 3
+
+//errUpsertRace the conflicting row changed between reading and writing it, the upsert is retried
+var errUpsertRace = errors.New("gormredis: conflicting row changed by a concurrent write")
+
+//Upsert insert r, or update all columns of the row conflicting on conflictColumns(id column if empty). Return the replaced row,
+// r is reloaded so it holds the persisted row including its id.
+// The conflicting row is read without lock and then locked by its primary key, a locking read of a missing row would take gap locks
+// on mysql which deadlock concurrent upserts. A missing row is inserted, and the upsert is retried if a concurrent write inserted it first
+func (s *Gorm[T, I]) Upsert(r *T, conflictColumns ...string) (T, bool, error) {
+	var old T
+	var existed bool
+	var err error
+	if len(conflictColumns) == 0 {
+		conflictColumns = []string{s.idField}
+	}
+	for i := 0; i < upsertAttempts; i++ {
+		if old, existed, err = s.upsert(r, conflictColumns); err != errUpsertRace {
+			return old, existed, conflict(err)
+		}
+	}
+	return old, existed, cachelayer.NewError(cachelayer.ErrConflict, err)
+}
+
+//upsert one attempt of Upsert in a transaction
+func (s *Gorm[T, I]) upsert(r *T, conflictColumns []string) (T, bool, error) {
+	var old T
+	var existed bool
+	err := s.db.WithContext(s.ctx).Transaction(func(tx *gorm.DB) error {
+		g := *s
+		g.db = tx
+		where, err := g.conflictWhere(r, conflictColumns)
+		if err != nil {
+			return err
+		}
+		err = g.writer().Where(where).First(&old).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		existed = err == nil
+		if existed {
+			old, err = g.lockedUpdate(r, old, where, conflictColumns)
+		} else {
+			// in a savepoint, a failed statement aborts the whole transaction on postgres
+			err = tx.Transaction(func(sp *gorm.DB) error {
+				g := g
+				g.db = sp
+				return g.returningWriter().Create(r).Error
+			})
+			if IsDuplicateKey(err) {
+				return errUpsertRace
+			}
+		}
+		if err != nil {
+			return err
+		}
+		var obj T
+		if err = g.writer().Where(where).First(&obj).Error; err != nil {
+			return err
+		}
+		*r = obj
+		if s.outbox == nil {
+			return nil
+		}
+		objs := []T{*r}
+		if existed {
+			objs = append(objs, old)
+		}
+		return s.outbox.Record(tx, s.outboxKeys(objs...)...)
+	})
+	return old, existed, err
+}
+
+//lockedUpdate lock old by primary key and update it with all columns of r, return the locked version of old.
+// errUpsertRace if old is gone or no longer conflicts with r
+func (s *Gorm[T, I]) lockedUpdate(r *T, old T, where map[string]interface{}, conflictColumns []string) (T, error) {
+	var locked T
+	err := s.writer().Clauses(clause.Locking{Strength: "UPDATE"}).Where(map[string]interface{}{s.idField: old.GetID()}).First(&locked).Error
+	if err == gorm.ErrRecordNotFound {
+		return locked, errUpsertRace
+	}
+	if err != nil {
+		return locked, err
+	}
+	lockedWhere, err := s.conflictWhere(&locked, conflictColumns)
+	if err != nil {
+		return locked, err
+	}
+	if !reflect.DeepEqual(where, lockedWhere) {
+		return locked, errUpsertRace
+	}
+	columns := make([]clause.Column, 0, len(where))
+	for k := range where {
+		columns = append(columns, clause.Column{Name: k})
+	}
+	return locked, s.returningWriter().Clauses(clause.OnConflict{Columns: columns, UpdateAll: true}).Create(r).Error
+}
+
+//conflictWhere column -> value of r for conflict columns
+func (s *Gorm[T, I]) conflictWhere(r *T, columns []string) (map[string]interface{}, error) {
+	stmt := &gorm.Statement{DB: s.db}
+	if err := stmt.Parse(r); err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(r).Elem()
+	where := make(map[string]interface{}, len(columns))
+	for _, c := range columns {
+		field := stmt.Schema.LookUpField(c)
+		if field == nil {
+			return nil, fmt.Errorf("gormredis: unknown conflict column %s", c)
+		}
+		where[field.DBName], _ = field.ValueOf(s.ctx, v)
+	}
+	return where, nil
+}
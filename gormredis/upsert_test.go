@@ -0,0 +1,80 @@
+package gormredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/gormredis"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+//Member account with a unique email
+type Member struct {
+	ID    uint
+	Email string `gorm:"uniqueIndex"`
+	Name  string
+}
+
+func (s Member) GetID() uint {
+	return s.ID
+}
+
+func (s Member) ListIndexes() cachelayer.Indexes {
+	return cachelayer.Indexes{}.Add(cachelayer.NewIndex("Email", s.Email))
+}
+
+func TestUpsert(t *testing.T) {
+	db := newSQLite(t)
+	assert.Nil(t, db.AutoMigrate(&Member{}))
+	// locking reads of the upsert
+	var locks []interface{}
+	assert.Nil(t, db.Callback().Query().Before("gorm:query").Register("test:locks", func(db *gorm.DB) {
+		if _, ok := db.Statement.Clauses["FOR"]; ok {
+			locks = append(locks, db.Statement.Clauses["WHERE"].Expression)
+		}
+	}))
+	mr, red := newMiniRedis(t)
+	cache := gormredis.NewGormRedis[Member, uint]("app", "member", "ID", db, red, time.Minute)
+	r := Member{Email: "tom@x.com", Name: "tom"}
+	assert.Nil(t, cache.Upsert(&r, "Email"))
+	assert.Equal(t, uint(1), r.ID)
+	// a missing row is never read with a lock, mysql would take a gap lock
+	assert.Empty(t, locks)
+
+	_, _, err := cache.GetBy(cachelayer.NewIndex("Email", "tom@x.com"))
+	assert.Nil(t, err)
+	r = Member{Email: "tom@x.com", Name: "jerry"}
+	assert.Nil(t, cache.Upsert(&r, "Email"))
+	assert.Equal(t, Member{ID: 1, Email: "tom@x.com", Name: "jerry"}, r)
+	// the existing row is locked by its primary key
+	assert.Len(t, locks, 1)
+	assert.False(t, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex("Email", "tom@x.com"))))
+	var count int64
+	assert.Nil(t, db.Model(&Member{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestUpsertConcurrentInsert(t *testing.T) {
+	db := newSQLite(t)
+	assert.Nil(t, db.AutoMigrate(&Member{}))
+	// a concurrent write inserts the same email right before the first insert of the upsert
+	creates := 0
+	assert.Nil(t, db.Callback().Create().Before("gorm:create").Register("test:race", func(db *gorm.DB) {
+		creates++
+		if creates == 1 {
+			assert.Nil(t, db.Session(&gorm.Session{NewDB: true}).Exec("INSERT INTO members (email, name) VALUES ('tom@x.com', 'other')").Error)
+		}
+	}))
+	g := gormredis.NewGorm[Member, uint](db, "member", "ID")
+	r := Member{Email: "tom@x.com", Name: "tom"}
+	_, existed, err := g.Upsert(&r, "Email")
+	assert.Nil(t, err)
+	assert.False(t, existed)
+	// retried instead of failing with a duplicate key
+	assert.Equal(t, 2, creates)
+	var rows []Member
+	assert.Nil(t, db.Find(&rows).Error)
+	assert.Equal(t, []Member{r}, rows)
+}
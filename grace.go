@@ -0,0 +1,172 @@
+package cachelayer
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const freshKeySuffix = ":fresh"
+
+func freshKey(key string) string {
+	return key + freshKeySuffix
+}
+
+//SetGrace keep entries grace longer than ttl, so they can be served stale when database is unavailable. 0 to disable
+func (s *RedisJson[T]) SetGrace(grace time.Duration) {
+	s.grace = grace
+}
+
+func (s *RedisJson[T]) GetGrace() time.Duration {
+	return s.grace
+}
+
+//storeTTL real redis ttl of entries: ttl + grace
+func (s *RedisJson[T]) storeTTL() time.Duration {
+	return s.ttl + s.grace
+}
+
+//GetJsonStale return (obj, exists, stale, error), stale is true if the entry outlived ttl and is kept only for grace
+func (s *RedisJson[T]) GetJsonStale(key string) (T, bool, bool, error) {
+	if s.grace <= 0 {
+		r, exists, err := s.GetJson(key)
+		return r, exists, false, err
+	}
+	var r T
+	p := s.reader(key).Pipeline()
+	valueCmd := p.Get(s.ctx, key)
+	freshCmd := p.Exists(s.ctx, freshKey(key))
+	_, err := p.Exec(s.ctx)
+	if err != nil && err != redis.Nil {
+		return r, false, false, cacheError(err)
+	}
+	y, err := valueCmd.Result()
+	if err != nil {
+		if err == redis.Nil {
+			return r, false, false, nil
+		}
+		return r, false, false, cacheError(err)
+	}
+	err = s.unmarshal(y, &r)
+	return r, true, freshCmd.Val() == 0, err
+}
+
+//SetGrace serve entries up to grace after ttl when database fails. 0 to disable
+func (s *RedisCache[T, I]) SetGrace(grace time.Duration) {
+	s.red.SetGrace(grace)
+}
+
+//GetWithStale same as Get, stale is true when the returned obj is a stale copy served because database failed
+func (s *RedisCache[T, I]) GetWithStale(id I) (T, bool, bool, error) {
+	r, exists, stale, err := s.getWithStale(id)
+	return r, exists, stale, s.notFound("get", s.MakeCacheKey(NewIndex(s.GetIdField(), id)), exists, err)
+}
+
+func (s *RedisCache[T, I]) getWithStale(id I) (T, bool, bool, error) {
+	redisKey := s.MakeCacheKey(NewIndex(s.GetIdField(), id))
+	if s.strongRead {
+		var zero T
+		return s.load(id, redisKey, zero, false)
+	}
+	s.hotKeys.Record(redisKey)
+	start := s.clock.Now()
+	r, exists, stale, err := s.red.GetJsonStale(redisKey)
+	s.observe(OpCacheRead, redisKey, start, err)
+	if err != nil && s.heal(redisKey, err) {
+		exists, stale, err = false, false, nil
+	}
+	if err != nil {
+		if s.failureMode == FailCache {
+			return r, false, false, err
+		}
+		s.report("cache_read", err)
+		var zero T
+		r, exists, _, dbErr := s.load(id, redisKey, zero, false)
+		if dbErr != nil {
+			r, exists, dbErr = failed[T](s.failureMode, s.lastValues, redisKey, err, dbErr)
+		}
+		return r, exists, false, dbErr
+	}
+	if exists && !stale && !s.sessionWritten(redisKey) {
+		s.stats.hit(1)
+		s.lastValues.put(redisKey, r, !s.IsNullID(r.GetID()))
+		if s.IsNullID(r.GetID()) {
+			s.stats.nullHit()
+			s.trace(TraceNullHit, nil, redisKey)
+		} else {
+			s.trace(TraceHit, nil, redisKey)
+		}
+		if recordPolicy(r) == CacheDefault {
+			s.report("refresh", s.red.Refresh(redisKey))
+		}
+		return r, !s.IsNullID(r.GetID()), false, nil
+	}
+	s.stats.miss(1)
+	if stale {
+		s.trace(TraceStale, nil, redisKey)
+	} else {
+		s.trace(TraceMiss, nil, redisKey)
+	}
+	if s.coalesceWait > 0 && !stale {
+		r, exists, err = s.coalesced(redisKey, func() (T, bool, error) {
+			r, exists, _, err := s.load(id, redisKey, r, false)
+			return r, exists, err
+		})
+		return r, exists, false, err
+	}
+	return s.load(id, redisKey, r, stale)
+}
+
+//load record of id from database and cache it, cached is served as a stale copy if database fails and stale is true
+func (s *RedisCache[T, I]) load(id I, redisKey string, cached T, stale bool) (T, bool, bool, error) {
+	if !s.allowDB(redisKey) {
+		if stale {
+			return cached, !s.IsNullID(cached.GetID()), true, nil
+		}
+		r, exists, err := rateLimited[T](s.dbLimiter, redisKey)
+		return r, exists, false, err
+	}
+	gen := s.generation.current()
+	start := s.clock.Now()
+	r, exists, err := s.db.Get(id)
+	s.dbLoaded(start, rowsOf(exists), err, redisKey)
+	if err != nil {
+		if stale {
+			return cached, !s.IsNullID(cached.GetID()), true, nil
+		}
+		return r, false, false, err
+	}
+	s.dbLimiter.remember(redisKey, r, exists)
+	s.lastValues.put(redisKey, r, exists)
+	if !exists {
+		if !s.noNegativeCache {
+			err = s.cacheFailed(s.populate(gen, func() error { return s.red.SetNull(redisKey) }))
+		}
+		return r, exists, false, err
+	}
+	obj := r
+	err = s.cacheFailed(s.populate(gen, func() error {
+		if err := s.red.SetJson(redisKey, obj); err != nil {
+			return err
+		}
+		return s.addRefs(s.red.UniversalClient, s.red.storeTTL(), map[string][]I{redisKey: {id}})
+	}))
+	return r, true, false, err
+}
+
+//serveStale fill positions of missed ids by their stale copies when database fails in grace mode, false if an id has none
+func (s *RedisCache[T, I]) serveStale(records []T, stale map[int]T, missedIds []I, missedPositions map[I][]int) bool {
+	for _, v := range missedIds {
+		for _, p := range missedPositions[v] {
+			if _, ok := stale[p]; !ok {
+				return false
+			}
+		}
+	}
+	for _, v := range missedIds {
+		for _, p := range missedPositions[v] {
+			records[p] = stale[p]
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrace(t *testing.T) {
+	cache, db, mr := newMemberCache(t, member{ID: 1, Name: "tom"}, member{ID: 2, Name: "ann"})
+	cache.SetGrace(time.Minute)
+	_, err := cache.List(1, 2)
+	assert.Nil(t, err)
+	assert.True(t, mr.Exists("app/member/id/1:fresh"))
+
+	// fresh entries are hits
+	_, err = cache.List(1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, db.Queries())
+
+	// entries older than ttl are reloaded
+	mr.FastForward(61 * time.Second)
+	assert.True(t, mr.Exists("app/member/id/1"))
+	_, err = cache.List(1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, db.Queries())
+
+	// and served stale by Get and List while database fails
+	mr.FastForward(61 * time.Second)
+	db.setDown(true)
+	r, exists, stale, err := cache.GetWithStale(1)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.True(t, stale)
+	assert.Equal(t, "tom", r.Name)
+	objs, err := cache.List(1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "ann", objs[1].Name)
+	// an id without stale copy fails the list
+	_, err = cache.List(1, 3)
+	assert.ErrorIs(t, err, errDBDown)
+}
+
+func TestGraceStaleNull(t *testing.T) {
+	cache, db, mr := newMemberCache(t, member{ID: 1, Name: "tom"})
+	cache.SetGrace(time.Minute)
+	_, exists, err := cache.Get(3)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	// a stale not found entry stays not found while database fails
+	mr.FastForward(61 * time.Second)
+	db.setDown(true)
+	_, exists, stale, err := cache.GetWithStale(3)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	assert.True(t, stale)
+	_, exists, err = cache.Get(3)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,228 @@
+package cachelayer
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//SetHashIndexes maintain a redis set of member ids per index value of the full hash, so GetBy/ListBy of indexes declared by ListIndexes
+// are answered from the full hash without querying the database. The sets are rebuilt by Load and updated by writes through the cache
+func (s *FullRedisCache[T, I]) SetHashIndexes(enabled bool) {
+	s.hashIndexes = enabled
+}
+
+//indexRegistryKey redis set of the index sets of the full hash, it exists only while the index sets are complete
+func (s *FullRedisCache[T, I]) indexRegistryKey() string {
+	return s.CacheKey() + ":idx"
+}
+
+//IndexSetKey redis set of ids of records of index, eg. app/user/full:idx/name/tom
+func (s *FullRedisCache[T, I]) IndexSetKey(index Index) string {
+	base := strings.ToLower(s.keyPrefix() + "/" + s.table)
+	return s.indexRegistryKey() + strings.TrimPrefix(s.MakeCacheKey(index), base)
+}
+
+//isHashIndex whether index has the fields of an index declared by ListIndexes
+func (s *FullRedisCache[T, I]) isHashIndex(index Index) bool {
+	if !s.hashIndexes {
+		return false
+	}
+	var t T
+	shape := indexShape(index)
+	for _, v := range t.ListIndexes() {
+		if indexShape(v) == shape {
+			return true
+		}
+	}
+	return false
+}
+
+//indexShape sorted lower case fields of index
+func indexShape(index Index) string {
+	fields := make([]string, 0, len(index))
+	for k := range index {
+		fields = append(fields, strings.ToLower(k))
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ",")
+}
+
+//addIndexSets add ids of objs to sets by index set key
+func (s *FullRedisCache[T, I]) addIndexSets(sets map[string][]interface{}, objs ...T) {
+	for _, v := range objs {
+		id := Stringify(v.GetID(), "")
+		for _, index := range v.ListIndexes() {
+			key := s.IndexSetKey(index)
+			sets[key] = append(sets[key], id)
+		}
+	}
+}
+
+//rebuildIndexes replace the index sets with sets, called by Load after the full hash is written
+func (s *FullRedisCache[T, I]) rebuildIndexes(sets map[string][]interface{}) error {
+	registry := s.indexRegistryKey()
+	old, err := s.red.SMembers(s.ctx, registry).Result()
+	if err != nil && err != redis.Nil {
+		return cacheError(err)
+	}
+	ttl := s.red.storeTTL()
+	p := s.red.Pipeline()
+	stale := []string{registry}
+	for _, v := range old {
+		if v != "" {
+			stale = append(stale, v)
+		}
+	}
+	p.Del(s.ctx, stale...)
+	// the empty member marks the registry of a table without records as complete
+	p.SAdd(s.ctx, registry, "")
+	for k, ids := range sets {
+		p.SAdd(s.ctx, k, ids...)
+		p.Expire(s.ctx, k, ttl)
+		p.SAdd(s.ctx, registry, k)
+	}
+	p.Expire(s.ctx, registry, ttl)
+	_, err = p.Exec(s.ctx)
+	return cacheError(err)
+}
+
+//reindex move ids of records from the index sets of old to the index sets of objs. Nothing is done if the index sets are not loaded,
+// and they are dropped if the update fails, so the next read reloads them
+func (s *FullRedisCache[T, I]) reindex(old, objs []T) error {
+	if !s.hashIndexes {
+		return nil
+	}
+	registry := s.indexRegistryKey()
+	n, err := s.red.Exists(s.ctx, registry).Result()
+	if err != nil || n == 0 {
+		return cacheError(err)
+	}
+	ttl := s.red.storeTTL()
+	p := s.red.Pipeline()
+	for _, v := range old {
+		for _, index := range v.ListIndexes() {
+			p.SRem(s.ctx, s.IndexSetKey(index), Stringify(v.GetID(), ""))
+		}
+	}
+	added := make(map[string][]interface{})
+	s.addIndexSets(added, objs...)
+	for k, ids := range added {
+		p.SAdd(s.ctx, k, ids...)
+		p.Expire(s.ctx, k, ttl)
+		p.SAdd(s.ctx, registry, k)
+	}
+	if _, err = p.Exec(s.ctx); err != nil {
+		s.report("reindex", s.red.Del(s.ctx, registry).Err())
+		return cacheError(err)
+	}
+	return nil
+}
+
+//listIndexed records of index from the index sets, loading the full hash if the hash or its index sets are missing
+func (s *FullRedisCache[T, I]) listIndexed(index Index, orderBys OrderBys) ([]T, error) {
+	key := s.CacheKey()
+	registry := s.indexRegistryKey()
+	s.hotKeys.Record(key)
+	start := s.clock.Now()
+	n, err := s.red.Exists(s.ctx, key, registry).Result()
+	s.observe(OpCacheRead, key, start, err)
+	if err != nil {
+		return nil, cacheError(err)
+	}
+	if n < 2 {
+		s.stats.miss(1)
+		s.trace(TraceMiss, nil, key)
+		if err := s.Load(); err != nil {
+			return nil, err
+		}
+	} else {
+		s.stats.hit(1)
+		s.trace(TraceHit, nil, key)
+	}
+	members, err := s.red.SMembers(s.ctx, s.IndexSetKey(index)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, cacheError(err)
+	}
+	ids := make([]I, len(members))
+	for i, v := range members {
+		if ids[i], err = ParseID[I](v); err != nil {
+			return nil, NewError(ErrSerialization, err)
+		}
+	}
+	s.report("refresh", s.red.Refresh(key))
+	objs, err := s.red.HMGetJson(key, ids...)
+	if err != nil {
+		return nil, err
+	}
+	objs = s.existingRecords(objs)
+	if len(orderBys) == 0 {
+		orderBys = NewOrderBys(s.GetIdField(), true)
+	}
+	sortRecords(objs, orderBys)
+	return objs, nil
+}
+
+//sortRecords sort objs by orderBys in memory, fields which can't be compared are skipped
+func sortRecords[T any](objs []T, orderBys OrderBys) {
+	if len(objs) < 2 || len(orderBys) == 0 {
+		return
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		a := reflect.Indirect(reflect.ValueOf(objs[i]))
+		b := reflect.Indirect(reflect.ValueOf(objs[j]))
+		if a.Kind() != reflect.Struct || b.Kind() != reflect.Struct {
+			return false
+		}
+		for _, v := range orderBys {
+			f, ok := lookupField(a.Type(), v.Field)
+			if !ok {
+				continue
+			}
+			if c := compareValues(a.FieldByName(f.Name), b.FieldByName(f.Name)); c != 0 {
+				return (c < 0) == v.Asc
+			}
+		}
+		return false
+	})
+}
+
+//compareValues -1, 0 or 1 for numbers, strings, bools and time.Time, 0 for other kinds
+func compareValues(a, b reflect.Value) int {
+	if !a.CanInterface() {
+		return 0
+	}
+	if t, ok := a.Interface().(time.Time); ok {
+		u := b.Interface().(time.Time)
+		switch {
+		case t.Before(u):
+			return -1
+		case t.After(u):
+			return 1
+		}
+		return 0
+	}
+	var less, greater bool
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less, greater = a.Int() < b.Int(), a.Int() > b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		less, greater = a.Uint() < b.Uint(), a.Uint() > b.Uint()
+	case reflect.Float32, reflect.Float64:
+		less, greater = a.Float() < b.Float(), a.Float() > b.Float()
+	case reflect.String:
+		less, greater = a.String() < b.String(), a.String() > b.String()
+	case reflect.Bool:
+		less, greater = !a.Bool() && b.Bool(), a.Bool() && !b.Bool()
+	}
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
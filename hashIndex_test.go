@@ -0,0 +1,14 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexSetKey(t *testing.T) {
+	cache := cachelayer.NewFullRedisCache[keyUser, uint]("app", "User", "ID", nil, nil, time.Minute)
+	assert.Equal(t, "app/user/full:idx/age/3/name/tom", cache.IndexSetKey(cachelayer.Index{"Name": "tom", "Age": 3}))
+}
@@ -0,0 +1,93 @@
+package cachelayer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//Pinger component whose connections can be checked, implemented by RedisCache, FullRedisCache and the database adapters
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+//HealthStatus result of pinging a cache
+type HealthStatus struct {
+	Name    string
+	Table   string
+	Healthy bool
+	Error   string `json:"error,omitempty"`
+	Latency time.Duration
+}
+
+//HealthReport result of HealthCheck, Healthy if every cache is
+type HealthReport struct {
+	Healthy bool
+	Caches  []HealthStatus
+}
+
+//pingAll ping redis and db(if it implements Pinger)
+func pingAll(ctx context.Context, red redis.UniversalClient, db interface{}) error {
+	if err := red.Ping(ctx).Err(); err != nil {
+		return cacheError(err)
+	}
+	if p, ok := db.(Pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			return fmt.Errorf("database: %w", err)
+		}
+	}
+	return nil
+}
+
+//Ping check redis by PING and the database if it implements Pinger
+func (s *RedisCache[T, I]) Ping(ctx context.Context) error {
+	return s.wrapErr("ping", "", pingAll(ctx, s.red.UniversalClient, s.db))
+}
+
+//Ping check redis by PING and the database if it implements Pinger
+func (s *FullRedisCache[T, I]) Ping(ctx context.Context) error {
+	return s.wrapErr("ping", "", pingAll(ctx, s.red.UniversalClient, s.db))
+}
+
+//HealthCheck ping caches implementing Pinger concurrently, eg. for readiness probes gating traffic. ctx bounds the check
+func (s *Registry) HealthCheck(ctx context.Context) HealthReport {
+	r := HealthReport{Healthy: true}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range s.Names() {
+		c, ok := s.Get(name)
+		if !ok {
+			continue
+		}
+		p, ok := c.(Pinger)
+		if !ok {
+			continue
+		}
+		name := name
+		wg.Add(1)
+		go labeled(ctx, "health_check", func(ctx context.Context) {
+			defer wg.Done()
+			start := time.Now()
+			err := safely("health_check", c.GetTableName(), func() error {
+				return p.Ping(ctx)
+			})
+			status := HealthStatus{Name: name, Table: c.GetTableName(), Healthy: err == nil, Latency: time.Since(start)}
+			if err != nil {
+				status.Error = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			r.Caches = append(r.Caches, status)
+			r.Healthy = r.Healthy && status.Healthy
+		})
+	}
+	wg.Wait()
+	sort.Slice(r.Caches, func(i, j int) bool {
+		return r.Caches[i].Name < r.Caches[j].Name
+	})
+	return r
+}
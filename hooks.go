@@ -0,0 +1,62 @@
+package cachelayer
+
+import "reflect"
+
+//BeforeCacher implemented by *T to transform a record before it is cached, eg. clear password hashes and tokens so they never reach redis.
+// BeforeCache is called on a copy, the caller's record is not changed
+type BeforeCacher interface {
+	BeforeCache()
+}
+
+//AfterLoader implemented by *T to transform a record read from cache, eg. mark redacted fields as not loaded
+type AfterLoader interface {
+	AfterLoad()
+}
+
+var beforeCacherType = reflect.TypeOf((*BeforeCacher)(nil)).Elem()
+
+//beforeCache copy of obj transformed by BeforeCacher, obj itself if it does not implement it
+func beforeCache(obj interface{}) interface{} {
+	v := reflect.ValueOf(obj)
+	if !v.IsValid() {
+		return obj
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return obj
+		}
+		v = v.Elem()
+	}
+	if !reflect.PtrTo(v.Type()).Implements(beforeCacherType) {
+		return obj
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	p.Interface().(BeforeCacher).BeforeCache()
+	return p.Interface()
+}
+
+//afterLoad transform objRef read from cache by AfterLoader
+func afterLoad(objRef interface{}) {
+	if l, ok := objRef.(AfterLoader); ok {
+		l.AfterLoad()
+	}
+}
+
+//GetFromDB read the record from database bypassing cache, eg. to get fields removed by BeforeCacher. Nothing is cached
+func (s *RedisCache[T, I]) GetFromDB(id I) (T, bool, error) {
+	key := s.MakeCacheKey(NewIndex(s.GetIdField(), id))
+	start := s.clock.Now()
+	r, exists, err := s.db.Get(id)
+	s.dbLoaded(start, rowsOf(exists), err, key)
+	return r, exists, s.notFound("get_from_db", key, exists, err)
+}
+
+//GetFromDB read the record from database bypassing cache, see RedisCache.GetFromDB
+func (s *FullRedisCache[T, I]) GetFromDB(id I) (T, bool, error) {
+	key := s.CacheKey()
+	start := s.clock.Now()
+	r, exists, err := s.db.Get(id)
+	s.dbLoaded(start, rowsOf(exists), err, key)
+	return r, exists, s.notFound("get_from_db", key, exists, err)
+}
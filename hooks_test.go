@@ -0,0 +1,127 @@
+package cachelayer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+type login struct {
+	ID           uint
+	Name         string
+	PasswordHash string
+	Redacted     bool `json:"-"`
+}
+
+func (s login) GetID() uint {
+	return s.ID
+}
+
+func (s login) ListIndexes() cachelayer.Indexes {
+	return cachelayer.Indexes{}
+}
+
+func (s *login) BeforeCache() {
+	s.PasswordHash = ""
+}
+
+func (s *login) AfterLoad() {
+	s.Redacted = true
+}
+
+//loginDB logins by id counting Get calls
+type loginDB struct {
+	rows map[uint]login
+	gets *int
+}
+
+func (s loginDB) Create(obj *login) error {
+	s.rows[obj.ID] = *obj
+	return nil
+}
+func (s loginDB) Save(obj *login) error                        { return s.Create(obj) }
+func (s loginDB) Delete(ids ...uint) (int64, error)            { return 0, nil }
+func (s loginDB) Update(id uint, v interface{}) (int64, error) { return 0, nil }
+func (s loginDB) Close() error                                 { return nil }
+func (s loginDB) Get(id uint) (login, bool, error) {
+	*s.gets++
+	r, ok := s.rows[id]
+	return r, ok, nil
+}
+func (s loginDB) List(ids ...uint) ([]login, error) {
+	var r []login
+	for _, v := range ids {
+		if x, ok := s.rows[v]; ok {
+			r = append(r, x)
+		}
+	}
+	return r, nil
+}
+func (s loginDB) GetBy(index cachelayer.Index) (login, bool, error) {
+	return login{}, false, nil
+}
+func (s loginDB) ListBy(index cachelayer.Index, orderBys cachelayer.OrderBys) ([]login, error) {
+	return s.ListAll()
+}
+func (s loginDB) ListAll() ([]login, error) {
+	var r []login
+	for _, v := range s.rows {
+		r = append(r, v)
+	}
+	return r, nil
+}
+
+func TestBeforeCache(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	db := loginDB{rows: map[uint]login{1: {ID: 1, Name: "tom", PasswordHash: "$2a$secret"}}, gets: new(int)}
+	cache := cachelayer.NewRedisCache[login, uint]("app", "login", "ID", db, red, time.Minute)
+	cache.SetWriteMode(cachelayer.WriteThrough)
+
+	r, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "tom", r.Name)
+	payload, err := mr.Get("app/login/id/1")
+	assert.Nil(t, err)
+	assert.Contains(t, payload, "tom")
+	assert.NotContains(t, payload, "secret")
+
+	r, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, login{ID: 1, Name: "tom", Redacted: true}, r)
+	assert.Equal(t, 1, *db.gets)
+
+	// the stripped field is read from database on demand, nothing is cached
+	r, exists, err := cache.GetFromDB(1)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "$2a$secret", r.PasswordHash)
+	assert.Equal(t, 2, *db.gets)
+
+	// the record of the caller is left untouched
+	obj := login{ID: 2, Name: "ann", PasswordHash: "$2a$other"}
+	assert.Nil(t, cache.Create(&obj))
+	assert.Equal(t, "$2a$other", obj.PasswordHash)
+	payload, err = mr.Get("app/login/id/2")
+	assert.Nil(t, err)
+	assert.NotContains(t, payload, "other")
+}
+
+func TestBeforeCacheFull(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	db := loginDB{rows: map[uint]login{1: {ID: 1, Name: "tom", PasswordHash: "$2a$secret"}}, gets: new(int)}
+	cache := cachelayer.NewFullRedisCache[login, uint]("app", "login", "ID", db, red, time.Minute)
+	_, err := cache.ListAll()
+	assert.Nil(t, err)
+	fields, err := mr.HKeys(cache.CacheKey())
+	assert.Nil(t, err)
+	assert.Len(t, fields, 1)
+	for _, v := range fields {
+		assert.False(t, strings.Contains(mr.HGet(cache.CacheKey(), v), "secret"))
+	}
+	objs, err := cache.ListAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []login{{ID: 1, Name: "tom", Redacted: true}}, objs)
+}
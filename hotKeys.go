@@ -0,0 +1,189 @@
+package cachelayer
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+const defaultHotKeyCapacity = 10000
+
+type HotKey struct {
+	Key   string
+	Count int64
+}
+
+//HotKeyTracker count sampled key accesses, report the most frequently accessed keys.
+// At most capacity keys are counted by the space-saving algorithm: a new key replaces the least counted one and inherits its count,
+// so a key turning hot after the tracker is full is still found. Counts of HotKeys may be overestimated by the inherited count,
+// OnHotKey only fires on accesses counted since the key was tracked
+type HotKeyTracker struct {
+	sampleRate float64
+	capacity   int
+	threshold  int64
+	onHot      func(key string, count int64)
+	mu         sync.Mutex
+	counts     map[string]*hotKeyCount
+	//least min-heap of counts, the root is replaced by new keys when full
+	least    hotKeyHeap
+	reported map[string]bool
+}
+
+//hotKeyCount sampled count of a key and its position in the heap
+type hotKeyCount struct {
+	key   string
+	count int64
+	//inherited count of the replaced key, the key was accessed at least count-inherited times
+	inherited int64
+	index     int
+}
+
+//hotKeyHeap heap.Interface of counts, least first
+type hotKeyHeap []*hotKeyCount
+
+func (s hotKeyHeap) Len() int {
+	return len(s)
+}
+func (s hotKeyHeap) Less(i, j int) bool {
+	return s[i].count < s[j].count
+}
+func (s hotKeyHeap) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+	s[i].index = i
+	s[j].index = j
+}
+func (s *hotKeyHeap) Push(x interface{}) {
+	v := x.(*hotKeyCount)
+	v.index = len(*s)
+	*s = append(*s, v)
+}
+func (s *hotKeyHeap) Pop() interface{} {
+	old := *s
+	v := old[len(old)-1]
+	old[len(old)-1] = nil
+	*s = old[:len(old)-1]
+	return v
+}
+
+//NewHotKeyTracker sampleRate in (0,1], eg. 0.01 means 1% of accesses are recorded
+func NewHotKeyTracker(sampleRate float64) *HotKeyTracker {
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = 1
+	}
+	return &HotKeyTracker{
+		sampleRate: sampleRate,
+		capacity:   defaultHotKeyCapacity,
+		counts:     make(map[string]*hotKeyCount),
+		reported:   make(map[string]bool),
+	}
+}
+
+//SetCapacity max number of distinct keys tracked, when full a new key replaces the least counted one
+func (s *HotKeyTracker) SetCapacity(capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capacity = capacity
+	for len(s.least) > 0 && len(s.least) > capacity {
+		s.evict(heap.Pop(&s.least).(*hotKeyCount))
+	}
+}
+
+//evict forget the counted key
+func (s *HotKeyTracker) evict(v *hotKeyCount) {
+	delete(s.counts, v.key)
+	delete(s.reported, v.key)
+}
+
+//increment count key once
+func (s *HotKeyTracker) increment(key string) *hotKeyCount {
+	if v, ok := s.counts[key]; ok {
+		v.count++
+		heap.Fix(&s.least, v.index)
+		return v
+	}
+	if len(s.least) < s.capacity {
+		v := &hotKeyCount{key: key, count: 1}
+		heap.Push(&s.least, v)
+		s.counts[key] = v
+		return v
+	}
+	// the least counted key is replaced, its count bounds the accesses of key missed meanwhile
+	v := s.least[0]
+	s.evict(v)
+	v.key = key
+	v.inherited = v.count
+	v.count++
+	s.counts[key] = v
+	heap.Fix(&s.least, 0)
+	return v
+}
+
+//OnHotKey fn is called once per key when its estimated access count reaches threshold
+func (s *HotKeyTracker) OnHotKey(threshold int64, fn func(key string, count int64)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.threshold = threshold
+	s.onHot = fn
+}
+
+func (s *HotKeyTracker) Record(keys ...string) {
+	if s == nil {
+		return
+	}
+	var hot []HotKey
+	var fn func(key string, count int64)
+	s.mu.Lock()
+	for _, key := range keys {
+		if s.sampleRate < 1 && rand.Float64() >= s.sampleRate {
+			continue
+		}
+		if s.capacity <= 0 {
+			continue
+		}
+		v := s.increment(key)
+		count := s.estimate(v.count - v.inherited)
+		if s.onHot != nil && s.threshold > 0 && count >= s.threshold && !s.reported[key] {
+			s.reported[key] = true
+			hot = append(hot, HotKey{Key: key, Count: count})
+		}
+	}
+	fn = s.onHot
+	s.mu.Unlock()
+	for _, v := range hot {
+		fn(v.Key, v.Count)
+	}
+}
+
+func (s *HotKeyTracker) estimate(sampled int64) int64 {
+	return int64(float64(sampled) / s.sampleRate)
+}
+
+//HotKeys return top n keys order by estimated access count desc
+func (s *HotKeyTracker) HotKeys(n int) []HotKey {
+	s.mu.Lock()
+	r := make([]HotKey, 0, len(s.counts))
+	for k, v := range s.counts {
+		r = append(r, HotKey{Key: k, Count: s.estimate(v.count)})
+	}
+	s.mu.Unlock()
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Count == r[j].Count {
+			return r[i].Key < r[j].Key
+		}
+		return r[i].Count > r[j].Count
+	})
+	if n >= 0 && n < len(r) {
+		r = r[:n]
+	}
+	return r
+}
+
+//Reset clear all counters, eg. call it periodically to get a sliding report
+func (s *HotKeyTracker) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts = make(map[string]*hotKeyCount)
+	s.least = nil
+	s.reported = make(map[string]bool)
+}
@@ -0,0 +1,52 @@
+package cachelayer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHotKeys(t *testing.T) {
+	tracker := cachelayer.NewHotKeyTracker(1)
+	var hot []string
+	tracker.OnHotKey(3, func(key string, count int64) {
+		hot = append(hot, key)
+	})
+	tracker.Record("a", "b", "a", "c", "a", "b", "a")
+	r := tracker.HotKeys(2)
+	assert.Equal(t, 2, len(r))
+	assert.Equal(t, "a", r[0].Key)
+	assert.Equal(t, int64(4), r[0].Count)
+	assert.Equal(t, "b", r[1].Key)
+	assert.Equal(t, []string{"a"}, hot)
+	tracker.Reset()
+	assert.Equal(t, 0, len(tracker.HotKeys(10)))
+}
+
+func TestHotKeysFull(t *testing.T) {
+	tracker := cachelayer.NewHotKeyTracker(1)
+	tracker.SetCapacity(3)
+	var hot []string
+	tracker.OnHotKey(5, func(key string, count int64) {
+		hot = append(hot, key)
+	})
+	// cold keys fill the tracker
+	for i := 0; i < 100; i++ {
+		tracker.Record(fmt.Sprintf("cold%d", i))
+	}
+	assert.Equal(t, 3, len(tracker.HotKeys(-1)))
+	// a key turning hot afterwards is still counted and reported
+	for i := 0; i < 10; i++ {
+		tracker.Record("hot", fmt.Sprintf("cold%d", 100+i))
+	}
+	r := tracker.HotKeys(1)
+	assert.Equal(t, "hot", r[0].Key)
+	assert.True(t, r[0].Count >= 10)
+	assert.Equal(t, []string{"hot"}, hot)
+	assert.Equal(t, 3, len(tracker.HotKeys(-1)))
+
+	tracker.SetCapacity(1)
+	assert.Equal(t, []cachelayer.HotKey{r[0]}, tracker.HotKeys(-1))
+}
@@ -0,0 +1,125 @@
+package cachelayer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//IndexField typed index field of entity T, created by NewIndexField which checks the field exists, eg.
+//
+//	var UserIdx = struct {
+//		Email cachelayer.IndexField[string]
+//	}{cachelayer.MustIndexField[User, string]("email")}
+//
+//	cache.GetBy(UserIdx.Email.Eq("a@b.c"))
+//
+// Declare them as package variables, so a typo fails at program start(and in every test) instead of causing permanent cache misses
+type IndexField[V any] struct {
+	name string
+}
+
+//NewIndexField field name of T with value type V(or *V). name matches the go field name or its json, bson or gorm column name, case-insensitively
+func NewIndexField[T any, V any](name string) (IndexField[V], error) {
+	var t T
+	typ := reflect.TypeOf(t)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return IndexField[V]{}, fmt.Errorf("cachelayer: index field %s: %T is not a struct", name, t)
+	}
+	field, ok := lookupField(typ, strings.SplitN(name, ".", 2)[0])
+	if !ok {
+		return IndexField[V]{}, fmt.Errorf("cachelayer: index field %s: no such field in %s", name, typ.Name())
+	}
+	vt := reflect.TypeOf((*V)(nil)).Elem()
+	if !strings.Contains(name, ".") && vt.Kind() != reflect.Interface && field.Type != vt && field.Type != reflect.PtrTo(vt) {
+		return IndexField[V]{}, fmt.Errorf("cachelayer: index field %s: type of field %s is %s, not %s", name, field.Name, field.Type, vt)
+	}
+	return IndexField[V]{name: name}, nil
+}
+
+//MustIndexField NewIndexField panicking on error
+func MustIndexField[T any, V any](name string) IndexField[V] {
+	r, err := NewIndexField[T, V](name)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (s IndexField[V]) Name() string {
+	return s.name
+}
+
+//Eq index of the field equal to value
+func (s IndexField[V]) Eq(value V) Index {
+	return NewIndex(s.name, value)
+}
+
+//And copy of s with fields of index, eg. UserIdx.TenantID.Eq(1).And(UserIdx.Email.Eq("a@b.c"))
+func (s Index) And(index Index) Index {
+	r := make(Index, len(s)+len(index))
+	for k, v := range s {
+		r[k] = v
+	}
+	for k, v := range index {
+		r[k] = v
+	}
+	return r
+}
+
+//LookupField field of struct T by go name or json, bson or gorm column name(case-insensitively), eg. to translate index fields
+// into database fields
+func LookupField[T any](name string) (reflect.StructField, bool) {
+	var t T
+	typ := reflect.TypeOf(t)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	return lookupField(typ, name)
+}
+
+//lookupField struct field of typ by go name or json, bson or gorm column name, embedded structs included
+func lookupField(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Anonymous {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if r, ok := lookupField(ft, name); ok {
+					return r, true
+				}
+			}
+		}
+		for _, v := range fieldNames(f) {
+			if strings.EqualFold(v, name) {
+				return f, true
+			}
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+//fieldNames go name and tag names of f
+func fieldNames(f reflect.StructField) []string {
+	names := []string{f.Name}
+	for _, tag := range []string{"json", "bson"} {
+		if v := strings.Split(f.Tag.Get(tag), ",")[0]; v != "" && v != "-" {
+			names = append(names, v)
+		}
+	}
+	for _, v := range strings.Split(f.Tag.Get("gorm"), ";") {
+		if strings.HasPrefix(strings.ToLower(v), "column:") {
+			names = append(names, v[len("column:"):])
+		}
+	}
+	return names
+}
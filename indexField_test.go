@@ -0,0 +1,63 @@
+package cachelayer_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+type indexedUser struct {
+	ID       uint
+	Email    string `bson:"mail"`
+	TenantID *int64 `gorm:"column:tenant"`
+}
+
+func TestIndexField(t *testing.T) {
+	email := cachelayer.MustIndexField[indexedUser, string]("email")
+	assert.Equal(t, cachelayer.Index{"email": "a@b.c"}, email.Eq("a@b.c"))
+	_, err := cachelayer.NewIndexField[indexedUser, string]("mail")
+	assert.Nil(t, err)
+	tenant := cachelayer.MustIndexField[indexedUser, int64]("tenant")
+	assert.Equal(t, cachelayer.Index{"tenant": int64(1), "email": "a@b.c"}, tenant.Eq(1).And(email.Eq("a@b.c")))
+
+	_, err = cachelayer.NewIndexField[indexedUser, string]("emial")
+	assert.NotNil(t, err)
+	_, err = cachelayer.NewIndexField[indexedUser, int]("email")
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { cachelayer.MustIndexField[indexedUser, string]("emial") })
+}
+
+func TestLookupField(t *testing.T) {
+	f, ok := cachelayer.LookupField[*indexedUser]("Email")
+	assert.True(t, ok)
+	assert.Equal(t, "mail", f.Tag.Get("bson"))
+	f, ok = cachelayer.LookupField[indexedUser]("tenant")
+	assert.True(t, ok)
+	assert.Equal(t, "TenantID", f.Name)
+	_, ok = cachelayer.LookupField[indexedUser]("code")
+	assert.False(t, ok)
+	_, ok = cachelayer.LookupField[int]("code")
+	assert.False(t, ok)
+}
+
+func (s indexedUser) GetID() uint {
+	return s.ID
+}
+func (s indexedUser) ListIndexes() cachelayer.Indexes {
+	return cachelayer.Indexes{cachelayer.NewIndex("email", s.Email), cachelayer.NewIndex("tenant", s.TenantID)}
+}
+func (s indexedUser) UniqueIndexes() [][]string {
+	return [][]string{{"email"}, {"tenant", "code"}}
+}
+
+func TestIsUniqueIndex(t *testing.T) {
+	c := cachelayer.NewCacheBase[indexedUser, uint]("app", "user", "id", context.Background())
+	assert.True(t, c.IsUniqueIndex(cachelayer.NewIndex("Email", "a@b.c")))
+	assert.True(t, c.IsUniqueIndex(cachelayer.Index{"code": "x", "tenant": 1}))
+	assert.False(t, c.IsUniqueIndex(cachelayer.NewIndex("tenant", 1)))
+
+	k := cachelayer.NewCacheBase[keyUser, uint]("app", "user", "id", context.Background())
+	assert.True(t, k.IsUniqueIndex(cachelayer.NewIndex("tenant", 1)))
+}
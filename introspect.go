@@ -0,0 +1,23 @@
+package cachelayer
+
+import (
+	"context"
+	"expvar"
+	"runtime/pprof"
+)
+
+//LabelKey pprof label naming the background component of a goroutine, eg. go tool pprof -tagfocus cachelayer=async_writer
+const LabelKey = "cachelayer"
+
+//labeled run fn with pprof labels of component and extra label pairs, so profiles and goroutine dumps show what the layer is doing
+func labeled(ctx context.Context, component string, fn func(ctx context.Context), labels ...string) {
+	pprof.Do(ctx, pprof.Labels(append([]string{LabelKey, component}, labels...)...), fn)
+}
+
+//PublishExpvar publish stats of all caches at /debug/vars under name: {"caches": {name: Stats}, "total": Stats}.
+// Like expvar.Publish it panics if name is already published
+func (s *Registry) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return map[string]interface{}{"caches": s.Stats(), "total": s.TotalStats()}
+	}))
+}
@@ -0,0 +1,68 @@
+package cachelayer
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//InvalidationEvent cache keys deleted by a write, published to other services and regions so they can apply it to their caches
+type InvalidationEvent struct {
+	//Source publisher of the event, consumers can skip their own events
+	Source string    `json:"source"`
+	Table  string    `json:"table"`
+	IDs    []string  `json:"ids"`
+	Keys   []string  `json:"keys"`
+	At     time.Time `json:"at"`
+}
+
+func (s InvalidationEvent) Marshal() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+func ParseInvalidationEvent(data []byte) (InvalidationEvent, error) {
+	var r InvalidationEvent
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+//InvalidationPublisher publish invalidation events of caches, eg. kafkabus.Publisher
+type InvalidationPublisher interface {
+	PublishInvalidation(ctx context.Context, event InvalidationEvent) error
+}
+
+//SetInvalidationPublisher publish an event on every ClearCache, source identifies this process in events.
+// Publishing errors are reported by ReportError, ClearCache does not fail because of them
+func (s *CacheBase[T, I]) SetInvalidationPublisher(publisher InvalidationPublisher, source string) {
+	s.publisher = publisher
+	s.publisherSource = source
+}
+
+//publishInvalidation publish deleted keys of entities ids. The keys are already deleted locally,
+// so a failed publish is passed to ReportError instead of failing the write
+func (s *CacheBase[T, I]) publishInvalidation(keys []string, ids ...I) {
+	if s.publisher == nil || len(keys) == 0 {
+		return
+	}
+	event := InvalidationEvent{Source: s.publisherSource, Table: s.table, Keys: keys, At: s.clock.Now()}
+	for _, v := range ids {
+		event.IDs = append(event.IDs, Stringify(v, ""))
+	}
+	// an update clears both versions of a record
+	event.IDs = UniqueStrings(event.IDs)
+	s.report("publish_invalidation", s.publisher.PublishInvalidation(s.ctx, event))
+}
+
+//ApplyInvalidation delete keys of event from red, eg. a consumer applying events of another region
+func ApplyInvalidation(ctx context.Context, red redis.UniversalClient, event InvalidationEvent) error {
+	_, err := DelKeys(ctx, red, event.Keys...)
+	return err
+}
+
+//RedisApplier apply events with ApplyInvalidation to red, eg. the apply function of kafkabus.Consumer
+func RedisApplier(red redis.UniversalClient) func(ctx context.Context, event InvalidationEvent) error {
+	return func(ctx context.Context, event InvalidationEvent) error {
+		return ApplyInvalidation(ctx, red, event)
+	}
+}
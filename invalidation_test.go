@@ -0,0 +1,79 @@
+package cachelayer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+//fakePublisher records published events, fails with err if set
+type fakePublisher struct {
+	events []cachelayer.InvalidationEvent
+	err    error
+}
+
+func (s *fakePublisher) PublishInvalidation(ctx context.Context, event cachelayer.InvalidationEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestPublishFailureDoesNotFailClearCache(t *testing.T) {
+	cache, _, mr := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com"})
+	cache.SetInvalidationPublisher(&fakePublisher{err: errors.New("broker down")}, "test")
+	var events []cachelayer.ErrorEvent
+	cachelayer.SetErrorHandler(func(event cachelayer.ErrorEvent) { events = append(events, event) })
+	defer cachelayer.SetErrorHandler(nil)
+	r, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Nil(t, cache.ClearCache(r))
+	assert.Empty(t, mr.Keys())
+	assert.Len(t, events, 1)
+	assert.Equal(t, "publish_invalidation", events[0].Component)
+	assert.Equal(t, "member", events[0].Table)
+}
+
+func TestInvalidationPublisher(t *testing.T) {
+	cache, _, _ := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1})
+	publisher := &fakePublisher{}
+	cache.SetInvalidationPublisher(publisher, "us-east")
+	_, err := cache.Update(1, map[string]interface{}{"name": "jerry"})
+	assert.Nil(t, err)
+	assert.Len(t, publisher.events, 1)
+	event := publisher.events[0]
+	assert.Equal(t, "us-east", event.Source)
+	assert.Equal(t, "member", event.Table)
+	assert.Equal(t, []string{"1"}, event.IDs)
+	assert.Contains(t, event.Keys, cache.MakeCacheKey(cachelayer.NewIndex("ID", 1)))
+	assert.Contains(t, event.Keys, cache.MakeCacheKey(cachelayer.NewIndex("Email", "tom@x.com")))
+	data, err := event.Marshal()
+	assert.Nil(t, err)
+	parsed, err := cachelayer.ParseInvalidationEvent(data)
+	assert.Nil(t, err)
+	assert.Equal(t, event.Keys, parsed.Keys)
+	assert.True(t, event.At.Equal(parsed.At))
+}
+
+func TestApplyInvalidation(t *testing.T) {
+	// another region caches the same records
+	remote, _, mr := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com"}, member{ID: 2, Name: "ann", Email: "ann@x.com"})
+	_, err := remote.List(1, 2)
+	assert.Nil(t, err)
+	cache, _, _ := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com"})
+	publisher := &fakePublisher{}
+	cache.SetInvalidationPublisher(publisher, "us-east")
+	r, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Nil(t, cache.ClearCache(r))
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer red.Close()
+	apply := cachelayer.RedisApplier(red)
+	assert.Nil(t, apply(context.Background(), publisher.events[0]))
+	assert.Equal(t, []string{remote.MakeCacheKey(cachelayer.NewIndex("ID", 2))}, mr.Keys())
+}
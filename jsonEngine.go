@@ -0,0 +1,75 @@
+package cachelayer
+
+import (
+	stdjson "encoding/json"
+
+	"github.com/daqiancode/jsoniter"
+)
+
+//JsonEngine json library of a JsonSerializer, eg. jsoniter(default), encoding/json(StdJson) or sonic(sonic.ConfigDefault)
+type JsonEngine interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+//JsonOptions field naming of a jsoniter engine, see NewJsoniterEngine
+type JsonOptions struct {
+	//Decapitalize name fields without json tag by their name with the first letter lower cased, eg. CategoryId as categoryId.
+	// Otherwise fields are named as Go fields, like encoding/json does
+	Decapitalize bool `yaml:"decapitalize"`
+	//CaseSensitive match object keys to field names case sensitively while decoding, encoding/json matches case insensitively
+	CaseSensitive bool `yaml:"caseSensitive"`
+	//TagKey struct tag naming fields, default json
+	TagKey string `yaml:"tagKey"`
+	//CacheTag name fields by their cache tag before TagKey, eg. `json:"email" cache:"e"`, `cache:"-"` leaves a field out of cache
+	CacheTag bool `yaml:"cacheTag"`
+	//NameMapper name fields without tag by it instead of Decapitalize, eg. SnakeCase
+	NameMapper NameMapper `yaml:"-"`
+	//Naming config name of NameMapper: as_is, snake_case or camel_case
+	Naming string `yaml:"naming"`
+}
+
+//DefaultJsonOptions options of the engine of JsonSerializer{}
+var DefaultJsonOptions = JsonOptions{Decapitalize: true}
+
+//NewJsoniterEngine jsoniter engine of options
+func NewJsoniterEngine(options JsonOptions) JsonEngine {
+	r := jsoniter.Config{
+		EscapeHTML:                    false,
+		Decapitalize:                  options.Decapitalize,
+		CaseSensitive:                 options.CaseSensitive,
+		TagKey:                        options.TagKey,
+		ObjectFieldMustBeSimpleString: true,
+	}.Froze()
+	mapper := options.NameMapper
+	if mapper == nil && options.Naming != "" {
+		mapper, _ = NameMapperOf(options.Naming)
+	}
+	if options.CacheTag || mapper != nil {
+		tagKey := options.TagKey
+		if tagKey == "" {
+			tagKey = "json"
+		}
+		r.RegisterExtension(&fieldNameExtension{tagKey: tagKey, cacheTag: options.CacheTag, mapper: mapper})
+	}
+	return r
+}
+
+//StdJson engine of encoding/json
+var StdJson JsonEngine = stdJsonEngine{}
+
+type stdJsonEngine struct{}
+
+func (stdJsonEngine) Marshal(v interface{}) ([]byte, error) {
+	return stdjson.Marshal(v)
+}
+
+func (stdJsonEngine) Unmarshal(data []byte, v interface{}) error {
+	return stdjson.Unmarshal(data, v)
+}
+
+//NewJsonSerializer json serializer of engine, nil is the default jsoniter engine of DefaultJsonOptions.
+// Register it to choose it in config, eg. RegisterSerializer("sonic", NewJsonSerializer(sonic.ConfigDefault))
+func NewJsonSerializer(engine JsonEngine) *JsonSerializer {
+	return &JsonSerializer{engine: engine}
+}
@@ -2,6 +2,7 @@ package cachelayer
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"github.com/daqiancode/jsoniter"
@@ -13,76 +14,161 @@ type Serializer interface {
 	Unmarshal(data string, objRef interface{}) error
 }
 
-var json = jsoniter.Config{EscapeHTML: false, Decapitalize: true, ObjectFieldMustBeSimpleString: true}.Froze()
+var json = NewJsoniterEngine(DefaultJsonOptions).(jsoniter.API)
 
+//JsonSerializer json serializer, the zero value encodes by jsoniter with DefaultJsonOptions. See NewJsonSerializer for other engines
 type JsonSerializer struct {
+	engine JsonEngine
 }
 
 func (s *JsonSerializer) Marshal(obj interface{}) (string, error) {
-	return json.MarshalToString(obj)
+	if s.engine == nil {
+		return json.MarshalToString(obj)
+	}
+	r, err := s.engine.Marshal(obj)
+	return string(r), err
 }
 func (s *JsonSerializer) Unmarshal(data string, objRef interface{}) error {
-	return json.UnmarshalFromString(data, objRef)
+	if s.engine == nil {
+		return json.UnmarshalFromString(data, objRef)
+	}
+	return s.engine.Unmarshal([]byte(data), objRef)
+}
+
+//api engine of s
+func (s *JsonSerializer) api() JsonEngine {
+	if s.engine == nil {
+		return json
+	}
+	return s.engine
+}
+
+//MarshalBytes see BytesSerializer
+func (s *JsonSerializer) MarshalBytes(obj interface{}) ([]byte, error) {
+	return s.api().Marshal(obj)
+}
+
+//UnmarshalBytes see BytesSerializer, jsoniter decodes by pooled iterators
+func (s *JsonSerializer) UnmarshalBytes(data []byte, objRef interface{}) error {
+	return s.api().Unmarshal(data, objRef)
+}
+
+//AppendMarshal see AppendSerializer, jsoniter encodes by pooled streams
+func (s *JsonSerializer) AppendMarshal(dst []byte, obj interface{}) ([]byte, error) {
+	api, ok := s.api().(jsoniter.API)
+	if !ok {
+		r, err := s.api().Marshal(obj)
+		return append(dst, r...), err
+	}
+	stream := api.BorrowStream(nil)
+	defer api.ReturnStream(stream)
+	stream.WriteVal(obj)
+	if stream.Error != nil {
+		return dst, stream.Error
+	}
+	return append(dst, stream.Buffer()...), nil
 }
 
 type RedisJson[T any] struct {
-	*redis.Client
+	redis.UniversalClient
 	serializer Serializer
 	ctx        context.Context
 	ttl        time.Duration
+	policy     ExpirationPolicy
+	maxTTL     time.Duration
+	grace      time.Duration
+	keepTTL    bool
+	nullTTL    time.Duration
+	replicas   *ReplicaReads
+	//policyTTLs ttl of entries by CachePolicy of records
+	policyTTLs map[CachePolicy]time.Duration
+	//corruptPolicy how MGetJson treats entries which can't be unmarshaled
+	corruptPolicy CorruptPolicy
+	onCorrupt     func(keys []string, err error)
+	//nullPlaceholder payload of cached "not found" entries, LegacyNullPlaceholder if empty
+	nullPlaceholder string
 }
 
-func NewRedisJson[T any](client *redis.Client, ttl time.Duration) *RedisJson[T] {
+func NewRedisJson[T any](client redis.UniversalClient, ttl time.Duration) *RedisJson[T] {
 	return &RedisJson[T]{
-		Client:     client,
-		serializer: &JsonSerializer{},
-		ctx:        context.Background(),
-		ttl:        ttl,
+		UniversalClient: client,
+		serializer:      &JsonSerializer{},
+		ctx:             context.Background(),
+		ttl:             ttl,
 	}
 }
 
+//withContext copy of s whose redis calls carry ctx
+func (s *RedisJson[T]) withContext(ctx context.Context) *RedisJson[T] {
+	r := *s
+	r.ctx = ctx
+	return &r
+}
+
 func (s *RedisJson[T]) GetJson(key string) (T, bool, error) {
+	r, exists, _, err := s.getJson(key)
+	return r, exists, err
+}
+
+//getJson return (obj, exists, isNull, error), isNull is true if the entry is a negative cache placeholder
+func (s *RedisJson[T]) getJson(key string) (T, bool, bool, error) {
 	var r T
-	y, err := s.Get(s.ctx, key).Result()
+	y, err := s.reader(key).Get(s.ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return r, false, nil
+			return r, false, false, nil
 		}
-		return r, false, err
+		return r, false, false, cacheError(err)
 	}
-	err = s.serializer.Unmarshal(y, &r)
-	return r, true, err
+	if s.isNull(y) {
+		return r, true, true, nil
+	}
+	err = unmarshal(s.serializer, y, &r)
+	return r, true, false, cacheError(err)
 }
 
+//SetJson cache obj, unless its CachePolicy is NoCache
 func (s *RedisJson[T]) SetJson(key string, obj T) error {
-	y, err := s.serializer.Marshal(obj)
+	if recordPolicy(obj) == NoCache {
+		return nil
+	}
+	y, err := marshal(s.serializer, obj)
 	if err != nil {
-		return err
+		return cacheError(err)
 	}
-	return s.SetEX(s.ctx, key, y, s.ttl).Err()
+	if err = s.SetEX(s.ctx, key, y, s.entryTTL(obj)).Err(); err != nil {
+		return cacheError(err)
+	}
+	return s.afterWrite(key)
 }
 
 func (s *RedisJson[T]) MSetJson(objMap map[string]interface{}) error {
 	if len(objMap) == 0 {
 		return nil
 	}
-	objJsonMap := make(map[string]string, len(objMap))
-	var err error
-	keys := make([]string, len(objMap))
-	i := 0
+	keys := make([]string, 0, len(objMap))
+	values := make([]interface{}, 0, len(objMap))
+	payloads := newPayloads(len(objMap))
+	defer payloads.release()
 	for k, v := range objMap {
-		objJsonMap[k], err = s.serializer.Marshal(v)
-		if err != nil {
-			return err
+		if recordPolicy(v) == NoCache {
+			continue
 		}
-		keys[i] = k
-		i++
+		if err := payloads.add(s.serializer, v); err != nil {
+			return cacheError(err)
+		}
+		keys = append(keys, k)
+		values = append(values, v)
 	}
-	err = s.MSet(s.ctx, objJsonMap).Err()
-	if err != nil {
-		return err
+	// one SETEX per key rather than MSET, so keys can live on different nodes and expire atomically
+	p := s.Pipeline()
+	for i, k := range keys {
+		p.SetEX(s.ctx, k, payloads.get(i), s.entryTTL(values[i]))
 	}
-	return s.Expires(keys...)
+	if _, err := p.Exec(s.ctx); err != nil {
+		return cacheError(err)
+	}
+	return s.afterWrite(keys...)
 }
 
 func (s *RedisJson[T]) Expires(keys ...string) error {
@@ -92,18 +178,24 @@ func (s *RedisJson[T]) Expires(keys ...string) error {
 	p := s.Pipeline()
 	var err error
 	for _, v := range keys {
-		err = p.Expire(s.ctx, v, s.ttl).Err()
+		err = p.Expire(s.ctx, v, s.storeTTL()).Err()
 		if err != nil {
-			return err
+			return cacheError(err)
+		}
+		if s.grace > 0 {
+			p.Expire(s.ctx, freshKey(v), s.ttl)
 		}
 	}
 	_, err = p.Exec(s.ctx)
-	return err
+	return cacheError(err)
 
 }
 
 func (s *RedisJson[T]) SetNull(key string) error {
-	return s.SetEX(s.ctx, key, "null", s.ttl).Err()
+	if err := s.SetEX(s.ctx, key, s.GetNullPlaceholder(), s.nullStoreTTL()).Err(); err != nil {
+		return cacheError(err)
+	}
+	return s.afterWrite(key)
 }
 
 func (s *RedisJson[T]) MSetNull(keys []string) error {
@@ -113,24 +205,42 @@ func (s *RedisJson[T]) MSetNull(keys []string) error {
 	p := s.Pipeline()
 	var err error
 	for _, v := range keys {
-		err = p.SetEX(s.ctx, v, "null", s.ttl).Err()
+		err = p.SetEX(s.ctx, v, s.GetNullPlaceholder(), s.nullStoreTTL()).Err()
 		if err != nil {
-			return err
+			return cacheError(err)
 		}
 	}
-	_, err = p.Exec(s.ctx)
-	return err
+	if _, err = p.Exec(s.ctx); err != nil {
+		return cacheError(err)
+	}
+	return s.afterWrite(keys...)
 }
 
+//MGetJson records of keys and indexes of missed keys, ttl of hit keys is refreshed
 func (s *RedisJson[T]) MGetJson(keys []string) ([]T, []int, error) {
+	r, missedIndexes, _, err := s.mgetJson(keys, false)
+	if err != nil {
+		return r, missedIndexes, err
+	}
+	if err = s.Refresh(hitKeys(keys, missedIndexes)...); err != nil {
+		return r, missedIndexes, cacheError(err)
+	}
+	return r, missedIndexes, nil
+}
+
+//mgetJson see MGetJson, ttl is not refreshed. With checkFresh in grace mode, entries which outlived ttl are missed and
+// returned by their indexes in stale
+func (s *RedisJson[T]) mgetJson(keys []string, checkFresh bool) ([]T, []int, map[int]T, error) {
 	if len(keys) == 0 {
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
-	vs, err := s.MGet(s.ctx, keys...).Result()
+	vs, err := mget(s.ctx, s.reader(keys...), keys...)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, cacheError(err)
 	}
 	var missedIndexes []int
+	var corruptKeys []string
+	var corruptErr error
 	r := make([]T, len(keys))
 	for i, v := range vs {
 		var t T
@@ -140,66 +250,130 @@ func (s *RedisJson[T]) MGetJson(keys []string) ([]T, []int, error) {
 			continue
 		}
 
-		err = s.serializer.Unmarshal(v.(string), &t)
+		err = s.unmarshal(v.(string), &t)
 		if err != nil {
-			return nil, missedIndexes, err
+			if s.corruptPolicy != CorruptAsMiss {
+				return nil, missedIndexes, nil, cacheError(err)
+			}
+			if corruptErr == nil {
+				corruptErr = err
+			}
+			corruptKeys = append(corruptKeys, keys[i])
+			missedIndexes = append(missedIndexes, i)
+			vs[i] = nil
+			continue
 		}
 		r[i] = t
 	}
-	for _, key := range keys {
-		err = s.Expire(s.ctx, key, s.ttl).Err()
-		if err != nil {
-			return r, missedIndexes, err
+	if len(corruptKeys) > 0 {
+		s.dropCorrupt(corruptKeys, corruptErr)
+	}
+	if !checkFresh || s.grace <= 0 || len(missedIndexes) == len(keys) {
+		return r, missedIndexes, nil, nil
+	}
+	hits := make([]int, 0, len(keys)-len(missedIndexes))
+	freshKeys := make([]string, 0, len(keys)-len(missedIndexes))
+	for i, v := range vs {
+		if v != nil {
+			hits = append(hits, i)
+			freshKeys = append(freshKeys, freshKey(keys[i]))
 		}
 	}
-	return r, missedIndexes, nil
+	fresh, err := mget(s.ctx, s.reader(freshKeys...), freshKeys...)
+	if err != nil {
+		return nil, nil, nil, cacheError(err)
+	}
+	stale := make(map[int]T)
+	var zero T
+	for i, v := range fresh {
+		if v == nil {
+			stale[hits[i]] = r[hits[i]]
+			r[hits[i]] = zero
+			missedIndexes = append(missedIndexes, hits[i])
+		}
+	}
+	sort.Ints(missedIndexes)
+	return r, missedIndexes, stale, nil
+}
 
+//hitKeys keys not at missedIndexes
+func hitKeys(keys []string, missedIndexes []int) []string {
+	if len(missedIndexes) == 0 {
+		return keys
+	}
+	missed := make(map[int]bool, len(missedIndexes))
+	for _, v := range missedIndexes {
+		missed[v] = true
+	}
+	r := make([]string, 0, len(keys))
+	for i, v := range keys {
+		if !missed[i] {
+			r = append(r, v)
+		}
+	}
+	return r
 }
 
 type RedisHashJson[T Table[I], I IDType] struct {
 	*RedisJson[T]
-	*redis.Client
+	redis.UniversalClient
 	serializer Serializer
 	ctx        context.Context
 	ttl        time.Duration
 }
 
-func NewRedisHashJson[T Table[I], I IDType](client *redis.Client, ttl time.Duration) *RedisHashJson[T, I] {
+func NewRedisHashJson[T Table[I], I IDType](client redis.UniversalClient, ttl time.Duration) *RedisHashJson[T, I] {
 	return &RedisHashJson[T, I]{
-		RedisJson:  NewRedisJson[T](client, ttl),
-		Client:     client,
-		serializer: &JsonSerializer{},
-		ctx:        context.Background(),
-		ttl:        ttl,
+		RedisJson:       NewRedisJson[T](client, ttl),
+		UniversalClient: client,
+		serializer:      &JsonSerializer{},
+		ctx:             context.Background(),
+		ttl:             ttl,
 	}
 }
 
+//withContext copy of s whose redis calls carry ctx
+func (s *RedisHashJson[T, I]) withContext(ctx context.Context) *RedisHashJson[T, I] {
+	r := *s
+	r.RedisJson = s.RedisJson.withContext(ctx)
+	r.ctx = ctx
+	return &r
+}
+
+func (s *RedisHashJson[T, I]) SetSerializer(serializer Serializer) {
+	s.serializer = serializer
+	s.RedisJson.SetSerializer(serializer)
+}
+
 func (s *RedisHashJson[T, I]) HGetJson(key string, id I) (T, bool, error) {
 	idStr := Stringify(id, "")
 	var r T
-	raw, err := s.HGet(s.ctx, key, idStr).Result()
+	raw, err := s.reader(key).HGet(s.ctx, key, idStr).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return r, false, nil
 		}
-		return r, false, err
+		return r, false, cacheError(err)
 	}
-	err = s.serializer.Unmarshal(raw, &r)
-	return r, true, err
+	err = s.unmarshal(raw, &r)
+	return r, true, cacheError(err)
 }
 
 func (s *RedisHashJson[T, I]) HGetAllJson(key string) ([]T, error) {
 	var r []T
-	raw, err := s.HGetAll(s.ctx, key).Result()
+	raw, err := s.reader(key).HGetAll(s.ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return r, nil
 		}
-		return r, err
+		return r, cacheError(err)
+	}
+	if len(raw) > 0 {
+		r = make([]T, 0, len(raw))
 	}
 	for _, v := range raw {
 		var t T
-		err = s.serializer.Unmarshal(v, &t)
+		err = s.unmarshal(v, &t)
 		if err != nil {
 			return r, nil
 		}
@@ -217,20 +391,22 @@ func (s *RedisHashJson[T, I]) HMGetJson(key string, ids ...I) ([]T, error) {
 		idStrs[i] = Stringify(v, "")
 	}
 	var r []T
-	raw, err := s.HMGet(s.ctx, key, idStrs...).Result()
+	raw, err := s.reader(key).HMGet(s.ctx, key, idStrs...).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return r, nil
 		}
-		return r, err
+		return r, cacheError(err)
 	}
-	for _, v := range raw {
-		var t T
-		err = s.serializer.Unmarshal(v.(string), &t)
-		if err != nil {
-			return r, nil
+	// keep the order of ids, missing fields are zero records
+	r = make([]T, len(raw))
+	for i, v := range raw {
+		if v == nil {
+			continue
+		}
+		if err = s.unmarshal(v.(string), &r[i]); err != nil {
+			return r, cacheError(err)
 		}
-		r = append(r, t)
 	}
 	return r, nil
 }
@@ -239,16 +415,20 @@ func (s *RedisHashJson[T, I]) HSetJson(key string, objs ...T) error {
 	if len(objs) == 0 {
 		return nil
 	}
-	var err error
-	args := make([]string, len(objs)*2)
+	payloads := newPayloads(len(objs))
+	defer payloads.release()
+	for _, v := range objs {
+		if err := payloads.add(s.serializer, v); err != nil {
+			return cacheError(err)
+		}
+	}
+	args := make([]interface{}, len(objs)*2)
 	for k, v := range objs {
 		args[2*k] = Stringify(v.GetID(), "")
-		args[2*k+1], err = s.serializer.Marshal(v)
-		if err != nil {
-			return err
-		}
+		args[2*k+1] = payloads.get(k)
 	}
-	return s.HSet(s.ctx, key, args).Err()
+	s.replicas.markWritten(key)
+	return cacheError(s.HSet(s.ctx, key, args...).Err())
 }
 
 func (s *RedisHashJson[T, I]) HDelJson(key string, ids ...I) error {
@@ -259,5 +439,107 @@ func (s *RedisHashJson[T, I]) HDelJson(key string, ids ...I) error {
 	for i, v := range ids {
 		idStrs[i] = Stringify(v, "")
 	}
-	return s.HDel(s.ctx, key, idStrs...).Err()
+	s.replicas.markWritten(key)
+	return cacheError(s.HDel(s.ctx, key, idStrs...).Err())
+}
+
+func (s *RedisJson[T]) SetSerializer(serializer Serializer) {
+	s.serializer = serializer
+}
+
+//GetDefaultTTL ttl of entries written by SetJson
+func (s *RedisJson[T]) GetDefaultTTL() time.Duration {
+	return s.ttl
+}
+
+//SetNullTTL ttl of cached "not found" entries, 0 means same as ttl
+func (s *RedisJson[T]) SetNullTTL(nullTTL time.Duration) {
+	s.nullTTL = nullTTL
+}
+
+func (s *RedisJson[T]) nullStoreTTL() time.Duration {
+	if s.nullTTL > 0 {
+		return s.nullTTL + s.grace
+	}
+	return s.storeTTL()
+}
+
+//SetKeepTTL writes of RedisCache rewrite cached entries by RewriteJson instead of deleting them, so rewrites do not restart the ttl
+func (s *RedisJson[T]) SetKeepTTL(keepTTL bool) {
+	s.keepTTL = keepTTL
+}
+
+//RewriteJson rewrite the cached entry of key with obj keeping its ttl(SET ... XX KEEPTTL), false if key is not cached or obj
+// is not cached by its CachePolicy. The entry is fresh again in grace mode, its max lifetime is kept. Requires redis >= 6.0
+func (s *RedisJson[T]) RewriteJson(key string, obj T) (bool, error) {
+	if recordPolicy(obj) == NoCache {
+		return false, nil
+	}
+	y, err := marshal(s.serializer, obj)
+	if err != nil {
+		return false, cacheError(err)
+	}
+	err = s.SetArgs(s.ctx, key, y, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, cacheError(err)
+	}
+	s.replicas.markWritten(key)
+	if s.grace > 0 {
+		err = s.SetEX(s.ctx, freshKey(key), "1", s.ttl).Err()
+	}
+	return true, cacheError(err)
+}
+
+//KeyTTL return remaining fresh ttl of key, exists is false if key is not cached
+func (s *RedisJson[T]) KeyTTL(key string) (time.Duration, bool, error) {
+	ttl, err := s.PTTL(s.ctx, key).Result()
+	if err != nil {
+		return 0, false, cacheError(err)
+	}
+	// -2: key does not exist, -1: key has no expiration
+	if ttl == -2 {
+		return 0, false, nil
+	}
+	if ttl < 0 {
+		return ttl, true, nil
+	}
+	if ttl -= s.grace; ttl < 0 {
+		ttl = 0
+	}
+	return ttl, true, nil
+}
+
+//Touch restart ttl of existing keys without reading them, return count of touched keys
+func (s *RedisJson[T]) Touch(keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	var n int64
+	if s.policy == ExpirationSlidingWithMax {
+		touched, err := s.expireWithMax(keys...)
+		for _, v := range touched {
+			if v {
+				n++
+			}
+		}
+		return n, err
+	}
+	p := s.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(keys))
+	for i, v := range keys {
+		cmds[i] = p.Expire(s.ctx, v, s.storeTTL())
+	}
+	s.expireFresh(p, keys...)
+	if _, err := p.Exec(s.ctx); err != nil && err != redis.Nil {
+		return 0, cacheError(err)
+	}
+	for _, v := range cmds {
+		if v.Val() {
+			n++
+		}
+	}
+	return n, nil
 }
@@ -0,0 +1,113 @@
+package kafkabus
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/segmentio/kafka-go"
+)
+
+//MessageWriter writes messages to a topic, eg. *kafka.Writer
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+//MessageReader reads messages of a consumer group, eg. *kafka.Reader
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+//Publisher publish invalidation events to a kafka topic. Messages are keyed by table, so events of a table keep their order
+type Publisher struct {
+	w MessageWriter
+}
+
+func NewPublisher(brokers []string, topic string) *Publisher {
+	return NewPublisherWithWriter(&kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	})
+}
+
+//NewPublisherWithWriter publisher with a custom writer, eg. a *kafka.Writer with TLS or SASL transport
+func NewPublisherWithWriter(w MessageWriter) *Publisher {
+	return &Publisher{w: w}
+}
+
+func (s *Publisher) PublishInvalidation(ctx context.Context, event cachelayer.InvalidationEvent) error {
+	value, err := event.Marshal()
+	if err != nil {
+		return err
+	}
+	return s.w.WriteMessages(ctx, kafka.Message{Key: []byte(event.Table), Value: value})
+}
+
+func (s *Publisher) Close() error {
+	return s.w.Close()
+}
+
+//Consumer apply invalidation events of a kafka topic, offsets are committed after apply succeeds(at least once)
+type Consumer struct {
+	r          MessageReader
+	apply      func(ctx context.Context, event cachelayer.InvalidationEvent) error
+	skipSource string
+}
+
+//NewConsumer consume topic in consumer group groupID, use a group per deployment(eg. per region) so every deployment receives all events
+func NewConsumer(brokers []string, topic, groupID string, apply func(ctx context.Context, event cachelayer.InvalidationEvent) error) *Consumer {
+	return NewConsumerWithReader(kafka.NewReader(kafka.ReaderConfig{
+		Brokers: brokers,
+		Topic:   topic,
+		GroupID: groupID,
+	}), apply)
+}
+
+func NewConsumerWithReader(r MessageReader, apply func(ctx context.Context, event cachelayer.InvalidationEvent) error) *Consumer {
+	return &Consumer{r: r, apply: apply}
+}
+
+//SkipSource ignore events published by source, eg. this deployment has already deleted its own keys
+func (s *Consumer) SkipSource(source string) {
+	s.skipSource = source
+}
+
+//Run apply events until ctx is done or apply fails, malformed messages are skipped
+func (s *Consumer) Run(ctx context.Context) error {
+	var err error
+	pprof.Do(ctx, pprof.Labels(cachelayer.LabelKey, "kafka_consumer"), func(ctx context.Context) {
+		err = s.run(ctx)
+	})
+	return err
+}
+
+func (s *Consumer) run(ctx context.Context) error {
+	for {
+		msg, err := s.r.FetchMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				return nil
+			}
+			return err
+		}
+		event, err := cachelayer.ParseInvalidationEvent(msg.Value)
+		if err == nil && (s.skipSource == "" || event.Source != s.skipSource) {
+			if err = s.apply(ctx, event); err != nil {
+				return err
+			}
+		}
+		if err = s.r.CommitMessages(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Consumer) Close() error {
+	return s.r.Close()
+}
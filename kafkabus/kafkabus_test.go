@@ -0,0 +1,89 @@
+package kafkabus_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/kafkabus"
+	"github.com/go-redis/redis/v8"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+//fakeTopic in-memory topic, it is both the writer and the reader
+type fakeTopic struct {
+	msgs      []kafka.Message
+	committed []kafka.Message
+	next      int
+}
+
+func (s *fakeTopic) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	s.msgs = append(s.msgs, msgs...)
+	return nil
+}
+
+//FetchMessage next message, context.Canceled once all are fetched
+func (s *fakeTopic) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if s.next == len(s.msgs) {
+		return kafka.Message{}, context.Canceled
+	}
+	s.next++
+	return s.msgs[s.next-1], nil
+}
+
+func (s *fakeTopic) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	s.committed = append(s.committed, msgs...)
+	return nil
+}
+
+func (s *fakeTopic) Close() error {
+	return nil
+}
+
+func TestPublisher(t *testing.T) {
+	topic := &fakeTopic{}
+	pub := kafkabus.NewPublisherWithWriter(topic)
+	event := cachelayer.InvalidationEvent{Source: "us-east", Table: "user", IDs: []string{"1"}, Keys: []string{"app/user/id/1"}}
+	assert.Nil(t, pub.PublishInvalidation(context.Background(), event))
+	assert.Len(t, topic.msgs, 1)
+	// keyed by table
+	assert.Equal(t, "user", string(topic.msgs[0].Key))
+	r, err := cachelayer.ParseInvalidationEvent(topic.msgs[0].Value)
+	assert.Nil(t, err)
+	assert.Equal(t, event, r)
+}
+
+func TestConsumer(t *testing.T) {
+	mr := miniredis.RunT(t)
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer red.Close()
+	mr.Set("app/user/id/1", "{}")
+	mr.Set("app/user/id/2", "{}")
+	topic := &fakeTopic{}
+	pub := kafkabus.NewPublisherWithWriter(topic)
+	ctx := context.Background()
+	assert.Nil(t, pub.PublishInvalidation(ctx, cachelayer.InvalidationEvent{Source: "us-east", Table: "user", Keys: []string{"app/user/id/2"}}))
+	assert.Nil(t, topic.WriteMessages(ctx, kafka.Message{Value: []byte("not json")}))
+	assert.Nil(t, pub.PublishInvalidation(ctx, cachelayer.InvalidationEvent{Source: "eu-west", Table: "user", Keys: []string{"app/user/id/1"}}))
+
+	consumer := kafkabus.NewConsumerWithReader(topic, cachelayer.RedisApplier(red))
+	consumer.SkipSource("us-east")
+	assert.Nil(t, consumer.Run(ctx))
+	assert.Equal(t, []string{"app/user/id/2"}, mr.Keys())
+	// skipped and malformed messages are committed too
+	assert.Len(t, topic.committed, 3)
+}
+
+func TestConsumerApplyError(t *testing.T) {
+	topic := &fakeTopic{}
+	pub := kafkabus.NewPublisherWithWriter(topic)
+	assert.Nil(t, pub.PublishInvalidation(context.Background(), cachelayer.InvalidationEvent{Table: "user", Keys: []string{"app/user/id/1"}}))
+	down := errors.New("redis down")
+	consumer := kafkabus.NewConsumerWithReader(topic, func(ctx context.Context, event cachelayer.InvalidationEvent) error { return down })
+	assert.ErrorIs(t, consumer.Run(context.Background()), down)
+	// redelivered after a restart
+	assert.Empty(t, topic.committed)
+}
@@ -0,0 +1,221 @@
+package cachelayer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//KeyKind kind of a cache key, see DecodeKey
+type KeyKind string
+
+const (
+	//KeyIndex id or index key: record of an id key, id of a unique index key, ids of other index keys
+	KeyIndex KeyKind = "index"
+	//KeyCount cached count of an index, see Count
+	KeyCount KeyKind = "count"
+	//KeyAnyOf ids of an OR query, see ListByAny
+	KeyAnyOf KeyKind = "any"
+	//KeyQuery ids of a custom query, see CachedQuery
+	KeyQuery KeyKind = "query"
+	//KeyTag set of query keys of a tag
+	KeyTag KeyKind = "tag"
+	//KeyFull hash of all records of a full cache
+	KeyFull KeyKind = "full"
+	//KeyFullIndex set of ids of an index of a full cache, see SetHashIndexes
+	KeyFullIndex KeyKind = "full_index"
+)
+
+//auxKeySuffixes suffixes of keys kept beside a cache key
+var auxKeySuffixes = []string{RefsKeySuffix, freshKeySuffix, deadlineKeySuffix}
+
+//transientKeySuffixes suffixes of short-lived keys of locks and loads
+var transientKeySuffixes = []string{coalesceLockSuffix, seedingKeySuffix}
+
+//isAuxKey whether key is kept beside a cache key, or is a lock or loading key, rather than a cache entry
+func isAuxKey(key string) bool {
+	for _, v := range auxKeySuffixes {
+		if strings.HasSuffix(key, v) {
+			return true
+		}
+	}
+	for _, v := range transientKeySuffixes {
+		if strings.HasSuffix(key, v) {
+			return true
+		}
+	}
+	return strings.Contains(key, loadingKeySuffix+":") || strings.Contains(key, coalesceStreamSuffix+":")
+}
+
+//KeyInfo parts of a cache key. Keys are lower case, so are the parts; values are encoded by KeyValue
+type KeyInfo struct {
+	Key    string
+	Prefix string
+	//Scope scope of the key, see SetKeyScope
+	Scope string
+	Table string
+	Kind  KeyKind
+	//Index fields and values of index, count, full index keys, "query" or "tag" of query and tag keys
+	Index Index
+	//AnyOf alternatives of an OR query key
+	AnyOf AnyOf
+	//Name name of a filtered full cache
+	Name string
+	//Suffix suffix of a key kept beside the cache key: ":refs", ":fresh" or ":deadline", Key without it is the cache key
+	Suffix string
+}
+
+//DecodeKey parse a redis key of a cache back into prefix, table and index, eg. app/user/email/tom@x.com.
+// The prefix must not contain "/", and values containing "/" can't be split from fields
+func DecodeKey(key string) (KeyInfo, error) {
+	r := KeyInfo{Key: key, Kind: KeyIndex}
+	for _, v := range auxKeySuffixes {
+		if strings.HasSuffix(key, v) {
+			r.Suffix = v
+			key = strings.TrimSuffix(key, v)
+			break
+		}
+	}
+	parts := strings.Split(key, "/")
+	if len(parts) < 3 {
+		return r, fmt.Errorf("cachelayer: %q is not a cache key {prefix}/{table}/...", r.Key)
+	}
+	r.Prefix, r.Table = parts[0], parts[1]
+	if i := strings.LastIndex(r.Prefix, ":"); i >= 0 {
+		r.Prefix, r.Scope = r.Prefix[:i], r.Prefix[i+1:]
+	}
+	rest := parts[2:]
+	var err error
+	switch {
+	case rest[0] == "full":
+		r.Kind = KeyFull
+		r.Name = strings.Join(rest[1:], "/")
+	case rest[0] == "full:idx":
+		r.Kind = KeyFullIndex
+		r.Index, err = decodePairs(rest[1:])
+	case rest[0] == "query" || rest[0] == "tag":
+		r.Kind = KeyKind(rest[0])
+		r.Index = NewIndex(rest[0], strings.Join(rest[1:], "/"))
+	case rest[0] == "count" && len(rest)%2 == 1:
+		r.Kind = KeyCount
+		r.Index, err = decodePairs(rest[1:])
+	case rest[0] == "any" && strings.Contains(key, "|"):
+		r.Kind = KeyAnyOf
+		for _, v := range strings.Split(strings.Join(rest[1:], "/"), "|") {
+			index, err := decodePairs(strings.Split(v, "/"))
+			if err != nil {
+				return r, err
+			}
+			r.AnyOf = append(r.AnyOf, index)
+		}
+	default:
+		r.Index, err = decodePairs(rest)
+	}
+	if err != nil {
+		return r, fmt.Errorf("cachelayer: %q: %w", r.Key, err)
+	}
+	return r, nil
+}
+
+//decodePairs index of field/value segments
+func decodePairs(parts []string) (Index, error) {
+	if len(parts)%2 != 0 {
+		return nil, errors.New("fields and values are not paired, a value may contain \"/\"")
+	}
+	r := make(Index, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		r[parts[i]] = parts[i+1]
+	}
+	return r, nil
+}
+
+//describeIndex field=value pairs of index ordered by field
+func describeIndex(index Index) string {
+	fields := make([]string, 0, len(index))
+	for k := range index {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for i, v := range fields {
+		fields[i] = v + "=" + KeyValue(index[v])
+	}
+	return strings.Join(fields, ",")
+}
+
+//ExplainKey one line description of a redis key of a cache, eg. for support engineers correlating redis contents with queries
+func ExplainKey(key string) string {
+	info, err := DecodeKey(key)
+	if err != nil {
+		return err.Error()
+	}
+	var r string
+	switch info.Kind {
+	case KeyFull:
+		r = "hash of all records of " + info.Table
+		if info.Name != "" {
+			r += " matching filter " + info.Name
+		}
+	case KeyFullIndex:
+		r = "ids of records of " + info.Table + " where " + describeIndex(info.Index) + ", in the full cache"
+	case KeyQuery:
+		r = "ids of records of " + info.Table + " of query " + KeyValue(info.Index["query"])
+	case KeyTag:
+		r = "query keys of " + info.Table + " tagged " + KeyValue(info.Index["tag"])
+	case KeyCount:
+		r = "count of records of " + info.Table
+		if len(info.Index) > 0 {
+			r += " where " + describeIndex(info.Index)
+		}
+	case KeyAnyOf:
+		alts := make([]string, len(info.AnyOf))
+		for i, v := range info.AnyOf {
+			alts[i] = describeIndex(v)
+		}
+		r = "ids of records of " + info.Table + " where " + strings.Join(alts, " or ")
+	default:
+		r = "record, id or ids of records of " + info.Table + " where " + describeIndex(info.Index)
+	}
+	switch info.Suffix {
+	case RefsKeySuffix:
+		r = "cache keys containing the record of: " + r
+	case freshKeySuffix:
+		r = "freshness marker(grace) of: " + r
+	case deadlineKeySuffix:
+		r = "max lifetime marker of: " + r
+	}
+	if info.Scope != "" {
+		return fmt.Sprintf("%s(prefix %s, scope %s): %s", info.Kind, info.Prefix, info.Scope, r)
+	}
+	return fmt.Sprintf("%s(prefix %s): %s", info.Kind, info.Prefix, r)
+}
+
+//ExplainGet keys read by Get(id), one per line with their role
+func (s *RedisCache[T, I]) ExplainGet(id I) string {
+	return s.explainRecord(s.MakeCacheKey(NewIndex(s.GetIdField(), id)))
+}
+
+//ExplainGetBy keys read by GetBy(index), one per line with their role
+func (s *RedisCache[T, I]) ExplainGetBy(index Index) string {
+	if !s.IsUniqueIndex(index) {
+		return s.ExplainListBy(index)
+	}
+	return s.MakeCacheKey(index) + " id of the record\n" + s.explainRecord(s.MakeCacheKey(NewIndex(s.GetIdField(), "{id}")))
+}
+
+//ExplainListBy keys read by ListBy(index, orderBys), one per line with their role
+func (s *RedisCache[T, I]) ExplainListBy(index Index) string {
+	return s.MakeCacheKey(index) + " ids of the records\n" + s.explainRecord(s.MakeCacheKey(NewIndex(s.GetIdField(), "{id}")))
+}
+
+//explainRecord keys read for the record of id key
+func (s *RedisCache[T, I]) explainRecord(key string) string {
+	lines := []string{key + " record"}
+	if s.red.grace > 0 {
+		lines = append(lines, freshKey(key)+" freshness marker, the record is stale without it")
+	}
+	if s.red.policy == ExpirationSlidingWithMax {
+		lines = append(lines, deadlineKey(key)+" max lifetime marker")
+	}
+	return strings.Join(lines, "\n")
+}
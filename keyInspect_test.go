@@ -0,0 +1,49 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeKey(t *testing.T) {
+	info, err := cachelayer.DecodeKey("app/user/email/tom@x.com/status/1:refs")
+	assert.Nil(t, err)
+	assert.Equal(t, "app", info.Prefix)
+	assert.Equal(t, "user", info.Table)
+	assert.Equal(t, cachelayer.KeyIndex, info.Kind)
+	assert.Equal(t, cachelayer.Index{"email": "tom@x.com", "status": "1"}, info.Index)
+	assert.Equal(t, cachelayer.RefsKeySuffix, info.Suffix)
+
+	info, err = cachelayer.DecodeKey("app/user/count/status/1")
+	assert.Nil(t, err)
+	assert.Equal(t, cachelayer.KeyCount, info.Kind)
+	assert.Equal(t, cachelayer.Index{"status": "1"}, info.Index)
+
+	info, err = cachelayer.DecodeKey("app/user/any/email/a|phone/1")
+	assert.Nil(t, err)
+	assert.Equal(t, cachelayer.AnyOf{{"email": "a"}, {"phone": "1"}}, info.AnyOf)
+
+	info, err = cachelayer.DecodeKey("app/user/query/where/age/range/18,%nil")
+	assert.Nil(t, err)
+	assert.Equal(t, cachelayer.KeyQuery, info.Kind)
+	assert.Equal(t, "where/age/range/18,%nil", info.Index["query"])
+
+	info, err = cachelayer.DecodeKey("app/user/full")
+	assert.Nil(t, err)
+	assert.Equal(t, cachelayer.KeyFull, info.Kind)
+
+	_, err = cachelayer.DecodeKey("app/user/email")
+	assert.NotNil(t, err)
+	assert.Equal(t, "count(prefix app): count of records of user where status=1", cachelayer.ExplainKey("app/user/count/status/1"))
+}
+
+func TestExplainGet(t *testing.T) {
+	c := cachelayer.NewRedisCache[keyUser, uint]("app", "user", "ID", nil, nil, time.Minute)
+	c.SetGrace(time.Minute)
+	assert.Equal(t, "app/user/id/1 record\napp/user/id/1:fresh freshness marker, the record is stale without it", c.ExplainGet(1))
+	assert.Equal(t, "app/user/name/tom ids of the records\napp/user/id/{id} record\napp/user/id/{id}:fresh freshness marker, the record is stale without it",
+		c.ExplainListBy(cachelayer.NewIndex("Name", "tom")))
+}
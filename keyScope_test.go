@@ -0,0 +1,30 @@
+package cachelayer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantKey struct{}
+
+func TestKeyScope(t *testing.T) {
+	c := cachelayer.NewRedisCache[keyUser, uint]("app", "user", "ID", nil, nil, time.Minute)
+	c.SetKeyScope(func(ctx context.Context) string {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return tenant
+	})
+	assert.Equal(t, "app/user/id/1", c.MakeCacheKey(cachelayer.NewIndex("ID", 1)))
+	acme := c.WithContext(context.WithValue(context.Background(), tenantKey{}, "Acme"))
+	assert.Equal(t, "app:acme/user/id/1", acme.MakeCacheKey(cachelayer.NewIndex("ID", 1)))
+	assert.Equal(t, "app:acme/user/count", acme.CountKey(cachelayer.Index{}))
+
+	info, err := cachelayer.DecodeKey("app:acme/user/id/1")
+	assert.Nil(t, err)
+	assert.Equal(t, "app", info.Prefix)
+	assert.Equal(t, "acme", info.Scope)
+	assert.Equal(t, "user", info.Table)
+}
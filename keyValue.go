@@ -0,0 +1,60 @@
+package cachelayer
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+//KeyValue canonical encoding of an index value in cache keys, so equal values of different go types share a key:
+// integers and integral floats are decimal(1, int64(1), 1.0 -> "1"), bools are "1"/"0", times are UTC RFC3339 with nanoseconds,
+// []byte is hex, UUID is compact(32 hex chars), pointers and driver.Valuer(eg. sql.NullInt64) are resolved, nil is "null"
+func KeyValue(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case []byte:
+		return hex.EncodeToString(v)
+	case UUID:
+		return v.Compact()
+	case driver.Valuer:
+		dv, err := v.Value()
+		if err != nil {
+			return Stringify(value, "null")
+		}
+		return KeyValue(dv)
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return "null"
+		}
+		return KeyValue(rv.Elem().Interface())
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return KeyValue(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64)
+	}
+	return Stringify(value, "null")
+}
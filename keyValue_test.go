@@ -0,0 +1,104 @@
+package cachelayer_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+type keyUser struct {
+	ID uint
+}
+
+func (s keyUser) GetID() uint {
+	return s.ID
+}
+func (s keyUser) ListIndexes() cachelayer.Indexes {
+	return nil
+}
+
+type status string
+
+func TestKeyValue(t *testing.T) {
+	one := 1
+	var nilPtr *int
+	at := time.Date(2022, 1, 2, 3, 4, 5, 6, time.FixedZone("x", 3600))
+	cases := []struct {
+		value interface{}
+		want  string
+	}{
+		{1, "1"},
+		{int64(1), "1"},
+		{uint8(1), "1"},
+		{1.0, "1"},
+		{float32(1.5), "1.5"},
+		{"1", "1"},
+		{true, "1"},
+		{false, "0"},
+		{&one, "1"},
+		{nilPtr, "null"},
+		{nil, "null"},
+		{status("on"), "on"},
+		{[]byte{1, 255}, "01ff"},
+		{at, "2022-01-02T02:04:05.000000006Z"},
+		{sql.NullInt64{Int64: 1, Valid: true}, "1"},
+		{sql.NullString{}, "null"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, cachelayer.KeyValue(c.value), "%#v", c.value)
+	}
+}
+
+func TestMakeCacheKey(t *testing.T) {
+	c := cachelayer.NewCacheBase[keyUser, uint]("app", "user", "id", context.Background())
+	// keys of the default encoding are kept, so instances of older versions share them
+	assert.Equal(t, "app/user/type/1/active/true", c.MakeCacheKey(cachelayer.Index{"Type": 1, "active": true}))
+	assert.Equal(t, "app/user/price/1.500000", c.MakeCacheKey(cachelayer.NewIndex("price", 1.5)))
+	c.SetCanonicalKeys(true)
+	a := c.MakeCacheKey(cachelayer.Index{"Type": 1, "active": true})
+	b := c.MakeCacheKey(cachelayer.Index{"type": "1", "Active": 1})
+	assert.Equal(t, "app/user/active/1/type/1", a)
+	assert.Equal(t, a, b)
+}
+
+type money struct {
+	cents int64
+}
+
+func (s money) String() string {
+	return fmt.Sprintf("%d.%02d", s.cents/100, s.cents%100)
+}
+
+type level int
+
+func (s level) MarshalText() ([]byte, error) {
+	return []byte("level" + strconv.Itoa(int(s))), nil
+}
+
+func TestStringify(t *testing.T) {
+	at := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := money{cents: 150}
+	var nilMoney *money
+	assert.Equal(t, "2022-01-02T03:04:05Z", cachelayer.Stringify(at, "null"))
+	assert.Equal(t, "1.50", cachelayer.Stringify(m, "null"))
+	assert.Equal(t, "1.50", cachelayer.Stringify(&m, "null"))
+	assert.Equal(t, "null", cachelayer.Stringify(nilMoney, "null"))
+	assert.Equal(t, "level2", cachelayer.Stringify(level(2), "null"))
+	assert.Equal(t, "on", cachelayer.Stringify(status("on"), "null"))
+	assert.Equal(t, "1.50", cachelayer.KeyValue(m))
+}
+
+func TestSetNullIDFunc(t *testing.T) {
+	c := cachelayer.NewCacheBase[keyUser, uint]("app", "user", "id", context.Background())
+	assert.True(t, c.IsNullID(0))
+	c.SetNullIDFunc(func(id uint) bool { return false })
+	assert.False(t, c.IsNullID(0))
+	c.SetNullIDFunc(nil)
+	assert.True(t, c.IsNullID(0))
+}
@@ -0,0 +1,207 @@
+package cachelayer
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//LatencyOp kind of timed operation
+type LatencyOp int
+
+const (
+	//OpCacheRead redis read of Get/List/GetBy/ListBy/CachedQuery
+	OpCacheRead LatencyOp = iota
+	//OpDBLoad database query on cache miss
+	OpDBLoad
+	//OpInvalidation deleting cache keys on writes and ClearCache
+	OpInvalidation
+	latencyOps
+)
+
+func (s LatencyOp) String() string {
+	switch s {
+	case OpCacheRead:
+		return "cache_read"
+	case OpDBLoad:
+		return "db_load"
+	case OpInvalidation:
+		return "invalidation"
+	}
+	return "unknown"
+}
+
+//LatencyBuckets upper bounds of latency histogram buckets, the last bucket counts everything slower
+var LatencyBuckets = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second,
+}
+
+//Latency histogram of an operation, Counts[i] counts operations <= Buckets[i], the last count is slower than all buckets
+type Latency struct {
+	Buckets []time.Duration
+	Counts  []int64
+	Count   int64
+	Sum     time.Duration
+}
+
+//Mean average latency, 0 without operations
+func (s Latency) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(s.Count)
+}
+
+//Quantile upper bound of the bucket containing quantile q(0-1), eg. Quantile(0.99) is p99. The last bucket bound is returned for the overflow bucket
+func (s Latency) Quantile(q float64) time.Duration {
+	if s.Count == 0 || len(s.Buckets) == 0 {
+		return 0
+	}
+	rank := int64(q * float64(s.Count))
+	var n int64
+	for i, v := range s.Counts {
+		n += v
+		if n > rank || n == s.Count {
+			if i < len(s.Buckets) {
+				return s.Buckets[i]
+			}
+			break
+		}
+	}
+	return s.Buckets[len(s.Buckets)-1]
+}
+
+type histogram struct {
+	counts [16]int64
+	count  int64
+	sum    int64
+}
+
+func (s *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	if i >= len(s.counts) {
+		i = len(s.counts) - 1
+	}
+	atomic.AddInt64(&s.counts[i], 1)
+	atomic.AddInt64(&s.count, 1)
+	atomic.AddInt64(&s.sum, int64(d))
+}
+
+func (s *histogram) snapshot() Latency {
+	n := len(LatencyBuckets) + 1
+	if n > len(s.counts) {
+		n = len(s.counts)
+	}
+	r := Latency{Buckets: LatencyBuckets, Counts: make([]int64, n), Count: atomic.LoadInt64(&s.count), Sum: time.Duration(atomic.LoadInt64(&s.sum))}
+	for i := range r.Counts {
+		r.Counts[i] = atomic.LoadInt64(&s.counts[i])
+	}
+	return r
+}
+
+//merge add counts of other latency with the same buckets
+func (s Latency) merge(other Latency) Latency {
+	if s.Counts == nil {
+		s.Buckets = other.Buckets
+		s.Counts = make([]int64, len(other.Counts))
+	}
+	for i := range s.Counts {
+		if i < len(other.Counts) {
+			s.Counts[i] += other.Counts[i]
+		}
+	}
+	s.Count += other.Count
+	s.Sum += other.Sum
+	return s
+}
+
+//SlowOp an operation slower than the slow log threshold
+type SlowOp struct {
+	Op       LatencyOp
+	Table    string
+	Key      string
+	Duration time.Duration
+	Err      error
+}
+
+//SetSlowLog report operations slower than threshold to fn, nil fn logs them by the standard logger. threshold 0 disables it
+func (s *CacheBase[T, I]) SetSlowLog(threshold time.Duration, fn func(op SlowOp)) {
+	if fn == nil {
+		fn = func(op SlowOp) {
+			log.Printf("cachelayer: slow %s table=%s key=%s duration=%s err=%v", op.Op, op.Table, op.Key, op.Duration, op.Err)
+		}
+	}
+	s.slowThreshold = threshold
+	s.slowLog = fn
+}
+
+//observe record latency of op started at start, report it if slow
+func (s *CacheBase[T, I]) observe(op LatencyOp, key string, start time.Time, err error) {
+	d := s.clock.Now().Sub(start)
+	s.stats.latency[op].observe(d)
+	if s.slowThreshold > 0 && d >= s.slowThreshold {
+		s.slowLog(SlowOp{Op: op, Table: s.table, Key: key, Duration: d, Err: err})
+	}
+}
+
+//delKeys DelKeys timed as OpInvalidation
+func (s *CacheBase[T, I]) delKeys(red redis.UniversalClient, keys ...string) (int64, error) {
+	start := s.clock.Now()
+	s.generation.advance()
+	n, err := DelKeys(s.ctx, red, keys...)
+	s.trace(TraceInvalidate, err, keys...)
+	key := ""
+	if len(keys) == 1 {
+		key = keys[0]
+	}
+	s.observe(OpInvalidation, key, start, err)
+	return n, err
+}
+
+//DBLoad a database query run on cache misses, see OnDBLoad
+type DBLoad struct {
+	Table string
+	//Keys cache keys missed and loaded by the query, empty if they are not known, eg. ListForLocale
+	Keys     []string
+	Duration time.Duration
+	//Rows records loaded, 1 of a count query
+	Rows int
+	Err  error
+}
+
+//OnDBLoad call fn after every database query of cache misses with the context of the cache, eg. to add the duration, rows and
+// missed keys to the current span, or as exemplar of a miss metric, so DB load is attributed to the cache keys causing it
+func (s *CacheBase[T, I]) OnDBLoad(fn func(ctx context.Context, load DBLoad)) {
+	s.onDBLoad = fn
+}
+
+//dbLoaded count a database query of keys started at start and returning rows, record its latency, trace and report it
+func (s *CacheBase[T, I]) dbLoaded(start time.Time, rows int, err error, keys ...string) {
+	d := s.clock.Now().Sub(start)
+	s.stats.dbLoad(err)
+	s.traceStep(TraceStep{Table: s.table, Event: TraceDBQuery, Keys: keys, Err: err, Duration: d, Rows: rows})
+	key := ""
+	if len(keys) == 1 {
+		key = keys[0]
+	}
+	s.observe(OpDBLoad, key, start, err)
+	if s.onDBLoad != nil {
+		s.onDBLoad(s.ctx, DBLoad{Table: s.table, Keys: keys, Duration: d, Rows: rows, Err: err})
+	}
+}
+
+//rowsOf rows of a query of a single record
+func rowsOf(exists bool) int {
+	if exists {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyQuantile(t *testing.T) {
+	l := cachelayer.Latency{
+		Buckets: []time.Duration{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond},
+		Counts:  []int64{90, 8, 1, 1},
+		Count:   100,
+		Sum:     500 * time.Millisecond,
+	}
+	assert.Equal(t, time.Millisecond, l.Quantile(0.5))
+	assert.Equal(t, 10*time.Millisecond, l.Quantile(0.95))
+	assert.Equal(t, 100*time.Millisecond, l.Quantile(0.99))
+	assert.Equal(t, 100*time.Millisecond, l.Quantile(1))
+	assert.Equal(t, 5*time.Millisecond, l.Mean())
+	assert.Equal(t, time.Duration(0), cachelayer.Latency{}.Quantile(0.5))
+	assert.Equal(t, "db_load", cachelayer.OpDBLoad.String())
+}
@@ -0,0 +1,140 @@
+package cachelayer
+
+import (
+	"fmt"
+	"reflect"
+)
+
+//InLister database answering several values of a unique index by one query, eg. WHERE field IN (...). Used by ListByIn
+type InLister[T Table[I], I IDType] interface {
+	ListByIn(field string, values []interface{}) ([]T, error)
+}
+
+//ListByIn GetBy for every value of the unique index field, in one MGET and one database query of the misses(InLister, GetBy per value otherwise).
+// Records are returned in the order of values, values not found are zero records
+func (s *RedisCache[T, I]) ListByIn(field string, values []interface{}) ([]T, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(values))
+	for i, v := range values {
+		keys[i] = s.MakeCacheKey(NewIndex(field, v))
+	}
+	s.hotKeys.Record(keys...)
+	start := s.clock.Now()
+	cachedIds, missedIndexes, err := s.redId.MGetJson(keys)
+	s.observe(OpCacheRead, "", start, err)
+	if err != nil {
+		return nil, s.wrapErr("list_by_in", "", err)
+	}
+	s.stats.hit(len(values) - len(missedIndexes))
+	s.stats.miss(len(missedIndexes))
+	missed := make(map[int]bool, len(missedIndexes))
+	for _, v := range missedIndexes {
+		missed[v] = true
+	}
+	var ids []I
+	for i, v := range cachedIds {
+		if !missed[i] && !s.IsNullID(v) {
+			ids = append(ids, v)
+		}
+	}
+	objs, err := s.List(ids...)
+	if err != nil {
+		return nil, s.wrapErr("list_by_in", "", err)
+	}
+	byId := make(map[I]T, len(objs))
+	for _, v := range s.existingRecords(objs) {
+		byId[v.GetID()] = v
+	}
+	r := make([]T, len(values))
+	for i, v := range cachedIds {
+		if !missed[i] {
+			r[i] = byId[v]
+		}
+	}
+	if len(missedIndexes) == 0 {
+		return r, nil
+	}
+	missedKeys := make([]string, len(missedIndexes))
+	missedValues := make([]interface{}, len(missedIndexes))
+	for i, v := range missedIndexes {
+		missedKeys[i] = keys[v]
+		missedValues[i] = values[v]
+	}
+	s.trace(TraceMiss, nil, missedKeys...)
+	gen := s.generation.current()
+	start = s.clock.Now()
+	loaded, err := s.listByIn(field, missedValues)
+	s.dbLoaded(start, len(loaded), err, missedKeys...)
+	if err != nil {
+		return r, s.wrapErr("list_by_in", "", err)
+	}
+	found := make(map[string]T, len(loaded))
+	for _, v := range loaded {
+		key, err := s.fieldKey(field, v)
+		if err != nil {
+			return r, s.wrapErr("list_by_in", "", err)
+		}
+		if _, ok := found[key]; ok {
+			return r, s.wrapErr("list_by_in", key, NewError(ErrNotUnique, nil))
+		}
+		found[key] = v
+	}
+	idMap := make(map[string]interface{}, len(found))
+	refs := make(map[string][]I, len(found))
+	var nulls []string
+	for i, key := range missedKeys {
+		if v, ok := found[key]; ok {
+			r[missedIndexes[i]] = v
+			idMap[key] = v.GetID()
+			refs[key] = []I{v.GetID()}
+		} else {
+			nulls = append(nulls, key)
+		}
+	}
+	s.report("populate", s.populate(gen, func() error {
+		if err := s.redId.MSetJson(idMap); err != nil {
+			return err
+		}
+		if err := s.addRefs(s.red.UniversalClient, s.redId.storeTTL(), refs); err != nil {
+			return err
+		}
+		if !s.noNegativeCache {
+			return s.redId.MSetNull(nulls)
+		}
+		return nil
+	}))
+	return r, nil
+}
+
+//listByIn records of values of field from db, by one query if db is an InLister
+func (s *RedisCache[T, I]) listByIn(field string, values []interface{}) ([]T, error) {
+	if l, ok := s.db.(InLister[T, I]); ok {
+		return l.ListByIn(field, values)
+	}
+	var r []T
+	for _, v := range values {
+		obj, exists, err := getByUnique[T, I](s.db, NewIndex(field, v))
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			r = append(r, obj)
+		}
+	}
+	return r, nil
+}
+
+//fieldKey cache key of the single-field index field of obj
+func (s *RedisCache[T, I]) fieldKey(field string, obj T) (string, error) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	f, ok := lookupField(v.Type(), field)
+	if !ok {
+		return "", fmt.Errorf("cachelayer: no field %s in %s", field, v.Type())
+	}
+	return s.MakeCacheKey(NewIndex(field, v.FieldByName(f.Name).Interface())), nil
+}
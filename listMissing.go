@@ -0,0 +1,39 @@
+package cachelayer
+
+//ListWithMissing List records of ids, return found records and the ids not found(deleted or never existed) in the order of ids,
+// so callers don't diff results against ids
+func (s *RedisCache[T, I]) ListWithMissing(ids ...I) ([]T, []I, error) {
+	objs, err := s.List(ids...)
+	if err != nil {
+		return nil, nil, err
+	}
+	found, missing := s.splitMissing(ids, objs)
+	return found, missing, nil
+}
+
+//ListWithMissing List records of ids, see RedisCache.ListWithMissing
+func (s *FullRedisCache[T, I]) ListWithMissing(ids ...I) ([]T, []I, error) {
+	objs, err := s.List(ids...)
+	if err != nil {
+		return nil, nil, err
+	}
+	found, missing := s.splitMissing(ids, objs)
+	return found, missing, nil
+}
+
+//splitMissing existing records of objs and ids without a record, each id is reported once
+func (s *CacheBase[T, I]) splitMissing(ids []I, objs []T) ([]T, []I) {
+	found := s.existingRecords(objs)
+	seen := make(map[I]bool, len(found))
+	for _, v := range found {
+		seen[v.GetID()] = true
+	}
+	var missing []I
+	for _, v := range ids {
+		if !seen[v] {
+			seen[v] = true
+			missing = append(missing, v)
+		}
+	}
+	return found, missing
+}
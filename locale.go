@@ -0,0 +1,119 @@
+package cachelayer
+
+import "errors"
+
+//LocaleLoader database loading records translated into a locale, eg. with denormalized translations joined. Used by GetForLocale and ListForLocale
+type LocaleLoader[T Table[I], I IDType] interface {
+	LoadForLocale(locale string, ids ...I) ([]T, error)
+}
+
+//LocaleKey cache key of the record of id translated into locale, eg. app/product/id/1/locale/de
+func (s *CacheBase[T, I]) LocaleKey(id I, locale string) string {
+	return s.MakeCacheKey(NewIndex(s.GetIdField(), id).Add("locale", locale))
+}
+
+//localeTag tag of the translated keys of the record of an id key
+func localeTag(idKey string) string {
+	return "locale:" + idKey
+}
+
+//localeTags tags of translated keys of objs, none if db does not load translations
+func (s *RedisCache[T, I]) localeTags(objs ...T) []string {
+	if _, ok := s.db.(LocaleLoader[T, I]); !ok {
+		return nil
+	}
+	r := make([]string, len(objs))
+	for i, v := range objs {
+		r[i] = localeTag(s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID())))
+	}
+	return r
+}
+
+//GetForLocale record of id translated into locale, see ListForLocale
+func (s *RedisCache[T, I]) GetForLocale(id I, locale string) (T, bool, error) {
+	r, err := s.ListForLocale(locale, id)
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	exists := !s.IsNullID(r[0].GetID())
+	return r[0], exists, s.notFound("get_for_locale", s.LocaleKey(id, locale), exists, nil)
+}
+
+//ListForLocale records of ids translated into locale, the i-th record is the record of ids[i] and a zero record if it is not found.
+// Translations are cached per locale under LocaleKey and loaded by LoadForLocale of the database, ClearCache of a record clears
+// the translations of all locales
+func (s *RedisCache[T, I]) ListForLocale(locale string, ids ...I) ([]T, error) {
+	loader, ok := s.db.(LocaleLoader[T, I])
+	if !ok {
+		return nil, s.wrapErr("list_for_locale", "", errors.New("cachelayer: database does not implement LocaleLoader"))
+	}
+	redisKeys := make([]string, len(ids))
+	for i, v := range ids {
+		redisKeys[i] = s.LocaleKey(v, locale)
+	}
+	s.hotKeys.Record(redisKeys...)
+	start := s.clock.Now()
+	records, missedIndexes, _, err := s.red.mgetJson(redisKeys, false)
+	s.observe(OpCacheRead, "", start, err)
+	if err != nil {
+		return nil, s.wrapErr("list_for_locale", "", err)
+	}
+	s.stats.hit(len(ids) - len(missedIndexes))
+	s.stats.miss(len(missedIndexes))
+	s.report("refresh", s.red.Refresh(slidingKeys(redisKeys, records, missedIndexes)...))
+	if len(missedIndexes) == 0 {
+		s.trace(TraceHit, nil, redisKeys...)
+		return records, nil
+	}
+	missedIds := make([]I, 0, len(missedIndexes))
+	missedPositions := make(map[I][]int, len(missedIndexes))
+	for _, v := range missedIndexes {
+		if _, ok := missedPositions[ids[v]]; !ok {
+			missedIds = append(missedIds, ids[v])
+		}
+		missedPositions[ids[v]] = append(missedPositions[ids[v]], v)
+	}
+	gen := s.generation.current()
+	start = s.clock.Now()
+	loaded, err := loader.LoadForLocale(locale, missedIds...)
+	s.dbLoaded(start, len(loaded), err)
+	if err != nil {
+		return records, s.wrapErr("list_for_locale", "", err)
+	}
+	needToCache := make(map[string]interface{}, len(loaded))
+	found := make(map[I]bool, len(loaded))
+	for _, v := range loaded {
+		needToCache[s.LocaleKey(v.GetID(), locale)] = v
+		for _, p := range missedPositions[v.GetID()] {
+			records[p] = v
+		}
+		found[v.GetID()] = true
+	}
+	var needToCacheNull []string
+	for _, v := range missedIds {
+		if !found[v] {
+			needToCacheNull = append(needToCacheNull, s.LocaleKey(v, locale))
+		}
+	}
+	s.report("populate", s.populate(gen, func() error {
+		if err := s.red.MSetJson(needToCache); err != nil {
+			return err
+		}
+		if !s.noNegativeCache {
+			if err := s.red.MSetNull(needToCacheNull); err != nil {
+				return err
+			}
+		}
+		// tag translated keys by their id key, so ClearCache of the record clears every locale
+		p := s.red.Pipeline()
+		for _, v := range missedIds {
+			tagKey := s.TagKey(localeTag(s.MakeCacheKey(NewIndex(s.GetIdField(), v))))
+			p.SAdd(s.ctx, tagKey, s.LocaleKey(v, locale))
+			p.Expire(s.ctx, tagKey, s.red.storeTTL())
+		}
+		_, err := p.Exec(s.ctx)
+		return cacheError(err)
+	}))
+	return records, nil
+}
@@ -0,0 +1,20 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocaleKey(t *testing.T) {
+	c := cachelayer.NewRedisCache[keyUser, uint]("app", "user", "ID", nil, nil, time.Minute)
+	assert.Equal(t, "app/user/id/1/locale/de", c.LocaleKey(1, "DE"))
+	info, err := cachelayer.DecodeKey(c.LocaleKey(1, "de"))
+	assert.Nil(t, err)
+	assert.Equal(t, cachelayer.Index{"id": "1", "locale": "de"}, info.Index)
+	// the database must load translations
+	_, err = c.ListForLocale("de", 1)
+	assert.NotNil(t, err)
+}
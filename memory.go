@@ -0,0 +1,100 @@
+package cachelayer
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//DefaultMemorySamples keys measured by MEMORY USAGE per report
+const DefaultMemorySamples = 1000
+
+//MemoryReport estimated redis memory of cache keys of a table
+type MemoryReport struct {
+	Table string
+	//Keys count of cache keys
+	Keys int64
+	//Sampled keys measured by MEMORY USAGE
+	Sampled int64
+	//SampledBytes memory of sampled keys
+	SampledBytes int64
+	//EstimatedBytes SampledBytes scaled to all keys
+	EstimatedBytes int64
+}
+
+//AvgKeyBytes average memory of a key, 0 if nothing is sampled
+func (s MemoryReport) AvgKeyBytes() int64 {
+	if s.Sampled == 0 {
+		return 0
+	}
+	return s.SampledBytes / s.Sampled
+}
+
+//MemoryUsage estimate redis memory used by cache keys of table: keys are counted by SCAN, samples of them(uniformly chosen, DefaultMemorySamples if <= 0)
+// are measured by MEMORY USAGE and scaled to all keys
+func MemoryUsage(ctx context.Context, red redis.UniversalClient, prefix, table string, samples int) (MemoryReport, error) {
+	report := MemoryReport{Table: table}
+	if samples <= 0 {
+		samples = DefaultMemorySamples
+	}
+	pattern := strings.ToLower(prefix + "/" + table + "/*")
+	var mu sync.Mutex
+	err := forEachNode(ctx, red, func(ctx context.Context, node redis.UniversalClient) error {
+		// reservoir sampling per node, measured on the node holding the keys
+		var keys int64
+		reservoir := make([]string, 0, samples)
+		iter := node.Scan(ctx, 0, pattern, DefaultScanBatchSize).Iterator()
+		for iter.Next(ctx) {
+			keys++
+			if len(reservoir) < samples {
+				reservoir = append(reservoir, iter.Val())
+			} else if i := rand.Int63n(keys); i < int64(samples) {
+				reservoir[i] = iter.Val()
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return cacheError(err)
+		}
+		p := node.Pipeline()
+		cmds := make([]*redis.IntCmd, len(reservoir))
+		for i, v := range reservoir {
+			cmds[i] = p.MemoryUsage(ctx, v)
+		}
+		if _, err := p.Exec(ctx); err != nil && err != redis.Nil {
+			return cacheError(err)
+		}
+		var sampled, bytes int64
+		for _, v := range cmds {
+			// expired since scan
+			if v.Err() == nil {
+				sampled++
+				bytes += v.Val()
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		report.Keys += keys
+		report.Sampled += sampled
+		report.SampledBytes += bytes
+		if sampled > 0 {
+			report.EstimatedBytes += bytes * keys / sampled
+		}
+		return nil
+	})
+	return report, err
+}
+
+//MemoryUsage estimate redis memory used by this cache, see MemoryUsage
+func (s *RedisCache[T, I]) MemoryUsage(samples int) (MemoryReport, error) {
+	r, err := MemoryUsage(s.ctx, s.red.UniversalClient, s.keyPrefix(), s.table, samples)
+	return r, s.wrapErr("memory_usage", "", err)
+}
+
+//MemoryUsage estimate redis memory used by this cache, see MemoryUsage
+func (s *FullRedisCache[T, I]) MemoryUsage(samples int) (MemoryReport, error) {
+	r, err := MemoryUsage(s.ctx, s.red.UniversalClient, s.keyPrefix(), s.table, samples)
+	return r, s.wrapErr("memory_usage", "", err)
+}
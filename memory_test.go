@@ -0,0 +1,65 @@
+package cachelayer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+//fakeMemoryUsage MEMORY USAGE missing in miniredis, every existing key takes 100 bytes
+func fakeMemoryUsage(t *testing.T, mr *miniredis.Miniredis) {
+	err := mr.Server().Register("MEMORY", func(c *server.Peer, cmd string, args []string) {
+		if len(args) < 2 || !strings.EqualFold(args[0], "usage") {
+			c.WriteError("ERR syntax error")
+			return
+		}
+		if !mr.Exists(args[1]) {
+			c.WriteNull()
+			return
+		}
+		c.WriteInt(100)
+	})
+	assert.Nil(t, err)
+}
+
+func TestMemoryUsage(t *testing.T) {
+	cache, _, mr := newMemberCache(t, member{ID: 1}, member{ID: 2}, member{ID: 3}, member{ID: 4})
+	fakeMemoryUsage(t, mr)
+	_, err := cache.List(1, 2, 3, 4)
+	assert.Nil(t, err)
+	// keys of other tables are not counted
+	mr.Set("app/group/id/1", "{}")
+
+	report, err := cache.MemoryUsage(0)
+	assert.Nil(t, err)
+	assert.Equal(t, cachelayer.MemoryReport{Table: "member", Keys: 4, Sampled: 4, SampledBytes: 400, EstimatedBytes: 400}, report)
+	assert.Equal(t, int64(100), report.AvgKeyBytes())
+
+	report, err = cache.MemoryUsage(2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), report.Keys)
+	assert.Equal(t, int64(2), report.Sampled)
+	assert.Equal(t, int64(400), report.EstimatedBytes)
+
+	registry := cachelayer.NewRegistry()
+	assert.Nil(t, registry.Register("member", cache))
+	w := httptest.NewRecorder()
+	cachelayer.NewAdminHandler(registry).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/caches/member/memory?samples=1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"estimatedBytes":400`)
+}
+
+func TestMemoryUsageEmpty(t *testing.T) {
+	_, red := newMiniRedis(t)
+	report, err := cachelayer.MemoryUsage(context.Background(), red, "app", "member", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, cachelayer.MemoryReport{Table: "member"}, report)
+	assert.Equal(t, int64(0), report.AvgKeyBytes())
+}
@@ -0,0 +1,426 @@
+package cachelayer
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//MigrationMode stage of migrating the cache tier to a new redis
+type MigrationMode int32
+
+const (
+	//MigrationDualWrite write to both redis, read from new with fallback to old
+	MigrationDualWrite MigrationMode = iota
+	//MigrationCutover use new redis only
+	MigrationCutover
+)
+
+func (s MigrationMode) String() string {
+	switch s {
+	case MigrationDualWrite:
+		return "dual_write"
+	case MigrationCutover:
+		return "cutover"
+	}
+	return "unknown"
+}
+
+//migrationWrites commands mirrored to the old redis after they succeed on the new one, and to the secondary of a Replicator
+var migrationWrites = map[string]bool{
+	"set": true, "setex": true, "psetex": true, "setnx": true, "mset": true, "getset": true,
+	"rename": true, "persist": true,
+	"expire": true, "pexpire": true, "expireat": true, "pexpireat": true,
+	"hset": true, "hmset": true, "sadd": true,
+	"incr": true, "incrby": true, "eval": true,
+}
+
+//migrationDeletes commands mirrored to the old redis before they run on the new one,
+// so an invalidation reaches old first and Backfill never copies the deleted entry back
+var migrationDeletes = map[string]bool{
+	"del": true, "unlink": true, "hdel": true, "srem": true,
+}
+
+//migrationReads commands falling back to the old redis on miss
+var migrationReads = map[string]bool{
+	"get": true, "hget": true, "mget": true, "hmget": true, "hgetall": true, "exists": true, "smembers": true,
+}
+
+//MigrationStats counters of a Migration
+type MigrationStats struct {
+	Mirrored     int64
+	MirrorErrors int64
+	Fallbacks    int64
+}
+
+//Migration go-redis hook migrating the cache tier from old to the new redis without a cold cache, add it to the new client:
+//
+//	newClient.AddHook(cachelayer.NewMigration(oldClient))
+//
+// In MigrationDualWrite mode writes are mirrored to old and misses are read from old, Cutover switches to the new redis only.
+// Deletes reach old before new, and EVALSHA of a script old has not loaded is run there as EVAL. See Backfill to copy existing keys
+type Migration struct {
+	old          redis.UniversalClient
+	mode         int32
+	mirrored     int64
+	mirrorErrors int64
+	fallbacks    int64
+}
+
+func NewMigration(old redis.UniversalClient) *Migration {
+	return &Migration{old: old, mode: int32(MigrationDualWrite)}
+}
+
+func (s *Migration) SetMode(mode MigrationMode) {
+	atomic.StoreInt32(&s.mode, int32(mode))
+}
+func (s *Migration) GetMode() MigrationMode {
+	return MigrationMode(atomic.LoadInt32(&s.mode))
+}
+
+//Cutover stop writing to and reading from the old redis
+func (s *Migration) Cutover() {
+	s.SetMode(MigrationCutover)
+}
+
+func (s *Migration) Stats() MigrationStats {
+	return MigrationStats{
+		Mirrored:     atomic.LoadInt64(&s.mirrored),
+		MirrorErrors: atomic.LoadInt64(&s.mirrorErrors),
+		Fallbacks:    atomic.LoadInt64(&s.fallbacks),
+	}
+}
+
+//migrationSkip context key of commands not to mirror, see Backfill
+type migrationSkip struct{}
+
+//dualWrite whether commands of ctx are mirrored to the old redis
+func (s *Migration) dualWrite(ctx context.Context) bool {
+	return s.GetMode() == MigrationDualWrite && ctx.Value(migrationSkip{}) == nil
+}
+
+func (s *Migration) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if s.dualWrite(ctx) && migrationDeletes[cmd.Name()] {
+		s.countMirror(s.old.Do(ctx, cmd.Args()...).Err())
+	}
+	return ctx, nil
+}
+
+func (s *Migration) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if !s.dualWrite(ctx) {
+		return nil
+	}
+	if s.mirrorAfter(cmd) {
+		s.countMirror(s.mirrorScript(ctx, cmd.Args(), s.old.Do(ctx, cmd.Args()...).Err()))
+	}
+	if migrationReads[cmd.Name()] {
+		s.fallback(ctx, cmd)
+	}
+	return nil
+}
+
+func (s *Migration) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	if !s.dualWrite(ctx) {
+		return ctx, nil
+	}
+	p := s.old.Pipeline()
+	var mirrored []redis.Cmder
+	for _, cmd := range cmds {
+		if migrationDeletes[cmd.Name()] {
+			mirrored = append(mirrored, p.Do(ctx, cmd.Args()...))
+		}
+	}
+	s.execMirrored(ctx, p, mirrored)
+	return ctx, nil
+}
+
+func (s *Migration) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	if !s.dualWrite(ctx) {
+		return nil
+	}
+	p := s.old.Pipeline()
+	var mirrored []redis.Cmder
+	for _, cmd := range cmds {
+		if s.mirrorAfter(cmd) {
+			mirrored = append(mirrored, p.Do(ctx, cmd.Args()...))
+		}
+		if migrationReads[cmd.Name()] {
+			s.fallback(ctx, cmd)
+		}
+	}
+	s.execMirrored(ctx, p, mirrored)
+	return nil
+}
+
+func (s *Migration) execMirrored(ctx context.Context, p redis.Pipeliner, mirrored []redis.Cmder) {
+	if len(mirrored) == 0 {
+		return
+	}
+	p.Exec(ctx)
+	for _, v := range mirrored {
+		s.countMirror(s.mirrorScript(ctx, v.Args(), v.Err()))
+	}
+}
+
+//mirrorAfter whether cmd is a successful write to mirror, remembering sources of scripts it loads
+func (s *Migration) mirrorAfter(cmd redis.Cmder) bool {
+	if cmd.Err() != nil {
+		return false
+	}
+	args := cmd.Args()
+	switch cmd.Name() {
+	case "eval":
+		rememberScript(args[1])
+	case "evalsha":
+		return true
+	case "script":
+		if len(args) < 3 || !strings.EqualFold(Stringify(args[1], ""), "load") {
+			return false
+		}
+		rememberScript(args[2])
+		return true
+	}
+	return migrationWrites[cmd.Name()]
+}
+
+//mirrorScript run a mirrored EVALSHA as EVAL if the old redis has not loaded the script, err is the error of the mirrored command
+func (s *Migration) mirrorScript(ctx context.Context, args []interface{}, err error) error {
+	if err == nil || !strings.HasPrefix(err.Error(), "NOSCRIPT") || len(args) < 2 {
+		return err
+	}
+	src, ok := scriptSources.Load(strings.ToLower(Stringify(args[1], "")))
+	if !ok {
+		return err
+	}
+	evalArgs := append([]interface{}{"eval", src}, args[2:]...)
+	return s.old.Do(ctx, evalArgs...).Err()
+}
+
+func (s *Migration) countMirror(err error) {
+	if err != nil && err != redis.Nil {
+		atomic.AddInt64(&s.mirrorErrors, 1)
+		return
+	}
+	atomic.AddInt64(&s.mirrored, 1)
+}
+
+//fallback fill missed result of cmd from the old redis
+func (s *Migration) fallback(ctx context.Context, cmd redis.Cmder) {
+	switch c := cmd.(type) {
+	case *redis.StringCmd:
+		if c.Err() != redis.Nil {
+			return
+		}
+		v, err := s.old.Do(ctx, c.Args()...).Text()
+		if err != nil {
+			return
+		}
+		c.SetErr(nil)
+		c.SetVal(v)
+	case *redis.SliceCmd:
+		vals := c.Val()
+		if c.Err() != nil || !hasNil(vals) {
+			return
+		}
+		old, err := s.old.Do(ctx, c.Args()...).Slice()
+		if err != nil || len(old) != len(vals) {
+			return
+		}
+		for i, v := range vals {
+			if v == nil {
+				vals[i] = old[i]
+			}
+		}
+		c.SetVal(vals)
+	case *redis.StringStringMapCmd:
+		if c.Err() != nil || len(c.Val()) > 0 {
+			return
+		}
+		v, err := s.old.HGetAll(ctx, c.Args()[1].(string)).Result()
+		if err != nil || len(v) == 0 {
+			return
+		}
+		c.SetVal(v)
+	case *redis.IntCmd:
+		if c.Err() != nil || c.Val() > 0 {
+			return
+		}
+		v, err := s.old.Do(ctx, c.Args()...).Int64()
+		if err != nil || v == 0 {
+			return
+		}
+		c.SetVal(v)
+	case *redis.StringSliceCmd:
+		if c.Err() != nil || len(c.Val()) > 0 {
+			return
+		}
+		v, err := s.old.Do(ctx, c.Args()...).StringSlice()
+		if err != nil || len(v) == 0 {
+			return
+		}
+		c.SetVal(v)
+	default:
+		return
+	}
+	atomic.AddInt64(&s.fallbacks, 1)
+}
+
+//migrationValue value of a key read from the old redis
+type migrationValue struct {
+	kind  string
+	value interface{}
+	ttl   time.Duration
+}
+
+//restoreScript write a key only if it does not exist, ARGV: command, ttl in milliseconds(0 means none), command arguments
+var restoreScript = newScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then return 0 end
+for i = 3, #ARGV, 1000 do
+	redis.call(ARGV[1], KEYS[1], unpack(ARGV, i, math.min(i + 999, #ARGV)))
+end
+if tonumber(ARGV[2]) > 0 then redis.call('PEXPIRE', KEYS[1], ARGV[2]) end
+return 1
+`)
+
+//Backfill copy keys matching pattern(eg. "app/*") from the old redis to red(the new one) if red does not have them, keeping their ttl.
+// Strings, hashes and sets are copied, other types are skipped. Return count of copied keys.
+// Keys written by the dual write are newer and never overwritten, and a key changed in old while it is copied is deleted from red again,
+// so an entry invalidated during the copy is not resurrected
+func (s *Migration) Backfill(ctx context.Context, red redis.UniversalClient, pattern string, batchSize int64) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultScanBatchSize
+	}
+	ctx = context.WithValue(ctx, migrationSkip{}, true)
+	var copied int64
+	err := forEachNode(ctx, s.old, func(ctx context.Context, node redis.UniversalClient) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(ctx, cursor, pattern, batchSize).Result()
+			if err != nil {
+				return cacheError(err)
+			}
+			for _, v := range keys {
+				ok, err := s.backfillKey(ctx, red, v)
+				if err != nil {
+					return err
+				}
+				if ok {
+					atomic.AddInt64(&copied, 1)
+				}
+			}
+			cursor = next
+			if cursor == 0 {
+				return nil
+			}
+		}
+	})
+	return copied, err
+}
+
+//backfillKey copy key from the old redis to red if red misses it, return whether it was copied
+func (s *Migration) backfillKey(ctx context.Context, red redis.UniversalClient, key string) (bool, error) {
+	v, err := s.readOld(ctx, key)
+	if err != nil || v.value == nil {
+		return false, err
+	}
+	args := []interface{}{"", v.ttl.Milliseconds()}
+	switch x := v.value.(type) {
+	case string:
+		args[0] = "set"
+		args = append(args, x)
+	case map[string]string:
+		args[0] = "hset"
+		for field, value := range x {
+			args = append(args, field, value)
+		}
+	case []string:
+		args[0] = "sadd"
+		for _, member := range x {
+			args = append(args, member)
+		}
+	}
+	ok, err := restoreScript.Run(ctx, red, []string{key}, args...).Bool()
+	if err != nil || !ok {
+		return false, cacheError(err)
+	}
+	cur, err := s.readOld(ctx, key)
+	if err == nil && cur.kind == v.kind && reflect.DeepEqual(cur.value, v.value) {
+		return true, nil
+	}
+	return false, cacheError(red.Del(ctx, key).Err())
+}
+
+//readOld value and ttl of key in the old redis, nil value if it does not exist or its type is not copied
+func (s *Migration) readOld(ctx context.Context, key string) (migrationValue, error) {
+	var r migrationValue
+	var err error
+	if r.kind, err = s.old.Type(ctx, key).Result(); err != nil {
+		return r, cacheError(err)
+	}
+	switch r.kind {
+	case "string":
+		r.value, err = s.old.Get(ctx, key).Result()
+	case "hash":
+		var v map[string]string
+		if v, err = s.old.HGetAll(ctx, key).Result(); len(v) > 0 {
+			r.value = v
+		}
+	case "set":
+		var v []string
+		if v, err = s.old.SMembers(ctx, key).Result(); len(v) > 0 {
+			sort.Strings(v)
+			r.value = v
+		}
+	default:
+		return r, nil
+	}
+	if err == redis.Nil {
+		return migrationValue{}, nil
+	}
+	if err != nil || r.value == nil {
+		return migrationValue{}, cacheError(err)
+	}
+	if r.ttl, err = s.old.PTTL(ctx, key).Result(); err != nil {
+		return migrationValue{}, cacheError(err)
+	}
+	if r.ttl == -2 {
+		// expired while being read
+		return migrationValue{}, nil
+	}
+	if r.ttl < 0 {
+		r.ttl = 0
+	}
+	return r, nil
+}
+
+//scriptSources sources of known lua scripts by sha1, so a mirrored EVALSHA can run on a redis that has not loaded the script
+var scriptSources sync.Map
+
+//newScript redis.NewScript remembering the source of the script
+func newScript(src string) *redis.Script {
+	script := redis.NewScript(src)
+	scriptSources.Store(script.Hash(), src)
+	return script
+}
+
+//rememberScript remember source of a script run with EVAL or SCRIPT LOAD
+func rememberScript(src interface{}) {
+	if v, ok := src.(string); ok {
+		newScript(v)
+	}
+}
+
+func hasNil(vals []interface{}) bool {
+	for _, v := range vals {
+		if v == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package cachelayer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMigration(t *testing.T) (*cachelayer.Migration, *redis.Client, *redis.Client) {
+	_, old := newMiniRedis(t)
+	_, red := newMiniRedis(t)
+	migration := cachelayer.NewMigration(old)
+	red.AddHook(migration)
+	return migration, old, red
+}
+
+func TestMigrationDualWrite(t *testing.T) {
+	migration, old, red := newMigration(t)
+	ctx := context.Background()
+	assert.Nil(t, red.Set(ctx, "a", "1", time.Minute).Err())
+	assert.Equal(t, "1", old.Get(ctx, "a").Val())
+	assert.Nil(t, old.Set(ctx, "b", "2", 0).Err())
+	v, err := red.Get(ctx, "b").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "2", v)
+	_, err = red.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, "a")
+		p.SAdd(ctx, "s", "x")
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), old.Exists(ctx, "a").Val())
+	assert.Equal(t, []string{"x"}, old.SMembers(ctx, "s").Val())
+	assert.Equal(t, cachelayer.MigrationStats{Mirrored: 3, Fallbacks: 1}, migration.Stats())
+
+	migration.Cutover()
+	assert.Nil(t, red.Set(ctx, "c", "3", 0).Err())
+	assert.Equal(t, int64(0), old.Exists(ctx, "c").Val())
+	assert.Equal(t, redis.Nil, red.Get(ctx, "b").Err())
+}
+
+func TestMigrationMirrorsEvalSha(t *testing.T) {
+	migration, old, red := newMigration(t)
+	ctx := context.Background()
+	script := redis.NewScript(`return redis.call('INCRBY', KEYS[1], ARGV[1])`)
+	// EVALSHA misses on the new redis, go-redis falls back to EVAL
+	assert.Nil(t, script.Run(ctx, red, []string{"n"}, 2).Err())
+	assert.Nil(t, old.ScriptFlush(ctx).Err())
+	// EVALSHA hits the new redis, the old one runs the script by its source
+	assert.Nil(t, script.Run(ctx, red, []string{"n"}, 3).Err())
+	assert.Equal(t, "5", red.Get(ctx, "n").Val())
+	assert.Equal(t, "5", old.Get(ctx, "n").Val())
+	assert.Equal(t, int64(0), migration.Stats().MirrorErrors)
+}
+
+func TestMigrationBackfill(t *testing.T) {
+	migration, old, red := newMigration(t)
+	ctx := context.Background()
+	assert.Nil(t, old.Set(ctx, "app/member/id/1", "tom", time.Minute).Err())
+	assert.Nil(t, old.HSet(ctx, "app/member/full", "1", "tom", "2", "ann").Err())
+	assert.Nil(t, old.SAdd(ctx, "app/member/refs", "a", "b").Err())
+	assert.Nil(t, old.Set(ctx, "app/member/id/2", "old", 0).Err())
+	assert.Nil(t, old.Set(ctx, "other/1", "x", 0).Err())
+	// written by the dual write, newer than the old copy
+	assert.Nil(t, red.Set(ctx, "app/member/id/2", "new", 0).Err())
+
+	n, err := migration.Backfill(ctx, red, "app/*", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+	// reads no longer fall back to the old redis
+	migration.Cutover()
+	assert.Equal(t, "tom", red.Get(ctx, "app/member/id/1").Val())
+	ttl := red.PTTL(ctx, "app/member/id/1").Val()
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+	assert.Equal(t, map[string]string{"1": "tom", "2": "ann"}, red.HGetAll(ctx, "app/member/full").Val())
+	assert.Equal(t, time.Duration(-1), red.PTTL(ctx, "app/member/full").Val())
+	assert.ElementsMatch(t, []string{"a", "b"}, red.SMembers(ctx, "app/member/refs").Val())
+	assert.Equal(t, "new", red.Get(ctx, "app/member/id/2").Val())
+	assert.Equal(t, int64(0), red.Exists(ctx, "other/1").Val())
+}
+
+//deleteAfterGet delete key once after it is read, like an invalidation racing the copy
+type deleteAfterGet struct {
+	red  *redis.Client
+	key  string
+	done bool
+}
+
+func (s *deleteAfterGet) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+func (s *deleteAfterGet) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if cmd.Name() == "get" && cmd.Args()[1] == s.key && !s.done {
+		s.done = true
+		return s.red.Del(ctx, s.key).Err()
+	}
+	return nil
+}
+func (s *deleteAfterGet) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+func (s *deleteAfterGet) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func TestMigrationBackfillInvalidated(t *testing.T) {
+	migration, old, red := newMigration(t)
+	ctx := context.Background()
+	assert.Nil(t, old.Set(ctx, "app/member/id/1", "tom", 0).Err())
+	old.AddHook(&deleteAfterGet{red: old, key: "app/member/id/1"})
+	n, err := migration.Backfill(ctx, red, "app/*", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+	migration.Cutover()
+	assert.Equal(t, int64(0), red.Exists(ctx, "app/member/id/1").Val())
+}
@@ -0,0 +1,85 @@
+package mongoredis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type aggregateResult struct {
+	Docs bson.RawValue `bson:"docs"`
+}
+
+//AggregateKey cache key of pipeline, eg. app/user/aggregate/{sha1 of pipeline}
+func (s *RedisMongo[T, I]) AggregateKey(pipeline interface{}) (string, error) {
+	bs, err := bson.MarshalExtJSON(bson.D{{Key: "pipeline", Value: pipeline}}, true, false)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.Sum(bs)
+	return s.MakeCacheKey(cachelayer.NewIndex("aggregate", hex.EncodeToString(h[:]))), nil
+}
+
+//AggregateCached run aggregation pipeline and decode documents into result(pointer to slice) like Cursor.All, decoded documents are cached for ttl(<=0 means cache ttl).
+// Use bson.D/mongo.Pipeline rather than bson.M in pipeline, so the cache key is stable. Cached results can be cleared by InvalidateTags(tags...)
+func (s *RedisMongo[T, I]) AggregateCached(pipeline interface{}, ttl time.Duration, result interface{}, tags ...string) error {
+	key, err := s.AggregateKey(pipeline)
+	if err != nil {
+		return err
+	}
+	s.GetHotKeyTracker().Record(key)
+	cached, err := s.red.Get(s.GetCtx(), key).Bytes()
+	if err != nil && err != redis.Nil {
+		return cachelayer.NewError(cachelayer.ErrCacheUnavailable, err)
+	}
+	if err == nil {
+		var r aggregateResult
+		if err = bson.Unmarshal(cached, &r); err != nil {
+			return cachelayer.NewError(cachelayer.ErrSerialization, err)
+		}
+		return r.Docs.Unmarshal(result)
+	}
+	cursor, err := s.m.reader().Aggregate(s.GetCtx(), pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(s.GetCtx())
+	docs := bson.A{}
+	for cursor.Next(s.GetCtx()) {
+		docs = append(docs, bson.Raw(append([]byte(nil), cursor.Current...)))
+	}
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+	bs, err := bson.Marshal(bson.D{{Key: "docs", Value: docs}})
+	if err != nil {
+		return cachelayer.NewError(cachelayer.ErrSerialization, err)
+	}
+	var r aggregateResult
+	if err = bson.Unmarshal(bs, &r); err != nil {
+		return cachelayer.NewError(cachelayer.ErrSerialization, err)
+	}
+	if err = r.Docs.Unmarshal(result); err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		ttl = s.red.GetDefaultTTL()
+	}
+	if err = s.red.Set(s.GetCtx(), key, bs, ttl).Err(); err != nil {
+		return cachelayer.NewError(cachelayer.ErrCacheUnavailable, err)
+	}
+	if err = s.AddTags(s.red.UniversalClient, ttl, key, tags...); err != nil {
+		return err
+	}
+	return nil
+}
+
+//InvalidateTags delete cached aggregation results tagged with any of tags
+func (s *RedisMongo[T, I]) InvalidateTags(tags ...string) error {
+	_, err := s.DeleteTags(s.red.UniversalClient, tags...)
+	return err
+}
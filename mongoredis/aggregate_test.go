@@ -0,0 +1,44 @@
+package mongoredis_test
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer/mongoredis"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestAggregateCached(t *testing.T) {
+	runMock(t, func(mt *mtest.T, cache *mongoredis.RedisMongo[Commodity, string], mr *miniredis.Miniredis) {
+		byCategory := mongo.Pipeline{{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$category"}, {Key: "n", Value: bson.D{{Key: "$sum", Value: 1}}}}}}}
+		byCountry := mongo.Pipeline{{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$addr.country"}, {Key: "n", Value: bson.D{{Key: "$sum", Value: 1}}}}}}}
+		groups := func(n int32) bson.D {
+			return mtest.CreateCursorResponse(0, "test.commodity", mtest.FirstBatch, bson.D{{Key: "_id", Value: 1}, {Key: "n", Value: n}})
+		}
+		mt.AddMockResponses(groups(3))
+		var r []bson.M
+		assert.Nil(mt, cache.AggregateCached(byCategory, 0, &r, "dashboard"))
+		assert.Equal(mt, []bson.M{{"_id": int32(1), "n": int32(3)}}, r)
+		key, err := cache.AggregateKey(byCategory)
+		assert.Nil(mt, err)
+		assert.True(mt, mr.Exists(key))
+		other, err := cache.AggregateKey(byCountry)
+		assert.Nil(mt, err)
+		assert.NotEqual(mt, key, other)
+
+		// no reply is queued, the result must come from redis
+		r = nil
+		assert.Nil(mt, cache.AggregateCached(byCategory, 0, &r, "dashboard"))
+		assert.Equal(mt, []bson.M{{"_id": int32(1), "n": int32(3)}}, r)
+
+		assert.Nil(mt, cache.InvalidateTags("dashboard"))
+		assert.False(mt, mr.Exists(key))
+		mt.AddMockResponses(groups(4))
+		r = nil
+		assert.Nil(mt, cache.AggregateCached(byCategory, 0, &r, "dashboard"))
+		assert.Equal(mt, []bson.M{{"_id": int32(1), "n": int32(4)}}, r)
+	})
+}
@@ -0,0 +1,120 @@
+package mongoredis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/daqiancode/cachelayer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//filterTag tag of cached filter results, cleared on every write since range conditions can't be matched to changed records
+const filterTag = cachelayer.FilterTag
+
+//IsEqualityFilter true if every condition of filter is field == value
+func IsEqualityFilter(filter cachelayer.Index) bool {
+	for _, v := range filter {
+		switch v.(type) {
+		case bson.M, map[string]interface{}, bson.D, primitive.Regex:
+			return false
+		}
+	}
+	return true
+}
+
+//FilterKey deterministic key of filter, sort and limit: maps are sorted by key and $in/$nin values are sorted, eg. {"age": bson.M{"$gte": 18, "$lt": 30}} == {"age": bson.D{{"$lt", 30}, {"$gte", 18}}}
+func FilterKey(filter cachelayer.Index, orderBys cachelayer.OrderBys, limit int64) string {
+	s := normalizeFilter("", map[string]interface{}(filter)) + "|sort:" + orderBys.String() + "|limit:" + strconv.FormatInt(limit, 10)
+	h := sha1.Sum([]byte(s))
+	return "filter/" + hex.EncodeToString(h[:])
+}
+
+func normalizeFilter(key string, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return normalizeDoc(v)
+	case bson.M:
+		return normalizeDoc(v)
+	case cachelayer.Index:
+		return normalizeDoc(v)
+	case bson.D:
+		m := make(map[string]interface{}, len(v))
+		for _, e := range v {
+			m[e.Key] = e.Value
+		}
+		return normalizeDoc(m)
+	case primitive.Regex:
+		return "regex:/" + v.Pattern + "/" + v.Options
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = normalizeFilter("", rv.Index(i).Interface())
+		}
+		if key == "$in" || key == "$nin" {
+			sort.Strings(items)
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	}
+	return fmt.Sprintf("%T:%s", value, cachelayer.Stringify(value, "null"))
+}
+
+func normalizeDoc(m map[string]interface{}) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := make([]string, len(keys))
+	for i, k := range keys {
+		items[i] = strconv.Quote(k) + ":" + normalizeFilter(k, m[k])
+	}
+	return "{" + strings.Join(items, ",") + "}"
+}
+
+//ListByFilter list records matching filter, filter values can be query operators, eg. {"age": bson.M{"$gte": 18}, "name": primitive.Regex{Pattern: "^a"}, "type": bson.M{"$in": []int{1, 2}}}, limit <= 0 means no limit
+func (s *Mongo[T, I]) ListByFilter(filter cachelayer.Index, orderBys cachelayer.OrderBys, limit int64) ([]T, error) {
+	var t []T
+	opts := options.Find()
+	if len(orderBys) > 0 {
+		ds := make(bson.D, len(orderBys))
+		for i, v := range orderBys {
+			order := -1
+			if v.Asc {
+				order = 1
+			}
+			ds[i] = bson.E{Key: s.field(v.Field), Value: order}
+		}
+		opts.SetSort(ds)
+	}
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	r, err := s.reader().Find(s.ctx, s.filter(filter), opts)
+	if err != nil {
+		return t, err
+	}
+	err = r.All(s.ctx, &t)
+	return t, err
+}
+
+//ListByFilter list records matching filter with cache, see Mongo.ListByFilter. Results of pure equality filters without limit are cached like ListBy,
+// others are cached under a normalized filter key and cleared on every write of the collection
+func (s *RedisMongo[T, I]) ListByFilter(filter cachelayer.Index, orderBys cachelayer.OrderBys, limit int64) ([]T, error) {
+	if limit <= 0 && IsEqualityFilter(filter) {
+		return s.cache.ListBy(filter, orderBys)
+	}
+	return s.cache.CachedQuery(FilterKey(filter, orderBys, limit), 0, func() ([]T, error) {
+		return s.m.ListByFilter(filter, orderBys, limit)
+	}, filterTag)
+}
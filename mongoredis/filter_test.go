@@ -0,0 +1,24 @@
+package mongoredis_test
+
+import (
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/mongoredis"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFilterKey(t *testing.T) {
+	a := cachelayer.Index{"age": bson.M{"$gte": 18, "$lt": 30}, "category": bson.M{"$in": []int{2, 1}}}
+	b := cachelayer.Index{"category": bson.D{{Key: "$in", Value: []int{1, 2}}}, "age": bson.D{{Key: "$lt", Value: 30}, {Key: "$gte", Value: 18}}}
+	assert.Equal(t, mongoredis.FilterKey(a, nil, 10), mongoredis.FilterKey(b, nil, 10))
+	assert.NotEqual(t, mongoredis.FilterKey(a, nil, 10), mongoredis.FilterKey(a, nil, 0))
+	assert.NotEqual(t, mongoredis.FilterKey(a, nil, 0), mongoredis.FilterKey(a, cachelayer.NewOrderBys("age", true), 0))
+	assert.NotEqual(t, mongoredis.FilterKey(cachelayer.Index{"age": 18}, nil, 0), mongoredis.FilterKey(cachelayer.Index{"age": "18"}, nil, 0))
+	assert.NotEqual(t, mongoredis.FilterKey(cachelayer.Index{"name": primitive.Regex{Pattern: "^A"}}, nil, 0), mongoredis.FilterKey(cachelayer.Index{"name": primitive.Regex{Pattern: "^a"}}, nil, 0))
+
+	assert.True(t, mongoredis.IsEqualityFilter(cachelayer.Index{"age": 18, "name": "a"}))
+	assert.False(t, mongoredis.IsEqualityFilter(a))
+}
@@ -0,0 +1,40 @@
+package mongoredis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/daqiancode/cachelayer"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+//SetReconnect connect a disconnected client again on Ping, eg. a client created by mongo.NewClient without Connect, so
+// health checks bring it back. Off by default, as it undoes Close
+func (s *Mongo[T, I]) SetReconnect(enabled bool) {
+	s.reconnect = enabled
+}
+
+//Ping ping the primary, see cachelayer.Pinger
+func (s *Mongo[T, I]) Ping(ctx context.Context) error {
+	err := s.db.Ping(ctx, readpref.Primary())
+	if !s.reconnect || !errors.Is(err, mongo.ErrClientDisconnected) {
+		return err
+	}
+	// a concurrent Ping may have connected it already, the second ping decides
+	_ = s.db.Connect(ctx)
+	return s.db.Ping(ctx, readpref.Primary())
+}
+
+//SetReconnect see Mongo.SetReconnect
+func (s *RedisMongo[T, I]) SetReconnect(enabled bool) {
+	s.m.SetReconnect(enabled)
+}
+
+//Ping check redis by PING and the primary of mongo, see cachelayer.Pinger
+func (s *RedisMongo[T, I]) Ping(ctx context.Context) error {
+	if err := s.red.Ping(ctx).Err(); err != nil {
+		return cachelayer.NewError(cachelayer.ErrCacheUnavailable, err)
+	}
+	return s.m.Ping(ctx)
+}
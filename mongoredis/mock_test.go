@@ -0,0 +1,24 @@
+package mongoredis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer/mongoredis"
+	"github.com/go-redis/redis/v8"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+//runMock run fn with a RedisMongo of commodities on a mock mongo deployment, replies are queued by mt.AddMockResponses
+// and a command without a queued reply fails, so cache hits are told from database reads
+func runMock(t *testing.T, fn func(mt *mtest.T, cache *mongoredis.RedisMongo[Commodity, string], mr *miniredis.Miniredis)) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("mock", func(mt *mtest.T) {
+		mr := miniredis.RunT(mt.T)
+		red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		defer red.Close()
+		fn(mt, mongoredis.NewRedisMongo[Commodity, string]("app", "test", "commodity", "Id", mt.Client, red, time.Minute), mr)
+	})
+}
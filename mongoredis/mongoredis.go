@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/daqiancode/cachelayer"
@@ -14,7 +17,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func NewMongoRedis[T cachelayer.Table[I], I cachelayer.IDType](prefix, database, collection, idField string, db *mongo.Client, red *redis.Client, ttl time.Duration) *cachelayer.RedisCache[T, I] {
+func NewMongoRedis[T cachelayer.Table[I], I cachelayer.IDType](prefix, database, collection, idField string, db *mongo.Client, red redis.UniversalClient, ttl time.Duration) *cachelayer.RedisCache[T, I] {
 	m := &Mongo[T, I]{
 		db:         db,
 		idField:    idField,
@@ -22,12 +25,13 @@ func NewMongoRedis[T cachelayer.Table[I], I cachelayer.IDType](prefix, database,
 		database:   database,
 		collection: collection,
 		c:          db.Database(database).Collection(collection),
+		shapes:     &sync.Map{},
 	}
 	rc := cachelayer.NewRedisCache[T, I](prefix, collection, idField, m, red, ttl)
 	return rc
 }
 
-func NewMongoRedisFull[T cachelayer.Table[I], I cachelayer.IDType](prefix, database, collection, idField string, db *mongo.Client, red *redis.Client, ttl time.Duration) *cachelayer.FullRedisCache[T, I] {
+func NewMongoRedisFull[T cachelayer.Table[I], I cachelayer.IDType](prefix, database, collection, idField string, db *mongo.Client, red redis.UniversalClient, ttl time.Duration) *cachelayer.FullRedisCache[T, I] {
 	m := &Mongo[T, I]{
 		db:         db,
 		idField:    idField,
@@ -35,6 +39,7 @@ func NewMongoRedisFull[T cachelayer.Table[I], I cachelayer.IDType](prefix, datab
 		database:   database,
 		collection: collection,
 		c:          db.Database(database).Collection(collection),
+		shapes:     &sync.Map{},
 	}
 	rc := cachelayer.NewFullRedisCache[T, I](prefix, collection, idField, m, red, ttl)
 	return rc
@@ -47,6 +52,93 @@ type Mongo[T cachelayer.Table[I], I cachelayer.IDType] struct {
 	database   string
 	collection string
 	c          *mongo.Collection
+	readC      *mongo.Collection
+	//idGenerator ids of new documents, default ObjectID hex, or UUIDv7 for cachelayer.UUID ids
+	idGenerator func() I
+	//nullID which ids are null on Create, Save and Upsert, nil means cachelayer.IsNullID
+	nullID func(id I) bool
+	//nameMapper document fields of index fields, nil means the bson fields of T
+	nameMapper cachelayer.NameMapper
+	//shapes document fields by index shape, see filter
+	shapes *sync.Map
+	//reconnect connect a disconnected client again on Ping
+	reconnect bool
+}
+
+//SetNameMapper translate fields of indexes, filters and orders into document fields by mapper instead of the bson fields of T,
+// eg. cachelayer.CamelCase to query CategoryId as categoryId. Fields starting with "_" or "$" are kept as is
+func (s *Mongo[T, I]) SetNameMapper(mapper cachelayer.NameMapper) {
+	s.nameMapper = mapper
+	s.shapes = &sync.Map{}
+}
+
+//field document field of an index field: the bson field of the field of T(go name or json, bson, column name), eg. UserId with
+// `bson:"uid"` is uid and Name without bson tag is name as the driver lower cases it. Unknown fields and paths are kept as is
+func (s *Mongo[T, I]) field(name string) string {
+	if strings.HasPrefix(name, "_") || strings.HasPrefix(name, "$") {
+		return name
+	}
+	if s.nameMapper != nil {
+		return s.nameMapper(name)
+	}
+	f, ok := cachelayer.LookupField[T](name)
+	if !ok {
+		return name
+	}
+	if v := strings.Split(f.Tag.Get("bson"), ",")[0]; v != "" && v != "-" {
+		return v
+	}
+	return strings.ToLower(f.Name)
+}
+
+//filter filter of index ordered by field. Document fields are translated once per index shape(its fields), so misses under
+// high QPS don't look up fields of T again
+func (s *Mongo[T, I]) filter(index cachelayer.Index) bson.D {
+	fields := index.Fields()
+	sort.Strings(fields)
+	key := strings.Join(fields, ",")
+	var docFields []string
+	if v, ok := s.shapes.Load(key); ok {
+		docFields = v.([]string)
+	} else {
+		docFields = make([]string, len(fields))
+		for i, v := range fields {
+			docFields[i] = s.field(v)
+		}
+		s.shapes.Store(key, docFields)
+	}
+	r := make(bson.D, len(fields))
+	for i, v := range fields {
+		r[i] = bson.E{Key: docFields[i], Value: index[v]}
+	}
+	return r
+}
+
+//SetIDGenerator generate ids of documents created without id
+func (s *Mongo[T, I]) SetIDGenerator(fn func() I) {
+	s.idGenerator = fn
+}
+
+//newID id of a new document
+func (s *Mongo[T, I]) newID() I {
+	if s.idGenerator != nil {
+		return s.idGenerator()
+	}
+	var id I
+	switch v := interface{}(&id).(type) {
+	case *cachelayer.UUID:
+		*v = cachelayer.NewUUIDv7()
+	default:
+		reflect.ValueOf(&id).Elem().SetString(primitive.NewObjectID().Hex())
+	}
+	return id
+}
+
+//WithContext copy of s whose queries carry ctx, see cachelayer.ContextDB
+func (s *Mongo[T, I]) WithContext(ctx context.Context) cachelayer.DBCRUD[T, I] {
+	r := *s
+	r.ctx = ctx
+	return &r
 }
 
 func (s *Mongo[T, I]) Close() error {
@@ -57,49 +149,163 @@ func (s *Mongo[T, I]) DB() *mongo.Client {
 	return s.db
 }
 
+func (s *Mongo[T, I]) Collection() *mongo.Collection {
+	return s.c
+}
+
+//SetNullIDFunc which ids are null(not assigned yet) on Create, Save and Upsert, set by SetNullIDFunc of the cache
+func (s *Mongo[T, I]) SetNullIDFunc(fn func(id I) bool) {
+	s.nullID = fn
+}
+
+//isNullID whether id is null by the NullIDFunc, default cachelayer.IsNullID
+func (s *Mongo[T, I]) isNullID(id I) bool {
+	if s.nullID != nil {
+		return s.nullID(id)
+	}
+	return cachelayer.IsNullID(id)
+}
+
 func (s *Mongo[T, I]) Create(t *T) error {
-	if cachelayer.IsNullID((*t).GetID()) {
-		reflect.ValueOf(t).Elem().FieldByName(s.idField).SetString(primitive.NewObjectID().Hex())
+	if s.isNullID((*t).GetID()) {
+		reflect.ValueOf(t).Elem().FieldByName(s.idField).Set(reflect.ValueOf(s.newID()))
 	}
 	_, err := s.c.InsertOne(s.ctx, *t)
+	if mongo.IsDuplicateKeyError(err) {
+		return cachelayer.NewError(cachelayer.ErrConflict, err)
+	}
 	return err
 }
 
+//Save create t if its id is null, otherwise upsert it on _id
 func (s *Mongo[T, I]) Save(t *T) error {
 	if t == nil {
 		return nil
 	}
-	id := (*t).GetID()
-	if cachelayer.IsNullID(id) {
+	if s.isNullID((*t).GetID()) {
 		return s.Create(t)
 	}
-	_, exist, err := s.Get(id)
+	_, _, err := s.Upsert(t)
+	return err
+}
+
+//upsertAttempts attempts of Upsert when concurrent upserts insert the same document and one fails with a duplicate key
+const upsertAttempts = 2
+
+//Upsert replace the document matching t on conflictFields(_id if empty) or insert t, return the replaced document.
+// Both happen in one atomic findAndModify: fields of t are set on the matching document, which keeps its _id and t takes it,
+// or t is inserted with its id or a new one. Conflict fields other than _id need a unique index, then a duplicate key of concurrent
+// upserts is retried and the second one updates the document inserted by the first
+func (s *Mongo[T, I]) Upsert(t *T, conflictFields ...string) (T, bool, error) {
+	var old T
+	if len(conflictFields) == 0 {
+		conflictFields = []string{"_id"}
+	}
+	id := (*t).GetID()
+	if s.isNullID(id) {
+		id = s.newID()
+	}
+	raw, err := bson.Marshal(*t)
 	if err != nil {
-		return nil
+		return old, false, err
 	}
-	if !exist {
-		return s.Create(t)
+	var doc bson.M
+	if err = bson.Unmarshal(raw, &doc); err != nil {
+		return old, false, err
 	}
-	query := bson.M{"_id": id}
-	err = s.c.FindOneAndReplace(s.ctx, query, *t).Err()
-	return err
+	doc["_id"] = id
+	filter := bson.M{}
+	for _, v := range conflictFields {
+		filter[v] = doc[v]
+	}
+	delete(doc, "_id")
+	update := bson.M{"$set": doc}
+	if _, ok := filter["_id"]; !ok {
+		update["$setOnInsert"] = bson.M{"_id": id}
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	var r *mongo.SingleResult
+	for i := 0; i < upsertAttempts; i++ {
+		r = s.c.FindOneAndUpdate(s.ctx, filter, update, opts)
+		if err = r.Err(); !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	if err == mongo.ErrNoDocuments {
+		reflect.ValueOf(t).Elem().FieldByName(s.idField).Set(reflect.ValueOf(id))
+		return old, false, nil
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return old, false, cachelayer.NewError(cachelayer.ErrConflict, err)
+	}
+	if err != nil {
+		return old, false, err
+	}
+	if err = r.Decode(&old); err != nil {
+		return old, false, err
+	}
+	reflect.ValueOf(t).Elem().FieldByName(s.idField).Set(reflect.ValueOf(old.GetID()))
+	return old, true, nil
 }
 
-func (s *Mongo[T, I]) Update(id I, values interface{}) (int64, error) {
-	if cachelayer.IsNullID(id) {
-		return 0, nil
+func (s *Mongo[T, I]) findOne(c *mongo.Collection, filter interface{}) (T, bool, error) {
+	var t T
+	r := c.FindOne(s.ctx, filter)
+	if err := r.Err(); err != nil {
+		if mongo.ErrNoDocuments == err {
+			return t, false, nil
+		}
+		return t, false, err
 	}
-	var setD bson.D
-	if m, ok := values.(map[string]interface{}); ok {
-		for k, v := range m {
-			setD = append(setD, bson.E{Key: k, Value: v})
+	err := r.Decode(&t)
+	return t, true, err
+}
+
+//UpdateDocument build mongo update document from values: fields are wrapped into $set, values with update operators($inc, $push, $pull, $unset, $addToSet ...) are passed through.
+// values type: map[string]interface{}, bson.M or bson.D, eg. bson.M{"$inc": bson.M{"views": 1}, "$set": bson.M{"name": "a"}}
+func UpdateDocument(values interface{}) (interface{}, error) {
+	var d bson.D
+	switch v := values.(type) {
+	case map[string]interface{}:
+		for k, u := range v {
+			d = append(d, bson.E{Key: k, Value: u})
 		}
-	} else {
-		return 0, errors.New("RedisMongo.Update not support this type of update values, only support map[string]interface{}")
+	case bson.M:
+		for k, u := range v {
+			d = append(d, bson.E{Key: k, Value: u})
+		}
+	case bson.D:
+		d = v
+	default:
+		return nil, errors.New("mongoredis: not support this type of update values, only support map[string]interface{}, bson.M and bson.D")
+	}
+	operators := 0
+	for _, e := range d {
+		if strings.HasPrefix(e.Key, "$") {
+			operators++
+		}
+	}
+	if operators == 0 {
+		return bson.D{{Key: "$set", Value: d}}, nil
+	}
+	if operators != len(d) {
+		return nil, errors.New("mongoredis: update values can not mix fields and update operators")
 	}
-	setValues := bson.D{{Key: "$set", Value: setD}}
-	rs, err := s.c.UpdateOne(s.ctx, bson.M{"_id": id}, setValues)
+	return d, nil
+}
 
+func (s *Mongo[T, I]) Update(id I, values interface{}) (int64, error) {
+	if s.isNullID(id) {
+		return 0, nil
+	}
+	update, err := UpdateDocument(values)
+	if err != nil {
+		return 0, err
+	}
+	rs, err := s.c.UpdateOne(s.ctx, bson.M{"_id": id}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return 0, cachelayer.NewError(cachelayer.ErrConflict, err)
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -129,21 +335,34 @@ func (s *Mongo[T, I]) Delete(ids ...I) (int64, error) {
 
 	return rs.DeletedCount, err
 }
-func (s *Mongo[T, I]) Get(id I) (T, bool, error) {
-	var t T
-	r := s.c.FindOne(s.ctx, bson.M{"_id": id})
-	if err := r.Err(); err != nil {
-		if mongo.ErrNoDocuments == err {
-			return t, false, nil
+
+//DeleteReturning delete documents of ids and return them, see cachelayer.DeleteReturner. Each document is deleted by FindOneAndDelete,
+// so the returned version is exactly the deleted one
+func (s *Mongo[T, I]) DeleteReturning(ids ...I) ([]T, error) {
+	var r []T
+	for _, id := range ids {
+		var t T
+		err := s.c.FindOneAndDelete(s.ctx, bson.M{"_id": id}).Decode(&t)
+		if err == mongo.ErrNoDocuments {
+			continue
 		}
-		return t, false, err
+		if err != nil {
+			return r, err
+		}
+		r = append(r, t)
 	}
-	err := r.Decode(&t)
-	return t, true, err
+	return r, nil
+}
+
+func (s *Mongo[T, I]) Get(id I) (T, bool, error) {
+	return s.getFrom(s.reader(), id)
+}
+func (s *Mongo[T, I]) getFrom(c *mongo.Collection, id I) (T, bool, error) {
+	return s.findOne(c, bson.M{"_id": id})
 }
 func (s *Mongo[T, I]) GetBy(index cachelayer.Index) (T, bool, error) {
 	var t T
-	r := s.c.FindOne(s.ctx, index)
+	r := s.reader().FindOne(s.ctx, s.filter(index))
 	if err := r.Err(); err != nil {
 		if mongo.ErrNoDocuments == err {
 			return t, false, nil
@@ -157,7 +376,7 @@ func (s *Mongo[T, I]) List(ids ...I) ([]T, error) {
 	var t []T
 	var err error
 	query := bson.M{"_id": bson.M{"$in": ids}}
-	r, err := s.c.Find(s.ctx, query)
+	r, err := s.reader().Find(s.ctx, query)
 	if err != nil {
 		return t, err
 	}
@@ -165,6 +384,16 @@ func (s *Mongo[T, I]) List(ids ...I) ([]T, error) {
 	err = r.All(s.ctx, &t)
 	return t, err
 }
+//ListByIn documents whose field is one of values, see cachelayer.InLister
+func (s *Mongo[T, I]) ListByIn(field string, values []interface{}) ([]T, error) {
+	var t []T
+	r, err := s.reader().Find(s.ctx, bson.M{s.field(field): bson.M{"$in": values}})
+	if err != nil {
+		return t, err
+	}
+	err = r.All(s.ctx, &t)
+	return t, err
+}
 func (s *Mongo[T, I]) ListBy(index cachelayer.Index, orderBys cachelayer.OrderBys) ([]T, error) {
 	var t []T
 	var err error
@@ -180,12 +409,12 @@ func (s *Mongo[T, I]) ListBy(index cachelayer.Index, orderBys cachelayer.OrderBy
 	if len(orderBys) > 0 {
 		ds := make([]bson.E, len(orderBys))
 		for i, v := range orderBys {
-			ds[i] = bson.E{Key: v.Field, Value: v.Asc}
+			ds[i] = bson.E{Key: s.field(v.Field), Value: v.Asc}
 		}
 		opts = options.Find().SetSort(ds)
 	}
 
-	r, err := s.c.Find(s.ctx, index, opts)
+	r, err := s.reader().Find(s.ctx, s.filter(index), opts)
 	if err != nil {
 		return t, err
 	}
@@ -193,12 +422,124 @@ func (s *Mongo[T, I]) ListBy(index cachelayer.Index, orderBys cachelayer.OrderBy
 	err = r.All(s.ctx, &t)
 	return t, err
 }
+
+//ListByAny documents matching any of the alternatives of anyOf by $or, see cachelayer.AnyLister
+func (s *Mongo[T, I]) ListByAny(anyOf cachelayer.AnyOf, orderBys cachelayer.OrderBys) ([]T, error) {
+	var t []T
+	opts := options.Find()
+	if len(orderBys) > 0 {
+		ds := make(bson.D, len(orderBys))
+		for i, v := range orderBys {
+			order := -1
+			if v.Asc {
+				order = 1
+			}
+			ds[i] = bson.E{Key: s.field(v.Field), Value: order}
+		}
+		opts.SetSort(ds)
+	}
+	alts := make(bson.A, len(anyOf))
+	for i, v := range anyOf {
+		alts[i] = s.filter(v)
+	}
+	r, err := s.reader().Find(s.ctx, bson.M{"$or": alts}, opts)
+	if err != nil {
+		return t, err
+	}
+	err = r.All(s.ctx, &t)
+	return t, err
+}
+
+//Count documents of index, see cachelayer.Counter
+func (s *Mongo[T, I]) Count(index cachelayer.Index) (int64, error) {
+	return s.reader().CountDocuments(s.ctx, s.filter(index))
+}
+
+//ListWhere documents matching filter, see cachelayer.FilterLister
+func (s *Mongo[T, I]) ListWhere(filter *cachelayer.Filter) ([]T, error) {
+	conds := bson.A{}
+	for _, v := range filter.Conds() {
+		switch v.Op {
+		case cachelayer.FilterEq:
+			conds = append(conds, bson.M{s.field(v.Field): v.Values[0]})
+		case cachelayer.FilterIn:
+			conds = append(conds, bson.M{s.field(v.Field): bson.M{"$in": v.Values}})
+		case cachelayer.FilterRange:
+			r := bson.M{}
+			if v.Values[0] != nil {
+				r["$gte"] = v.Values[0]
+			}
+			if v.Values[1] != nil {
+				r["$lt"] = v.Values[1]
+			}
+			if len(r) > 0 {
+				conds = append(conds, bson.M{s.field(v.Field): r})
+			}
+		}
+	}
+	query := bson.M{}
+	if len(conds) > 0 {
+		query["$and"] = conds
+	}
+	var t []T
+	opts := options.Find()
+	if len(filter.OrderBys()) > 0 {
+		ds := make(bson.D, len(filter.OrderBys()))
+		for i, v := range filter.OrderBys() {
+			order := -1
+			if v.Asc {
+				order = 1
+			}
+			ds[i] = bson.E{Key: s.field(v.Field), Value: order}
+		}
+		opts.SetSort(ds)
+	}
+	if filter.GetLimit() > 0 {
+		opts.SetLimit(int64(filter.GetLimit()))
+	}
+	r, err := s.reader().Find(s.ctx, query, opts)
+	if err != nil {
+		return t, err
+	}
+	err = r.All(s.ctx, &t)
+	return t, err
+}
 func (s *Mongo[T, I]) ListAll() ([]T, error) {
 	var t []T
-	r, err := s.c.Find(s.ctx, bson.D{})
+	r, err := s.reader().Find(s.ctx, bson.D{})
 	if err != nil {
 		return t, err
 	}
 	err = r.All(s.ctx, &t)
 	return t, err
 }
+
+//ListAllInBatches stream all records with a cursor, batch is reused between calls of fn
+func (s *Mongo[T, I]) ListAllInBatches(batchSize int, fn func(batch []T) error) error {
+	r, err := s.reader().Find(s.ctx, bson.D{}, options.Find().SetBatchSize(int32(batchSize)))
+	if err != nil {
+		return err
+	}
+	defer r.Close(s.ctx)
+	batch := make([]T, 0, batchSize)
+	for r.Next(s.ctx) {
+		var t T
+		if err = r.Decode(&t); err != nil {
+			return err
+		}
+		batch = append(batch, t)
+		if len(batch) >= batchSize {
+			if err = fn(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err = r.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
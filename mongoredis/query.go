@@ -0,0 +1,58 @@
+package mongoredis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//CachedQuery run custom query fn with the cache's collection and cache the result under key, see RedisCache.CachedQuery
+func CachedQuery[T cachelayer.Table[I], I cachelayer.IDType](cache *cachelayer.RedisCache[T, I], key string, ttl time.Duration, fn func(c *mongo.Collection) ([]T, error), tags ...string) ([]T, error) {
+	m, ok := cache.GetDB().(*Mongo[T, I])
+	if !ok {
+		return nil, errors.New("mongoredis.CachedQuery: cache is not backed by mongo")
+	}
+	return cache.CachedQuery(key, ttl, func() ([]T, error) {
+		return fn(m.Collection())
+	}, tags...)
+}
+
+//CachedRecent run fn of documents within [from, to) and cache the result per time bucket, see RedisCache.CachedRecent
+func CachedRecent[T cachelayer.Table[I], I cachelayer.IDType](cache *cachelayer.RedisCache[T, I], key string, window, bucket time.Duration, fn func(c *mongo.Collection, from, to time.Time) ([]T, error), tags ...string) ([]T, error) {
+	m, ok := cache.GetDB().(*Mongo[T, I])
+	if !ok {
+		return nil, errors.New("mongoredis.CachedRecent: cache is not backed by mongo")
+	}
+	return cache.CachedRecent(key, window, bucket, func(from, to time.Time) ([]T, error) {
+		return fn(m.Collection(), from, to)
+	}, tags...)
+}
+
+//CachedFind cache records matching filter under key, eg. CachedFind(cache, "adults", time.Hour, bson.M{"age": bson.M{"$gte": 18}}, nil, "age")
+func CachedFind[T cachelayer.Table[I], I cachelayer.IDType](cache *cachelayer.RedisCache[T, I], key string, ttl time.Duration, filter interface{}, opts *options.FindOptions, tags ...string) ([]T, error) {
+	return CachedQuery(cache, key, ttl, func(c *mongo.Collection) ([]T, error) {
+		var t []T
+		r, err := c.Find(cache.GetCtx(), filter, opts)
+		if err != nil {
+			return t, err
+		}
+		err = r.All(cache.GetCtx(), &t)
+		return t, err
+	}, tags...)
+}
+
+//CachedAggregate cache records returned by aggregation pipeline under key, the pipeline must output documents of T
+func CachedAggregate[T cachelayer.Table[I], I cachelayer.IDType](cache *cachelayer.RedisCache[T, I], key string, ttl time.Duration, pipeline interface{}, tags ...string) ([]T, error) {
+	return CachedQuery(cache, key, ttl, func(c *mongo.Collection) ([]T, error) {
+		var t []T
+		r, err := c.Aggregate(cache.GetCtx(), pipeline)
+		if err != nil {
+			return t, err
+		}
+		err = r.All(cache.GetCtx(), &t)
+		return t, err
+	}, tags...)
+}
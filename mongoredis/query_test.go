@@ -0,0 +1,35 @@
+package mongoredis_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer/mongoredis"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestCachedFindContext(t *testing.T) {
+	mr := miniredis.RunT(t)
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer red.Close()
+	// nothing listens, server selection only ends by its timeout or the context
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1").SetServerSelectionTimeout(time.Minute))
+	assert.Nil(t, err)
+	defer client.Disconnect(context.Background())
+	cache := mongoredis.NewMongoRedis[Commodity, string]("app", "test", "commodity", "Id", client, red, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = mongoredis.CachedFind(cache.WithContext(ctx), "all", time.Minute, bson.M{}, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	_, err = mongoredis.CachedAggregate(cache.WithContext(ctx), "all", time.Minute, mongo.Pipeline{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,83 @@
+package mongoredis
+
+import (
+	"github.com/daqiancode/cachelayer"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+//SetReadPreference read preference of queries, eg. readpref.SecondaryPreferred(). Writes always go to primary
+func (s *Mongo[T, I]) SetReadPreference(rp *readpref.ReadPref) error {
+	c, err := s.c.Clone(options.Collection().SetReadPreference(rp))
+	if err != nil {
+		return err
+	}
+	s.readC = c
+	return nil
+}
+
+//WithReadPreference return a copy reading with rp
+func (s *Mongo[T, I]) WithReadPreference(rp *readpref.ReadPref) (*Mongo[T, I], error) {
+	r := *s
+	if err := r.SetReadPreference(rp); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+//Primary copy of s reading from primary, see cachelayer.PrimaryDB
+func (s *Mongo[T, I]) Primary() cachelayer.DBCRUD[T, I] {
+	r := *s
+	r.readC = s.writeReader(true)
+	return &r
+}
+
+func (s *Mongo[T, I]) reader() *mongo.Collection {
+	if s.readC != nil {
+		return s.readC
+	}
+	return s.c
+}
+
+//writeReader collection to read back a record just written, primary if readYourWrites
+func (s *Mongo[T, I]) writeReader(readYourWrites bool) *mongo.Collection {
+	if !readYourWrites {
+		return s.reader()
+	}
+	c, err := s.c.Clone(options.Collection().SetReadPreference(readpref.Primary()))
+	if err != nil {
+		return s.reader()
+	}
+	return c
+}
+
+//SetReadPreference read preference of database queries on cache misses, eg. readpref.SecondaryPreferred()
+func (s *RedisMongo[T, I]) SetReadPreference(rp *readpref.ReadPref) error {
+	return s.m.SetReadPreference(rp)
+}
+
+//SetReadYourWrites read the record from primary after Update, so the re-cached value isn't a stale secondary read
+func (s *RedisMongo[T, I]) SetReadYourWrites(enabled bool) {
+	s.readYourWrites = enabled
+}
+
+//WithReadPreference return a copy of the cache whose database queries use rp, eg. cache.WithReadPreference(readpref.Primary()).Get(id)
+func (s *RedisMongo[T, I]) WithReadPreference(rp *readpref.ReadPref) (*RedisMongo[T, I], error) {
+	m, err := s.m.WithReadPreference(rp)
+	if err != nil {
+		return nil, err
+	}
+	r := *s
+	r.m = m
+	r.cache = s.cache.WithDB(m)
+	return &r, nil
+}
+
+//StrongRead return a copy of the cache whose reads skip redis and query the primary, see cachelayer.RedisCache.StrongRead
+func (s *RedisMongo[T, I]) StrongRead() *RedisMongo[T, I] {
+	r := *s
+	r.m = s.m.Primary().(*Mongo[T, I])
+	r.cache = s.cache.WithDB(r.m).StrongRead()
+	return &r
+}
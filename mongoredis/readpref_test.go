@@ -0,0 +1,73 @@
+package mongoredis_test
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer/mongoredis"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+//readMode read preference mode of the next started command, primary if the command has none.
+// Reads from primary are sent as primaryPreferred to a single server like the mock deployment
+func readMode(mt *mtest.T) string {
+	e := mt.GetStartedEvent()
+	if e == nil {
+		return ""
+	}
+	v, err := e.Command.LookupErr("$readPreference", "mode")
+	if err != nil || v.StringValue() == "primaryPreferred" {
+		return "primary"
+	}
+	return v.StringValue()
+}
+
+func TestReadPreference(t *testing.T) {
+	runMock(t, func(mt *mtest.T, cache *mongoredis.RedisMongo[Commodity, string], mr *miniredis.Miniredis) {
+		apple := Commodity{Id: "a", Name: "apple", Category: 1}
+		updated := bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}}
+		assert.Nil(mt, cache.SetReadPreference(readpref.SecondaryPreferred()))
+		mt.ClearEvents()
+		mt.AddMockResponses(commodities(apple))
+		_, _, err := cache.Get("a")
+		assert.Nil(mt, err)
+		assert.Equal(mt, "secondaryPreferred", readMode(mt))
+
+		// the record read back after a write comes from a secondary unless ReadYourWrites
+		mt.ClearEvents()
+		mt.AddMockResponses(updated, commodities(apple))
+		_, err = cache.Update("a", map[string]interface{}{"name": "apple"})
+		assert.Nil(mt, err)
+		assert.Equal(mt, "primary", readMode(mt))
+		assert.Equal(mt, "secondaryPreferred", readMode(mt))
+		cache.SetReadYourWrites(true)
+		mt.ClearEvents()
+		mt.AddMockResponses(commodities(apple), updated, commodities(apple))
+		_, err = cache.Update("a", map[string]interface{}{"name": "apple"})
+		assert.Nil(mt, err)
+		assert.Equal(mt, "secondaryPreferred", readMode(mt))
+		assert.Equal(mt, "primary", readMode(mt))
+		assert.Equal(mt, "primary", readMode(mt))
+
+		primary, err := cache.WithReadPreference(readpref.Primary())
+		assert.Nil(mt, err)
+		mt.ClearEvents()
+		mt.AddMockResponses(commodities(), commodities())
+		_, _, err = primary.Get("b")
+		assert.Nil(mt, err)
+		assert.Equal(mt, "primary", readMode(mt))
+		_, _, err = cache.Get("c")
+		assert.Nil(mt, err)
+		assert.Equal(mt, "secondaryPreferred", readMode(mt))
+
+		// strong reads skip the cached copy
+		mt.ClearEvents()
+		mt.AddMockResponses(commodities(apple))
+		_, _, err = cache.StrongRead().Get("b")
+		assert.Nil(mt, err)
+		assert.Equal(mt, "primary", readMode(mt))
+	})
+}
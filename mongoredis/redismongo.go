@@ -2,134 +2,204 @@ package mongoredis
 
 import (
 	"context"
-	"errors"
 	"reflect"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/daqiancode/cachelayer"
 	"github.com/go-redis/redis/v8"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type RedisMongo[T cachelayer.Table[I], I string] struct {
+type RedisMongo[T cachelayer.Table[I], I ~string] struct {
 	*cachelayer.CacheBase[T, I]
 	db         *mongo.Client
 	red        *cachelayer.RedisJson[T]
-	redId      *cachelayer.RedisJson[string]
-	redIds     *cachelayer.RedisJson[[]string]
+	cache      *cachelayer.RedisCache[T, I]
+	m          *Mongo[T, I]
 	database   string
 	collection string
 	c          *mongo.Collection
+	//readYourWrites read the record from primary after write before re-caching it
+	readYourWrites bool
+	//seq numeric ids of documents created without id, see SetSequence
+	seq *cachelayer.Sequence
 }
 
-func NewRedisMongo[T cachelayer.Table[I], I string](prefix, database, table, idField string, db *mongo.Client, red *redis.Client, ttl time.Duration) *RedisMongo[T, I] {
+func NewRedisMongo[T cachelayer.Table[I], I ~string](prefix, database, table, idField string, db *mongo.Client, red redis.UniversalClient, ttl time.Duration) *RedisMongo[T, I] {
+	m := &Mongo[T, I]{
+		db:         db,
+		idField:    idField,
+		ctx:        context.Background(),
+		database:   database,
+		collection: table,
+		c:          db.Database(database).Collection(table),
+		shapes:     &sync.Map{},
+	}
+	//reads go through the same cache-aside flow as RedisCache
+	cache := cachelayer.NewRedisCache[T, I](prefix, table, idField, m, red, ttl)
 	return &RedisMongo[T, I]{
-		CacheBase:  cachelayer.NewCacheBase[T, I](prefix, table, idField, context.Background()),
+		CacheBase:  cache.CacheBase,
 		db:         db,
 		red:        cachelayer.NewRedisJson[T](red, ttl),
-		redId:      cachelayer.NewRedisJson[string](red, ttl),
-		redIds:     cachelayer.NewRedisJson[[]string](red, ttl),
+		cache:      cache,
+		m:          m,
 		database:   database,
 		collection: table,
-		c:          db.Database(database).Collection(table),
+		c:          m.c,
 	}
 }
 
+//GetCache underlying RedisCache serving reads, eg. to configure serializer or expiration policy
+func (s *RedisMongo[T, I]) GetCache() *cachelayer.RedisCache[T, I] {
+	return s.cache
+}
+
 func (s *RedisMongo[T, I]) Close() error {
 	return s.db.Disconnect(s.GetCtx())
 }
+//ClearCache delete id key, index keys, their counts, OR queries of them and filter query results in one round trip
 func (s *RedisMongo[T, I]) ClearCache(id I, indexes cachelayer.Indexes) error {
-
 	var keys []string
-	if !cachelayer.IsNullID(id) {
+	var ids []I
+	if !s.IsNullID(id) {
 		keys = append(keys, s.MakeCacheKey(cachelayer.NewIndex(s.GetIdField(), id)))
+		ids = append(ids, id)
 	}
+	keys = append(keys, s.CountKey(cachelayer.Index{}))
 	for _, v := range indexes {
-		keys = append(keys, s.MakeCacheKey(v))
+		keys = append(keys, s.MakeCacheKey(v), s.CountKey(v))
 	}
-	keys = cachelayer.UniqueStrings(keys)
-	return s.red.Del(s.GetCtx(), keys...).Err()
+	return s.cache.ClearKeys(keys, ids, append(s.cache.AnyOfTags(keys), filterTag)...)
 }
 
-func (s *RedisMongo[T, I]) Get(id I) (T, bool, error) {
-	var t T
+//ClearCacheFor clear cache of a batch of documents changed outside the cache, eg. by a change stream consumer, in one round trip
+func (s *RedisMongo[T, I]) ClearCacheFor(objs ...T) error {
+	return s.clearObjs(objs...)
+}
 
-	r := s.c.FindOne(s.GetCtx(), bson.M{"_id": id})
-	if err := r.Err(); err != nil {
-		if mongo.ErrNoDocuments == err {
-			return t, false, nil
-		}
-		return t, false, err
+//clearObjs clear cache of all objs in one round trip
+func (s *RedisMongo[T, I]) clearObjs(objs ...T) error {
+	if len(objs) == 0 {
+		return nil
+	}
+	ids := make([]I, len(objs))
+	for i, v := range objs {
+		ids[i] = v.GetID()
 	}
-	err := r.Decode(&t)
-	return t, true, err
+	keys := append(s.cache.CacheKeys(objs...), s.cache.CountKeys(objs...)...)
+	return s.cache.ClearKeys(keys, ids, append(s.cache.AnyOfTags(keys), filterTag)...)
 }
 
-func (s *RedisMongo[T, I]) List(ids ...I) ([]T, error) {
-	var t []T
-	var err error
-	// objectIds := make([]primitive.ObjectID, len(ids))
+func (s *RedisMongo[T, I]) Get(id I) (T, bool, error) {
+	return s.cache.Get(id)
+}
 
-	// for i, v := range ids {
-	// 	objectIds[i], err = primitive.ObjectIDFromHex(cachelayer.Stringify(v, ""))
-	// 	if err != nil {
-	// 		return t, err
-	// 	}
-	// }
-	query := bson.M{"_id": bson.M{"$in": ids}}
-	r, err := s.c.Find(s.GetCtx(), query)
-	if err != nil {
-		return t, err
-	}
-	// err = r.Decode(&t)
-	err = r.All(s.GetCtx(), &t)
-	return t, err
+func (s *RedisMongo[T, I]) List(ids ...I) ([]T, error) {
+	return s.cache.List(ids...)
 }
 
 func (s *RedisMongo[T, I]) Create(t *T) error {
 	if t == nil {
 		return nil
 	}
-	if cachelayer.IsNullID((*t).GetID()) {
-		reflect.ValueOf(t).Elem().FieldByName(s.GetIdField()).SetString(primitive.NewObjectID().Hex())
+	filled, err := s.FillID(t)
+	if err != nil {
+		return err
+	}
+	if !filled && s.IsNullID((*t).GetID()) {
+		id, err := s.nextID()
+		if err != nil {
+			return err
+		}
+		reflect.ValueOf(t).Elem().FieldByName(s.GetIdField()).Set(reflect.ValueOf(id))
+	}
+	_, err = s.c.InsertOne(s.GetCtx(), *t)
+	if mongo.IsDuplicateKeyError(err) {
+		return cachelayer.NewError(cachelayer.ErrConflict, err)
 	}
-	_, err := s.c.InsertOne(s.GetCtx(), *t)
 	if err != nil {
 		return err
 	}
-	s.ClearCache((*t).GetID(), (*t).ListIndexes())
+	cachelayer.ReportError("invalidation", s.GetTableName(), s.clearObjs(*t))
 	return nil
 }
+
+//SetSequence give documents created without id the next id of seq in decimal, eg. "1042", instead of an ObjectID.
+// An id generator set by SetIDGenerator takes precedence
+func (s *RedisMongo[T, I]) SetSequence(seq *cachelayer.Sequence) {
+	s.seq = seq
+}
+
+//SetNameMapper translate index fields into document fields by mapper, see Mongo.SetNameMapper
+func (s *RedisMongo[T, I]) SetNameMapper(mapper cachelayer.NameMapper) {
+	s.m.SetNameMapper(mapper)
+}
+
+//nextID id of a new document, from the sequence if set
+func (s *RedisMongo[T, I]) nextID() (I, error) {
+	if s.seq == nil {
+		return s.m.newID(), nil
+	}
+	n, err := s.seq.Next(s.GetCtx())
+	if err != nil {
+		return "", err
+	}
+	return I(strconv.FormatInt(n, 10)), nil
+}
+
+//Save create t if its id is null, otherwise upsert it on _id
 func (s *RedisMongo[T, I]) Save(t *T) error {
 	if t == nil {
 		return nil
 	}
-	id := (*t).GetID()
-	if cachelayer.IsNullID(id) {
+	if s.IsNullID((*t).GetID()) {
 		return s.Create(t)
 	}
-	_, exist, err := s.Get(id)
+	return s.Upsert(t)
+}
+
+//Upsert replace the document matching t on conflictFields(_id if empty) or insert t, cache of both the replaced document and t is cleared
+func (s *RedisMongo[T, I]) Upsert(t *T, conflictFields ...string) error {
+	old, existed, err := s.m.Upsert(t, conflictFields...)
 	if err != nil {
-		return nil
+		return err
 	}
-	if !exist {
-		return s.Create(t)
+	if existed {
+		return s.clearObjs(old, *t)
 	}
-	query := bson.M{"_id": id}
-	err = s.c.FindOneAndReplace(s.GetCtx(), query, *t).Err()
-	return err
+	return s.clearObjs(*t)
 }
 
 func (s *RedisMongo[T, I]) Delete(ids ...I) (int64, error) {
+	_, n, err := s.delete(ids...)
+	return n, err
+}
+
+//DeleteReturning delete records and return the deleted ones atomically, eg. for audit logs. Missing ids are skipped
+func (s *RedisMongo[T, I]) DeleteReturning(ids ...I) ([]T, error) {
+	objs, err := s.m.DeleteReturning(ids...)
+	if err != nil {
+		return nil, err
+	}
+	return objs, s.clearObjs(objs...)
+}
+
+func (s *RedisMongo[T, I]) delete(ids ...I) ([]T, int64, error) {
 	if len(ids) == 0 {
-		return 0, nil
+		return nil, 0, nil
 	}
-	objs, err := s.List(ids...)
+	list, err := s.List(ids...)
 	if err != nil {
-		return 0, err
+		return nil, 0, err
+	}
+	var objs []T
+	for _, v := range list {
+		if !s.IsNullID(v.GetID()) {
+			objs = append(objs, v)
+		}
 	}
 	// objectIds := make([]primitive.ObjectID, len(ids))
 
@@ -142,17 +212,14 @@ func (s *RedisMongo[T, I]) Delete(ids ...I) (int64, error) {
 	query := bson.M{"_id": bson.M{"$in": ids}}
 	rs, err := s.c.DeleteMany(s.GetCtx(), query)
 	if err != nil {
-		return 0, err
-	}
-	for _, v := range objs {
-		s.ClearCache(v.GetID(), v.ListIndexes())
+		return nil, 0, err
 	}
-	return rs.DeletedCount, err
+	return objs, rs.DeletedCount, s.clearObjs(objs...)
 }
 
-//Update values type: map[string]interface{} , eg:map[string]interface{}{"addr.country": "uae", "tags.0.name": "gg"}
+//Update values type: map[string]interface{}, bson.M or bson.D , eg:map[string]interface{}{"addr.country": "uae", "tags.0.name": "gg"}, bson.M{"$inc": bson.M{"views": 1}, "$push": bson.M{"tags": tag}}
 func (s *RedisMongo[T, I]) Update(id I, values interface{}) (int64, error) {
-	if cachelayer.IsNullID(id) {
+	if s.IsNullID(id) {
 		return 0, nil
 	}
 	// objectId, err := primitive.ObjectIDFromHex(cachelayer.Stringify(id, ""))
@@ -163,67 +230,44 @@ func (s *RedisMongo[T, I]) Update(id I, values interface{}) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	var setD bson.D
-	if m, ok := values.(map[string]interface{}); ok {
-		for k, v := range m {
-			setD = append(setD, bson.E{Key: k, Value: v})
-		}
-	} else {
-		return 0, errors.New("RedisMongo.Update not support this type of update values, only support map[string]interface{}")
+	update, err := UpdateDocument(values)
+	if err != nil {
+		return 0, err
 	}
-	setValues := bson.D{{Key: "$set", Value: setD}}
-	rs, err := s.c.UpdateOne(s.GetCtx(), bson.M{"_id": id}, setValues)
+	rs, err := s.c.UpdateOne(s.GetCtx(), bson.M{"_id": id}, update)
 
 	if err != nil {
 		return 0, err
 	}
-	newObj, _, err := s.Get(id)
+	// read from mongo, the cached copy is the old one
+	newObj, _, err := s.m.getFrom(s.m.writeReader(s.readYourWrites), id)
 	if err != nil {
 		return 0, err
 	}
-	err = s.ClearCache(old.GetID(), old.ListIndexes().Merge(newObj.ListIndexes()))
-
-	return rs.MatchedCount, nil
+	return rs.MatchedCount, s.clearObjs(old, newObj)
 }
 
 func (s *RedisMongo[T, I]) GetBy(index cachelayer.Index) (T, bool, error) {
-	var t T
-	r := s.c.FindOne(s.GetCtx(), index)
-	if err := r.Err(); err != nil {
-		if mongo.ErrNoDocuments == err {
-			return t, false, nil
-		}
-		return t, false, err
-	}
-	err := r.Decode(&t)
-	return t, true, err
+	return s.cache.GetBy(index)
 }
 
-func (s *RedisMongo[T, I]) ListBy(index cachelayer.Index, orderBys cachelayer.OrderBys) ([]T, error) {
-	var t []T
-	var err error
-	// objectIds := make([]primitive.ObjectID, len(ids))
+//ListByAny records matching any of the alternatives of anyOf, see cachelayer.RedisCache.ListByAny
+func (s *RedisMongo[T, I]) ListByAny(anyOf cachelayer.AnyOf, orderBys cachelayer.OrderBys) ([]T, error) {
+	return s.cache.ListByAny(anyOf, orderBys)
+}
 
-	// for i, v := range ids {
-	// 	objectIds[i], err = primitive.ObjectIDFromHex(cachelayer.Stringify(v, ""))
-	// 	if err != nil {
-	// 		return t, err
-	// 	}
-	// }
-	var opts *options.FindOptions
-	if len(orderBys) > 0 {
-		ds := make([]bson.E, len(orderBys))
-		for i, v := range orderBys {
-			ds[i] = bson.E{Key: v.Field, Value: v.Asc}
-		}
-		opts = options.Find().SetSort(ds)
-	}
+//Count count of records of index, see cachelayer.RedisCache.Count
+func (s *RedisMongo[T, I]) Count(index cachelayer.Index) (int64, error) {
+	return s.cache.Count(index)
+}
 
-	r, err := s.c.Find(s.GetCtx(), index, opts)
-	if err != nil {
-		return t, err
-	}
-	// err = r.Decode(&t)
-	err = r.All(s.GetCtx(), &t)
-	return t, err
+//ListWhere records matching filter, see cachelayer.RedisCache.ListWhere
+func (s *RedisMongo[T, I]) ListWhere(filter *cachelayer.Filter) ([]T, error) {
+	return s.cache.ListWhere(filter)
 }
+
+//ListBy index values can be query operators, see ListByFilter
+func (s *RedisMongo[T, I]) ListBy(index cachelayer.Index, orderBys cachelayer.OrderBys) ([]T, error) {
+	return s.ListByFilter(index, orderBys, 0)
+}
+
@@ -0,0 +1,115 @@
+package mongoredis_test
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/mongoredis"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+//commodities cursor reply of docs
+func commodities(docs ...Commodity) bson.D {
+	batch := make([]bson.D, len(docs))
+	for i, v := range docs {
+		batch[i] = bson.D{{Key: "_id", Value: v.Id}, {Key: "name", Value: v.Name}, {Key: "category", Value: v.Category}}
+	}
+	return mtest.CreateCursorResponse(0, "test.commodity", mtest.FirstBatch, batch...)
+}
+
+func TestRedisMongoReadThrough(t *testing.T) {
+	runMock(t, func(mt *mtest.T, cache *mongoredis.RedisMongo[Commodity, string], mr *miniredis.Miniredis) {
+		apple := Commodity{Id: "a", Name: "apple", Category: 1}
+		pear := Commodity{Id: "p", Name: "pear", Category: 1}
+		mt.AddMockResponses(commodities(apple))
+		r, exists, err := cache.Get("a")
+		assert.Nil(mt, err)
+		assert.True(mt, exists)
+		assert.Equal(mt, "apple", r.Name)
+		// replies are no longer queued, reads below are served by redis
+		r, exists, err = cache.Get("a")
+		assert.Nil(mt, err)
+		assert.True(mt, exists)
+		assert.Equal(mt, "apple", r.Name)
+
+		mt.AddMockResponses(commodities())
+		_, exists, err = cache.Get("x")
+		assert.Nil(mt, err)
+		assert.False(mt, exists)
+		_, exists, err = cache.Get("x")
+		assert.Nil(mt, err)
+		assert.False(mt, exists)
+
+		mt.AddMockResponses(commodities(pear))
+		objs, err := cache.List("p", "a", "x")
+		assert.Nil(mt, err)
+		assert.Equal(mt, []string{"p", "a", ""}, []string{objs[0].Id, objs[1].Id, objs[2].Id})
+		objs, err = cache.List("p", "a", "x")
+		assert.Nil(mt, err)
+		assert.Equal(mt, "pear", objs[0].Name)
+
+		mt.AddMockResponses(commodities(pear))
+		r, exists, err = cache.GetBy(cachelayer.NewIndex("name", "pear"))
+		assert.Nil(mt, err)
+		assert.True(mt, exists)
+		assert.Equal(mt, "p", r.Id)
+		r, exists, err = cache.GetBy(cachelayer.NewIndex("name", "pear"))
+		assert.Nil(mt, err)
+		assert.True(mt, exists)
+		assert.Equal(mt, "p", r.Id)
+		assert.True(mt, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex("name", "pear"))))
+	})
+}
+
+func TestRedisMongoDeleteReturning(t *testing.T) {
+	runMock(t, func(mt *mtest.T, cache *mongoredis.RedisMongo[Commodity, string], mr *miniredis.Miniredis) {
+		apple := Commodity{Id: "a", Name: "apple", Category: 1}
+		mt.AddMockResponses(commodities(apple))
+		_, _, err := cache.Get("a")
+		assert.Nil(mt, err)
+		assert.True(mt, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex(cache.GetIdField(), "a"))))
+		// each document is deleted by findAndModify, which returns the deleted version
+		deleted := bson.D{{Key: "_id", Value: "a"}, {Key: "name", Value: "green apple"}, {Key: "category", Value: 1}}
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: deleted}}, bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+		objs, err := cache.DeleteReturning("a", "x")
+		assert.Nil(mt, err)
+		assert.Equal(mt, []Commodity{{Id: "a", Name: "green apple", Category: 1}}, objs)
+		assert.False(mt, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex(cache.GetIdField(), "a"))))
+	})
+}
+
+func TestRedisMongoUpsert(t *testing.T) {
+	runMock(t, func(mt *mtest.T, cache *mongoredis.RedisMongo[Commodity, string], mr *miniredis.Miniredis) {
+		// a concurrent upsert inserted the same name first, the retry updates its document
+		mt.AddMockResponses(
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 11000, Name: "DuplicateKey", Message: "E11000 duplicate key error"}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: bson.D{{Key: "_id", Value: "a"}, {Key: "name", Value: "apple"}, {Key: "category", Value: 1}}}},
+		)
+		mr.Set(cache.MakeCacheKey(cachelayer.NewIndex(cache.GetIdField(), "a")), "{}")
+		r := Commodity{Name: "apple", Category: 2}
+		assert.Nil(mt, cache.Upsert(&r, "name"))
+		assert.Equal(mt, "a", r.Id)
+		assert.False(mt, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex(cache.GetIdField(), "a"))))
+		var ids []string
+		for e := mt.GetStartedEvent(); e != nil; e = mt.GetStartedEvent() {
+			assert.Equal(mt, "findAndModify", e.CommandName)
+			// matched by name in one atomic write, the id only applies to an insert
+			assert.Equal(mt, "apple", e.Command.Lookup("query", "name").StringValue())
+			update := e.Command.Lookup("update").Document()
+			_, err := update.LookupErr("$set", "_id")
+			assert.NotNil(mt, err)
+			ids = append(ids, update.Lookup("$setOnInsert", "_id").StringValue())
+		}
+		assert.Len(mt, ids, 2)
+
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+		r = Commodity{Name: "pear", Category: 1}
+		assert.Nil(mt, cache.Upsert(&r, "name"))
+		assert.NotEmpty(mt, r.Id)
+		e := mt.GetStartedEvent()
+		assert.Equal(mt, r.Id, e.Command.Lookup("update", "$setOnInsert", "_id").StringValue())
+	})
+}
@@ -0,0 +1,61 @@
+package mongoredis_test
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/mongoredis"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestUpdateDocument(t *testing.T) {
+	d, err := mongoredis.UpdateDocument(map[string]interface{}{"name": "pear"})
+	assert.Nil(t, err)
+	assert.Equal(t, bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "pear"}}}}, d)
+	ops := bson.D{{Key: "$inc", Value: bson.M{"category": 1}}, {Key: "$push", Value: bson.M{"tags": Tag{Name: "new"}}}}
+	d, err = mongoredis.UpdateDocument(ops)
+	assert.Nil(t, err)
+	assert.Equal(t, ops, d)
+	d, err = mongoredis.UpdateDocument(bson.M{"$unset": bson.M{"addr": ""}})
+	assert.Nil(t, err)
+	assert.Equal(t, bson.D{{Key: "$unset", Value: bson.M{"addr": ""}}}, d)
+	_, err = mongoredis.UpdateDocument(bson.M{"$inc": bson.M{"category": 1}, "name": "pear"})
+	assert.NotNil(t, err)
+	_, err = mongoredis.UpdateDocument(Commodity{})
+	assert.NotNil(t, err)
+}
+
+func TestUpdateOperators(t *testing.T) {
+	runMock(t, func(mt *mtest.T, cache *mongoredis.RedisMongo[Commodity, string], mr *miniredis.Miniredis) {
+		mt.AddMockResponses(commodities(Commodity{Id: "a", Name: "apple", Category: 1}))
+		_, _, err := cache.Get("a")
+		assert.Nil(mt, err)
+		mt.AddMockResponses(commodities())
+		_, err = cache.ListBy(cachelayer.NewIndex("category", 2), nil)
+		assert.Nil(mt, err)
+		oldKey := cache.MakeCacheKey(cachelayer.NewIndex("category", 1))
+		newKey := cache.MakeCacheKey(cachelayer.NewIndex("category", 2))
+		mr.Set(oldKey, "[]")
+		assert.True(mt, mr.Exists(newKey))
+
+		mt.ClearEvents()
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+			commodities(Commodity{Id: "a", Name: "apple", Category: 2}),
+		)
+		n, err := cache.Update("a", bson.M{"$inc": bson.M{"category": 1}})
+		assert.Nil(mt, err)
+		assert.Equal(mt, int64(1), n)
+		update := mt.GetStartedEvent()
+		assert.Equal(mt, "update", update.CommandName)
+		u := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		assert.Equal(mt, int32(1), u.Lookup("$inc", "category").Int32())
+		// index keys of both the old and the new version are invalidated
+		assert.False(mt, mr.Exists(cache.MakeCacheKey(cachelayer.NewIndex("Id", "a"))))
+		assert.False(mt, mr.Exists(oldKey))
+		assert.False(mt, mr.Exists(newKey))
+	})
+}
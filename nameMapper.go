@@ -0,0 +1,75 @@
+package cachelayer
+
+import (
+	"strings"
+	"unicode"
+)
+
+//NameMapper name of a go field in cache payloads, database columns or document fields, eg. SnakeCase("CategoryId") is category_id
+type NameMapper func(field string) string
+
+var (
+	//AsIs names fields as go fields
+	AsIs NameMapper = func(field string) string { return field }
+	//SnakeCase eg. CategoryId as category_id, UserID as user_id
+	SnakeCase NameMapper = snakeCase
+	//CamelCase eg. CategoryId as categoryId, ID as id, category_id as categoryId
+	CamelCase NameMapper = camelCase
+)
+
+//NameMapperOf mapper by its config name: as_is, snake_case or camel_case
+func NameMapperOf(name string) (NameMapper, bool) {
+	switch name {
+	case "as_is":
+		return AsIs, true
+	case "snake_case":
+		return SnakeCase, true
+	case "camel_case":
+		return CamelCase, true
+	}
+	return nil, false
+}
+
+func snakeCase(field string) string {
+	rs := []rune(field)
+	var b strings.Builder
+	for i, r := range rs {
+		if unicode.IsUpper(r) {
+			// a new word starts after a lower case letter or digit, or at the last capital of an acronym, eg. URLPath
+			if i > 0 && rs[i-1] != '_' && (!unicode.IsUpper(rs[i-1]) || i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func camelCase(field string) string {
+	var b strings.Builder
+	for i, v := range strings.Split(field, "_") {
+		if v == "" {
+			continue
+		}
+		rs := []rune(v)
+		if i == 0 || b.Len() == 0 {
+			// lower the leading capitals, but keep the capital starting the next word, eg. URLPath as urlPath
+			n := 0
+			for n < len(rs) && unicode.IsUpper(rs[n]) {
+				n++
+			}
+			if n > 1 && n < len(rs) {
+				n--
+			}
+			for j := 0; j < n; j++ {
+				rs[j] = unicode.ToLower(rs[j])
+			}
+		} else {
+			rs[0] = unicode.ToUpper(rs[0])
+		}
+		b.WriteString(string(rs))
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package cachelayer_test
+
+import (
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameMapper(t *testing.T) {
+	for _, c := range []struct{ in, snake, camel string }{
+		{"CategoryId", "category_id", "categoryId"},
+		{"UserID", "user_id", "userID"},
+		{"ID", "id", "id"},
+		{"URLPath", "url_path", "urlPath"},
+		{"category_id", "category_id", "categoryId"},
+		{"name", "name", "name"},
+	} {
+		assert.Equal(t, c.snake, cachelayer.SnakeCase(c.in), c.in)
+		assert.Equal(t, c.camel, cachelayer.CamelCase(c.in), c.in)
+		assert.Equal(t, c.in, cachelayer.AsIs(c.in))
+	}
+	_, ok := cachelayer.NameMapperOf("kebab")
+	assert.False(t, ok)
+
+	type item struct {
+		CategoryId uint
+		Name       string `json:"title"`
+	}
+	s := cachelayer.NewJsonSerializer(cachelayer.NewJsoniterEngine(cachelayer.JsonOptions{Naming: "snake_case"}))
+	data, err := s.Marshal(item{CategoryId: 3, Name: "pen"})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"category_id":3,"title":"pen"}`, data)
+	var r item
+	assert.Nil(t, s.Unmarshal(data, &r))
+	assert.Equal(t, uint(3), r.CategoryId)
+	assert.Nil(t, cachelayer.VerifyFieldMapping[item](s))
+}
@@ -0,0 +1,105 @@
+package natsbus
+
+import (
+	"context"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/nats-io/nats.go"
+)
+
+//Publisher publish invalidation events to a NATS subject, with core NATS(at most once) or JetStream(persisted)
+type Publisher struct {
+	nc      *nats.Conn
+	js      nats.JetStreamContext
+	subject string
+}
+
+func NewPublisher(nc *nats.Conn, subject string) *Publisher {
+	return &Publisher{nc: nc, subject: subject}
+}
+
+//NewJetStreamPublisher publish to a subject of a JetStream stream, so subscribers offline for a while still receive events
+func NewJetStreamPublisher(js nats.JetStreamContext, subject string) *Publisher {
+	return &Publisher{js: js, subject: subject}
+}
+
+func (s *Publisher) PublishInvalidation(ctx context.Context, event cachelayer.InvalidationEvent) error {
+	data, err := event.Marshal()
+	if err != nil {
+		return err
+	}
+	if s.js != nil {
+		_, err = s.js.Publish(s.subject, data, nats.Context(ctx))
+		return err
+	}
+	return s.nc.Publish(s.subject, data)
+}
+
+//Subscriber apply invalidation events of a NATS subject
+type Subscriber struct {
+	apply        func(ctx context.Context, event cachelayer.InvalidationEvent) error
+	skipSource   string
+	errorHandler func(err error)
+	sub          *nats.Subscription
+}
+
+func NewSubscriber(apply func(ctx context.Context, event cachelayer.InvalidationEvent) error) *Subscriber {
+	return &Subscriber{apply: apply, errorHandler: func(err error) { cachelayer.ReportError("nats_subscriber", "", err) }}
+}
+
+//SkipSource ignore events published by source, eg. this deployment has already deleted its own keys
+func (s *Subscriber) SkipSource(source string) {
+	s.skipSource = source
+}
+
+//SetErrorHandler handle errors of applying events, default the global error handler of cachelayer
+func (s *Subscriber) SetErrorHandler(handler func(err error)) {
+	s.errorHandler = handler
+}
+
+//Subscribe subscribe subject with core NATS, every subscribing process receives all events
+func (s *Subscriber) Subscribe(nc *nats.Conn, subject string) error {
+	sub, err := nc.Subscribe(subject, func(msg *nats.Msg) {
+		if err := s.handle(msg); err != nil {
+			s.errorHandler(err)
+		}
+	})
+	s.sub = sub
+	return err
+}
+
+//SubscribeJetStream durable subscription of a JetStream subject, messages are acked after apply succeeds and redelivered otherwise.
+// Use a durable name per deployment(eg. per region) so every deployment receives all events
+func (s *Subscriber) SubscribeJetStream(js nats.JetStreamContext, subject, durable string) error {
+	sub, err := js.Subscribe(subject, func(msg *nats.Msg) {
+		if err := s.handle(msg); err != nil {
+			s.errorHandler(err)
+			msg.Nak()
+			return
+		}
+		msg.Ack()
+	}, nats.Durable(durable), nats.ManualAck())
+	s.sub = sub
+	return err
+}
+
+//handle apply event of msg, malformed messages are skipped
+func (s *Subscriber) handle(msg *nats.Msg) error {
+	event, err := cachelayer.ParseInvalidationEvent(msg.Data)
+	if err != nil {
+		s.errorHandler(err)
+		return nil
+	}
+	if s.skipSource != "" && event.Source == s.skipSource {
+		return nil
+	}
+	return s.apply(context.Background(), event)
+}
+
+//Close unsubscribe, the connection is owned by the caller
+func (s *Subscriber) Close() error {
+	if s.sub == nil {
+		return nil
+	}
+	return s.sub.Unsubscribe()
+}
@@ -0,0 +1,76 @@
+package natsbus_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/daqiancode/cachelayer/natsbus"
+	"github.com/go-redis/redis/v8"
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+//newNats embedded NATS server with JetStream and a connection to it, both closed by the end of t
+func newNats(t *testing.T) *nats.Conn {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	server := natsserver.RunServer(&opts)
+	t.Cleanup(server.Shutdown)
+	nc, err := nats.Connect(server.ClientURL())
+	assert.Nil(t, err)
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+func newMiniRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { red.Close() })
+	return mr, red
+}
+
+func TestCoreNats(t *testing.T) {
+	nc := newNats(t)
+	mr, red := newMiniRedis(t)
+	mr.Set("app/user/id/1", "{}")
+	mr.Set("app/user/id/2", "{}")
+	errs := make(chan error, 1)
+	sub := natsbus.NewSubscriber(cachelayer.RedisApplier(red))
+	sub.SkipSource("us-east")
+	sub.SetErrorHandler(func(err error) { errs <- err })
+	assert.Nil(t, sub.Subscribe(nc, "cache.invalidation"))
+	defer sub.Close()
+
+	pub := natsbus.NewPublisher(nc, "cache.invalidation")
+	ctx := context.Background()
+	// own events are skipped
+	assert.Nil(t, pub.PublishInvalidation(ctx, cachelayer.InvalidationEvent{Source: "us-east", Table: "user", Keys: []string{"app/user/id/2"}}))
+	assert.Nil(t, nc.Publish("cache.invalidation", []byte("not json")))
+	assert.Nil(t, pub.PublishInvalidation(ctx, cachelayer.InvalidationEvent{Source: "eu-west", Table: "user", Keys: []string{"app/user/id/1"}}))
+	assert.NotNil(t, <-errs)
+	assert.Eventually(t, func() bool { return !mr.Exists("app/user/id/1") }, time.Second, 10*time.Millisecond)
+	assert.True(t, mr.Exists("app/user/id/2"))
+}
+
+func TestJetStream(t *testing.T) {
+	nc := newNats(t)
+	js, err := nc.JetStream()
+	assert.Nil(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "CACHE", Subjects: []string{"cache.>"}})
+	assert.Nil(t, err)
+	mr, red := newMiniRedis(t)
+	mr.Set("app/user/id/1", "{}")
+	// published before the deployment subscribes, delivered from the stream
+	pub := natsbus.NewJetStreamPublisher(js, "cache.invalidation")
+	assert.Nil(t, pub.PublishInvalidation(context.Background(), cachelayer.InvalidationEvent{Source: "eu-west", Table: "user", Keys: []string{"app/user/id/1"}}))
+	sub := natsbus.NewSubscriber(cachelayer.RedisApplier(red))
+	assert.Nil(t, sub.SubscribeJetStream(js, "cache.invalidation", "us-east"))
+	defer sub.Close()
+	assert.Eventually(t, func() bool { return !mr.Exists("app/user/id/1") }, time.Second, 10*time.Millisecond)
+}
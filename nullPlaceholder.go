@@ -0,0 +1,73 @@
+package cachelayer
+
+import (
+	"reflect"
+	"strings"
+)
+
+//LegacyNullPlaceholder payload of cached "not found" entries written by default, json null, read by every version
+const LegacyNullPlaceholder = "null"
+
+//DefaultNullPlaceholder marker of cached "not found" entries, the prefix '!' never starts json or an encrypted payload,
+// so monitoring tells it apart from records and corrupted entries. Caches write it once SetNullPlaceholder enables it
+const DefaultNullPlaceholder = "!null"
+
+//IsNullPlaceholder whether a raw cache entry is a cached "not found" written as LegacyNullPlaceholder or DefaultNullPlaceholder.
+// Caches also read their own placeholder set by SetNullPlaceholder
+func IsNullPlaceholder(data string) bool {
+	return data == DefaultNullPlaceholder || data == LegacyNullPlaceholder
+}
+
+//SetNullPlaceholder payload of cached "not found" entries, LegacyNullPlaceholder by default. Other placeholders must start with '!',
+// so they can not be taken for a serialized record. Versions before the placeholder read it as a corrupted entry,
+// so enable it in two phases: deploy this version everywhere first, then set the placeholder
+func (s *RedisJson[T]) SetNullPlaceholder(placeholder string) {
+	if placeholder != LegacyNullPlaceholder && !strings.HasPrefix(placeholder, "!") {
+		panic("cachelayer: null placeholder must start with '!'")
+	}
+	s.nullPlaceholder = placeholder
+}
+
+//GetNullPlaceholder payload of cached "not found" entries written by s
+func (s *RedisJson[T]) GetNullPlaceholder() string {
+	if s.nullPlaceholder == "" {
+		return LegacyNullPlaceholder
+	}
+	return s.nullPlaceholder
+}
+
+//isNull whether data is a cached "not found", of any placeholder or the one of s
+func (s *RedisJson[T]) isNull(data string) bool {
+	return IsNullPlaceholder(data) || data == s.nullPlaceholder
+}
+
+//unmarshal deserialize data into objRef, a cached "not found" of the placeholder of s is read as the zero value
+func (s *RedisJson[T]) unmarshal(data string, objRef interface{}) error {
+	if s.isNull(data) {
+		setZero(objRef)
+		return nil
+	}
+	return unmarshal(s.serializer, data, objRef)
+}
+
+//SetNullPlaceholder payload of cached "not found" entries of the cache, see RedisJson.SetNullPlaceholder
+func (s *RedisCache[T, I]) SetNullPlaceholder(placeholder string) {
+	s.red.SetNullPlaceholder(placeholder)
+	s.redId.SetNullPlaceholder(placeholder)
+	s.redIds.SetNullPlaceholder(placeholder)
+}
+
+//SetNullPlaceholder payload of cached "not found" entries of the cache, see RedisJson.SetNullPlaceholder
+func (s *FullRedisCache[T, I]) SetNullPlaceholder(placeholder string) {
+	s.red.SetNullPlaceholder(placeholder)
+	s.redId.SetNullPlaceholder(placeholder)
+	s.redIds.SetNullPlaceholder(placeholder)
+}
+
+//setZero reset objRef to the zero value of its element
+func setZero(objRef interface{}) {
+	v := reflect.ValueOf(objRef)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+}
@@ -0,0 +1,48 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNullPlaceholder(t *testing.T) {
+	assert.True(t, cachelayer.IsNullPlaceholder(cachelayer.DefaultNullPlaceholder))
+	assert.True(t, cachelayer.IsNullPlaceholder(cachelayer.LegacyNullPlaceholder))
+	assert.False(t, cachelayer.IsNullPlaceholder(`{"iD":1}`))
+
+	// written as json null by default, so versions before the placeholder read it during a rolling deploy
+	mr, red := newMiniRedis(t)
+	db := newMemDB()
+	cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	other := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	_, exists, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	v, err := mr.Get("app/member/id/1")
+	assert.Nil(t, err)
+	assert.Equal(t, cachelayer.LegacyNullPlaceholder, v)
+
+	// the second phase sets the placeholder per cache
+	assert.Panics(t, func() { cache.SetNullPlaceholder("missing") })
+	cache.SetNullPlaceholder("!missing")
+	_, exists, err = cache.Get(2)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	v, err = mr.Get("app/member/id/2")
+	assert.Nil(t, err)
+	assert.Equal(t, "!missing", v)
+	_, exists, err = cache.Get(2)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, 2, db.Queries())
+
+	// a cache of the first phase reads the marker of the second
+	mr.Set("app/member/id/3", cachelayer.DefaultNullPlaceholder)
+	_, exists, err = other.Get(3)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, 2, db.Queries())
+}
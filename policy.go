@@ -0,0 +1,86 @@
+package cachelayer
+
+import "time"
+
+//CachePolicy how the entry of a record is cached, decided by the record itself, see CachePolicer
+type CachePolicy int
+
+const (
+	//CacheDefault cached with the ttl and expiration policy of the cache
+	CacheDefault CachePolicy = iota
+	//NoCache never cached, every read loads the record from database, eg. rows in "processing" status
+	NoCache
+	//CacheShort cached with the short ttl(default ttl/10), not extended by reads
+	CacheShort
+	//CacheLong cached with the long ttl(default ttl*10), not extended by reads
+	CacheLong
+)
+
+func (s CachePolicy) String() string {
+	switch s {
+	case NoCache:
+		return "no_cache"
+	case CacheShort:
+		return "short"
+	case CacheLong:
+		return "long"
+	}
+	return "default"
+}
+
+//CachePolicer record deciding its own CachePolicy, eg.
+// func (s Order) CachePolicy() cachelayer.CachePolicy { if s.Status == "processing" { return cachelayer.NoCache }; return cachelayer.CacheDefault }
+type CachePolicer interface {
+	CachePolicy() CachePolicy
+}
+
+//recordPolicy policy of obj, CacheDefault if it is not a CachePolicer
+func recordPolicy(obj interface{}) CachePolicy {
+	if p, ok := obj.(CachePolicer); ok {
+		return p.CachePolicy()
+	}
+	return CacheDefault
+}
+
+//SetPolicyTTL ttl of entries of CacheShort or CacheLong records
+func (s *RedisJson[T]) SetPolicyTTL(policy CachePolicy, ttl time.Duration) {
+	if s.policyTTLs == nil {
+		s.policyTTLs = make(map[CachePolicy]time.Duration)
+	}
+	s.policyTTLs[policy] = ttl
+}
+
+//entryTTL redis ttl of the entry of obj by its policy
+func (s *RedisJson[T]) entryTTL(obj interface{}) time.Duration {
+	policy := recordPolicy(obj)
+	if ttl, ok := s.policyTTLs[policy]; ok {
+		return ttl
+	}
+	switch policy {
+	case CacheShort:
+		return s.ttl / 10
+	case CacheLong:
+		return s.storeTTL() * 10
+	}
+	return s.storeTTL()
+}
+
+//slidingKeys keys of hit objs whose entries are extended by reads, keys[i] is the key of objs[i], objs at missedIndexes are skipped
+func slidingKeys[T any](keys []string, objs []T, missedIndexes []int) []string {
+	missed := make(map[int]bool, len(missedIndexes))
+	for _, v := range missedIndexes {
+		missed[v] = true
+	}
+	r := make([]string, 0, len(keys))
+	for i, v := range keys {
+		if !missed[i] && recordPolicy(objs[i]) == CacheDefault {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+//SetPolicyTTL ttl of entries of records whose CachePolicy is CacheShort or CacheLong
+func (s *RedisCache[T, I]) SetPolicyTTL(policy CachePolicy, ttl time.Duration) {
+	s.red.SetPolicyTTL(policy, ttl)
+}
@@ -0,0 +1,36 @@
+package cachelayer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+type policyOrder struct {
+	ID     uint
+	Status string
+}
+
+func (s policyOrder) CachePolicy() cachelayer.CachePolicy {
+	if s.Status == "processing" {
+		return cachelayer.NoCache
+	}
+	return cachelayer.CacheDefault
+}
+
+func TestCachePolicy(t *testing.T) {
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer red.Close()
+	r := cachelayer.NewRedisJson[policyOrder](red, time.Minute)
+	// NoCache records never reach redis
+	assert.Nil(t, r.SetJson("app/order/id/1", policyOrder{ID: 1, Status: "processing"}))
+	assert.Nil(t, r.MSetJson(map[string]interface{}{"app/order/id/1": policyOrder{ID: 1, Status: "processing"}}))
+	err := r.SetJson("app/order/id/2", policyOrder{ID: 2, Status: "paid"})
+	assert.True(t, errors.Is(err, cachelayer.ErrCacheUnavailable))
+	assert.Equal(t, "no_cache", cachelayer.NoCache.String())
+	assert.Equal(t, "default", cachelayer.CacheDefault.String())
+}
@@ -0,0 +1,134 @@
+package cachelayer
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//PurgeRecord audit record of a PurgeEntity call
+type PurgeRecord struct {
+	Table string
+	ID    string
+	//Keys cache keys deleted
+	Keys []string
+	//HashKey full cache hash the entity was removed from
+	HashKey string
+	//Deleted count of keys(and hash fields) actually removed from redis
+	Deleted int64
+	At      time.Time
+}
+
+//SetPurgeAuditor fn is called with the audit record after every PurgeEntity
+func (s *CacheBase[T, I]) SetPurgeAuditor(fn func(record PurgeRecord)) {
+	s.purgeAuditor = fn
+}
+
+func (s *CacheBase[T, I]) audit(record PurgeRecord) {
+	if s.purgeAuditor != nil {
+		s.purgeAuditor(record)
+	}
+}
+
+//entityKeys id key and index keys of objs
+func (s *CacheBase[T, I]) entityKeys(objs ...T) []string {
+	var keys []string
+	for _, v := range objs {
+		if s.IsNullID(v.GetID()) {
+			continue
+		}
+		keys = append(keys, s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID())))
+		for _, u := range v.ListIndexes() {
+			keys = append(keys, s.MakeCacheKey(u))
+		}
+	}
+	return keys
+}
+
+//purgeKeys cache keys of the entity found without reading cached payloads: keys of the stored entity and its reverse index
+func (s *CacheBase[T, I]) purgeKeys(db DBCRUD[T, I], red redis.UniversalClient, id I) ([]string, error) {
+	stored, exists, err := db.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	if exists {
+		keys = s.entityKeys(stored)
+	}
+	refs, err := s.listRefs(red, id)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, refs...), nil
+}
+
+//PurgeEntity delete every cache key the entity participates in (right-to-be-forgotten): the id key, index keys of the stored entity
+// and keys of the reverse index(see SetReverseIndex). Cached payloads are never decoded, so unreadable entries are purged as well;
+// index keys of values changed since caching are only found through the reverse index.
+// Keys are deleted by one UNLINK, on a Ring or Cluster by one pipeline of UNLINKs which is not atomic, call PurgeEntity again if it fails
+func (s *RedisCache[T, I]) PurgeEntity(id I) (PurgeRecord, error) {
+	record := PurgeRecord{Table: s.table, ID: Stringify(id, ""), At: s.clock.Now()}
+	idKey := s.MakeCacheKey(NewIndex(s.GetIdField(), id))
+	keys, err := s.purgeKeys(s.db, s.red.UniversalClient, id)
+	if err != nil {
+		return record, s.wrapErr("purge", idKey, err)
+	}
+	for _, v := range UniqueStrings(append([]string{idKey}, keys...)) {
+		record.Keys = append(record.Keys, v, deadlineKey(v), freshKey(v))
+	}
+	s.red.replicas.markWritten(record.Keys...)
+	s.generation.advance()
+	record.Deleted, err = DelKeys(s.ctx, s.red.UniversalClient, record.Keys...)
+	if err != nil {
+		return record, s.wrapErr("purge", idKey, err)
+	}
+	s.audit(record)
+	return record, nil
+}
+
+//PurgeEntity remove the entity from the full cache hash and delete its index keys, see RedisCache.PurgeEntity
+func (s *FullRedisCache[T, I]) PurgeEntity(id I) (PurgeRecord, error) {
+	key := s.CacheKey()
+	record := PurgeRecord{Table: s.table, ID: Stringify(id, ""), HashKey: key, At: s.clock.Now()}
+	keys, err := s.purgeKeys(s.db, s.red.UniversalClient, id)
+	if err != nil {
+		return record, s.wrapErr("purge", key, err)
+	}
+	for _, v := range UniqueStrings(keys) {
+		if v == key {
+			continue
+		}
+		record.Keys = append(record.Keys, v, deadlineKey(v), freshKey(v))
+	}
+	s.red.replicas.markWritten(record.Keys...)
+	s.generation.advance()
+	record.Deleted, err = s.purgeFull(key, Stringify(id, ""), record.Keys)
+	if err != nil {
+		return record, s.wrapErr("purge", key, err)
+	}
+	s.audit(record)
+	return record, nil
+}
+
+//purgeFull remove field from the hash and delete keys, in one transaction on a single node.
+// Keys of a Ring or Cluster may live on different nodes, they are deleted in one pipeline then and a failed purge is retried by calling PurgeEntity again
+func (s *FullRedisCache[T, I]) purgeFull(key, field string, keys []string) (int64, error) {
+	var p redis.Pipeliner
+	if IsSharded(s.red.UniversalClient) {
+		p = s.red.Pipeline()
+	} else {
+		p = s.red.TxPipeline()
+	}
+	cmds := []*redis.IntCmd{p.HDel(s.ctx, key, field)}
+	for _, v := range keys {
+		cmds = append(cmds, p.Unlink(s.ctx, v))
+	}
+	if _, err := p.Exec(s.ctx); err != nil {
+		return 0, cacheError(err)
+	}
+	var n int64
+	for _, v := range cmds {
+		n += v.Val()
+	}
+	return n, nil
+}
@@ -0,0 +1,54 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeEntity(t *testing.T) {
+	cache, db, mr := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1})
+	var audited []cachelayer.PurgeRecord
+	cache.SetPurgeAuditor(func(record cachelayer.PurgeRecord) { audited = append(audited, record) })
+	_, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	_, _, err = cache.GetBy(cachelayer.NewIndex("Email", "tom@x.com"))
+	assert.Nil(t, err)
+	assert.Len(t, mr.Keys(), 2)
+	// unreadable payloads must not stop a purge
+	idKey := cache.MakeCacheKey(cachelayer.NewIndex("ID", 1))
+	mr.Set(idKey, "not json")
+	record, err := cache.PurgeEntity(1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), record.Deleted)
+	assert.Empty(t, mr.Keys())
+	assert.Len(t, audited, 1)
+	assert.Equal(t, "1", audited[0].ID)
+
+	// index keys of changed values are found by the reverse index
+	cache.SetReverseIndex(true)
+	_, _, err = cache.GetBy(cachelayer.NewIndex("Email", "tom@x.com"))
+	assert.Nil(t, err)
+	_, err = db.Update(1, map[string]interface{}{"email": "tom@y.com"})
+	assert.Nil(t, err)
+	_, err = cache.PurgeEntity(1)
+	assert.Nil(t, err)
+	assert.Empty(t, mr.Keys())
+}
+
+func TestFullPurgeEntity(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	db := newMemDB(member{ID: 1, Name: "tom", Email: "tom@x.com"}, member{ID: 2, Name: "ann", Email: "ann@x.com"})
+	cache := cachelayer.NewFullRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	_, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	mr.HSet(cache.CacheKey(), "1", "not json")
+	record, err := cache.PurgeEntity(1)
+	assert.Nil(t, err)
+	assert.Equal(t, cache.CacheKey(), record.HashKey)
+	assert.Equal(t, int64(1), record.Deleted)
+	assert.Empty(t, mr.HGet(cache.CacheKey(), "1"))
+	assert.NotEmpty(t, mr.HGet(cache.CacheKey(), "2"))
+}
@@ -0,0 +1,137 @@
+package cachelayer
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//QueryCacheKey cache key of a custom query, eg. app/user/query/{key}. Unlike index values key keeps its case, so queries differing in case
+// (eg. filters of regular expressions) do not share an entry
+func (s *CacheBase[T, I]) QueryCacheKey(key string) string {
+	return s.MakeCacheKey(NewIndex("query", "")) + key
+}
+
+//TagKey redis set of query keys tagged with tag
+func (s *CacheBase[T, I]) TagKey(tag string) string {
+	return s.MakeCacheKey(NewIndex("tag", tag))
+}
+
+//CachedQuery cache ids of records returned by fn under key for ttl(<=0 means cache ttl), records are fetched by List, so they are shared with Get/List.
+// Query results can be cleared by InvalidateTags(tags...), and by ClearCache of returned records if reverse index is enabled.
+func (s *RedisCache[T, I]) CachedQuery(key string, ttl time.Duration, fn func() ([]T, error), tags ...string) ([]T, error) {
+	redisKey := s.QueryCacheKey(key)
+	s.hotKeys.Record(redisKey)
+	start := s.clock.Now()
+	cachedIds, exists, err := s.redIds.GetJson(redisKey)
+	s.observe(OpCacheRead, redisKey, start, err)
+	if err != nil {
+		return nil, s.wrapErr("cached_query", redisKey, err)
+	}
+	if exists {
+		s.stats.hit(1)
+		s.trace(TraceHit, nil, redisKey)
+		return s.List(cachedIds...)
+	}
+	s.stats.miss(1)
+	s.trace(TraceMiss, nil, redisKey)
+	start = s.clock.Now()
+	r, err := fn()
+	s.dbLoaded(start, len(r), err, redisKey)
+	if err != nil {
+		return nil, s.wrapErr("cached_query", redisKey, err)
+	}
+	if ttl <= 0 {
+		ttl = s.redIds.ttl
+	}
+	ids := listIDs[T, I](r...)
+	y, err := marshal(s.redIds.serializer, ids)
+	if err != nil {
+		return r, s.wrapErr("cached_query", redisKey, err)
+	}
+	if err = s.red.SetEX(s.ctx, redisKey, y, ttl).Err(); err != nil {
+		return r, s.wrapErr("cached_query", redisKey, cacheError(err))
+	}
+	if err = s.AddTags(s.red.UniversalClient, ttl, redisKey, tags...); err != nil {
+		return r, s.wrapErr("cached_query", redisKey, err)
+	}
+	err = s.addRefs(s.red.UniversalClient, ttl, map[string][]I{redisKey: ids})
+	return r, s.wrapErr("cached_query", redisKey, err)
+}
+
+//TimeBucket bucket of a time window query at now: the window ends at the end of the current bucket, eg. window 1h and bucket 5m at 10:07 is [9:10, 10:10)
+type TimeBucket struct {
+	From time.Time
+	To   time.Time
+}
+
+func NewTimeBucket(now time.Time, window, bucket time.Duration) TimeBucket {
+	to := now.Truncate(bucket).Add(bucket)
+	return TimeBucket{From: to.Add(-window), To: to}
+}
+
+//Key cache key of query key in this bucket, eg. recent-orders@1656000000
+func (s TimeBucket) Key(key string) string {
+	return key + "@" + strconv.FormatInt(s.To.Unix(), 10)
+}
+
+//TTL time left to the end of the bucket
+func (s TimeBucket) TTL(now time.Time) time.Duration {
+	ttl := s.To.Sub(now)
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	return ttl
+}
+
+//CachedRecent cache a query of rows within the last window(eg. orders in the last hour) per time bucket, see CachedQuery.
+// fn loads rows of [from, to) of the current bucket, the entry expires at the bucket boundary, so results lag at most one bucket.
+// Rows created within the bucket are not visible until the next bucket unless the query is invalidated by tags
+func (s *RedisCache[T, I]) CachedRecent(key string, window, bucket time.Duration, fn func(from, to time.Time) ([]T, error), tags ...string) ([]T, error) {
+	now := s.clock.Now()
+	b := NewTimeBucket(now, window, bucket)
+	return s.CachedQuery(b.Key(key), b.TTL(now), func() ([]T, error) {
+		return fn(b.From, b.To)
+	}, tags...)
+}
+
+//AddTags tag cache key so it will be deleted by DeleteTags of any of tags
+func (s *CacheBase[T, I]) AddTags(red redis.UniversalClient, ttl time.Duration, key string, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	p := red.Pipeline()
+	for _, tag := range tags {
+		tagKey := s.TagKey(tag)
+		p.SAdd(s.ctx, tagKey, key)
+		p.Expire(s.ctx, tagKey, ttl)
+	}
+	_, err := p.Exec(s.ctx)
+	return cacheError(err)
+}
+
+//DeleteTags delete cache keys tagged with any of tags and the tag sets, return count of deleted keys
+func (s *CacheBase[T, I]) DeleteTags(red redis.UniversalClient, tags ...string) (int, error) {
+	if len(tags) == 0 {
+		return 0, nil
+	}
+	keys, err := s.listMembers(red, nil, tags)
+	if err != nil {
+		return 0, err
+	}
+	keys = UniqueStrings(keys)
+	n, err := s.delKeys(red, keys...)
+	return int(n), err
+}
+
+//InvalidateTags delete cached query results tagged with any of tags
+func (s *RedisCache[T, I]) InvalidateTags(tags ...string) error {
+	n, err := s.DeleteTags(s.red.UniversalClient, tags...)
+	s.stats.invalidate(n)
+	if n > 0 {
+		s.notify(CacheEvent{Type: EventMassInvalidation, Pattern: strings.Join(tags, ","), Keys: int64(n)})
+	}
+	return s.wrapErr("invalidate_tags", "", err)
+}
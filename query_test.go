@@ -0,0 +1,54 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeBucket(t *testing.T) {
+	now := time.Date(2022, 6, 1, 10, 7, 30, 0, time.UTC)
+	b := cachelayer.NewTimeBucket(now, time.Hour, 5*time.Minute)
+	assert.Equal(t, time.Date(2022, 6, 1, 9, 10, 0, 0, time.UTC), b.From)
+	assert.Equal(t, time.Date(2022, 6, 1, 10, 10, 0, 0, time.UTC), b.To)
+	assert.Equal(t, 150*time.Second, b.TTL(now))
+	assert.Equal(t, "recent@1654078200", b.Key("recent"))
+
+	later := cachelayer.NewTimeBucket(now.Add(2*time.Minute), time.Hour, 5*time.Minute)
+	assert.Equal(t, b, later)
+	next := cachelayer.NewTimeBucket(now.Add(3*time.Minute), time.Hour, 5*time.Minute)
+	assert.NotEqual(t, b.Key("recent"), next.Key("recent"))
+}
+
+func TestCachedQuery(t *testing.T) {
+	cache, db, mr := newMemberCache(t, member{ID: 1, Name: "Tom", GroupID: 1}, member{ID: 2, Name: "tom", GroupID: 1})
+	calls := 0
+	query := func(name string) func() ([]member, error) {
+		return func() ([]member, error) {
+			calls++
+			return db.ListBy(cachelayer.NewIndex("Name", name), nil)
+		}
+	}
+	r, err := cache.CachedQuery("name=Tom", 0, query("Tom"), "names")
+	assert.Nil(t, err)
+	assert.Equal(t, []member{{ID: 1, Name: "Tom", GroupID: 1}}, r)
+	// keys differing in case are different queries
+	r, err = cache.CachedQuery("name=tom", 0, query("tom"), "names")
+	assert.Nil(t, err)
+	assert.Equal(t, []member{{ID: 2, Name: "tom", GroupID: 1}}, r)
+	assert.NotEqual(t, cache.QueryCacheKey("name=Tom"), cache.QueryCacheKey("name=tom"))
+	assert.True(t, mr.Exists(cache.QueryCacheKey("name=Tom")))
+
+	r, err = cache.CachedQuery("name=Tom", 0, query("Tom"), "names")
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), r[0].ID)
+	assert.Equal(t, 2, calls)
+
+	assert.Nil(t, cache.InvalidateTags("names"))
+	assert.False(t, mr.Exists(cache.QueryCacheKey("name=Tom")))
+	_, err = cache.CachedQuery("name=Tom", 0, query("Tom"), "names")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,150 @@
+package cachelayer
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultRateLimitCapacity = 10000
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	//value last loaded from database, served to rejected callers
+	value  interface{}
+	exists bool
+	known  bool
+}
+
+//DBRateLimiter token bucket per cache key limiting database loads, so a hot record can not flood the database even if redis is down
+// and every call misses. Rejected callers get the last loaded value, or ErrRateLimited if there is none
+type DBRateLimiter struct {
+	rate     float64
+	burst    float64
+	capacity int
+	clock    Clock
+	mu       sync.Mutex
+	buckets  map[string]*tokenBucket
+}
+
+//NewDBRateLimiter allow perSecond database loads per key, up to burst at once
+func NewDBRateLimiter(perSecond float64, burst int) *DBRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &DBRateLimiter{
+		rate:     perSecond,
+		burst:    float64(burst),
+		capacity: defaultRateLimitCapacity,
+		clock:    RealClock{},
+		buckets:  make(map[string]*tokenBucket),
+	}
+}
+
+//SetCapacity max number of keys tracked, idle keys are dropped when full and new keys are not limited while they are busy
+func (s *DBRateLimiter) SetCapacity(capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capacity = capacity
+}
+
+//SetClock clock refilling tokens, default RealClock
+func (s *DBRateLimiter) SetClock(clock Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = clock
+}
+
+//Allow take a token of key, return false if the database load must be skipped
+func (s *DBRateLimiter) Allow(key string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= s.capacity && !s.evictIdle(now) {
+			return true
+		}
+		b = &tokenBucket{tokens: s.burst, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * s.rate
+	if b.tokens > s.burst {
+		b.tokens = s.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+//evictIdle drop keys whose buckets have refilled, return whether any is dropped
+func (s *DBRateLimiter) evictIdle(now time.Time) bool {
+	n := len(s.buckets)
+	for k, v := range s.buckets {
+		if v.tokens+now.Sub(v.last).Seconds()*s.rate >= s.burst {
+			delete(s.buckets, k)
+		}
+	}
+	return len(s.buckets) < n
+}
+
+//remember value loaded for key, exists is false if it was not found. Served to callers rejected later
+func (s *DBRateLimiter) remember(key string, value interface{}, exists bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.buckets[key]; ok {
+		b.value = value
+		b.exists = exists
+		b.known = true
+	}
+}
+
+//lastKnown value last loaded for key, known is false if nothing is loaded yet
+func (s *DBRateLimiter) lastKnown(key string) (value interface{}, exists, known bool) {
+	if s == nil {
+		return nil, false, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.buckets[key]; ok && b.known {
+		return b.value, b.exists, true
+	}
+	return nil, false, false
+}
+
+//SetDBRateLimiter limit database loads per cache key, nil disables it. The limiter can be shared by caches
+func (s *CacheBase[T, I]) SetDBRateLimiter(limiter *DBRateLimiter) {
+	s.dbLimiter = limiter
+}
+
+//allowDB whether a database load of key may run, counted by Stats().RateLimited otherwise
+func (s *CacheBase[T, I]) allowDB(key string) bool {
+	if s.strongRead || s.dbLimiter.Allow(key) {
+		return true
+	}
+	s.stats.rateLimited()
+	s.trace(TraceRateLimited, nil, key)
+	return false
+}
+
+//rateLimited last known value of key for a rejected database load, ErrRateLimited if there is none
+func rateLimited[V any](limiter *DBRateLimiter, key string) (V, bool, error) {
+	var r V
+	v, exists, known := limiter.lastKnown(key)
+	if !known {
+		return r, false, NewError(ErrRateLimited, nil)
+	}
+	if !exists {
+		return r, false, nil
+	}
+	return v.(V), true, nil
+}
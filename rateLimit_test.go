@@ -0,0 +1,35 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBRateLimiter(t *testing.T) {
+	clock := cachelayer.NewFakeClock(time.Unix(0, 0))
+	l := cachelayer.NewDBRateLimiter(2, 2)
+	l.SetClock(clock)
+	assert.True(t, l.Allow("a"))
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+	// keys are limited separately
+	assert.True(t, l.Allow("b"))
+	clock.Advance(500 * time.Millisecond)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+	clock.Advance(time.Hour)
+	assert.True(t, l.Allow("a"))
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+
+	// idle keys are dropped when full, busy keys are kept
+	l.SetCapacity(2)
+	assert.True(t, l.Allow("c"))
+	assert.False(t, l.Allow("a"))
+
+	var nilLimiter *cachelayer.DBRateLimiter
+	assert.True(t, nilLimiter.Allow("a"))
+}
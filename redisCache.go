@@ -15,14 +15,16 @@ type RedisCache[T Table[I], I IDType] struct {
 	db     DBCRUD[T, I]
 }
 
-func NewRedisCache[T Table[I], I IDType](prefix, table, idField string, db DBCRUD[T, I], red *redis.Client, ttl time.Duration) *RedisCache[T, I] {
-	return &RedisCache[T, I]{
-		CacheBase: &CacheBase[T, I]{prefix: prefix, table: table, idField: idField, ctx: context.Background()},
+func NewRedisCache[T Table[I], I IDType](prefix, table, idField string, db DBCRUD[T, I], red redis.UniversalClient, ttl time.Duration) *RedisCache[T, I] {
+	r := &RedisCache[T, I]{
+		CacheBase: NewCacheBase[T, I](prefix, table, idField, context.Background()),
 		red:       NewRedisJson[T](red, ttl),
 		redId:     NewRedisJson[I](red, ttl),
 		redIds:    NewRedisJson[[]I](red, ttl),
 		db:        db,
 	}
+	r.SetCorruptPolicy(CorruptAsMiss)
+	return r
 }
 
 // func (s *RedisCache[T, I]) SetDB(db DBCRUD[T, I]) {
@@ -32,23 +34,105 @@ func NewRedisCache[T Table[I], I IDType](prefix, table, idField string, db DBCRU
 func (s *RedisCache[T, I]) GetDB() DBCRUD[T, I] {
 	return s.db
 }
+
+//SetCtx set context of cache operations, and of database queries if db supports it(eg. gormredis.Gorm).
+// Deprecated: it mutates a cache shared by goroutines, use WithContext per call
+func (s *RedisCache[T, I]) SetCtx(ctx context.Context) {
+	s.CacheBase.SetCtx(ctx)
+	if c, ok := s.db.(interface{ SetCtx(context.Context) }); ok {
+		c.SetCtx(ctx)
+	}
+}
+
+//SetNullIDFunc override which ids are null, and of the database if it decides null ids itself(eg. gormredis.Gorm inserting or upserting on Save)
+func (s *RedisCache[T, I]) SetNullIDFunc(fn func(id I) bool) {
+	s.CacheBase.SetNullIDFunc(fn)
+	if d, ok := s.db.(interface{ SetNullIDFunc(fn func(id I) bool) }); ok {
+		d.SetNullIDFunc(fn)
+	}
+}
+
+//SetExpirationPolicy set ttl semantics of all cache keys of this cache, maxTTL is only used by ExpirationSlidingWithMax
+func (s *RedisCache[T, I]) SetExpirationPolicy(policy ExpirationPolicy, maxTTL time.Duration) {
+	s.red.SetExpirationPolicy(policy, maxTTL)
+	s.redId.SetExpirationPolicy(policy, maxTTL)
+	s.redIds.SetExpirationPolicy(policy, maxTTL)
+}
+func (s *RedisCache[T, I]) SetSerializer(serializer Serializer) {
+	s.red.SetSerializer(serializer)
+	s.redId.SetSerializer(serializer)
+	s.redIds.SetSerializer(serializer)
+}
+
+//SetNullTTL ttl of cached "not found" entries, 0 means same as ttl
+func (s *RedisCache[T, I]) SetNullTTL(nullTTL time.Duration) {
+	s.red.SetNullTTL(nullTTL)
+	s.redId.SetNullTTL(nullTTL)
+	s.redIds.SetNullTTL(nullTTL)
+}
 func (s *RedisCache[T, I]) Close() error {
 	return s.db.Close()
 }
+//ClearCache delete cache keys of all objs, their counts, their translations, OR queries of their index keys and filter results(see ListWhere)
 func (s *RedisCache[T, I]) ClearCache(objs ...T) error {
+	return s.clearCache(true, nil, objs...)
+}
+
+//ClearCacheFor clear cache of a batch of records changed outside the cache, eg. by a consumer of a change feed. All id, index,
+// count and related keys are computed first and deleted by one pipeline, same as ClearCache
+func (s *RedisCache[T, I]) ClearCacheFor(objs ...T) error {
+	return s.ClearCache(objs...)
+}
+
+//clearCache see ClearCache, counts are kept if clearCounts is false. Keys in kept were rewritten in place(see SetKeepTTL) and are not deleted
+func (s *RedisCache[T, I]) clearCache(clearCounts bool, kept []string, objs ...T) error {
 	if len(objs) == 0 {
 		return nil
 	}
-	var keys []string
+	keys := s.CacheKeys(objs...)
+	if clearCounts {
+		keys = append(keys, s.CountKeys(objs...)...)
+	}
+	tags := append(s.AnyOfTags(keys), s.localeTags(objs...)...)
+	return s.clearKeys(keys, kept, listIDs[T, I](objs...), append(tags, FilterTag)...)
+}
+
+//ClearKeys delete keys, keys found by reverse index of ids and query results tagged with tags.
+// Keys are collected first and deleted by one pipelined UNLINK, then the invalidation is published
+func (s *RedisCache[T, I]) ClearKeys(keys []string, ids []I, tags ...string) error {
+	return s.clearKeys(keys, nil, ids, tags...)
+}
+
+//clearKeys see ClearKeys, keys in kept are published but not deleted
+func (s *RedisCache[T, I]) clearKeys(keys, kept []string, ids []I, tags ...string) error {
+	members, err := s.listMembers(s.red.UniversalClient, ids, tags)
+	if err != nil {
+		return s.wrapErr("clear_cache", "", err)
+	}
+	keys = UniqueStrings(append(keys, members...))
+	if len(keys) == 0 {
+		return nil
+	}
+	s.stats.invalidate(len(keys))
+	s.red.replicas.markWritten(keys...)
+	SessionFromContext(s.ctx).MarkWritten(keys...)
+	if _, err = s.delKeys(s.red.UniversalClient, s.red.withAuxKeys(exceptKeys(keys, kept))...); err != nil {
+		return s.wrapErr("clear_cache", "", err)
+	}
+	s.publishInvalidation(keys, ids...)
+	return nil
+}
+
+//CacheKeys id keys, index keys and keys of related tables(see RelatedTable) of objs, without keys found by reverse index
+func (s *RedisCache[T, I]) CacheKeys(objs ...T) []string {
+	keys := s.relatedKeys(objs...)
 	for _, v := range objs {
 		keys = append(keys, s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID())))
 		for _, u := range v.ListIndexes() {
 			keys = append(keys, s.MakeCacheKey(u))
 		}
 	}
-	keys = UniqueStrings(keys)
-	return s.red.Del(s.ctx, keys...).Err()
-
+	return UniqueStrings(keys)
 }
 
 // func (s *RedisCache[T, I]) ClearCacheRaw(id I, indexes Indexes) error {
@@ -64,115 +148,363 @@ func (s *RedisCache[T, I]) ClearCache(objs ...T) error {
 // }
 
 func (s *RedisCache[T, I]) Create(obj *T) error {
+	if _, err := s.FillID(obj); err != nil {
+		return s.wrapErr("create", "", err)
+	}
 	if err := s.db.Create(obj); err != nil {
-		return err
+		return s.wrapErr("create", "", err)
 	}
-	s.ClearCache(*obj)
+	s.report("invalidation", s.clearCounted(1, *obj))
 	// s.ClearCache((*obj).GetID(), (*obj).ListIndexes())
+	s.report("write_through", s.writeThrough(*obj))
 	return nil
 }
 func (s *RedisCache[T, I]) Delete(ids ...I) (int64, error) {
+	_, rowsAffected, err := s.delete("delete", ids...)
+	return rowsAffected, err
+}
+
+//DeleteReturning delete records and return the deleted ones, eg. for audit logs. Missing ids are skipped.
+// Rows are deleted and returned atomically if db implements DeleteReturner, otherwise they are listed before the delete
+// and a write committed in between is not reflected
+func (s *RedisCache[T, I]) DeleteReturning(ids ...I) ([]T, error) {
+	d, ok := s.db.(DeleteReturner[T, I])
+	if !ok {
+		objs, _, err := s.delete("delete_returning", ids...)
+		return objs, err
+	}
+	objs, err := d.DeleteReturning(ids...)
+	if err != nil {
+		return nil, s.wrapErr("delete_returning", "", err)
+	}
+	s.report("invalidation", s.clearCounted(-1, objs...))
+	return objs, nil
+}
+
+func (s *RedisCache[T, I]) delete(op string, ids ...I) ([]T, int64, error) {
 	objs, err := s.List(ids...)
 	if err != nil {
-		return 0, err
+		return nil, 0, s.wrapErr(op, "", err)
 	}
+	objs = s.existingRecords(objs)
 	rowsAffected, err := s.db.Delete(ids...)
 	if err != nil {
-		return 0, err
+		return nil, 0, s.wrapErr(op, "", err)
 	}
-	s.ClearCache(objs...)
+	s.report("invalidation", s.clearCounted(-1, objs...))
 	// for _, v := range objs {
 	// 	err = s.ClearCache(v.GetID(), v.ListIndexes())
 	// }
-	return rowsAffected, err
+	return objs, rowsAffected, s.wrapErr(op, "", err)
 }
+//Upsert insert obj or update the row conflicting on conflictColumns(id if empty) atomically, db must implement Upserter.
+// Index keys of both the replaced row and obj are cleared
+func (s *RedisCache[T, I]) Upsert(obj *T, conflictColumns ...string) error {
+	u, ok := s.db.(Upserter[T, I])
+	if !ok {
+		return s.wrapErr("upsert", "", ErrUpsertNotSupported)
+	}
+	old, existed, err := u.Upsert(obj, conflictColumns...)
+	if err != nil {
+		return s.wrapErr("upsert", "", err)
+	}
+	if existed {
+		err = s.clearCache(true, s.rewrite(*obj), old, *obj)
+	} else {
+		err = s.ClearCache(*obj)
+	}
+	if err != nil {
+		return s.wrapErr("upsert", "", err)
+	}
+	s.report("write_through", s.writeThrough(*obj))
+	return nil
+}
+
+//Save upsert obj if db implements Upserter, otherwise create obj if it is not found or update it
 func (s *RedisCache[T, I]) Save(obj *T) error {
-	old, exists, err := s.Get((*obj).GetID())
+	if _, ok := s.db.(Upserter[T, I]); ok && !s.IsNullID((*obj).GetID()) {
+		return s.Upsert(obj)
+	}
+	old, exists, _, err := s.getWithStale((*obj).GetID())
 	if err != nil {
-		return err
+		return s.wrapErr("save", "", err)
 	}
-	if IsNullID((*obj).GetID()) || !exists {
+	if s.IsNullID((*obj).GetID()) || !exists {
 		if err := s.db.Create(obj); err != nil {
-			return err
+			return s.wrapErr("save", "", err)
 		}
 	} else {
 		if err := s.db.Save(obj); err != nil {
-			return err
+			return s.wrapErr("save", "", err)
 		}
 	}
-	s.ClearCache(old, *obj)
+	s.report("invalidation", s.clearCache(true, s.rewrite(*obj), old, *obj))
+	s.report("write_through", s.writeThrough(*obj))
 	return nil
 }
 
 //Update values can be struct or map[string]interface{}
 func (s *RedisCache[T, I]) Update(id I, values interface{}) (int64, error) {
-	if IsNullID(id) {
+	if s.IsNullID(id) {
 		return 0, nil
 	}
-	old, _, err := s.Get(id)
+	old, _, _, err := s.getWithStale(id)
 	if err != nil {
-		return 0, err
+		return 0, s.wrapErr("update", "", err)
 	}
 	effectedRows, err := s.db.Update(id, values)
 	if err != nil {
-		return 0, err
-	}
-
-	obj, _, err := s.Get(id)
-	s.ClearCache(old, obj)
+		return 0, s.wrapErr("update", "", err)
+	}
+	// values may be partial, read the updated record back to clear keys of its new values, and to rewrite or cache it
+	key := s.MakeCacheKey(NewIndex(s.GetIdField(), id))
+	start := s.clock.Now()
+	fresh, exists, err := s.db.Get(id)
+	s.dbLoaded(start, rowsOf(exists), err, key)
+	if err != nil || !exists {
+		s.report("load", err)
+		s.report("invalidation", s.ClearCache(old))
+		return effectedRows, nil
+	}
+	if s.red.keepTTL || s.writeMode == WriteThrough {
+		s.report("invalidation", s.clearCache(true, s.rewrite(fresh), old, fresh))
+		s.report("write_through", s.writeThrough(fresh))
+		return effectedRows, nil
+	}
+	s.report("invalidation", s.ClearCache(old, fresh))
 	// err = s.ClearCache(old.GetID(), old.ListIndexes().Merge(obj.ListIndexes()))
-	return effectedRows, err
+	return effectedRows, nil
 }
 
+//Get serve stale copy if database fails and grace is set, see GetWithStale
 func (s *RedisCache[T, I]) Get(id I) (T, bool, error) {
-	redisKey := s.MakeCacheKey(NewIndex(s.GetIdField(), id))
-	r, exists, err := s.red.GetJson(redisKey)
-	if err != nil {
-		return r, false, err
+	r, exists, _, err := s.GetWithStale(id)
+	return r, exists, err
+}
+
+//WithoutNegativeCache return a copy of the cache which does not cache "not found" results, eg. cache.WithoutNegativeCache().Get(id)
+func (s *RedisCache[T, I]) WithoutNegativeCache() *RedisCache[T, I] {
+	r := *s
+	base := *s.CacheBase
+	base.noNegativeCache = true
+	r.CacheBase = &base
+	return &r
+}
+
+//WithCacheKeyPrefix return a copy of the cache whose keys start with prefix, eg. to isolate tenants
+func (s *RedisCache[T, I]) WithCacheKeyPrefix(prefix string) *RedisCache[T, I] {
+	r := *s
+	base := *s.CacheBase
+	base.SetCacheKeyPrefix(prefix)
+	r.CacheBase = &base
+	return &r
+}
+
+//WithContext return a copy of the cache whose calls carry ctx, eg. a context of WithTrace or with a deadline. Redis calls and database
+// queries(if db implements ContextDB, eg. gormredis.Gorm) use ctx too. The cache itself is not changed, so it is safe for concurrent calls
+func (s *RedisCache[T, I]) WithContext(ctx context.Context) *RedisCache[T, I] {
+	r := *s
+	base := *s.CacheBase
+	base.ctx = ctx
+	r.CacheBase = &base
+	r.red = s.red.withContext(ctx)
+	r.redId = s.redId.withContext(ctx)
+	r.redIds = s.redIds.withContext(ctx)
+	if c, ok := s.db.(ContextDB[T, I]); ok {
+		r.db = c.WithContext(ctx)
+	}
+	return &r
+}
+
+//StrongRead return a copy of the cache whose reads skip redis and query the database, from the primary if db implements PrimaryDB,
+// eg. cache.StrongRead().Get(id) before a payment. Loaded records are written back to the cache, so it is refreshed too
+func (s *RedisCache[T, I]) StrongRead() *RedisCache[T, I] {
+	r := *s
+	base := *s.CacheBase
+	base.strongRead = true
+	r.CacheBase = &base
+	if p, ok := s.db.(PrimaryDB[T, I]); ok {
+		r.db = p.Primary()
+	}
+	return &r
+}
+
+//WithDB return a copy of the cache loading records from db, eg. a database client with another read preference
+func (s *RedisCache[T, I]) WithDB(db DBCRUD[T, I]) *RedisCache[T, I] {
+	r := *s
+	r.db = db
+	return &r
+}
+
+//SetKeepTTL rewrite cached records in place keeping their original expiration on Save/Update/Upsert, instead of deleting them,
+// so frequently updated records still expire. Records not cached are left to the next read(or WriteThrough). Requires redis >= 6.0
+func (s *RedisCache[T, I]) SetKeepTTL(keepTTL bool) {
+	s.red.SetKeepTTL(keepTTL)
+}
+
+//rewrite rewrite cached records of objs in place if SetKeepTTL is set, return their keys
+func (s *RedisCache[T, I]) rewrite(objs ...T) []string {
+	if !s.red.keepTTL {
+		return nil
 	}
-	if exists {
-		s.red.Expires(redisKey)
-		return r, true, nil
+	var r []string
+	for _, v := range objs {
+		if s.IsNullID(v.GetID()) {
+			continue
+		}
+		key := s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID()))
+		ok, err := s.red.RewriteJson(key, v)
+		s.report("rewrite", err)
+		if ok {
+			r = append(r, key)
+		}
 	}
-	r, exists, err = s.db.Get(id)
-	if err != nil {
-		return r, false, err
+	return r
+}
+
+//exceptKeys keys not in except
+func exceptKeys(keys, except []string) []string {
+	if len(except) == 0 {
+		return keys
+	}
+	skip := make(map[string]bool, len(except))
+	for _, v := range except {
+		skip[v] = true
+	}
+	r := make([]string, 0, len(keys))
+	for _, v := range keys {
+		if !skip[v] {
+			r = append(r, v)
+		}
 	}
-	if !exists {
-		err = s.red.SetNull(redisKey)
-		return r, exists, err
+	return r
+}
+
+//GetTTL return remaining ttl of cached record, exists is false if the record is not cached
+func (s *RedisCache[T, I]) GetTTL(id I) (time.Duration, bool, error) {
+	key := s.MakeCacheKey(NewIndex(s.GetIdField(), id))
+	ttl, exists, err := s.red.KeyTTL(key)
+	return ttl, exists, s.wrapErr("get_ttl", key, err)
+}
+
+//Touch extend ttl of cached records without reading them, return count of records touched
+func (s *RedisCache[T, I]) Touch(ids ...I) (int64, error) {
+	keys := make([]string, len(ids))
+	for i, v := range ids {
+		keys[i] = s.MakeCacheKey(NewIndex(s.GetIdField(), v))
 	}
-	err = s.red.SetJson(redisKey, r)
-	return r, true, err
+	n, err := s.red.Touch(keys...)
+	return n, s.wrapErr("touch", "", err)
 }
 
-//List list records by ids, order & empty records keeped
+//List list records by ids, the i-th record is the record of ids[i] and a zero record if it is not found, whatever the order of database results
 func (s *RedisCache[T, I]) List(ids ...I) ([]T, error) {
 	// fetch records from redis by ids
 	redisKeys := make([]string, len(ids))
 	for i, v := range ids {
 		redisKeys[i] = s.MakeCacheKey(NewIndex(s.GetIdField(), v))
 	}
-	cachedRecords, missedIndexes, err := s.red.MGetJson(redisKeys)
-	if err != nil {
-		return nil, err
+	var cachedRecords []T
+	var missedIndexes []int
+	//stale copies of entries which outlived ttl in grace mode, by index
+	var stale map[int]T
+	//cacheErr failed redis read, the database is read instead out of FailCache
+	var cacheErr error
+	var err error
+	start := s.clock.Now()
+	if !s.strongRead {
+		s.hotKeys.Record(redisKeys...)
+		cachedRecords, missedIndexes, stale, err = s.red.mgetJson(redisKeys, true)
+		s.observe(OpCacheRead, "", start, err)
+		if err != nil {
+			if s.failureMode == FailCache {
+				return nil, s.wrapErr("list", "", err)
+			}
+			s.report("cache_read", err)
+			cacheErr, err = err, nil
+		}
+	}
+	if s.strongRead || cacheErr != nil {
+		cachedRecords = make([]T, len(ids))
+		missedIndexes = make([]int, len(ids))
+		for i := range ids {
+			missedIndexes[i] = i
+		}
+	}
+	if session := SessionFromContext(s.ctx); session != nil {
+		// records written by the session are reloaded, the cached ones may be stale
+		missed := make(map[int]bool, len(missedIndexes))
+		for _, v := range missedIndexes {
+			missed[v] = true
+		}
+		var zero T
+		for i, v := range redisKeys {
+			if !missed[i] && session.Written(v) {
+				cachedRecords[i] = zero
+				missedIndexes = append(missedIndexes, i)
+			}
+			delete(stale, i)
+		}
 	}
+	s.stats.hit(len(ids) - len(missedIndexes))
+	s.stats.miss(len(missedIndexes))
+	if s.lastValues != nil {
+		missed := make(map[int]bool, len(missedIndexes))
+		for _, v := range missedIndexes {
+			missed[v] = true
+		}
+		for i, v := range cachedRecords {
+			if !missed[i] {
+				s.lastValues.put(redisKeys[i], v, !s.IsNullID(v.GetID()))
+			}
+		}
+	}
+	s.report("refresh", s.red.Refresh(slidingKeys(redisKeys, cachedRecords, missedIndexes)...))
 	if len(missedIndexes) == 0 {
-		s.red.Expires(redisKeys...)
-		return cachedRecords, err
+		s.trace(TraceHit, nil, redisKeys...)
+		return cachedRecords, s.wrapErr("list", "", err)
 	}
 	cachedIdIndexMap := make(map[I]bool, len(cachedRecords))
 
 	for _, v := range cachedRecords {
 		cachedIdIndexMap[v.GetID()] = true
 	}
-	// 没有命中的Id(key)
-	missedIds := make([]I, len(missedIndexes))
-	//没有命中的id索引
-	missedIdIndexMap := make(map[I]int)
-	for i, v := range missedIndexes {
-		missedIds[i] = ids[v]
-		missedIdIndexMap[ids[v]] = i
+	// 没有命中的Id(key), each id once
+	missedIds := make([]I, 0, len(missedIndexes))
+	//没有命中的id在ids中的位置, an id may be requested more than once
+	missedPositions := make(map[I][]int, len(missedIndexes))
+	missedKeys := make([]string, 0, len(missedIndexes))
+	for _, v := range missedIndexes {
+		if _, ok := missedPositions[ids[v]]; !ok {
+			missedIds = append(missedIds, ids[v])
+			missedKeys = append(missedKeys, redisKeys[v])
+		}
+		missedPositions[ids[v]] = append(missedPositions[ids[v]], v)
+	}
+	s.trace(TraceMiss, nil, missedKeys...)
+	if s.dbLimiter != nil {
+		// rate limited ids are served by values loaded before, and neither queried nor cached
+		allowed := make([]I, 0, len(missedIds))
+		allowedKeys := make([]string, 0, len(missedIds))
+		for i, v := range missedIds {
+			if s.allowDB(missedKeys[i]) {
+				allowed = append(allowed, v)
+				allowedKeys = append(allowedKeys, missedKeys[i])
+				continue
+			}
+			obj, exists, err := rateLimited[T](s.dbLimiter, missedKeys[i])
+			if err != nil {
+				return cachedRecords, s.wrapErr("list", missedKeys[i], err)
+			}
+			if exists {
+				for _, p := range missedPositions[v] {
+					cachedRecords[p] = obj
+				}
+			}
+		}
+		if missedIds, missedKeys = allowed, allowedKeys; len(missedIds) == 0 {
+			return cachedRecords, nil
+		}
 	}
 
 	// for i, v := range ids {
@@ -186,83 +518,194 @@ func (s *RedisCache[T, I]) List(ids ...I) ([]T, error) {
 	// }
 	// search missed record from database
 	var missedRecords []T
+	gen := s.generation.current()
+	start = s.clock.Now()
 	missedRecords, err = s.db.List(missedIds...)
+	s.dbLoaded(start, len(missedRecords), err, missedKeys...)
+	if err != nil && cacheErr != nil {
+		dbErr := err
+		for _, v := range missedIds {
+			obj, exists, err := failed[T](s.failureMode, s.lastValues, s.MakeCacheKey(NewIndex(s.GetIdField(), v)), cacheErr, dbErr)
+			if err != nil {
+				return cachedRecords, s.wrapErr("list", "", err)
+			}
+			if exists {
+				for _, p := range missedPositions[v] {
+					cachedRecords[p] = obj
+				}
+			}
+		}
+		return cachedRecords, nil
+	}
+	if err != nil && len(stale) > 0 && s.serveStale(cachedRecords, stale, missedIds, missedPositions) {
+		return cachedRecords, nil
+	}
 	if err != nil {
-		return cachedRecords, err
+		return cachedRecords, s.wrapErr("list", "", err)
 	}
 	needToCache := make(map[string]interface{}, len(missedRecords))
-	needToCacheNull := make([]string, len(missedIds)-len(missedRecords))
+	refs := make(map[string][]I, len(missedRecords))
+	var needToCacheNull []string
 
 	//数据库中存在的id
 	dbIds := make(map[I]bool)
 	for _, v := range missedRecords {
 		needToCache[s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID()))] = v
-		cachedRecords[missedIdIndexMap[v.GetID()]] = v
+		refs[s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID()))] = []I{v.GetID()}
+		for _, p := range missedPositions[v.GetID()] {
+			cachedRecords[p] = v
+		}
 		dbIds[v.GetID()] = true
+		s.dbLimiter.remember(s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID())), v, true)
+		s.lastValues.put(s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID())), v, true)
 	}
 	//数据库中不存在的objs
-	i := 0
 	for _, v := range missedIds {
 		if !dbIds[v] {
-			needToCacheNull[i] = s.MakeCacheKey(NewIndex(s.GetIdField(), v))
-			i++
+			key := s.MakeCacheKey(NewIndex(s.GetIdField(), v))
+			needToCacheNull = append(needToCacheNull, key)
+			s.dbLimiter.remember(key, nil, false)
+			s.lastValues.put(key, nil, false)
 		}
 	}
-	s.red.MSetJson(needToCache)
-	s.red.MSetNull(needToCacheNull)
+	s.report("populate", s.populate(gen, func() error {
+		if err := s.red.MSetJson(needToCache); err != nil {
+			return err
+		}
+		if err := s.addRefs(s.red.UniversalClient, s.red.storeTTL(), refs); err != nil {
+			return err
+		}
+		if !s.noNegativeCache {
+			return s.red.MSetNull(needToCacheNull)
+		}
+		return nil
+	}))
 	return cachedRecords, nil
 
 }
 func (s *RedisCache[T, I]) GetBy(index Index) (T, bool, error) {
-	// fetch id from redis
 	redisKey := s.MakeCacheKey(index)
+	if !s.IsUniqueIndex(index) {
+		objs, err := s.ListBy(index, nil)
+		if err != nil || len(objs) == 0 {
+			var r T
+			return r, false, s.notFound("get_by", redisKey, false, err)
+		}
+		return objs[0], true, nil
+	}
+	// fetch id from redis
 	var r T
-	cachedId, exists, err := s.redId.GetJson(redisKey)
-	if err != nil && err != redis.Nil {
-		return r, false, err
+	var cachedId I
+	var exists, isNull bool
+	var err error
+	start := s.clock.Now()
+	if !s.strongRead {
+		s.hotKeys.Record(redisKey)
+		cachedId, exists, isNull, err = s.redId.getJson(redisKey)
+		s.observe(OpCacheRead, redisKey, start, err)
+		if err != nil && s.heal(redisKey, err) {
+			exists, err = false, nil
+		}
+		if err != nil && err != redis.Nil {
+			return r, false, s.wrapErr("get_by", redisKey, err)
+		}
+		exists = exists && !s.sessionWritten(redisKey)
 	}
-	if exists && IsNullID(cachedId) {
-		return r, false, nil
+	if exists && (isNull || s.IsNullID(cachedId)) {
+		s.stats.hit(1)
+		s.stats.nullHit()
+		s.trace(TraceNullHit, nil, redisKey)
+		return r, false, s.notFound("get_by", redisKey, false, nil)
 	}
 	if exists {
-		s.red.Expires(redisKey)
-		return s.Get(cachedId)
+		s.stats.hit(1)
+		s.trace(TraceHit, nil, redisKey)
+		s.report("refresh", s.red.Refresh(redisKey))
+		r, exists, _, err = s.getWithStale(cachedId)
+		return r, exists, s.notFound("get_by", redisKey, exists, err)
 	}
 	// search from db
-	r, exists, err = s.db.GetBy(index)
+	s.stats.miss(1)
+	s.trace(TraceMiss, nil, redisKey)
+	if !s.allowDB(redisKey) {
+		r, exists, err = rateLimited[T](s.dbLimiter, redisKey)
+		return r, exists, s.notFound("get_by", redisKey, exists, err)
+	}
+	gen := s.generation.current()
+	start = s.clock.Now()
+	r, exists, err = getByUnique[T, I](s.db, index)
+	s.dbLoaded(start, rowsOf(exists), err, redisKey)
 	if err != nil {
-		return r, false, err
+		return r, false, s.wrapErr("get_by", redisKey, err)
 	}
+	s.dbLimiter.remember(redisKey, r, exists)
 	if !exists {
-		err = s.red.SetNull(redisKey)
-		return r, exists, err
+		if !s.noNegativeCache {
+			err = s.populate(gen, func() error { return s.red.SetNull(redisKey) })
+		}
+		return r, exists, s.notFound("get_by", redisKey, exists, err)
 	}
 	// set id to redis
-	err = s.redId.SetJson(redisKey, r.GetID())
-	return r, true, err
+	id := r.GetID()
+	err = s.populate(gen, func() error {
+		if err := s.redId.SetJson(redisKey, id); err != nil {
+			return err
+		}
+		return s.addRefs(s.red.UniversalClient, s.redId.storeTTL(), map[string][]I{redisKey: {id}})
+	})
+	return r, true, s.wrapErr("get_by", redisKey, err)
 }
 func (s *RedisCache[T, I]) ListBy(index Index, orderBys OrderBys) ([]T, error) {
 	// fetch ids from redis
 	redisKey := s.MakeCacheKey(index)
 	var r []T
-	cachedIds, exists, err := s.redIds.GetJson(redisKey)
-	if err != nil && err != redis.Nil {
-		return nil, err
+	var cachedIds []I
+	var exists bool
+	var err error
+	start := s.clock.Now()
+	if !s.strongRead {
+		s.hotKeys.Record(redisKey)
+		cachedIds, exists, err = s.redIds.GetJson(redisKey)
+		s.observe(OpCacheRead, redisKey, start, err)
+		if err != nil && s.heal(redisKey, err) {
+			exists, err = false, nil
+		}
+		if err != nil && err != redis.Nil {
+			return nil, s.wrapErr("list_by", redisKey, err)
+		}
+		exists = exists && !s.sessionWritten(redisKey)
 	}
 	if exists {
-		s.red.Expires(redisKey)
+		s.stats.hit(1)
+		s.trace(TraceHit, nil, redisKey)
+		s.report("refresh", s.red.Refresh(redisKey))
 		return s.List(cachedIds...)
 	}
 	// search from db
+	s.stats.miss(1)
+	s.trace(TraceMiss, nil, redisKey)
+	if !s.allowDB(redisKey) {
+		r, _, err = rateLimited[[]T](s.dbLimiter, redisKey)
+		return r, s.wrapErr("list_by", redisKey, err)
+	}
+	gen := s.generation.current()
+	start = s.clock.Now()
 	r, err = s.db.ListBy(index, orderBys)
+	s.dbLoaded(start, len(r), err, redisKey)
 	if err != nil {
-		return nil, err
+		return nil, s.wrapErr("list_by", redisKey, err)
 	}
+	s.dbLimiter.remember(redisKey, r, true)
 	ids := make([]I, len(r))
 	for i, v := range r {
 		ids[i] = v.GetID()
 	}
 	// set ids to redis
-	err = s.redIds.SetJson(redisKey, ids)
-	return r, err
+	err = s.populate(gen, func() error {
+		if err := s.redIds.SetJson(redisKey, ids); err != nil {
+			return err
+		}
+		return s.addRefs(s.red.UniversalClient, s.redIds.storeTTL(), map[string][]I{redisKey: ids})
+	})
+	return r, s.wrapErr("list_by", redisKey, err)
 }
@@ -0,0 +1,372 @@
+package cachelayer_test
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+//newMiniRedis embedded redis and a client of it, both closed by the end of t
+func newMiniRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	red := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { red.Close() })
+	return mr, red
+}
+
+type member struct {
+	ID      uint
+	Name    string
+	Email   string
+	GroupID uint
+}
+
+func (s member) GetID() uint {
+	return s.ID
+}
+
+func (s member) ListIndexes() cachelayer.Indexes {
+	return cachelayer.Indexes{}.Add(cachelayer.NewIndex("Email", s.Email)).Add(cachelayer.NewIndex("GroupID", s.GroupID))
+}
+
+func (s member) UniqueIndexes() [][]string {
+	return [][]string{{"Email"}}
+}
+
+//memDB in-memory database of members counting its queries, down fails every call
+type memDB struct {
+	mu      sync.Mutex
+	rows    map[uint]member
+	nextID  uint
+	queries int
+	down    bool
+}
+
+func newMemDB(rows ...member) *memDB {
+	r := &memDB{rows: make(map[uint]member), nextID: 1}
+	for _, v := range rows {
+		r.rows[v.ID] = v
+		if v.ID >= r.nextID {
+			r.nextID = v.ID + 1
+		}
+	}
+	return r
+}
+
+var errDBDown = errors.New("db down")
+
+func (s *memDB) query() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries++
+	if s.down {
+		return errDBDown
+	}
+	return nil
+}
+
+func (s *memDB) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *memDB) Queries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries
+}
+
+func (s *memDB) Create(obj *member) error {
+	if err := s.query(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if obj.ID == 0 {
+		obj.ID = s.nextID
+	}
+	if obj.ID >= s.nextID {
+		s.nextID = obj.ID + 1
+	}
+	s.rows[obj.ID] = *obj
+	return nil
+}
+
+func (s *memDB) Save(obj *member) error {
+	if obj.ID == 0 {
+		return s.Create(obj)
+	}
+	if err := s.query(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows[obj.ID] = *obj
+	return nil
+}
+
+func (s *memDB) Delete(ids ...uint) (int64, error) {
+	if err := s.query(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, v := range ids {
+		if _, ok := s.rows[v]; ok {
+			delete(s.rows, v)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memDB) Update(id uint, values interface{}) (int64, error) {
+	if err := s.query(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rows[id]
+	if !ok {
+		return 0, nil
+	}
+	for k, v := range values.(map[string]interface{}) {
+		switch strings.ToLower(k) {
+		case "name":
+			r.Name = v.(string)
+		case "email":
+			r.Email = v.(string)
+		case "groupid", "group_id":
+			r.GroupID = v.(uint)
+		}
+	}
+	s.rows[id] = r
+	return 1, nil
+}
+
+func (s *memDB) Get(id uint) (member, bool, error) {
+	if err := s.query(); err != nil {
+		return member{}, false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rows[id]
+	return r, ok, nil
+}
+
+func (s *memDB) List(ids ...uint) ([]member, error) {
+	if err := s.query(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r []member
+	for _, v := range ids {
+		if m, ok := s.rows[v]; ok {
+			r = append(r, m)
+		}
+	}
+	return r, nil
+}
+
+func (s *memDB) GetBy(index cachelayer.Index) (member, bool, error) {
+	r, err := s.ListBy(index, nil)
+	if err != nil || len(r) == 0 {
+		return member{}, false, err
+	}
+	return r[0], true, nil
+}
+
+func (s *memDB) ListBy(index cachelayer.Index, orderBys cachelayer.OrderBys) ([]member, error) {
+	if err := s.query(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r []member
+	for _, v := range s.rows {
+		if matches(v, index) {
+			r = append(r, v)
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].ID < r[j].ID })
+	return r, nil
+}
+
+func (s *memDB) ListAll() ([]member, error) {
+	return s.ListBy(nil, nil)
+}
+
+func (s *memDB) Close() error {
+	return nil
+}
+
+//matches whether m has every field value of index
+func matches(m member, index cachelayer.Index) bool {
+	for k, v := range index {
+		var field interface{}
+		switch strings.ToLower(k) {
+		case "id":
+			field = m.ID
+		case "name":
+			field = m.Name
+		case "email":
+			field = m.Email
+		case "groupid":
+			field = m.GroupID
+		}
+		if cachelayer.KeyValue(field) != cachelayer.KeyValue(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func newMemberCache(t *testing.T, rows ...member) (*cachelayer.RedisCache[member, uint], *memDB, *miniredis.Miniredis) {
+	mr, red := newMiniRedis(t)
+	db := newMemDB(rows...)
+	return cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute), db, mr
+}
+
+func TestRedisCacheReadThrough(t *testing.T) {
+	cache, db, _ := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1}, member{ID: 2, Name: "ann", Email: "ann@x.com", GroupID: 1})
+	r, exists, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "tom", r.Name)
+	_, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, db.Queries())
+
+	objs, err := cache.List(2, 1, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{2, 1, 0}, []uint{objs[0].ID, objs[1].ID, objs[2].ID})
+	assert.Equal(t, 2, db.Queries())
+	_, err = cache.List(2, 1, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, db.Queries())
+
+	r, exists, err = cache.GetBy(cachelayer.NewIndex("Email", "ann@x.com"))
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, uint(2), r.ID)
+	objs, err = cache.ListBy(cachelayer.NewIndex("GroupID", 1), nil)
+	assert.Nil(t, err)
+	assert.Len(t, objs, 2)
+}
+
+func TestNegativeCache(t *testing.T) {
+	cache, db, mr := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1})
+	for i := 0; i < 2; i++ {
+		_, exists, err := cache.Get(9)
+		assert.Nil(t, err)
+		assert.False(t, exists)
+	}
+	assert.Equal(t, 1, db.Queries())
+	assert.Len(t, mr.Keys(), 1)
+
+	mr.FlushAll()
+	cache.SetNegativeCache(false)
+	for i := 0; i < 2; i++ {
+		_, exists, err := cache.Get(9)
+		assert.Nil(t, err)
+		assert.False(t, exists)
+		_, exists, err = cache.GetBy(cachelayer.NewIndex("Email", "ann@x.com"))
+		assert.Nil(t, err)
+		assert.False(t, exists)
+	}
+	assert.Equal(t, 5, db.Queries())
+	assert.Empty(t, mr.Keys())
+
+	cache.SetNegativeCache(true)
+	objs, err := cache.WithoutNegativeCache().List(9, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), objs[1].ID)
+	assert.Len(t, mr.Keys(), 1)
+}
+
+func TestDeleteClearsInOneRoundTrip(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	counter := newCmdCounter()
+	red.AddHook(counter)
+	db := newMemDB(member{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1}, member{ID: 2, Name: "ann", Email: "ann@x.com", GroupID: 1}, member{ID: 3, Name: "bob", Email: "bob@x.com", GroupID: 2})
+	cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	cache.SetReverseIndex(true)
+	_, err := cache.List(1, 2, 3)
+	assert.Nil(t, err)
+	for _, v := range []string{"tom@x.com", "ann@x.com", "bob@x.com"} {
+		_, _, err = cache.GetBy(cachelayer.NewIndex("Email", v))
+		assert.Nil(t, err)
+	}
+	_, err = cache.ListBy(cachelayer.NewIndex("GroupID", 1), nil)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, mr.Keys())
+
+	n, err := cache.Delete(1, 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+	// keys of all entities and the lists referring to them go in a single UNLINK
+	assert.Equal(t, 1, counter.Count("unlink"))
+	assert.Equal(t, 0, counter.Count("del"))
+	assert.Empty(t, mr.Keys())
+}
+
+//returningDB memDB deleting and returning rows under one lock
+type returningDB struct {
+	*memDB
+}
+
+func (s returningDB) DeleteReturning(ids ...uint) ([]member, error) {
+	if err := s.query(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var r []member
+	for _, v := range ids {
+		if obj, ok := s.rows[v]; ok {
+			delete(s.rows, v)
+			r = append(r, obj)
+		}
+	}
+	return r, nil
+}
+
+func TestDeleteReturning(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	db := returningDB{newMemDB(member{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1}, member{ID: 2, Name: "ann", Email: "ann@x.com", GroupID: 1})}
+	cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	_, err := cache.List(1, 2)
+	assert.Nil(t, err)
+	_, _, err = cache.GetBy(cachelayer.NewIndex("Email", "tom@x.com"))
+	assert.Nil(t, err)
+	// changed behind the cache, the deleted version is returned rather than the cached one
+	_, err = db.Update(1, map[string]interface{}{"name": "jerry"})
+	assert.Nil(t, err)
+	queries := db.Queries()
+	objs, err := cache.DeleteReturning(1, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []member{{ID: 1, Name: "jerry", Email: "tom@x.com", GroupID: 1}}, objs)
+	// deleted and returned by one call, no read before
+	assert.Equal(t, queries+1, db.Queries())
+	assert.Equal(t, []string{cache.MakeCacheKey(cachelayer.NewIndex("ID", 2))}, mr.Keys())
+
+	full := cachelayer.NewFullRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	assert.Nil(t, full.Load())
+	objs, err = full.DeleteReturning(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []member{{ID: 2, Name: "ann", Email: "ann@x.com", GroupID: 1}}, objs)
+	all, err := full.ListAll()
+	assert.Nil(t, err)
+	assert.Empty(t, all)
+}
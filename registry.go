@@ -0,0 +1,164 @@
+package cachelayer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//ManagedCache cache managed by Registry, implemented by RedisCache and FullRedisCache
+type ManagedCache interface {
+	GetTableName() string
+	Stats() Stats
+	HotKeys(n int) []HotKey
+	Refresh() error
+	ClearAll() error
+	Close() error
+}
+
+//Loader cache which can load full data in advance, eg. FullRedisCache
+type Loader interface {
+	Load() error
+}
+
+//Verifier cache which can diff its keys against database, eg. RedisCache and FullRedisCache
+type Verifier interface {
+	Verify() (VerifyReport, error)
+}
+
+//MemoryReporter cache which can estimate its redis memory, eg. RedisCache and FullRedisCache
+type MemoryReporter interface {
+	MemoryUsage(samples int) (MemoryReport, error)
+}
+
+//Registry all caches of a service by name, for bulk lifecycle management
+type Registry struct {
+	mu     sync.RWMutex
+	caches map[string]ManagedCache
+	//stoppers background components stopped by Shutdown
+	stoppers     []func(ctx context.Context) error
+	connections  []io.Closer
+	warmProgress func(p WarmProgress)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{caches: make(map[string]ManagedCache)}
+}
+
+//Register return ErrConflict if name is registered
+func (s *Registry) Register(name string, cache ManagedCache) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.caches[name]; ok {
+		return NewError(ErrConflict, fmt.Errorf("cache %s is registered", name))
+	}
+	s.caches[name] = cache
+	return nil
+}
+
+func (s *Registry) Unregister(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.caches, name)
+}
+
+func (s *Registry) Get(name string) (ManagedCache, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.caches[name]
+	return c, ok
+}
+
+//GetByTable caches of table, table name is case insensitive
+func (s *Registry) GetByTable(table string) []ManagedCache {
+	var r []ManagedCache
+	for _, name := range s.Names() {
+		c, _ := s.Get(name)
+		if c != nil && strings.EqualFold(c.GetTableName(), table) {
+			r = append(r, c)
+		}
+	}
+	return r
+}
+
+//Names sorted names of registered caches
+func (s *Registry) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := make([]string, 0, len(s.caches))
+	for k := range s.caches {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+//each call fn for every cache in name order, continue on error and return the first error
+func (s *Registry) each(fn func(name string, c ManagedCache) error) error {
+	var first error
+	for _, name := range s.Names() {
+		c, ok := s.Get(name)
+		if !ok {
+			continue
+		}
+		if err := fn(name, c); err != nil && first == nil {
+			first = fmt.Errorf("cache %s: %w", name, err)
+		}
+	}
+	return first
+}
+
+//WarmUp load full data of caches implementing Loader one by one, see WarmAll to load them concurrently
+func (s *Registry) WarmUp() error {
+	return s.each(func(name string, c ManagedCache) error {
+		if l, ok := c.(Loader); ok {
+			return l.Load()
+		}
+		return nil
+	})
+}
+
+//Close close all caches
+func (s *Registry) Close() error {
+	return s.each(func(name string, c ManagedCache) error {
+		return c.Close()
+	})
+}
+
+//Stats stats of every cache by name
+func (s *Registry) Stats() map[string]Stats {
+	r := make(map[string]Stats)
+	s.each(func(name string, c ManagedCache) error {
+		r[name] = c.Stats()
+		return nil
+	})
+	return r
+}
+
+//TotalStats sum of stats of all caches
+func (s *Registry) TotalStats() Stats {
+	var r Stats
+	for _, v := range s.Stats() {
+		r.Hits += v.Hits
+		r.Misses += v.Misses
+		r.NullHits += v.NullHits
+		r.DBLoads += v.DBLoads
+		r.DBErrors += v.DBErrors
+		r.Invalidations += v.Invalidations
+		r.Sampled += v.Sampled
+		r.Drifted += v.Drifted
+		r.PersistentKeys += v.PersistentKeys
+		r.RateLimited += v.RateLimited
+		r.Corrupted += v.Corrupted
+		for op, l := range v.Latency {
+			if r.Latency == nil {
+				r.Latency = make(map[string]Latency)
+			}
+			r.Latency[op] = r.Latency[op].merge(l)
+		}
+	}
+	return r
+}
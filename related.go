@@ -0,0 +1,47 @@
+package cachelayer
+
+//Relation cache keys of another table depending on an entity, eg. an order item declares the id key of its order and the key of the order summary holding totals
+type Relation struct {
+	Table   string
+	Indexes Indexes
+}
+
+//NewRelation relation to keys of table by indexes
+func NewRelation(table string, indexes ...Index) Relation {
+	return Relation{Table: table, Indexes: indexes}
+}
+
+//RelatedTable entity whose writes also invalidate caches of other tables, implemented by T:
+//
+//	func (s OrderItem) ListRelations() []cachelayer.Relation {
+//		return []cachelayer.Relation{cachelayer.NewRelation("order", cachelayer.NewIndex("id", s.OrderID)), cachelayer.NewRelation("order_summary", cachelayer.NewIndex("orderId", s.OrderID))}
+//	}
+//
+// Related tables must share the key prefix of the cache
+type RelatedTable interface {
+	ListRelations() []Relation
+}
+
+//relatedKeys cache keys of other tables declared by objs
+func (s *CacheBase[T, I]) relatedKeys(objs ...T) []string {
+	var keys []string
+	for _, v := range objs {
+		rt, ok := interface{}(v).(RelatedTable)
+		if !ok {
+			return nil
+		}
+		for _, rel := range rt.ListRelations() {
+			for _, index := range rel.Indexes {
+				keys = append(keys, makeCacheKey(s.keyPrefix(), rel.Table, index, s.canonicalKeys))
+			}
+		}
+	}
+	return keys
+}
+
+//hasRelations whether T declares related tables
+func (s *CacheBase[T, I]) hasRelations() bool {
+	var t T
+	_, ok := interface{}(t).(RelatedTable)
+	return ok
+}
@@ -0,0 +1,142 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+//item order item, GroupID is the id of its order
+type item member
+
+func (s item) GetID() uint {
+	return s.ID
+}
+
+func (s item) ListIndexes() cachelayer.Indexes {
+	return cachelayer.Indexes{}.Add(cachelayer.NewIndex("GroupID", s.GroupID))
+}
+
+func (s item) ListRelations() []cachelayer.Relation {
+	return []cachelayer.Relation{cachelayer.NewRelation("order", cachelayer.NewIndex("ID", s.GroupID)), cachelayer.NewRelation("order_summary", cachelayer.NewIndex("OrderID", s.GroupID))}
+}
+
+//itemDB memDB of items
+type itemDB struct {
+	*memDB
+}
+
+func items(objs []member) []item {
+	r := make([]item, len(objs))
+	for i, v := range objs {
+		r[i] = item(v)
+	}
+	return r
+}
+
+func (s itemDB) Create(obj *item) error {
+	m := member(*obj)
+	err := s.memDB.Create(&m)
+	*obj = item(m)
+	return err
+}
+
+func (s itemDB) Save(obj *item) error {
+	m := member(*obj)
+	err := s.memDB.Save(&m)
+	*obj = item(m)
+	return err
+}
+
+func (s itemDB) Get(id uint) (item, bool, error) {
+	r, ok, err := s.memDB.Get(id)
+	return item(r), ok, err
+}
+
+func (s itemDB) List(ids ...uint) ([]item, error) {
+	r, err := s.memDB.List(ids...)
+	return items(r), err
+}
+
+func (s itemDB) GetBy(index cachelayer.Index) (item, bool, error) {
+	r, ok, err := s.memDB.GetBy(index)
+	return item(r), ok, err
+}
+
+func (s itemDB) ListBy(index cachelayer.Index, orderBys cachelayer.OrderBys) ([]item, error) {
+	r, err := s.memDB.ListBy(index, orderBys)
+	return items(r), err
+}
+
+func (s itemDB) ListAll() ([]item, error) {
+	r, err := s.memDB.ListAll()
+	return items(r), err
+}
+
+func TestRelatedKeys(t *testing.T) {
+	for _, full := range []bool{false, true} {
+		name := "redis"
+		if full {
+			name = "full"
+		}
+		t.Run(name, func(t *testing.T) {
+			mr, red := newMiniRedis(t)
+			db := itemDB{newMemDB(member{ID: 1, Name: "a", GroupID: 7}, member{ID: 2, Name: "b", GroupID: 8})}
+			var cache cachelayer.Cache[item, uint]
+			if full {
+				f := cachelayer.NewFullRedisCache[item, uint]("app", "item", "ID", db, red, time.Minute)
+				assert.Nil(t, f.Load())
+				cache = f
+			} else {
+				cache = cachelayer.NewRedisCache[item, uint]("app", "item", "ID", db, red, time.Minute)
+			}
+			order := func(id uint) []string {
+				return []string{"app/order/id/" + cachelayer.Stringify(id, ""), "app/order_summary/orderid/" + cachelayer.Stringify(id, "")}
+			}
+			cached := func(keys []string) bool {
+				for _, v := range keys {
+					if !mr.Exists(v) {
+						return false
+					}
+				}
+				return true
+			}
+			cacheOrders := func() {
+				for _, id := range []uint{7, 8, 9} {
+					for _, v := range order(id) {
+						mr.Set(v, "{}")
+					}
+				}
+			}
+
+			cacheOrders()
+			r := item{Name: "c", GroupID: 9}
+			assert.Nil(t, cache.Create(&r))
+			assert.False(t, mr.Exists(order(9)[0]))
+			assert.False(t, mr.Exists(order(9)[1]))
+			assert.True(t, cached(order(7)))
+
+			// moved to order 8, both orders change
+			cacheOrders()
+			_, err := cache.Update(1, map[string]interface{}{"groupid": uint(8)})
+			assert.Nil(t, err)
+			assert.False(t, cached(order(7)))
+			assert.False(t, mr.Exists(order(8)[0]))
+			assert.True(t, cached(order(9)))
+
+			cacheOrders()
+			_, err = cache.Delete(2)
+			assert.Nil(t, err)
+			assert.False(t, mr.Exists(order(8)[0]))
+			assert.False(t, mr.Exists(order(8)[1]))
+			assert.True(t, cached(order(7)))
+
+			cacheOrders()
+			assert.Nil(t, cache.ClearCache(r))
+			assert.False(t, mr.Exists(order(9)[0]))
+			assert.True(t, cached(order(7)))
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package cachelayer
+
+import (
+	"sync"
+	"time"
+)
+
+type reloadRun struct {
+	done chan struct{}
+	err  error
+}
+
+//reloadDebouncer collapse reload triggers: at most one load starts per window, triggers before a load starts join it,
+// and triggers while a load is running are served by a trailing load starting after it, so every caller sees data loaded after its call
+type reloadDebouncer struct {
+	window  time.Duration
+	mu      sync.Mutex
+	last    time.Time
+	pending *reloadRun
+	running *reloadRun
+}
+
+//do trigger load and wait for the load serving this trigger
+func (s *reloadDebouncer) do(clock Clock, table string, load func() error) error {
+	s.mu.Lock()
+	run := s.pending
+	if run == nil {
+		run = &reloadRun{done: make(chan struct{})}
+		s.pending = run
+		go s.start(clock, table, run, s.running, load)
+	}
+	s.mu.Unlock()
+	<-run.done
+	return run.err
+}
+
+//start run load once prev is done and the window since the last load has passed
+func (s *reloadDebouncer) start(clock Clock, table string, run, prev *reloadRun, load func() error) {
+	if prev != nil {
+		<-prev.done
+	}
+	s.mu.Lock()
+	wait := s.window - clock.Now().Sub(s.last)
+	s.mu.Unlock()
+	if wait > 0 {
+		<-clock.After(wait)
+	}
+	s.mu.Lock()
+	s.pending = nil
+	s.running = run
+	s.last = clock.Now()
+	s.mu.Unlock()
+	run.err = safely("reload", table, load)
+	close(run.done)
+}
+
+//SetReloadDebounce start at most one Load per window, eg. 500ms, so a burst of invalidations(eg. a bulk import through the gorm plugin)
+// does not reload the full hash once per row. Loads triggered meanwhile wait for one trailing Load, 0 disables debouncing
+func (s *FullRedisCache[T, I]) SetReloadDebounce(window time.Duration) {
+	if window <= 0 {
+		s.reloads = nil
+		return
+	}
+	s.reloads = &reloadDebouncer{window: window}
+}
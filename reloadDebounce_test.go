@@ -0,0 +1,45 @@
+package cachelayer_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+type listAllDB struct {
+	cachelayer.DBCRUD[keyUser, uint]
+	loads int32
+}
+
+func (s *listAllDB) ListAll() ([]keyUser, error) {
+	atomic.AddInt32(&s.loads, 1)
+	return nil, nil
+}
+
+func TestReloadDebounce(t *testing.T) {
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer red.Close()
+	db := &listAllDB{}
+	cache := cachelayer.NewFullRedisCache[keyUser, uint]("app", "user", "ID", db, red, time.Minute)
+	cache.SetReloadDebounce(100 * time.Millisecond)
+	cache.Load()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&db.loads))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Load()
+		}()
+	}
+	wg.Wait()
+	// the burst is collapsed into a trailing load, started a window after the first one
+	assert.LessOrEqual(t, atomic.LoadInt32(&db.loads), int32(3))
+	assert.Greater(t, atomic.LoadInt32(&db.loads), int32(1))
+}
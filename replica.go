@@ -0,0 +1,98 @@
+package cachelayer
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//ReplicaReads route cache reads(GET/MGET/HGET...) to redis replicas round robin, writes and invalidations stay on the primary.
+// Keys written or invalidated within forcePrimary are read from the primary, so a process reads its own writes despite replication lag
+type ReplicaReads struct {
+	replicas     []redis.UniversalClient
+	next         uint32
+	forcePrimary time.Duration
+	mu           sync.Mutex
+	written      map[string]time.Time
+	lastPrune    time.Time
+	clock        Clock
+}
+
+//maxRecentWrites prune expired entries of recent writes once there are more of them
+const maxRecentWrites = 10000
+
+func NewReplicaReads(forcePrimary time.Duration, replicas ...redis.UniversalClient) *ReplicaReads {
+	return &ReplicaReads{replicas: replicas, forcePrimary: forcePrimary, written: make(map[string]time.Time), clock: RealClock{}}
+}
+
+//SetClock clock of the read-your-writes window, default RealClock
+func (s *ReplicaReads) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+//client client to read keys from
+func (s *ReplicaReads) client(primary redis.UniversalClient, keys ...string) redis.UniversalClient {
+	if s == nil || len(s.replicas) == 0 {
+		return primary
+	}
+	if s.forcePrimary > 0 {
+		now := s.clock.Now()
+		s.mu.Lock()
+		for _, v := range keys {
+			if at, ok := s.written[v]; ok && now.Sub(at) < s.forcePrimary {
+				s.mu.Unlock()
+				return primary
+			}
+		}
+		s.mu.Unlock()
+	}
+	i := atomic.AddUint32(&s.next, 1)
+	return s.replicas[int(i)%len(s.replicas)]
+}
+
+//markWritten read keys from primary for the next forcePrimary
+func (s *ReplicaReads) markWritten(keys ...string) {
+	if s == nil || s.forcePrimary <= 0 || len(keys) == 0 {
+		return
+	}
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range keys {
+		s.written[v] = now
+	}
+	if len(s.written) > maxRecentWrites && now.Sub(s.lastPrune) > s.forcePrimary {
+		for k, at := range s.written {
+			if now.Sub(at) >= s.forcePrimary {
+				delete(s.written, k)
+			}
+		}
+		s.lastPrune = now
+	}
+}
+
+//SetReplicaReads read entries from replicas, nil to read from the primary
+func (s *RedisJson[T]) SetReplicaReads(replicas *ReplicaReads) {
+	s.replicas = replicas
+}
+
+//reader client to read keys from
+func (s *RedisJson[T]) reader(keys ...string) redis.UniversalClient {
+	return s.replicas.client(s.UniversalClient, keys...)
+}
+
+//SetReplicaReads read cached entries from replicas, see ReplicaReads
+func (s *RedisCache[T, I]) SetReplicaReads(replicas *ReplicaReads) {
+	s.red.SetReplicaReads(replicas)
+	s.redId.SetReplicaReads(replicas)
+	s.redIds.SetReplicaReads(replicas)
+}
+
+//SetReplicaReads read cached entries from replicas, see ReplicaReads
+func (s *FullRedisCache[T, I]) SetReplicaReads(replicas *ReplicaReads) {
+	s.red.SetReplicaReads(replicas)
+	s.redId.SetReplicaReads(replicas)
+	s.redIds.SetReplicaReads(replicas)
+}
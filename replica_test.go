@@ -0,0 +1,46 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaReads(t *testing.T) {
+	_, primary := newMiniRedis(t)
+	_, replica := newMiniRedis(t)
+	db := newMemDB(member{ID: 1, Name: "tom", Email: "tom@x.com"})
+	// the replica lags behind: it still holds tom
+	_, _, err := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, replica, time.Minute).Get(1)
+	assert.Nil(t, err)
+	_, err = db.Update(1, map[string]interface{}{"name": "jerry"})
+	assert.Nil(t, err)
+
+	cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, primary, time.Minute)
+	clock := cachelayer.NewFakeClock(time.Now())
+	replicas := cachelayer.NewReplicaReads(time.Second, replica)
+	replicas.SetClock(clock)
+	cache.SetReplicaReads(replicas)
+	queries := db.Queries()
+	r, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "tom", r.Name)
+	assert.Equal(t, queries, db.Queries())
+
+	// keys invalidated by this process are read from the primary for a while
+	assert.Nil(t, cache.ClearCache(r))
+	r, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "jerry", r.Name)
+	assert.Equal(t, queries+1, db.Queries())
+	r, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "jerry", r.Name)
+
+	clock.Advance(2 * time.Second)
+	r, _, err = cache.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "tom", r.Name)
+}
@@ -0,0 +1,152 @@
+package cachelayer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//ReplicatorStats counters of a Replicator
+type ReplicatorStats struct {
+	Queued   int64
+	Mirrored int64
+	Failed   int64
+	//Dropped commands rejected because the queue was full
+	Dropped int64
+}
+
+//Replicator go-redis hook mirroring cache writes and invalidations to a redis of another region, so the standby region starts warm on failover:
+//
+//	client.AddHook(cachelayer.NewReplicator(secondary, 4, 10000))
+//
+// Mirroring is asynchronous and fire-and-forget: commands are dropped when the queue is full and failures are only counted, see Stats
+type Replicator struct {
+	secondary redis.UniversalClient
+	queue     chan []redis.Cmder
+	wg        sync.WaitGroup
+	mu        sync.RWMutex
+	closed    bool
+	queued    int64
+	mirrored  int64
+	failed    int64
+	dropped   int64
+}
+
+func NewReplicator(secondary redis.UniversalClient, workers, queueSize int) *Replicator {
+	if workers <= 0 {
+		workers = 1
+	}
+	s := &Replicator{secondary: secondary, queue: make(chan []redis.Cmder, queueSize)}
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go labeled(context.Background(), "replicator", func(ctx context.Context) { s.work(ctx) })
+	}
+	return s
+}
+
+func (s *Replicator) work(ctx context.Context) {
+	defer s.wg.Done()
+	for cmds := range s.queue {
+		atomic.AddInt64(&s.queued, -1)
+		safely("replicator", "", func() error {
+			s.mirror(ctx, cmds)
+			return nil
+		})
+	}
+}
+
+//mirror run cmds on the secondary redis in one pipeline
+func (s *Replicator) mirror(ctx context.Context, cmds []redis.Cmder) {
+	p := s.secondary.Pipeline()
+	mirrored := make([]redis.Cmder, len(cmds))
+	for i, cmd := range cmds {
+		mirrored[i] = p.Do(ctx, cmd.Args()...)
+	}
+	p.Exec(ctx)
+	for _, v := range mirrored {
+		if err := v.Err(); err != nil && err != redis.Nil {
+			atomic.AddInt64(&s.failed, 1)
+		} else {
+			atomic.AddInt64(&s.mirrored, 1)
+		}
+	}
+}
+
+//submit enqueue successful write commands of cmds without blocking. Commands are copied,
+// since byte slice arguments may be pooled buffers reused once the hook returns, see payloads
+func (s *Replicator) submit(cmds ...redis.Cmder) {
+	var writes []redis.Cmder
+	for _, cmd := range cmds {
+		if (migrationWrites[cmd.Name()] || migrationDeletes[cmd.Name()]) && cmd.Err() == nil {
+			writes = append(writes, detachedCmd(cmd))
+		}
+	}
+	if len(writes) == 0 {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		atomic.AddInt64(&s.dropped, int64(len(writes)))
+		return
+	}
+	select {
+	case s.queue <- writes:
+		atomic.AddInt64(&s.queued, 1)
+	default:
+		atomic.AddInt64(&s.dropped, int64(len(writes)))
+	}
+}
+
+//detachedCmd copy of cmd owning its arguments
+func detachedCmd(cmd redis.Cmder) redis.Cmder {
+	args := make([]interface{}, len(cmd.Args()))
+	for i, v := range cmd.Args() {
+		if b, ok := v.([]byte); ok {
+			v = append([]byte(nil), b...)
+		}
+		args[i] = v
+	}
+	return redis.NewCmd(context.Background(), args...)
+}
+
+func (s *Replicator) Stats() ReplicatorStats {
+	return ReplicatorStats{
+		Queued:   atomic.LoadInt64(&s.queued),
+		Mirrored: atomic.LoadInt64(&s.mirrored),
+		Failed:   atomic.LoadInt64(&s.failed),
+		Dropped:  atomic.LoadInt64(&s.dropped),
+	}
+}
+
+//Close stop mirroring and wait for queued commands
+func (s *Replicator) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Replicator) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (s *Replicator) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	s.submit(cmd)
+	return nil
+}
+
+func (s *Replicator) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (s *Replicator) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	s.submit(cmds...)
+	return nil
+}
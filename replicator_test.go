@@ -0,0 +1,62 @@
+package cachelayer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicator(t *testing.T) {
+	//commands fail before dialing, no redis is needed
+	secondary := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer secondary.Close()
+	r := cachelayer.NewReplicator(secondary, 1, 10)
+	ctx := context.Background()
+	assert.Nil(t, r.AfterProcess(ctx, redis.NewStatusCmd(ctx, "set", "a", "1")))
+	// reads are not mirrored
+	assert.Nil(t, r.AfterProcess(ctx, redis.NewStringCmd(ctx, "get", "a")))
+	assert.Nil(t, r.AfterProcessPipeline(ctx, []redis.Cmder{redis.NewIntCmd(ctx, "del", "a"), redis.NewIntCmd(ctx, "exists", "a")}))
+	r.Close()
+	assert.Nil(t, r.AfterProcess(ctx, redis.NewStatusCmd(ctx, "set", "a", "1")))
+	assert.Equal(t, cachelayer.ReplicatorStats{Failed: 2, Dropped: 1}, r.Stats())
+}
+
+//gateHook blocks pipelines until open is closed
+type gateHook struct {
+	cmdCounter
+	open chan struct{}
+}
+
+func (s *gateHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	<-s.open
+	return ctx, nil
+}
+
+func TestReplicatorBatchWrites(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	secondaryMr, secondary := newMiniRedis(t)
+	gate := &gateHook{open: make(chan struct{})}
+	secondary.AddHook(gate)
+	r := cachelayer.NewReplicator(secondary, 1, 10)
+	red.AddHook(r)
+	db := newMemDB(member{ID: 1, Name: "tom"}, member{ID: 2, Name: "ann"}, member{ID: 3, Name: "bob"}, member{ID: 4, Name: "joe"})
+	cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	// the second batch reuses the pooled buffer of the first while it is still queued
+	_, err := cache.List(1, 2)
+	assert.Nil(t, err)
+	_, err = cache.List(3, 4)
+	assert.Nil(t, err)
+	close(gate.open)
+	assert.Nil(t, r.Close())
+	for _, key := range mr.Keys() {
+		v, err := mr.Get(key)
+		assert.Nil(t, err)
+		mirrored, err := secondaryMr.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, v, mirrored, key)
+	}
+}
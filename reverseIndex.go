@@ -0,0 +1,94 @@
+package cachelayer
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//RefsKeySuffix suffix of reverse index sets, appended to id keys
+const RefsKeySuffix = ":refs"
+
+//SetReverseIndex maintain a redis set per entity listing every cache key containing it, so invalidation also removes keys ListIndexes no longer reports
+func (s *CacheBase[T, I]) SetReverseIndex(enabled bool) {
+	s.reverseIndex = enabled
+}
+
+func (s *CacheBase[T, I]) refsKey(id I) string {
+	return s.MakeCacheKey(NewIndex(s.GetIdField(), id)) + RefsKeySuffix
+}
+
+//addRefs record that cache keys contain entities of ids, refs: cache key -> ids
+func (s *CacheBase[T, I]) addRefs(red redis.UniversalClient, ttl time.Duration, refs map[string][]I) error {
+	if !s.reverseIndex || len(refs) == 0 {
+		return nil
+	}
+	p := red.Pipeline()
+	for key, ids := range refs {
+		for _, id := range ids {
+			if s.IsNullID(id) {
+				continue
+			}
+			refsKey := s.refsKey(id)
+			p.SAdd(s.ctx, refsKey, key)
+			p.Expire(s.ctx, refsKey, ttl)
+		}
+	}
+	_, err := p.Exec(s.ctx)
+	return cacheError(err)
+}
+
+//listRefs return cache keys containing entities of ids, including the reverse index sets themselves
+func (s *CacheBase[T, I]) listRefs(red redis.UniversalClient, ids ...I) ([]string, error) {
+	return s.listMembers(red, ids, nil)
+}
+
+//listMembers reverse index sets of ids and tag sets of tags with their members, read in one round trip
+func (s *CacheBase[T, I]) listMembers(red redis.UniversalClient, ids []I, tags []string) ([]string, error) {
+	var sets []string
+	if s.reverseIndex {
+		for _, id := range ids {
+			if !s.IsNullID(id) {
+				sets = append(sets, s.refsKey(id))
+			}
+		}
+	}
+	for _, v := range tags {
+		sets = append(sets, s.TagKey(v))
+	}
+	if len(sets) == 0 {
+		return nil, nil
+	}
+	p := red.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, len(sets))
+	for i, v := range sets {
+		cmds[i] = p.SMembers(s.ctx, v)
+	}
+	if _, err := p.Exec(s.ctx); err != nil && err != redis.Nil {
+		return nil, cacheError(err)
+	}
+	keys := sets
+	for _, cmd := range cmds {
+		keys = append(keys, cmd.Val()...)
+	}
+	return keys, nil
+}
+
+//existingRecords objs without empty records of missing ids
+func (s *CacheBase[T, I]) existingRecords(objs []T) []T {
+	r := make([]T, 0, len(objs))
+	for _, v := range objs {
+		if !s.IsNullID(v.GetID()) {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+func listIDs[T Table[I], I IDType](objs ...T) []I {
+	r := make([]I, len(objs))
+	for i, v := range objs {
+		r[i] = v.GetID()
+	}
+	return r
+}
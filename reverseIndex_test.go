@@ -0,0 +1,37 @@
+package cachelayer_test
+
+import (
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReverseIndex(t *testing.T) {
+	cache, db, mr := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1}, member{ID: 2, Name: "ann", Email: "ann@x.com", GroupID: 1})
+	cache.SetReverseIndex(true)
+	_, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	_, _, err = cache.GetBy(cachelayer.NewIndex("Email", "tom@x.com"))
+	assert.Nil(t, err)
+	_, err = cache.ListBy(cachelayer.NewIndex("GroupID", 1), nil)
+	assert.Nil(t, err)
+	idKey := cache.MakeCacheKey(cachelayer.NewIndex("ID", 1))
+	emailKey := cache.MakeCacheKey(cachelayer.NewIndex("Email", "tom@x.com"))
+	groupKey := cache.MakeCacheKey(cachelayer.NewIndex("GroupID", 1))
+	refs, err := mr.Members(idKey + cachelayer.RefsKeySuffix)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{idKey, emailKey, groupKey}, refs)
+
+	// the email changed behind the cache, ListIndexes of the new version no longer reports the old email key
+	_, err = db.Update(1, map[string]interface{}{"email": "tom@y.com"})
+	assert.Nil(t, err)
+	r, _, err := db.Get(1)
+	assert.Nil(t, err)
+	assert.Nil(t, cache.ClearCache(r))
+	assert.False(t, mr.Exists(emailKey))
+	assert.False(t, mr.Exists(groupKey))
+	assert.False(t, mr.Exists(idKey + cachelayer.RefsKeySuffix))
+	// ann was cached in the group list only, her set still refers to the deleted list
+	assert.Equal(t, []string{cache.MakeCacheKey(cachelayer.NewIndex("ID", 2)) + cachelayer.RefsKeySuffix}, mr.Keys())
+}
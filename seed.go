@@ -0,0 +1,234 @@
+package cachelayer
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ReadSeedJSON read entities from a json array or json lines
+func ReadSeedJSON[T any](r io.Reader) ([]T, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	var objs []T
+	if bytes.HasPrefix(data, []byte("[")) {
+		err = json.Unmarshal(data, &objs)
+		return objs, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var obj T
+		if err = dec.Decode(&obj); err == io.EOF {
+			return objs, nil
+		} else if err != nil {
+			return objs, err
+		}
+		objs = append(objs, obj)
+	}
+}
+
+//ReadSeedCSV read entities from csv, the header row names fields by go name or json/bson/gorm column tag.
+// Values are parsed by field kind, time is RFC3339, fields implementing encoding.TextUnmarshaler or sql.Scanner parse themselves
+func ReadSeedCSV[T any](r io.Reader) ([]T, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	var t T
+	typ := reflect.TypeOf(t)
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cachelayer: cannot read csv into %s", typ)
+	}
+	fields := make([]string, len(rows[0]))
+	for i, name := range rows[0] {
+		f, ok := lookupField(typ, strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("cachelayer: no field %s in %s", name, typ)
+		}
+		fields[i] = f.Name
+	}
+	objs := make([]T, 0, len(rows)-1)
+	for line, row := range rows[1:] {
+		var obj T
+		v := reflect.ValueOf(&obj).Elem()
+		for i, value := range row {
+			if err = setField(v.FieldByName(fields[i]), value); err != nil {
+				return nil, fmt.Errorf("cachelayer: line %d, field %s: %w", line+2, fields[i], err)
+			}
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+const seedingKeySuffix = ":seeding"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+//setField parse csv value into field
+func setField(f reflect.Value, value string) error {
+	if value == "" {
+		return nil
+	}
+	if f.Kind() == reflect.Ptr {
+		p := reflect.New(f.Type().Elem())
+		if err := setField(p.Elem(), value); err != nil {
+			return err
+		}
+		f.Set(p)
+		return nil
+	}
+	switch x := f.Addr().Interface().(type) {
+	case encoding.TextUnmarshaler:
+		return x.UnmarshalText([]byte(value))
+	case sql.Scanner:
+		return x.Scan(value)
+	}
+	if f.Type() == timeType {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err == nil {
+			f.Set(reflect.ValueOf(t))
+		}
+		return err
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		// slices, maps and structs are json
+		return json.Unmarshal([]byte(value), f.Addr().Interface())
+	}
+	return nil
+}
+
+//ReadSeedFile read entities from a .json, .jsonl or .csv file
+func ReadSeedFile[T any](path string) ([]T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ReadSeedCSV[T](f)
+	case ".json", ".jsonl", ".ndjson":
+		return ReadSeedJSON[T](f)
+	}
+	return nil, fmt.Errorf("cachelayer: unknown seed file type %s", path)
+}
+
+//Seed put objs into the cache without touching database, eg. reference tables loaded from a file at startup while the database is unreachable.
+// Only id keys are written and existing entries are kept, so fresher data wins. Return count of seeded records
+func (s *RedisCache[T, I]) Seed(objs ...T) (int, error) {
+	p := s.red.Pipeline()
+	keys := make([]string, 0, len(objs))
+	for _, v := range objs {
+		if s.IsNullID(v.GetID()) {
+			continue
+		}
+		y, err := marshal(s.red.serializer, v)
+		if err != nil {
+			return 0, s.wrapErr("seed", "", cacheError(err))
+		}
+		key := s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID()))
+		p.SetNX(s.ctx, key, y, s.red.storeTTL())
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	cmds, err := p.Exec(s.ctx)
+	if err != nil {
+		return 0, s.wrapErr("seed", "", cacheError(err))
+	}
+	var seeded []string
+	for i, cmd := range cmds {
+		if cmd.(interface{ Val() bool }).Val() {
+			seeded = append(seeded, keys[i])
+		}
+	}
+	return len(seeded), s.wrapErr("seed", "", s.red.afterWrite(seeded...))
+}
+
+//SeedFile seed records of a .json, .jsonl or .csv file, see Seed
+func (s *RedisCache[T, I]) SeedFile(path string) (int, error) {
+	objs, err := ReadSeedFile[T](path)
+	if err != nil {
+		return 0, s.wrapErr("seed", path, err)
+	}
+	return s.Seed(objs...)
+}
+
+//Seed fill the full hash with objs without touching database if it is not loaded, so the cache serves reference data while the database is unreachable.
+// Return count of seeded records, 0 if the hash already exists
+func (s *FullRedisCache[T, I]) Seed(objs ...T) (int, error) {
+	key := s.CacheKey()
+	count, err := s.red.Exists(s.ctx, key).Result()
+	if err != nil || count > 0 || len(objs) == 0 {
+		return 0, s.wrapErr("seed", key, cacheError(err))
+	}
+	seedingKey := key + seedingKeySuffix
+	if err = s.red.HSetJson(seedingKey, objs...); err != nil {
+		return 0, s.wrapErr("seed", key, err)
+	}
+	// a Load finished meanwhile wins, sharded redis can not rename across slots atomically
+	ok := true
+	if IsSharded(s.red.UniversalClient) {
+		err = renameKey(s.ctx, s.red.UniversalClient, seedingKey, key)
+	} else {
+		ok, err = s.red.RenameNX(s.ctx, seedingKey, key).Result()
+	}
+	if err != nil || !ok {
+		s.red.Del(s.ctx, seedingKey)
+		return 0, s.wrapErr("seed", key, cacheError(err))
+	}
+	if err = s.red.Expire(s.ctx, key, s.red.ttl).Err(); err != nil {
+		return 0, s.wrapErr("seed", key, cacheError(err))
+	}
+	return len(objs), s.wrapErr("seed", key, s.red.afterWrite(key))
+}
+
+//SeedFile seed the full hash with records of a .json, .jsonl or .csv file, see Seed
+func (s *FullRedisCache[T, I]) SeedFile(path string) (int, error) {
+	objs, err := ReadSeedFile[T](path)
+	if err != nil {
+		return 0, s.wrapErr("seed", path, err)
+	}
+	return s.Seed(objs...)
+}
@@ -0,0 +1,50 @@
+package cachelayer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+type country struct {
+	ID        uint   `json:"id"`
+	Code      string `json:"code"`
+	Name      string `gorm:"column:title"`
+	Active    bool
+	Rate      *float64
+	UpdatedAt time.Time
+	Tags      []string
+}
+
+func TestReadSeedCSV(t *testing.T) {
+	data := "id,code,title,Active,rate,updatedAt,tags\n" +
+		"1,AE,United Arab Emirates,true,1.5,2022-01-02T03:04:05Z,\"[\"\"gulf\"\"]\"\n" +
+		"2,CN,China,false,,,\n"
+	objs, err := cachelayer.ReadSeedCSV[country](strings.NewReader(data))
+	assert.Nil(t, err)
+	assert.Len(t, objs, 2)
+	assert.Equal(t, uint(1), objs[0].ID)
+	assert.Equal(t, "United Arab Emirates", objs[0].Name)
+	assert.True(t, objs[0].Active)
+	assert.Equal(t, 1.5, *objs[0].Rate)
+	assert.Equal(t, time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC), objs[0].UpdatedAt)
+	assert.Equal(t, []string{"gulf"}, objs[0].Tags)
+	assert.Nil(t, objs[1].Rate)
+
+	_, err = cachelayer.ReadSeedCSV[country](strings.NewReader("id,population\n1,2\n"))
+	assert.NotNil(t, err)
+	_, err = cachelayer.ReadSeedCSV[country](strings.NewReader("id\nx\n"))
+	assert.NotNil(t, err)
+}
+
+func TestReadSeedJSON(t *testing.T) {
+	objs, err := cachelayer.ReadSeedJSON[country](strings.NewReader(`[{"id":1,"code":"AE"},{"id":2,"code":"CN"}]`))
+	assert.Nil(t, err)
+	assert.Len(t, objs, 2)
+	objs, err = cachelayer.ReadSeedJSON[country](strings.NewReader("{\"id\":1}\n{\"id\":2}\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), objs[1].ID)
+}
@@ -0,0 +1,75 @@
+package cachelayer
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//DefaultSequenceBlock count of ids a Sequence allocates per round trip
+const DefaultSequenceBlock = 100
+
+//Sequence auto increment ids emulated by INCRBY of the redis key {prefix}:seq:{table}, eg. human friendly numeric ids of mongo documents.
+// Ids are allocated in blocks to cut round trips, so they are unique and increasing per process but not gap free:
+// ids left in a block are skipped when the process exits, and ids of concurrent processes interleave.
+// The key is separated by ':' so it is never a cache key of a table, and has no ttl, so clearing caches keeps it. Redis must not evict it:
+// use a maxmemory-policy of noeviction or volatile-*, otherwise a lost counter restarts from 1 and ids repeat
+type Sequence struct {
+	mu    sync.Mutex
+	red   redis.UniversalClient
+	key   string
+	block int64
+	//next next id to hand out, ids up to max are allocated
+	next int64
+	max  int64
+}
+
+//NewSequence sequence of table allocating block ids per round trip, block <= 0 means DefaultSequenceBlock
+func NewSequence(red redis.UniversalClient, prefix, table string, block int64) *Sequence {
+	if block <= 0 {
+		block = DefaultSequenceBlock
+	}
+	return &Sequence{red: red, key: strings.ToLower(prefix + ":seq:" + table), block: block}
+}
+
+//Key redis key of the counter
+func (s *Sequence) Key() string {
+	return s.key
+}
+
+//Next next id, starting from 1
+func (s *Sequence) Next(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next == 0 || s.next > s.max {
+		max, err := s.red.IncrBy(ctx, s.key, s.block).Result()
+		if err != nil {
+			return 0, cacheError(err)
+		}
+		s.next, s.max = max-s.block+1, max
+	}
+	id := s.next
+	s.next++
+	return id, nil
+}
+
+//raiseCounter set KEYS[1] to ARGV[1] if it is less
+var raiseCounter = newScript(`
+local v = tonumber(redis.call('GET', KEYS[1]) or '0')
+if v < tonumber(ARGV[1]) then redis.call('SET', KEYS[1], ARGV[1]) end
+return 1
+`)
+
+//EnsureAbove make sure ids allocated from now on are greater than id, eg. the max id of existing documents when the sequence is introduced.
+// Ids already allocated in the block of this process are dropped
+func (s *Sequence) EnsureAbove(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := raiseCounter.Run(ctx, s.red, []string{s.key}, id).Err(); err != nil {
+		return cacheError(err)
+	}
+	s.next, s.max = 0, 0
+	return nil
+}
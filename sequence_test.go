@@ -0,0 +1,67 @@
+package cachelayer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequence(t *testing.T) {
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer red.Close()
+	seq := cachelayer.NewSequence(red, "App", "Order", 0)
+	assert.Equal(t, "app:seq:order", seq.Key())
+	_, err := seq.Next(context.Background())
+	assert.True(t, errors.Is(err, cachelayer.ErrCacheUnavailable))
+	assert.NotNil(t, seq.EnsureAbove(context.Background(), 10))
+}
+
+func TestSequenceBlocks(t *testing.T) {
+	ctx := context.Background()
+	mr, red := newMiniRedis(t)
+	counter := newCmdCounter()
+	red.AddHook(counter)
+	a := cachelayer.NewSequence(red, "app", "order", 3)
+	b := cachelayer.NewSequence(red, "app", "order", 3)
+	var ids []int64
+	for _, seq := range []*cachelayer.Sequence{a, a, b, a, a, b} {
+		id, err := seq.Next(ctx)
+		assert.Nil(t, err)
+		ids = append(ids, id)
+	}
+	// a takes 1-3 and 7-9, b takes 4-6
+	assert.Equal(t, []int64{1, 2, 4, 3, 7, 5}, ids)
+	assert.Equal(t, 3, counter.Count("incrby"))
+	// outside the cache keys of the table and without ttl
+	assert.Equal(t, []string{"app:seq:order"}, mr.Keys())
+	assert.Equal(t, time.Duration(0), mr.TTL("app:seq:order"))
+
+	assert.Nil(t, a.EnsureAbove(ctx, 100))
+	id, err := a.Next(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(101), id)
+	// never lowered, the block left in b is dropped only by its own EnsureAbove
+	assert.Nil(t, b.EnsureAbove(ctx, 50))
+	id, err = b.Next(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(104), id)
+}
+
+func TestSequenceKeptByClear(t *testing.T) {
+	ctx := context.Background()
+	mr, red := newMiniRedis(t)
+	seq := cachelayer.NewSequence(red, "app", "order", 10)
+	_, err := seq.Next(ctx)
+	assert.Nil(t, err)
+	// a table named seq clears its own keys only
+	cache := cachelayer.NewRedisCache[member, uint]("app", "seq", "ID", newMemDB(), red, time.Minute)
+	assert.Nil(t, cache.ClearAll())
+	v, err := mr.Get("app:seq:order")
+	assert.Nil(t, err)
+	assert.Equal(t, "10", v)
+}
@@ -0,0 +1,81 @@
+package cachelayer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//Session read-your-writes guarantee of a user session, eg. kept per logged in user. Keys cleared by writes through a cache whose context
+// carries the session are read from the database by that session for the window after the write, so it sees its own updates
+// even if invalidation lags(async invalidation, a failed delete, a concurrent read caching the old record). Other sessions read the cache as usual
+type Session struct {
+	mu        sync.Mutex
+	window    time.Duration
+	clock     Clock
+	written   map[string]time.Time
+	lastPrune time.Time
+}
+
+func NewSession(window time.Duration) *Session {
+	return &Session{window: window, clock: RealClock{}, written: make(map[string]time.Time)}
+}
+
+//SetClock clock of the window, default RealClock
+func (s *Session) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+//MarkWritten read keys from the database for the next window
+func (s *Session) MarkWritten(keys ...string) {
+	if s == nil || s.window <= 0 || len(keys) == 0 {
+		return
+	}
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range keys {
+		s.written[v] = now
+	}
+	if len(s.written) > maxRecentWrites && now.Sub(s.lastPrune) > s.window {
+		for k, at := range s.written {
+			if now.Sub(at) >= s.window {
+				delete(s.written, k)
+			}
+		}
+		s.lastPrune = now
+	}
+}
+
+//Written whether key was written by the session within the window
+func (s *Session) Written(key string) bool {
+	if s == nil {
+		return false
+	}
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at, ok := s.written[key]
+	return ok && now.Sub(at) < s.window
+}
+
+type sessionKey struct{}
+
+//WithSession return a context carrying session, eg. userCache.WithContext(cachelayer.WithSession(ctx, session)).Update(id, values)
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+//SessionFromContext session of ctx, nil if ctx has no session
+func SessionFromContext(ctx context.Context) *Session {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+//sessionWritten whether key was written by the session of the cache context
+func (s *CacheBase[T, I]) sessionWritten(key string) bool {
+	return SessionFromContext(s.ctx).Written(key)
+}
@@ -0,0 +1,27 @@
+package cachelayer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	clock := cachelayer.NewFakeClock(time.Now())
+	session := cachelayer.NewSession(time.Second)
+	session.SetClock(clock)
+	session.MarkWritten("app/user/id/1")
+	assert.True(t, session.Written("app/user/id/1"))
+	assert.False(t, session.Written("app/user/id/2"))
+	clock.Advance(time.Second)
+	assert.False(t, session.Written("app/user/id/1"))
+
+	ctx := cachelayer.WithSession(context.Background(), session)
+	assert.Equal(t, session, cachelayer.SessionFromContext(ctx))
+	assert.Nil(t, cachelayer.SessionFromContext(context.Background()))
+	var none *cachelayer.Session
+	assert.False(t, none.Written("app/user/id/1"))
+}
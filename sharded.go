@@ -0,0 +1,105 @@
+package cachelayer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//IsSharded true if keys of red are spread over several nodes(go-redis Ring or Cluster). Multi-key commands are split into one command per key then
+func IsSharded(red redis.UniversalClient) bool {
+	switch red.(type) {
+	case *redis.Ring, *redis.ClusterClient:
+		return true
+	}
+	return false
+}
+
+//DelKeys delete keys with UNLINK in one round trip(one pipeline on sharded clients), return count of deleted keys
+func DelKeys(ctx context.Context, red redis.UniversalClient, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if !IsSharded(red) {
+		n, err := red.Unlink(ctx, keys...).Result()
+		return n, cacheError(err)
+	}
+	p := red.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, v := range keys {
+		cmds[i] = p.Unlink(ctx, v)
+	}
+	if _, err := p.Exec(ctx); err != nil {
+		return 0, cacheError(err)
+	}
+	var n int64
+	for _, v := range cmds {
+		n += v.Val()
+	}
+	return n, nil
+}
+
+//mget values of keys, nil for missing keys and keys which are not strings(as MGET)
+func mget(ctx context.Context, red redis.UniversalClient, keys ...string) ([]interface{}, error) {
+	if !IsSharded(red) {
+		return red.MGet(ctx, keys...).Result()
+	}
+	p := red.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, v := range keys {
+		cmds[i] = p.Get(ctx, v)
+	}
+	// errors are checked by command, GET of a set or hash fails with WRONGTYPE where MGET returns nil
+	p.Exec(ctx)
+	r := make([]interface{}, len(keys))
+	for i, v := range cmds {
+		switch err := v.Err(); {
+		case err == nil:
+			r[i] = v.Val()
+		case err != redis.Nil && !isWrongType(err):
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func isWrongType(err error) bool {
+	return strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
+
+//renameKey rename from to to with ttl of from, DUMP/RESTORE if the keys may live on different nodes
+func renameKey(ctx context.Context, red redis.UniversalClient, from, to string) error {
+	if !IsSharded(red) {
+		return cacheError(red.Rename(ctx, from, to).Err())
+	}
+	dump, err := red.Dump(ctx, from).Result()
+	if err != nil {
+		return cacheError(err)
+	}
+	ttl, err := red.PTTL(ctx, from).Result()
+	if err != nil {
+		return cacheError(err)
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err = red.RestoreReplace(ctx, to, ttl, dump).Err(); err != nil {
+		return cacheError(err)
+	}
+	return cacheError(red.Del(ctx, from).Err())
+}
+
+//forEachNode call fn for every node holding keys of red, fn is called once with red if it is a single node
+func forEachNode(ctx context.Context, red redis.UniversalClient, fn func(ctx context.Context, node redis.UniversalClient) error) error {
+	wrap := func(ctx context.Context, node *redis.Client) error {
+		return fn(ctx, node)
+	}
+	switch c := red.(type) {
+	case *redis.Ring:
+		return c.ForEachShard(ctx, wrap)
+	case *redis.ClusterClient:
+		return c.ForEachMaster(ctx, wrap)
+	}
+	return fn(ctx, red)
+}
@@ -0,0 +1,91 @@
+package cachelayer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+//shardedRedis a sharded client and its nodes
+type shardedRedis struct {
+	red   redis.UniversalClient
+	nodes []*miniredis.Miniredis
+}
+
+//hashFields fields of hash key found on any node
+func (s shardedRedis) hashFields(key string) []string {
+	var fields []string
+	for _, v := range s.nodes {
+		if v.Exists(key) {
+			keys, _ := v.HKeys(key)
+			fields = append(fields, keys...)
+		}
+	}
+	return fields
+}
+
+//newSharded a client of miniredis in cluster mode and a ring of two miniredis
+func newSharded(t *testing.T) map[string]shardedRedis {
+	mr := miniredis.RunT(t)
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{mr.Addr()}})
+	t.Cleanup(func() { cluster.Close() })
+	a, b := miniredis.RunT(t), miniredis.RunT(t)
+	ring := redis.NewRing(&redis.RingOptions{Addrs: map[string]string{"a": a.Addr(), "b": b.Addr()}})
+	t.Cleanup(func() { ring.Close() })
+	return map[string]shardedRedis{"cluster": {cluster, []*miniredis.Miniredis{mr}}, "ring": {ring, []*miniredis.Miniredis{a, b}}}
+}
+
+func TestShardedVerify(t *testing.T) {
+	for name, sharded := range newSharded(t) {
+		red := sharded.red
+		t.Run(name, func(t *testing.T) {
+			assert.True(t, cachelayer.IsSharded(red))
+			db := newMemDB(member{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1}, member{ID: 2, Name: "ann", Email: "ann@x.com", GroupID: 1})
+			cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+			// reference sets are scanned along with the entries
+			cache.SetReverseIndex(true)
+			_, err := cache.List(1, 2)
+			assert.Nil(t, err)
+			_, err = cache.ListBy(cachelayer.NewIndex("GroupID", 1), nil)
+			assert.Nil(t, err)
+			report, err := cache.Verify()
+			assert.Nil(t, err)
+			assert.True(t, report.OK())
+			assert.Equal(t, 5, report.Keys)
+		})
+	}
+}
+
+func TestShardedPurgeEntity(t *testing.T) {
+	// miniredis does not describe every command to go-redis, a Ring routes some hash commands to the wrong node
+	sharded := newSharded(t)["cluster"]
+	db := newMemDB(member{ID: 1, Name: "tom", Email: "tom@x.com"}, member{ID: 2, Name: "ann", Email: "ann@x.com"})
+	cache := cachelayer.NewFullRedisCache[member, uint]("app", "member", "ID", db, sharded.red, time.Minute)
+	assert.Nil(t, cache.Load())
+	_, _, err := cache.GetBy(cachelayer.NewIndex("Email", "tom@x.com"))
+	assert.Nil(t, err)
+	record, err := cache.PurgeEntity(1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), record.Deleted)
+	assert.Equal(t, []string{"2"}, sharded.hashFields(cache.CacheKey()))
+	assert.Equal(t, []string{cache.CacheKey()}, sharded.nodes[0].Keys())
+}
+
+func TestFullPurgeEntityTransaction(t *testing.T) {
+	_, red := newMiniRedis(t)
+	counter := newCmdCounter()
+	red.AddHook(counter)
+	db := newMemDB(member{ID: 1, Name: "tom", Email: "tom@x.com"})
+	cache := cachelayer.NewFullRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	_, _, err := cache.GetBy(cachelayer.NewIndex("Email", "tom@x.com"))
+	assert.Nil(t, err)
+	_, err = cache.PurgeEntity(1)
+	assert.Nil(t, err)
+	// the hash field and the index keys are removed in one MULTI/EXEC
+	assert.Equal(t, 1, counter.Count("multi"))
+	assert.Equal(t, 1, counter.Count("hdel"))
+}
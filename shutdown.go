@@ -0,0 +1,64 @@
+package cachelayer
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+//OnShutdown register fn stopping a background component, eg. refreshers, warm-up subscribers and async writers.
+// Shutdown runs them in reverse order of registration before closing caches
+func (s *Registry) OnShutdown(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stoppers = append(s.stoppers, fn)
+}
+
+//AddConnection connection closed by Shutdown after all caches are closed, eg. redis clients shared by caches
+func (s *Registry) AddConnection(conn io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connections = append(s.connections, conn)
+}
+
+//Shutdown stop background components(draining pending writes and invalidations), close caches and then connections, in this order.
+// Every step runs even if ctx is done, return the first error
+func (s *Registry) Shutdown(ctx context.Context) error {
+	s.mu.RLock()
+	stoppers := append([]func(ctx context.Context) error{}, s.stoppers...)
+	connections := append([]io.Closer{}, s.connections...)
+	s.mu.RUnlock()
+	var first error
+	for i := len(stoppers) - 1; i >= 0; i-- {
+		if err := stoppers[i](ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	if err := s.Close(); err != nil && first == nil {
+		first = err
+	}
+	for _, v := range connections {
+		if err := v.Close(); err != nil && first == nil {
+			first = fmt.Errorf("close connection: %w", err)
+		}
+	}
+	if first == nil {
+		first = ctx.Err()
+	}
+	return first
+}
+
+//Shutdown stop accepting writes and wait for queued writes until ctx is done
+func (s *AsyncWriter) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,182 @@
+package cachelayer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//SnapshotEntry a cache entry of Export, one json per line. Key is relative to the table(eg. "id/1"), so snapshots can be imported under another prefix.
+// TTL is the remaining ttl in milliseconds at export, -1 means no expiration
+type SnapshotEntry struct {
+	Key   string            `json:"key"`
+	Type  string            `json:"type"`
+	TTL   int64             `json:"ttl"`
+	Value string            `json:"value,omitempty"`
+	Hash  map[string]string `json:"hash,omitempty"`
+	Set   []string          `json:"set,omitempty"`
+}
+
+//exportSnapshot write all cache keys of the table(strings, hashes and sets) to w, return count of entries
+func (s *CacheBase[T, I]) exportSnapshot(red redis.UniversalClient, w io.Writer) (int, error) {
+	keys, err := scanKeys(s.ctx, red, s.tablePattern("*"))
+	if err != nil {
+		return 0, err
+	}
+	base := s.tablePattern("")
+	enc := json.NewEncoder(w)
+	n := 0
+	for start := 0; start < len(keys); start += DefaultScanBatchSize {
+		end := start + DefaultScanBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+		p := red.Pipeline()
+		types := make([]*redis.StatusCmd, len(batch))
+		ttls := make([]*redis.DurationCmd, len(batch))
+		for i, key := range batch {
+			types[i] = p.Type(s.ctx, key)
+			ttls[i] = p.PTTL(s.ctx, key)
+		}
+		if _, err = p.Exec(s.ctx); err != nil && err != redis.Nil {
+			return n, cacheError(err)
+		}
+		p = red.Pipeline()
+		values := make([]redis.Cmder, len(batch))
+		for i, key := range batch {
+			switch types[i].Val() {
+			case "string":
+				values[i] = p.Get(s.ctx, key)
+			case "hash":
+				values[i] = p.HGetAll(s.ctx, key)
+			case "set":
+				values[i] = p.SMembers(s.ctx, key)
+			}
+		}
+		if _, err = p.Exec(s.ctx); err != nil && err != redis.Nil {
+			return n, cacheError(err)
+		}
+		for i, key := range batch {
+			// expired or deleted since scan
+			if values[i] == nil || values[i].Err() != nil {
+				continue
+			}
+			e := SnapshotEntry{Key: strings.TrimPrefix(key, base), Type: types[i].Val(), TTL: -1}
+			if ttl := ttls[i].Val(); ttl > 0 {
+				e.TTL = ttl.Milliseconds()
+			}
+			switch v := values[i].(type) {
+			case *redis.StringCmd:
+				e.Value = v.Val()
+			case *redis.StringStringMapCmd:
+				e.Hash = v.Val()
+			case *redis.StringSliceCmd:
+				e.Set = v.Val()
+			}
+			if err = enc.Encode(e); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+//importSnapshot restore entries written by exportSnapshot under cache keys of this table, return count of restored entries
+func (s *CacheBase[T, I]) importSnapshot(red redis.UniversalClient, r io.Reader) (int, error) {
+	base := s.tablePattern("")
+	// one entry per line, decoded line by line: the streaming decoder misreads values spanning its buffer
+	lines := bufio.NewReader(r)
+	n := 0
+	p := red.Pipeline()
+	pending := 0
+	for {
+		line, err := lines.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err == io.EOF {
+				break
+			}
+			continue
+		}
+		var e SnapshotEntry
+		if err = json.Unmarshal(line, &e); err != nil {
+			return n, NewError(ErrSerialization, err)
+		}
+		key := base + e.Key
+		ttl := time.Duration(e.TTL) * time.Millisecond
+		if e.TTL < 0 {
+			ttl = 0
+		}
+		switch e.Type {
+		case "string":
+			p.Set(s.ctx, key, e.Value, ttl)
+		case "hash":
+			p.Del(s.ctx, key)
+			if len(e.Hash) > 0 {
+				p.HSet(s.ctx, key, e.Hash)
+			}
+		case "set":
+			p.Del(s.ctx, key)
+			if len(e.Set) > 0 {
+				members := make([]interface{}, len(e.Set))
+				for i, v := range e.Set {
+					members[i] = v
+				}
+				p.SAdd(s.ctx, key, members...)
+			}
+		default:
+			continue
+		}
+		if e.Type != "string" && ttl > 0 {
+			p.PExpire(s.ctx, key, ttl)
+		}
+		n++
+		pending++
+		if pending >= DefaultScanBatchSize {
+			if _, err = p.Exec(s.ctx); err != nil {
+				return n, cacheError(err)
+			}
+			pending = 0
+		}
+	}
+	if pending > 0 {
+		if _, err := p.Exec(s.ctx); err != nil {
+			return n, cacheError(err)
+		}
+	}
+	return n, nil
+}
+
+//Export dump all cached entries of the table(key, ttl, payload) to w as json lines, eg. to seed staging from production or start a blue/green deployment warm.
+// Entries are read while the cache is serving, the snapshot is not atomic
+func (s *RedisCache[T, I]) Export(w io.Writer) (int, error) {
+	n, err := s.exportSnapshot(s.red.UniversalClient, w)
+	return n, s.wrapErr("export", "", err)
+}
+
+//Import restore entries of Export under the cache keys of this table, existing entries of the same keys are overwritten.
+// Entries keep the ttl left at export
+func (s *RedisCache[T, I]) Import(r io.Reader) (int, error) {
+	n, err := s.importSnapshot(s.red.UniversalClient, r)
+	return n, s.wrapErr("import", "", err)
+}
+
+//Export dump all cached entries of the table to w, see RedisCache.Export
+func (s *FullRedisCache[T, I]) Export(w io.Writer) (int, error) {
+	n, err := s.exportSnapshot(s.red.UniversalClient, w)
+	return n, s.wrapErr("export", "", err)
+}
+
+//Import restore entries of Export, see RedisCache.Import
+func (s *FullRedisCache[T, I]) Import(r io.Reader) (int, error) {
+	n, err := s.importSnapshot(s.red.UniversalClient, r)
+	return n, s.wrapErr("import", "", err)
+}
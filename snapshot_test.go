@@ -0,0 +1,70 @@
+package cachelayer_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	rows := []member{{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1}, {ID: 2, Name: "ann", Email: "ann@x.com", GroupID: 1}}
+	prod, _, prodMr := newMemberCache(t, rows...)
+	_, err := prod.List(1, 2)
+	assert.Nil(t, err)
+	_, err = prod.ListBy(cachelayer.NewIndex("GroupID", 1), nil)
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	n, err := prod.Export(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, len(prodMr.Keys()), n)
+	assert.Equal(t, n, strings.Count(buf.String(), "\n"))
+
+	// restored under another prefix, served without the database
+	mr, red := newMiniRedis(t)
+	db := newMemDB(rows...)
+	staging := cachelayer.NewRedisCache[member, uint]("staging", "member", "ID", db, red, time.Minute)
+	m, err := staging.Import(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, n, m)
+	for _, v := range prodMr.Keys() {
+		key := "staging" + strings.TrimPrefix(v, "app")
+		assert.True(t, mr.Exists(key), key)
+		assert.Equal(t, prodMr.TTL(v), mr.TTL(key), key)
+	}
+	r, exists, err := staging.Get(1)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "tom", r.Name)
+	objs, err := staging.ListBy(cachelayer.NewIndex("GroupID", 1), nil)
+	assert.Nil(t, err)
+	assert.Len(t, objs, 2)
+	assert.Equal(t, 0, db.Queries())
+
+	_, err = staging.Import(strings.NewReader("{not json"))
+	assert.ErrorIs(t, err, cachelayer.ErrSerialization)
+}
+
+func TestExportImportFull(t *testing.T) {
+	rows := []member{{ID: 1, Name: "tom", GroupID: 1}, {ID: 2, Name: "ann", GroupID: 2}}
+	_, prodRed := newMiniRedis(t)
+	prod := cachelayer.NewFullRedisCache[member, uint]("app", "member", "ID", newMemDB(rows...), prodRed, time.Minute)
+	_, err := prod.ListAll()
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	_, err = prod.Export(&buf)
+	assert.Nil(t, err)
+
+	_, red := newMiniRedis(t)
+	db := newMemDB(rows...)
+	staging := cachelayer.NewFullRedisCache[member, uint]("app", "member", "ID", db, red, time.Minute)
+	_, err = staging.Import(&buf)
+	assert.Nil(t, err)
+	objs, err := staging.ListAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ann", "tom"}, names(objs))
+	assert.Equal(t, 0, db.Queries())
+}
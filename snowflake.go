@@ -0,0 +1,150 @@
+package cachelayer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	snowflakeWorkerBits   = 10
+	snowflakeSequenceBits = 12
+	//MaxSnowflakeWorker largest worker id of a Snowflake
+	MaxSnowflakeWorker   = 1<<snowflakeWorkerBits - 1
+	maxSnowflakeSequence = 1<<snowflakeSequenceBits - 1
+)
+
+//DefaultSnowflakeEpoch epoch of snowflake timestamps, 41 bits of milliseconds since it last about 69 years
+var DefaultSnowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+//Snowflake generator of 64 bit time ordered ids: 41 bits of milliseconds since the epoch, 10 bits of worker id and 12 bits of sequence,
+// so workers with distinct ids generate unique ids without a database round trip, eg. for sharded tables.
+// If the clock moves backwards or a millisecond runs out of sequence, ids continue from the last timestamp instead of waiting
+type Snowflake struct {
+	mu     sync.Mutex
+	worker int64
+	epoch  time.Time
+	clock  Clock
+	last   int64
+	seq    int64
+}
+
+//NewSnowflake generator of worker, 0 <= worker <= MaxSnowflakeWorker. Worker ids must be unique among running processes, see ClaimWorkerID
+func NewSnowflake(worker int64) (*Snowflake, error) {
+	if worker < 0 || worker > MaxSnowflakeWorker {
+		return nil, fmt.Errorf("cachelayer: snowflake worker id %d out of range [0, %d]", worker, MaxSnowflakeWorker)
+	}
+	return &Snowflake{worker: worker, epoch: DefaultSnowflakeEpoch, clock: RealClock{}}, nil
+}
+
+//SetEpoch epoch of timestamps, default DefaultSnowflakeEpoch. Must not change once ids are stored
+func (s *Snowflake) SetEpoch(epoch time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.epoch = epoch
+}
+
+//SetClock clock of timestamps, default RealClock
+func (s *Snowflake) SetClock(clock Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = clock
+}
+
+//NextID next id, greater than every id generated before by this generator
+func (s *Snowflake) NextID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := s.clock.Now().Sub(s.epoch).Milliseconds()
+	if ms > s.last {
+		s.last = ms
+		s.seq = 0
+	} else if s.seq++; s.seq > maxSnowflakeSequence {
+		s.last++
+		s.seq = 0
+	}
+	return s.last<<(snowflakeWorkerBits+snowflakeSequenceBits) | s.worker<<snowflakeSequenceBits | s.seq
+}
+
+//SnowflakeTime time an id of a generator with epoch was generated at
+func SnowflakeTime(id int64, epoch time.Time) time.Time {
+	return epoch.Add(time.Duration(id>>(snowflakeWorkerBits+snowflakeSequenceBits)) * time.Millisecond)
+}
+
+//SnowflakeWorker worker id of an id
+func SnowflakeWorker(id int64) int64 {
+	return id >> snowflakeSequenceBits & MaxSnowflakeWorker
+}
+
+//SnowflakeIDs id generator of integer ids for SetIDGenerator of caches and of gorm/mongo adapters, eg. cache.SetIDGenerator(cachelayer.SnowflakeIDs[int64](sf))
+func SnowflakeIDs[I ~int64 | ~uint64](sf *Snowflake) func() I {
+	return func() I {
+		return I(sf.NextID())
+	}
+}
+
+//WorkerLease worker id leased in redis, renew it before ttl expires and release it on shutdown
+type WorkerLease struct {
+	ID    int64
+	key   string
+	token string
+	ttl   time.Duration
+	red   redis.UniversalClient
+}
+
+//renewLease extend KEYS[1] to ARGV[2] ms only if it is still held by token ARGV[1]
+var renewLease = newScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then return 0 end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+//releaseLease delete KEYS[1] only if it is still held by token ARGV[1]
+var releaseLease = newScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then return 0 end
+return redis.call('DEL', KEYS[1])
+`)
+
+//ClaimWorkerID lease the first free snowflake worker id of {prefix}:snowflake:worker:{id} for ttl, a key never among cache keys,, so processes don't need configured worker ids
+func ClaimWorkerID(ctx context.Context, red redis.UniversalClient, prefix string, ttl time.Duration) (*WorkerLease, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(b)
+	for id := int64(0); id <= MaxSnowflakeWorker; id++ {
+		key := strings.ToLower(fmt.Sprintf("%s:snowflake:worker:%d", prefix, id))
+		ok, err := red.SetNX(ctx, key, token, ttl).Result()
+		if err != nil {
+			return nil, cacheError(err)
+		}
+		if ok {
+			return &WorkerLease{ID: id, key: key, token: token, ttl: ttl, red: red}, nil
+		}
+	}
+	return nil, errors.New("cachelayer: no free snowflake worker id")
+}
+
+//Renew extend the lease by its ttl, fail if it was lost(expired and claimed by another process)
+func (s *WorkerLease) Renew(ctx context.Context) error {
+	ok, err := renewLease.Run(ctx, s.red, []string{s.key}, s.token, s.ttl.Milliseconds()).Int()
+	if err != nil {
+		return cacheError(err)
+	}
+	if ok == 0 {
+		return fmt.Errorf("cachelayer: snowflake worker id %d lease lost", s.ID)
+	}
+	return nil
+}
+
+//Release free the worker id
+func (s *WorkerLease) Release(ctx context.Context) error {
+	return cacheError(releaseLease.Run(ctx, s.red, []string{s.key}, s.token).Err())
+}
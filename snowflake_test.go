@@ -0,0 +1,53 @@
+package cachelayer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnowflake(t *testing.T) {
+	_, err := cachelayer.NewSnowflake(cachelayer.MaxSnowflakeWorker + 1)
+	assert.NotNil(t, err)
+	sf, err := cachelayer.NewSnowflake(7)
+	assert.Nil(t, err)
+	now := cachelayer.DefaultSnowflakeEpoch.Add(time.Hour)
+	clock := cachelayer.NewFakeClock(now)
+	sf.SetClock(clock)
+
+	first := sf.NextID()
+	assert.Equal(t, int64(7), cachelayer.SnowflakeWorker(first))
+	assert.Equal(t, now, cachelayer.SnowflakeTime(first, cachelayer.DefaultSnowflakeEpoch))
+	last := first
+	// a millisecond running out of sequence and a clock moving backwards keep ids increasing
+	for i := 0; i < 5000; i++ {
+		id := sf.NextID()
+		assert.Greater(t, id, last)
+		last = id
+	}
+	clock.Set(now.Add(-time.Second))
+	assert.Greater(t, sf.NextID(), last)
+
+	gen := cachelayer.SnowflakeIDs[uint64](sf)
+	assert.Greater(t, gen(), uint64(last))
+}
+
+func TestClaimWorkerID(t *testing.T) {
+	ctx := context.Background()
+	mr, red := newMiniRedis(t)
+	a, err := cachelayer.ClaimWorkerID(ctx, red, "app", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), a.ID)
+	assert.True(t, mr.Exists("app:snowflake:worker:0"))
+	// a table named snowflake clears its own keys only
+	cache := cachelayer.NewRedisCache[member, uint]("app", "snowflake", "ID", newMemDB(), red, time.Minute)
+	assert.Nil(t, cache.ClearAll())
+	b, err := cachelayer.ClaimWorkerID(ctx, red, "app", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), b.ID)
+	assert.Nil(t, a.Release(ctx))
+	assert.Nil(t, b.Renew(ctx))
+}
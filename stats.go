@@ -0,0 +1,121 @@
+package cachelayer
+
+import "sync/atomic"
+
+//Stats cache counters since the cache was created
+type Stats struct {
+	Hits          int64
+	Misses        int64
+	NullHits      int64
+	DBLoads       int64
+	DBErrors      int64
+	Invalidations int64
+	//Sampled records compared with database by anti-entropy
+	Sampled int64
+	//Drifted sampled records differing from database
+	Drifted int64
+	//PersistentKeys keys without ttl found by the last AuditTTL, a gauge rather than a counter
+	PersistentKeys int64
+	//RateLimited database loads rejected by DBRateLimiter
+	RateLimited int64
+	//Corrupted cached entries which couldn't be unmarshaled and were read as misses, see SetCorruptPolicy
+	Corrupted int64
+	//Latency histograms by operation, see LatencyOp
+	Latency map[string]Latency
+}
+
+//DriftRatio drifted / sampled, 0 if nothing is sampled
+func (s Stats) DriftRatio() float64 {
+	if s.Sampled == 0 {
+		return 0
+	}
+	return float64(s.Drifted) / float64(s.Sampled)
+}
+
+//HitRatio hits / (hits + misses), 0 if there is no read
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type statsCounter struct {
+	hits           int64
+	misses         int64
+	nullHits       int64
+	dbLoads        int64
+	dbErrors       int64
+	invalidations  int64
+	sampled        int64
+	drifted        int64
+	persistentKeys int64
+	rateLimits     int64
+	corrupts       int64
+	latency        [latencyOps]histogram
+}
+
+func (s *statsCounter) hit(n int) {
+	atomic.AddInt64(&s.hits, int64(n))
+}
+func (s *statsCounter) miss(n int) {
+	atomic.AddInt64(&s.misses, int64(n))
+}
+func (s *statsCounter) nullHit() {
+	atomic.AddInt64(&s.nullHits, 1)
+}
+
+//dbLoad count a database query and its result
+func (s *statsCounter) dbLoad(err error) {
+	atomic.AddInt64(&s.dbLoads, 1)
+	if err != nil {
+		atomic.AddInt64(&s.dbErrors, 1)
+	}
+}
+func (s *statsCounter) invalidate(n int) {
+	atomic.AddInt64(&s.invalidations, int64(n))
+}
+
+func (s *statsCounter) sample(sampled, drifted int) {
+	atomic.AddInt64(&s.sampled, int64(sampled))
+	atomic.AddInt64(&s.drifted, int64(drifted))
+}
+
+func (s *statsCounter) persistent(n int) {
+	atomic.StoreInt64(&s.persistentKeys, int64(n))
+}
+
+func (s *statsCounter) rateLimited() {
+	atomic.AddInt64(&s.rateLimits, 1)
+}
+
+func (s *statsCounter) corrupted(n int) {
+	atomic.AddInt64(&s.corrupts, int64(n))
+}
+
+func (s *statsCounter) snapshot() Stats {
+	latency := make(map[string]Latency, latencyOps)
+	for i := range s.latency {
+		latency[LatencyOp(i).String()] = s.latency[i].snapshot()
+	}
+	return Stats{
+		Latency:        latency,
+		Hits:           atomic.LoadInt64(&s.hits),
+		Misses:         atomic.LoadInt64(&s.misses),
+		NullHits:       atomic.LoadInt64(&s.nullHits),
+		DBLoads:        atomic.LoadInt64(&s.dbLoads),
+		DBErrors:       atomic.LoadInt64(&s.dbErrors),
+		Invalidations:  atomic.LoadInt64(&s.invalidations),
+		Sampled:        atomic.LoadInt64(&s.sampled),
+		Drifted:        atomic.LoadInt64(&s.drifted),
+		PersistentKeys: atomic.LoadInt64(&s.persistentKeys),
+		RateLimited:    atomic.LoadInt64(&s.rateLimits),
+		Corrupted:      atomic.LoadInt64(&s.corrupts),
+	}
+}
+
+//Stats return counters of the cache
+func (s *CacheBase[T, I]) Stats() Stats {
+	return s.stats.snapshot()
+}
@@ -0,0 +1,39 @@
+package cachelayer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+type primaryDB struct {
+	cachelayer.DBCRUD[keyUser, uint]
+	primary bool
+}
+
+func (s *primaryDB) Primary() cachelayer.DBCRUD[keyUser, uint] {
+	return &primaryDB{primary: true}
+}
+
+func (s *primaryDB) Get(id uint) (keyUser, bool, error) {
+	return keyUser{ID: id}, s.primary, nil
+}
+
+func TestStrongRead(t *testing.T) {
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer red.Close()
+	cache := cachelayer.NewRedisCache[keyUser, uint]("app", "user", "ID", &primaryDB{}, red, time.Minute)
+	_, exists, err := cache.Get(1)
+	assert.False(t, exists)
+	assert.True(t, errors.Is(err, cachelayer.ErrCacheUnavailable))
+
+	// redis is skipped and the primary is read, only refreshing the cache fails
+	r, exists, err := cache.StrongRead().Get(1)
+	assert.True(t, exists)
+	assert.Equal(t, uint(1), r.ID)
+	assert.True(t, errors.Is(err, cachelayer.ErrCacheUnavailable))
+}
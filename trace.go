@@ -0,0 +1,127 @@
+package cachelayer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+//TraceEvent kind of a cache decision
+type TraceEvent string
+
+const (
+	//TraceHit record found in redis
+	TraceHit TraceEvent = "hit"
+	//TraceNullHit cached "not found" found in redis
+	TraceNullHit TraceEvent = "null_hit"
+	//TraceStale stale copy found in redis, database is queried
+	TraceStale TraceEvent = "stale"
+	//TraceMiss key not in redis, database is queried
+	TraceMiss TraceEvent = "miss"
+	//TraceDBQuery database queried
+	TraceDBQuery TraceEvent = "db_query"
+	//TraceInvalidate cache keys deleted
+	TraceInvalidate TraceEvent = "invalidate"
+	//TraceRateLimited database load rejected by DBRateLimiter
+	TraceRateLimited TraceEvent = "rate_limited"
+	//TraceCoalesced record loaded by another process, see SetCoalesce
+	TraceCoalesced TraceEvent = "coalesced"
+)
+
+//TraceStep a cache decision of a call
+type TraceStep struct {
+	At    time.Time
+	Table string
+	Event TraceEvent
+	Keys  []string
+	Err   error
+	//Duration and Rows of the query of a TraceDBQuery step, so DB load is attributed to the missed Keys
+	Duration time.Duration
+	Rows     int
+}
+
+func (s TraceStep) String() string {
+	r := fmt.Sprintf("%s %s %s %s", s.At.Format(time.RFC3339Nano), s.Table, s.Event, strings.Join(s.Keys, ","))
+	if s.Event == TraceDBQuery {
+		r += fmt.Sprintf(" duration=%s rows=%d", s.Duration, s.Rows)
+	}
+	if s.Err != nil {
+		r += " err=" + s.Err.Error()
+	}
+	return r
+}
+
+//Trace steps recorded by caches called with a traced context, safe for concurrent use
+type Trace struct {
+	mu    sync.Mutex
+	steps []TraceStep
+}
+
+func (s *Trace) add(step TraceStep) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.steps = append(s.steps, step)
+}
+
+//Steps recorded steps in order
+func (s *Trace) Steps() []TraceStep {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]TraceStep(nil), s.steps...)
+}
+
+//String one step per line
+func (s *Trace) String() string {
+	var b strings.Builder
+	for _, v := range s.Steps() {
+		b.WriteString(v.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+type traceKey struct{}
+
+//WithTrace return a context recording cache decisions into the returned trace, eg.
+// ctx, trace := cachelayer.WithTrace(ctx); userCache.WithContext(ctx).Get(id); log.Print(trace)
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{}
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+//TraceFromContext trace of ctx, nil if ctx is not traced
+func TraceFromContext(ctx context.Context) *Trace {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+//SetDebug log every cache decision of this cache by the standard logger, besides recording it to the trace of the context
+func (s *CacheBase[T, I]) SetDebug(debug bool) {
+	s.debug = debug
+}
+
+//trace record a decision to the trace of the cache context, and log it in debug mode
+func (s *CacheBase[T, I]) trace(event TraceEvent, err error, keys ...string) {
+	s.traceStep(TraceStep{Table: s.table, Event: event, Keys: keys, Err: err})
+}
+
+//traceStep record step at now, see trace
+func (s *CacheBase[T, I]) traceStep(step TraceStep) {
+	t := TraceFromContext(s.ctx)
+	if t == nil && !s.debug {
+		return
+	}
+	step.At = s.clock.Now()
+	if t != nil {
+		t.add(step)
+	}
+	if s.debug {
+		log.Printf("cachelayer: trace %s", step)
+	}
+}
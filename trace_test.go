@@ -0,0 +1,72 @@
+package cachelayer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	assert.Nil(t, cachelayer.TraceFromContext(context.Background()))
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer red.Close()
+	fi := cachelayer.NewFaultInjector()
+	fi.SetFailInvalidations(true)
+	red.AddHook(fi)
+	cache := cachelayer.NewRedisCache[keyUser, uint]("app", "user", "ID", nil, red, time.Minute)
+
+	ctx, trace := cachelayer.WithTrace(context.Background())
+	assert.Equal(t, trace, cachelayer.TraceFromContext(ctx))
+	err := cache.WithContext(ctx).ClearKeys([]string{"app/user/id/1"}, nil)
+	assert.True(t, errors.Is(err, cachelayer.ErrInjectedFault))
+	steps := trace.Steps()
+	if assert.Len(t, steps, 1) {
+		assert.Equal(t, cachelayer.TraceInvalidate, steps[0].Event)
+		assert.Equal(t, "user", steps[0].Table)
+		assert.Equal(t, []string{"app/user/id/1"}, steps[0].Keys)
+		assert.True(t, errors.Is(steps[0].Err, cachelayer.ErrInjectedFault))
+	}
+	assert.Contains(t, trace.String(), "user invalidate app/user/id/1 err=")
+	// the shared cache is not traced
+	cache.ClearKeys([]string{"app/user/id/1"}, nil)
+	assert.Len(t, trace.Steps(), 1)
+}
+
+func TestOnDBLoad(t *testing.T) {
+	red := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer red.Close()
+	cache := cachelayer.NewRedisCache[keyUser, uint]("app", "user", "ID", &flakyDB{}, red, time.Minute)
+	cache.SetFailureMode(cachelayer.FailUnavailable, 0)
+	type spanKey struct{}
+	var loads []cachelayer.DBLoad
+	var spans []interface{}
+	cache.OnDBLoad(func(ctx context.Context, load cachelayer.DBLoad) {
+		loads = append(loads, load)
+		spans = append(spans, ctx.Value(spanKey{}))
+	})
+
+	ctx, trace := cachelayer.WithTrace(context.WithValue(context.Background(), spanKey{}, "span-1"))
+	_, err := cache.WithContext(ctx).List(1, 2, 2)
+	assert.Nil(t, err)
+	if assert.Len(t, loads, 1) {
+		assert.Equal(t, "user", loads[0].Table)
+		assert.Equal(t, []string{"app/user/id/1", "app/user/id/2"}, loads[0].Keys)
+		assert.Equal(t, 2, loads[0].Rows)
+		assert.Nil(t, loads[0].Err)
+		assert.Equal(t, []interface{}{"span-1"}, spans)
+	}
+	var query cachelayer.TraceStep
+	for _, v := range trace.Steps() {
+		if v.Event == cachelayer.TraceDBQuery {
+			query = v
+		}
+	}
+	assert.Equal(t, 2, query.Rows)
+	assert.Equal(t, loads[0].Keys, query.Keys)
+	assert.Contains(t, query.String(), "rows=2")
+}
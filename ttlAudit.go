@@ -0,0 +1,82 @@
+package cachelayer
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//TTLAudit keys without expiration found by AuditTTL
+type TTLAudit struct {
+	Table string
+	//Keys count of scanned keys
+	Keys int
+	//Persistent keys without ttl
+	Persistent []string
+	//Repaired persistent keys given the configured ttl
+	Repaired int
+}
+
+//auditTTL scan cache keys of the table for keys without ttl, writes like MSET+EXPIRE are not atomic and may leave them behind.
+// repair applies ttl to them
+func (s *CacheBase[T, I]) auditTTL(red redis.UniversalClient, ttl time.Duration, repair bool) (TTLAudit, error) {
+	report := TTLAudit{Table: s.table}
+	keys, err := scanKeys(s.ctx, red, s.tablePattern("*"))
+	if err != nil {
+		return report, err
+	}
+	report.Keys = len(keys)
+	for start := 0; start < len(keys); start += DefaultScanBatchSize {
+		end := start + DefaultScanBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+		p := red.Pipeline()
+		ttls := make([]*redis.DurationCmd, len(batch))
+		for i, key := range batch {
+			ttls[i] = p.PTTL(s.ctx, key)
+		}
+		if _, err = p.Exec(s.ctx); err != nil && err != redis.Nil {
+			return report, cacheError(err)
+		}
+		var persistent []string
+		for i, v := range ttls {
+			// -1: no expiration, -2: deleted since scan
+			if v.Val() == -1 {
+				persistent = append(persistent, batch[i])
+			}
+		}
+		report.Persistent = append(report.Persistent, persistent...)
+		if !repair || len(persistent) == 0 {
+			continue
+		}
+		p = red.Pipeline()
+		expires := make([]*redis.BoolCmd, len(persistent))
+		for i, key := range persistent {
+			expires[i] = p.Expire(s.ctx, key, ttl)
+		}
+		if _, err = p.Exec(s.ctx); err != nil && err != redis.Nil {
+			return report, cacheError(err)
+		}
+		for _, v := range expires {
+			if v.Val() {
+				report.Repaired++
+			}
+		}
+	}
+	s.stats.persistent(len(report.Persistent))
+	return report, nil
+}
+
+//AuditTTL report cache keys of the table without ttl, repair applies the configured ttl to them. Stats().PersistentKeys holds the count of the last audit
+func (s *RedisCache[T, I]) AuditTTL(repair bool) (TTLAudit, error) {
+	r, err := s.auditTTL(s.red.UniversalClient, s.red.storeTTL(), repair)
+	return r, s.wrapErr("audit_ttl", "", err)
+}
+
+//AuditTTL report cache keys of the table without ttl, see RedisCache.AuditTTL
+func (s *FullRedisCache[T, I]) AuditTTL(repair bool) (TTLAudit, error) {
+	r, err := s.auditTTL(s.red.UniversalClient, s.red.storeTTL(), repair)
+	return r, s.wrapErr("audit_ttl", "", err)
+}
@@ -0,0 +1,37 @@
+package cachelayer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditTTL(t *testing.T) {
+	mr, red := newMiniRedis(t)
+	cache := cachelayer.NewRedisCache[member, uint]("app", "member", "ID", newMemDB(member{ID: 1}, member{ID: 2}), red, time.Minute)
+	_, err := cache.List(1, 2)
+	assert.Nil(t, err)
+	// left behind by a failed SET+EXPIRE
+	assert.Nil(t, red.Persist(context.Background(), "app/member/id/2").Err())
+	mr.Set("app/member/id/3", "{}")
+
+	report, err := cache.AuditTTL(false)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"app/member/id/2", "app/member/id/3"}, report.Persistent)
+	assert.Equal(t, 0, report.Repaired)
+	assert.Equal(t, int64(2), cache.Stats().PersistentKeys)
+
+	report, err = cache.AuditTTL(true)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Repaired)
+	assert.Equal(t, time.Minute, mr.TTL("app/member/id/2"))
+
+	// a gauge of the last audit
+	report, err = cache.AuditTTL(true)
+	assert.Nil(t, err)
+	assert.Empty(t, report.Persistent)
+	assert.Equal(t, int64(0), cache.Stats().PersistentKeys)
+}
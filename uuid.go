@@ -0,0 +1,188 @@
+package cachelayer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+//UUID id type of uuid primary keys, canonical lowercase form "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx".
+// It scans from uuid/char columns and 16 byte binary columns, is stored as binary subtype 4 in mongo
+// and takes 32 chars(lowercase hex) in cache keys
+type UUID string
+
+//NewUUIDv7 time ordered uuid(RFC 9562 version 7), so inserts stay sequential in btree indexes
+func NewUUIDv7() UUID {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic(err)
+	}
+	ms := uint64(time.Now().UnixMilli())
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	return UUIDFromBytes(b)
+}
+
+func UUIDFromBytes(b [16]byte) UUID {
+	h := hex.EncodeToString(b[:])
+	return UUID(h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:])
+}
+
+//ParseUUID parse canonical or compact(32 hex chars) form of uuid, case-insensitively
+func ParseUUID(s string) (UUID, error) {
+	var b [16]byte
+	var err error
+	switch len(s) {
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return "", fmt.Errorf("invalid uuid: %s", s)
+		}
+		_, err = hex.Decode(b[:], []byte(strings.ReplaceAll(s, "-", "")))
+	case 32:
+		_, err = hex.Decode(b[:], []byte(s))
+	default:
+		return "", fmt.Errorf("invalid uuid: %s", s)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid uuid: %s", s)
+	}
+	return UUIDFromBytes(b), nil
+}
+
+//Bytes 16 bytes of uuid, zero bytes if s is not a valid uuid
+func (s UUID) Bytes() [16]byte {
+	var b [16]byte
+	if u, err := ParseUUID(string(s)); err == nil {
+		hex.Decode(b[:], []byte(strings.ReplaceAll(string(u), "-", "")))
+	}
+	return b
+}
+
+//Compact 32 chars lowercase hex form without dashes, used in cache keys. Keys are lowercased, so the form must not depend on case
+func (s UUID) Compact() string {
+	if s == "" {
+		return ""
+	}
+	b := s.Bytes()
+	return hex.EncodeToString(b[:])
+}
+
+//Time creation time of a version 7 uuid
+func (s UUID) Time() time.Time {
+	b := s.Bytes()
+	var ms [8]byte
+	copy(ms[2:], b[:6])
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(ms[:])))
+}
+
+func (s UUID) Value() (driver.Value, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return string(s), nil
+}
+
+func (s *UUID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case []byte:
+		if len(v) == 16 {
+			var b [16]byte
+			copy(b[:], v)
+			*s = UUIDFromBytes(b)
+			return nil
+		}
+		return s.Scan(string(v))
+	case string:
+		u, err := ParseUUID(v)
+		if err != nil {
+			return err
+		}
+		*s = u
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into UUID", src)
+}
+
+//MarshalBSONValue store uuid as bson binary subtype 4
+func (s UUID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if s == "" {
+		return bsontype.Null, nil, nil
+	}
+	u, err := ParseUUID(string(s))
+	if err != nil {
+		return 0, nil, err
+	}
+	b := u.Bytes()
+	return bsontype.Binary, bsoncore.AppendBinary(nil, bsontype.BinaryUUID, b[:]), nil
+}
+
+//UnmarshalBSONValue read uuid from binary(subtype 3 or 4) or string
+func (s *UUID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	switch t {
+	case bsontype.Null, bsontype.Undefined:
+		*s = ""
+		return nil
+	case bsontype.Binary:
+		_, b, _, ok := bsoncore.ReadBinary(data)
+		if !ok || len(b) != 16 {
+			return fmt.Errorf("invalid uuid binary")
+		}
+		return s.Scan(b)
+	case bsontype.String:
+		v, _, ok := bsoncore.ReadString(data)
+		if !ok {
+			return fmt.Errorf("invalid uuid string")
+		}
+		return s.Scan(v)
+	}
+	return fmt.Errorf("cannot unmarshal bson %s into UUID", t)
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var ulidMu sync.Mutex
+var lastULID [16]byte
+
+//NewULID 26 chars lexicographically sortable id(https://github.com/ulid/spec), monotonic within a millisecond
+func NewULID() string {
+	ulidMu.Lock()
+	defer ulidMu.Unlock()
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	if bytes.Equal(b[:6], lastULID[:6]) {
+		// same millisecond: increment the random part
+		b = lastULID
+		for i := 15; i >= 6; i-- {
+			b[i]++
+			if b[i] != 0 {
+				break
+			}
+		}
+	} else if _, err := rand.Read(b[6:]); err != nil {
+		panic(err)
+	}
+	lastULID = b
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	r := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		r[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(r)
+}
@@ -0,0 +1,99 @@
+package cachelayer_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type uuidDoc struct {
+	ID   cachelayer.UUID `json:"id" bson:"_id"`
+	Name string
+}
+
+func (s uuidDoc) GetID() cachelayer.UUID {
+	return s.ID
+}
+func (s uuidDoc) ListIndexes() cachelayer.Indexes {
+	return nil
+}
+
+func TestUUID(t *testing.T) {
+	u := cachelayer.NewUUIDv7()
+	assert.Len(t, string(u), 36)
+	assert.Equal(t, byte('7'), u[14])
+	assert.Len(t, u.Compact(), 32)
+	assert.Equal(t, strings.ToLower(u.Compact()), u.Compact())
+
+	for _, s := range []string{string(u), strings.ReplaceAll(string(u), "-", ""), u.Compact(), strings.ToUpper(string(u))} {
+		p, err := cachelayer.ParseUUID(s)
+		assert.Nil(t, err)
+		assert.Equal(t, u, p)
+	}
+	_, err := cachelayer.ParseUUID("not-a-uuid")
+	assert.NotNil(t, err)
+
+	var scanned cachelayer.UUID
+	b := u.Bytes()
+	assert.Nil(t, scanned.Scan(b[:]))
+	assert.Equal(t, u, scanned)
+	assert.Nil(t, scanned.Scan([]byte(u)))
+	assert.Equal(t, u, scanned)
+
+	id, err := cachelayer.ParseID[cachelayer.UUID](u.Compact())
+	assert.Nil(t, err)
+	assert.Equal(t, u, id)
+	assert.Equal(t, u.Compact(), cachelayer.KeyValue(u))
+	// the id survives the lowercased key
+	c := cachelayer.NewCacheBase[uuidDoc, cachelayer.UUID]("app", "doc", "id", context.Background())
+	key := c.MakeCacheKey(cachelayer.NewIndex("id", u))
+	id, err = cachelayer.ParseID[cachelayer.UUID](key[strings.LastIndex(key, "/")+1:])
+	assert.Nil(t, err)
+	assert.Equal(t, u, id)
+}
+
+func TestUUIDBson(t *testing.T) {
+	doc := uuidDoc{ID: cachelayer.NewUUIDv7(), Name: "a"}
+	y, err := bson.Marshal(doc)
+	assert.Nil(t, err)
+	var raw bson.Raw = y
+	subtype, _ := raw.Lookup("_id").Binary()
+	assert.Equal(t, byte(4), subtype)
+
+	var r uuidDoc
+	assert.Nil(t, bson.Unmarshal(y, &r))
+	assert.Equal(t, doc, r)
+}
+
+func TestNewULID(t *testing.T) {
+	prev := ""
+	for i := 0; i < 1000; i++ {
+		v := cachelayer.NewULID()
+		assert.Len(t, v, 26)
+		assert.Greater(t, v, prev)
+		prev = v
+	}
+}
+
+func TestFillID(t *testing.T) {
+	c := cachelayer.NewCacheBase[uuidDoc, cachelayer.UUID]("app", "doc", "id", context.Background())
+	doc := uuidDoc{}
+	filled, err := c.FillID(&doc)
+	assert.Nil(t, err)
+	assert.False(t, filled)
+
+	c.SetIDGenerator(cachelayer.NewUUIDv7)
+	filled, err = c.FillID(&doc)
+	assert.Nil(t, err)
+	assert.True(t, filled)
+	assert.False(t, c.IsNullID(doc.ID))
+
+	id := doc.ID
+	filled, _ = c.FillID(&doc)
+	assert.False(t, filled)
+	assert.Equal(t, id, doc.ID)
+}
@@ -0,0 +1,206 @@
+package cachelayer
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//VerifyReport result of comparing all cache keys of a table with database
+type VerifyReport struct {
+	Table string
+	//Keys cache keys scanned
+	Keys int
+	//Stale entries differing from database, eg. an outdated record or a cached "not found" of an existing record
+	Stale []string
+	//Orphaned entries of records deleted from database, or index entries referencing them
+	Orphaned []string
+	//Malformed entries which can not be deserialized
+	Malformed []string
+}
+
+//OK no inconsistency found
+func (s VerifyReport) OK() bool {
+	return len(s.Stale) == 0 && len(s.Orphaned) == 0 && len(s.Malformed) == 0
+}
+
+//scanKeys all keys matching pattern of every node
+func scanKeys(ctx context.Context, red redis.UniversalClient, pattern string) ([]string, error) {
+	var keys []string
+	var mu sync.Mutex
+	err := forEachNode(ctx, red, func(ctx context.Context, node redis.UniversalClient) error {
+		iter := node.Scan(ctx, 0, pattern, DefaultScanBatchSize).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			keys = append(keys, iter.Val())
+			mu.Unlock()
+		}
+		return cacheError(iter.Err())
+	})
+	return keys, err
+}
+
+//Verify scan all cache keys of the table and diff them against database, read only. Run it in maintenance windows, every cached record is loaded from database
+func (s *RedisCache[T, I]) Verify() (VerifyReport, error) {
+	report := VerifyReport{Table: s.table}
+	keys, err := scanKeys(s.ctx, s.red.UniversalClient, s.tablePattern("*"))
+	if err != nil {
+		return report, s.wrapErr("verify", "", err)
+	}
+	report.Keys = len(keys)
+	idKeyPrefix := s.tablePattern(s.GetIdField() + "/")
+	for start := 0; start < len(keys); start += DefaultScanBatchSize {
+		end := start + DefaultScanBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+		//sets(tags, refs) and hashes are nil
+		values, err := mget(s.ctx, s.red.UniversalClient, batch...)
+		if err != nil {
+			return report, s.wrapErr("verify", "", cacheError(err))
+		}
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			key, raw := batch[i], v.(string)
+			// counts are not ids
+			if isAuxKey(key) || s.isCountKey(key) {
+				continue
+			}
+			suffix := strings.TrimPrefix(key, idKeyPrefix)
+			if suffix != key && !strings.Contains(suffix, "/") {
+				var id I
+				if id, err = ParseID[I](suffix); err == nil {
+					err = s.verifyRecord(&report, key, raw, id)
+				}
+			} else {
+				err = s.verifyIds(&report, key, raw)
+			}
+			if err != nil {
+				return report, s.wrapErr("verify", key, err)
+			}
+		}
+	}
+	return report, nil
+}
+
+//verifyRecord compare cached record of an id key with database
+func (s *RedisCache[T, I]) verifyRecord(report *VerifyReport, key, raw string, id I) error {
+	isNull := s.red.isNull(raw)
+	var cached T
+	if !isNull {
+		if err := unmarshal(s.red.serializer, raw, &cached); err != nil {
+			report.Malformed = append(report.Malformed, key)
+			return nil
+		}
+	}
+	obj, exists, err := s.db.Get(id)
+	s.stats.dbLoad(err)
+	if err != nil {
+		return err
+	}
+	if !isNull && !exists {
+		report.Orphaned = append(report.Orphaned, key)
+		return nil
+	}
+	same, err := s.sameRecord(cached, isNull, obj, exists)
+	if err != nil {
+		return err
+	}
+	if !same {
+		report.Stale = append(report.Stale, key)
+	}
+	return nil
+}
+
+//verifyIds check ids cached by index and query keys exist in database
+func (s *RedisCache[T, I]) verifyIds(report *VerifyReport, key, raw string) error {
+	if s.redIds.isNull(raw) {
+		return nil
+	}
+	var ids []I
+	if err := unmarshal(s.redIds.serializer, raw, &ids); err != nil {
+		var id I
+		if err = unmarshal(s.redId.serializer, raw, &id); err != nil {
+			report.Malformed = append(report.Malformed, key)
+			return nil
+		}
+		ids = []I{id}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	objs, err := s.db.List(ids...)
+	s.stats.dbLoad(err)
+	if err != nil {
+		return err
+	}
+	found := make(map[I]bool, len(objs))
+	for _, v := range objs {
+		found[v.GetID()] = true
+	}
+	for _, v := range ids {
+		if !found[v] {
+			report.Orphaned = append(report.Orphaned, key)
+			return nil
+		}
+	}
+	return nil
+}
+
+//Verify diff the full hash against database
+func (s *FullRedisCache[T, I]) Verify() (VerifyReport, error) {
+	report := VerifyReport{Table: s.table}
+	key := s.CacheKey()
+	raw, err := s.red.HGetAll(s.ctx, key).Result()
+	if err != nil {
+		return report, s.wrapErr("verify", key, cacheError(err))
+	}
+	if len(raw) == 0 {
+		return report, nil
+	}
+	report.Keys = 1
+	objs, err := s.db.ListAll()
+	s.stats.dbLoad(err)
+	if err != nil {
+		return report, s.wrapErr("verify", key, err)
+	}
+	rows := make(map[string]T, len(objs))
+	for _, v := range objs {
+		rows[Stringify(v.GetID(), "")] = v
+	}
+	for field, v := range raw {
+		entry := key + "#" + field
+		var cached T
+		if err = unmarshal(s.red.serializer, v, &cached); err != nil {
+			report.Malformed = append(report.Malformed, entry)
+			continue
+		}
+		obj, exists := rows[field]
+		if !exists {
+			report.Orphaned = append(report.Orphaned, entry)
+			continue
+		}
+		y1, err := marshal(s.red.serializer, cached)
+		if err != nil {
+			return report, s.wrapErr("verify", key, err)
+		}
+		y2, err := marshal(s.red.serializer, obj)
+		if err != nil {
+			return report, s.wrapErr("verify", key, err)
+		}
+		if y1 != y2 {
+			report.Stale = append(report.Stale, entry)
+		}
+	}
+	for field := range rows {
+		if _, ok := raw[field]; !ok {
+			report.Stale = append(report.Stale, key+"#"+field)
+		}
+	}
+	return report, nil
+}
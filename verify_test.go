@@ -0,0 +1,43 @@
+package cachelayer_test
+
+import (
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	cache, db, mr := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com"}, member{ID: 2, Name: "ann", Email: "ann@x.com"}, member{ID: 3, Name: "bob", Email: "bob@x.com"})
+	cache.SetReverseIndex(true)
+	_, err := cache.List(1, 2, 3, 4)
+	assert.Nil(t, err)
+	report, err := cache.Verify()
+	assert.Nil(t, err)
+	assert.True(t, report.OK())
+
+	// changed and deleted behind the cache
+	_, err = db.Update(1, map[string]interface{}{"name": "jerry"})
+	assert.Nil(t, err)
+	_, err = db.Delete(2)
+	assert.Nil(t, err)
+	key := func(id uint) string { return cache.MakeCacheKey(cachelayer.NewIndex("ID", id)) }
+	mr.Set(key(3), "not json")
+	// keys kept beside entries are not records
+	mr.Set(key(1)+":fresh", "1")
+	mr.Set(key(1)+":deadline", "1700000000000")
+	report, err = cache.Verify()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{key(1)}, report.Stale)
+	assert.Equal(t, []string{key(2)}, report.Orphaned)
+	assert.Equal(t, []string{key(3)}, report.Malformed)
+}
+
+func TestVerifyDBError(t *testing.T) {
+	cache, db, _ := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com"})
+	_, _, err := cache.Get(1)
+	assert.Nil(t, err)
+	db.setDown(true)
+	_, err = cache.Verify()
+	assert.NotNil(t, err)
+}
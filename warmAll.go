@@ -0,0 +1,97 @@
+package cachelayer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//WarmProgress progress of WarmAll after a table is loaded
+type WarmProgress struct {
+	Name  string
+	Table string
+	//Done count of caches loaded so far(including failed ones), of Total
+	Done    int
+	Total   int
+	Elapsed time.Duration
+	Err     error
+}
+
+func (s WarmProgress) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("warm %d/%d %s failed after %s: %v", s.Done, s.Total, s.Name, s.Elapsed, s.Err)
+	}
+	return fmt.Sprintf("warm %d/%d %s loaded in %s", s.Done, s.Total, s.Name, s.Elapsed)
+}
+
+//OnWarmProgress call fn after each cache is loaded by WarmAll, eg. to log startup progress. Calls are serialized
+func (s *Registry) OnWarmProgress(fn func(p WarmProgress)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warmProgress = fn
+}
+
+//WarmAll load caches implementing Loader(eg. FullRedisCache) by parallelism workers, so tables are warm after deploy instead of
+// cold-loading on their first read. Every cache is tried, the first error is returned. Caches not started when ctx is done are skipped
+func (s *Registry) WarmAll(ctx context.Context, parallelism int) error {
+	type job struct {
+		name  string
+		table string
+		l     Loader
+	}
+	var jobs []job
+	for _, name := range s.Names() {
+		c, ok := s.Get(name)
+		if !ok {
+			continue
+		}
+		if l, ok := c.(Loader); ok {
+			jobs = append(jobs, job{name: name, table: c.GetTableName(), l: l})
+		}
+	}
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	s.mu.RLock()
+	progress := s.warmProgress
+	s.mu.RUnlock()
+	var mu sync.Mutex
+	var first error
+	done := 0
+	ch := make(chan job)
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go labeled(ctx, "warm_all", func(ctx context.Context) {
+			defer wg.Done()
+			for j := range ch {
+				start := time.Now()
+				err := safely("warm_all", j.table, j.l.Load)
+				mu.Lock()
+				done++
+				if err != nil && first == nil {
+					first = fmt.Errorf("cache %s: %w", j.name, err)
+				}
+				if progress != nil {
+					progress(WarmProgress{Name: j.name, Table: j.table, Done: done, Total: len(jobs), Elapsed: time.Since(start), Err: err})
+				}
+				mu.Unlock()
+			}
+		})
+	}
+feed:
+	for _, j := range jobs {
+		select {
+		case ch <- j:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(ch)
+	wg.Wait()
+	if first != nil {
+		return first
+	}
+	return ctx.Err()
+}
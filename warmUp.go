@@ -0,0 +1,48 @@
+package cachelayer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//WarmUpChannel redis pub/sub channel for warm-up requests of caches with prefix
+func WarmUpChannel(prefix string) string {
+	return strings.ToLower(prefix + "/cachelayer/warmup")
+}
+
+//RequestWarmUp ask running services to warm up table, return count of subscribers received the request
+func RequestWarmUp(ctx context.Context, red redis.UniversalClient, prefix, table string) (int64, error) {
+	n, err := red.Publish(ctx, WarmUpChannel(prefix), strings.ToLower(table)).Result()
+	return n, cacheError(err)
+}
+
+//SubscribeWarmUp call fn for every warm-up request until ctx is done, eg. fn can call FullRedisCache.Load of the requested table
+func SubscribeWarmUp(ctx context.Context, red redis.UniversalClient, prefix string, fn func(table string)) error {
+	var err error
+	labeled(ctx, "warmup_subscriber", func(ctx context.Context) {
+		err = subscribeWarmUp(ctx, red, prefix, fn)
+	})
+	return err
+}
+
+func subscribeWarmUp(ctx context.Context, red redis.UniversalClient, prefix string, fn func(table string)) error {
+	sub := red.Subscribe(ctx, WarmUpChannel(prefix))
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return cacheError(err)
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			safely("warmup_subscriber", msg.Payload, func() error { fn(msg.Payload); return nil })
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package cachelayer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type CacheEventType string
+
+const (
+	//EventTableFlush all cache keys of a table were deleted, eg. ClearAll or Refresh
+	EventTableFlush CacheEventType = "table_flush"
+	//EventMassInvalidation keys were deleted by pattern or tags
+	EventMassInvalidation CacheEventType = "mass_invalidation"
+	//EventReloadFailure full cache failed to load from database
+	EventReloadFailure CacheEventType = "reload_failure"
+	//EventCorruption a cached entry couldn't be unmarshaled and was deleted, see SetCorruptPolicy
+	EventCorruption CacheEventType = "corruption"
+)
+
+const WebhookSignatureHeader = "X-Cachelayer-Signature"
+const WebhookTimestampHeader = "X-Cachelayer-Timestamp"
+
+var errWebhookQueueFull = errors.New("cachelayer: webhook queue is full, event dropped")
+var errWebhookClosed = errors.New("cachelayer: webhook is closed, event dropped")
+
+//CacheEvent notable event of a cache, sent to webhooks
+type CacheEvent struct {
+	Type  CacheEventType `json:"type"`
+	Table string         `json:"table"`
+	//Pattern key pattern or tags of a mass invalidation, key of a corrupt entry
+	Pattern string `json:"pattern,omitempty"`
+	//Keys count of deleted keys
+	Keys  int64     `json:"keys"`
+	Error string    `json:"error,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+//Webhook POST cache events as json to url in background, retried with exponential backoff.
+// If secret is set, body is signed by hex HMAC-SHA256 of "{timestamp}.{body}" in header X-Cachelayer-Signature.
+// Events are delivered by a fixed number of workers from a bounded queue, see SetQueue. Stop it with Shutdown
+type Webhook struct {
+	url          string
+	secret       []byte
+	events       map[CacheEventType]bool
+	client       *http.Client
+	maxRetries   int
+	backoff      time.Duration
+	errorHandler func(event CacheEvent, err error)
+	clock        Clock
+	workers      int
+	queueSize    int
+	//start starts workers on first use
+	start  sync.Once
+	queue  chan CacheEvent
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+	//ctx of deliveries, canceled by Shutdown when its ctx is done
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+//NewWebhook webhook fired on events, no events means all events
+func NewWebhook(url, secret string, events ...CacheEventType) *Webhook {
+	s := &Webhook{
+		url:          url,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		maxRetries:   3,
+		backoff:      time.Second,
+		errorHandler: func(event CacheEvent, err error) { ReportError("webhook", event.Table, err) },
+		clock:        RealClock{},
+		workers:      4,
+		queueSize:    100,
+	}
+	if secret != "" {
+		s.secret = []byte(secret)
+	}
+	if len(events) > 0 {
+		s.events = make(map[CacheEventType]bool, len(events))
+		for _, v := range events {
+			s.events[v] = true
+		}
+	}
+	return s
+}
+
+//SetRetry retry failed deliveries maxRetries times, waiting backoff, 2*backoff, 4*backoff ...
+func (s *Webhook) SetRetry(maxRetries int, backoff time.Duration) {
+	s.maxRetries = maxRetries
+	s.backoff = backoff
+}
+
+func (s *Webhook) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
+//SetClock clock of retry backoff and signature timestamps, default RealClock
+func (s *Webhook) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+//SetErrorHandler handle deliveries failed after all retries, default the global error handler
+func (s *Webhook) SetErrorHandler(handler func(event CacheEvent, err error)) {
+	s.errorHandler = handler
+}
+
+//SetQueue deliver events by workers goroutines from a queue of size events, default 4 workers and 100 events.
+// Events are dropped(and passed to the error handler) when the queue is full. Call it before the first event
+func (s *Webhook) SetQueue(workers, size int) {
+	if workers <= 0 {
+		workers = 1
+	}
+	s.workers = workers
+	s.queueSize = size
+}
+
+func (s *Webhook) run() {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.queue = make(chan CacheEvent, s.queueSize)
+	s.wg.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go s.work()
+	}
+}
+
+func (s *Webhook) work() {
+	defer s.wg.Done()
+	for event := range s.queue {
+		event := event
+		labeled(s.ctx, "webhook", func(ctx context.Context) {
+			if err := safely("webhook", event.Table, func() error { return s.Send(ctx, event) }); err != nil {
+				s.errorHandler(event, err)
+			}
+		}, "event", string(event.Type))
+	}
+}
+
+//Close stop accepting events and wait for queued deliveries
+func (s *Webhook) Close() error {
+	s.start.Do(s.run)
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+//Shutdown stop accepting events and wait for queued deliveries until ctx is done, then cancel deliveries in flight
+func (s *Webhook) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+//Accepts whether the webhook is fired on events of type t
+func (s *Webhook) Accepts(t CacheEventType) bool {
+	return s.events == nil || s.events[t]
+}
+
+//Notify queue event for delivery if the webhook accepts it, without blocking
+func (s *Webhook) Notify(event CacheEvent) {
+	if !s.Accepts(event.Type) {
+		return
+	}
+	s.start.Do(s.run)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.errorHandler(event, errWebhookClosed)
+		return
+	}
+	select {
+	case s.queue <- event:
+	default:
+		s.errorHandler(event, errWebhookQueueFull)
+	}
+}
+
+//Send deliver event with retries, a 2xx response means delivered
+func (s *Webhook) Send(ctx context.Context, event CacheEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	backoff := s.backoff
+	for i := 0; ; i++ {
+		err = s.post(ctx, body)
+		if err == nil || i >= s.maxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (s *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.secret != nil {
+		ts := strconv.FormatInt(s.clock.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, ts)
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(s.secret, ts, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("cachelayer: webhook %s responded %d", s.url, resp.StatusCode)
+	}
+	return nil
+}
+
+//SignWebhook signature of a webhook body, receivers compare it with header X-Cachelayer-Signature
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//AddWebhook fire webhook on events of this cache
+func (s *CacheBase[T, I]) AddWebhook(webhook *Webhook) {
+	s.webhooks = append(s.webhooks, webhook)
+}
+
+func (s *CacheBase[T, I]) notify(event CacheEvent) {
+	if len(s.webhooks) == 0 {
+		return
+	}
+	event.Table = s.table
+	event.At = s.clock.Now()
+	for _, v := range s.webhooks {
+		v.Notify(event)
+	}
+}
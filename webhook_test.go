@@ -0,0 +1,88 @@
+package cachelayer_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts := r.Header.Get(cachelayer.WebhookTimestampHeader)
+		assert.Equal(t, cachelayer.SignWebhook([]byte("secret"), ts, body), r.Header.Get(cachelayer.WebhookSignatureHeader))
+		assert.Contains(t, string(body), `"type":"table_flush"`)
+		if atomic.AddInt32(&calls, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	w := cachelayer.NewWebhook(srv.URL, "secret", cachelayer.EventTableFlush)
+	w.SetRetry(2, time.Millisecond)
+	assert.True(t, w.Accepts(cachelayer.EventTableFlush))
+	assert.False(t, w.Accepts(cachelayer.EventReloadFailure))
+	assert.Nil(t, w.Send(context.Background(), cachelayer.CacheEvent{Type: cachelayer.EventTableFlush, Table: "user"}))
+	assert.Equal(t, int32(2), calls)
+
+	w.SetRetry(0, time.Millisecond)
+	atomic.StoreInt32(&calls, 0)
+	assert.NotNil(t, w.Send(context.Background(), cachelayer.CacheEvent{Type: cachelayer.EventTableFlush}))
+}
+
+func TestWebhookQueue(t *testing.T) {
+	received := make(chan string, 10)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+		<-release
+	}))
+	defer srv.Close()
+	w := cachelayer.NewWebhook(srv.URL, "")
+	w.SetQueue(1, 1)
+	errs := make(chan error, 10)
+	w.SetErrorHandler(func(event cachelayer.CacheEvent, err error) { errs <- err })
+	w.Notify(cachelayer.CacheEvent{Type: cachelayer.EventTableFlush, Table: "a"})
+	// the only worker is busy with the first event
+	<-received
+	w.Notify(cachelayer.CacheEvent{Type: cachelayer.EventTableFlush, Table: "b"})
+	w.Notify(cachelayer.CacheEvent{Type: cachelayer.EventTableFlush, Table: "c"})
+	assert.Contains(t, (<-errs).Error(), "queue is full")
+	close(release)
+	// queued deliveries are drained
+	assert.Nil(t, w.Shutdown(context.Background()))
+	assert.Contains(t, <-received, `"table":"b"`)
+	w.Notify(cachelayer.CacheEvent{Type: cachelayer.EventTableFlush, Table: "d"})
+	assert.Contains(t, (<-errs).Error(), "closed")
+	assert.Empty(t, received)
+}
+
+func TestWebhookShutdownCancels(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	w := cachelayer.NewWebhook(srv.URL, "")
+	errs := make(chan error, 1)
+	w.SetErrorHandler(func(event cachelayer.CacheEvent, err error) { errs <- err })
+	w.Notify(cachelayer.CacheEvent{Type: cachelayer.EventTableFlush})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Shutdown(ctx), context.DeadlineExceeded)
+	// the delivery in flight is canceled instead of retried
+	assert.ErrorIs(t, <-errs, context.Canceled)
+}
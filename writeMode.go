@@ -0,0 +1,55 @@
+package cachelayer
+
+//WriteMode how writes through the cache treat cached entries
+type WriteMode int
+
+const (
+	//WriteAround writes only invalidate cached entries, the next read loads the record from database
+	WriteAround WriteMode = iota
+	//WriteThrough writes invalidate cached entries, then cache the fresh record by its id key and declared unique index keys,
+	// so the next read is a hit
+	WriteThrough
+)
+
+//SetWriteMode choose WriteAround(default) or WriteThrough for Create/Save/Upsert/Update
+func (s *RedisCache[T, I]) SetWriteMode(mode WriteMode) {
+	s.writeMode = mode
+}
+
+//writeThrough cache fresh objs in WriteThrough mode. Index keys are only written for indexes declared by UniqueIndexed,
+// list keys are left invalidated since other records may join them
+func (s *RedisCache[T, I]) writeThrough(objs ...T) error {
+	if s.writeMode != WriteThrough {
+		return nil
+	}
+	var t T
+	_, declared := interface{}(t).(UniqueIndexed)
+	records := make(map[string]interface{}, len(objs))
+	ids := make(map[string]interface{})
+	refs := make(map[string][]I, len(objs))
+	for _, v := range objs {
+		if s.IsNullID(v.GetID()) {
+			continue
+		}
+		key := s.MakeCacheKey(NewIndex(s.GetIdField(), v.GetID()))
+		records[key] = v
+		refs[key] = []I{v.GetID()}
+		if !declared {
+			continue
+		}
+		for _, index := range v.ListIndexes() {
+			if s.IsUniqueIndex(index) {
+				key = s.MakeCacheKey(index)
+				ids[key] = v.GetID()
+				refs[key] = []I{v.GetID()}
+			}
+		}
+	}
+	if err := s.red.MSetJson(records); err != nil {
+		return err
+	}
+	if err := s.redId.MSetJson(ids); err != nil {
+		return err
+	}
+	return s.addRefs(s.red.UniversalClient, s.red.storeTTL(), refs)
+}
@@ -0,0 +1,69 @@
+package cachelayer_test
+
+import (
+	"testing"
+
+	"github.com/daqiancode/cachelayer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteThrough(t *testing.T) {
+	cache, db, _ := newMemberCache(t, member{ID: 1, Name: "tom", Email: "tom@x.com", GroupID: 1})
+	cache.SetWriteMode(cachelayer.WriteThrough)
+	objs, err := cache.ListBy(cachelayer.NewIndex("GroupID", 1), nil)
+	assert.Nil(t, err)
+	assert.Len(t, objs, 1)
+
+	obj := member{Name: "ann", Email: "ann@x.com", GroupID: 1}
+	assert.Nil(t, cache.Create(&obj))
+	queries := db.Queries()
+	// read after write hits the id key and the unique index key
+	r, exists, err := cache.Get(obj.ID)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "ann", r.Name)
+	r, _, err = cache.GetBy(cachelayer.NewIndex("Email", "ann@x.com"))
+	assert.Nil(t, err)
+	assert.Equal(t, obj.ID, r.ID)
+	assert.Equal(t, queries, db.Queries())
+	// list keys are invalidated, not written
+	objs, err = cache.ListBy(cachelayer.NewIndex("GroupID", 1), nil)
+	assert.Nil(t, err)
+	assert.Len(t, objs, 2)
+	assert.Equal(t, queries+1, db.Queries())
+
+	_, err = cache.Update(obj.ID, map[string]interface{}{"name": "anna", "email": "anna@x.com"})
+	assert.Nil(t, err)
+	queries = db.Queries()
+	r, _, err = cache.Get(obj.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "anna", r.Name)
+	r, _, err = cache.GetBy(cachelayer.NewIndex("Email", "anna@x.com"))
+	assert.Nil(t, err)
+	assert.Equal(t, obj.ID, r.ID)
+	assert.Equal(t, queries, db.Queries())
+	// the unique index key of the old value is gone
+	_, exists, err = cache.GetBy(cachelayer.NewIndex("Email", "ann@x.com"))
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	obj.Name = "annie"
+	assert.Nil(t, cache.Save(&obj))
+	queries = db.Queries()
+	r, _, err = cache.Get(obj.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "annie", r.Name)
+	assert.Equal(t, queries, db.Queries())
+}
+
+func TestWriteAround(t *testing.T) {
+	cache, db, mr := newMemberCache(t)
+	obj := member{Name: "ann", Email: "ann@x.com"}
+	assert.Nil(t, cache.Create(&obj))
+	assert.Empty(t, mr.Keys())
+	queries := db.Queries()
+	_, exists, err := cache.Get(obj.ID)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, queries+1, db.Queries())
+}